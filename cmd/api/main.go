@@ -9,24 +9,24 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	"ptop/config"
-	"ptop/internal/btcwatcher"
+	"ptop/internal/chains"
 	"ptop/internal/db"
-	"ptop/internal/ethwatcher"
 	"ptop/internal/handlers"
+	"ptop/internal/reconcile"
 	"ptop/internal/services"
 	storage "ptop/internal/services/storage"
-	"ptop/internal/solwatcher"
-	"ptop/internal/xmrwatcher"
+	"ptop/internal/watchers"
+	"ptop/internal/webauthn"
 
 	docs "ptop/docs"
 )
@@ -37,6 +37,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("config load failed: %v", err)
 	}
+	// Режим сетей выбирается до деривации адресов и создания наблюдателей
+	if err := chains.SetMode(cfg.NetworkMode); err != nil {
+		log.Fatalf("network mode: %v", err)
+	}
 
 	// 1.1 Определяем режим запуска (dev/prod)
 	env := os.Getenv("APP_ENV")
@@ -54,6 +58,9 @@ func main() {
 
 	rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPassword, DB: cfg.RedisDB})
 	chatCache := services.NewChatCache(rdb, cfg.ChatCacheLimit)
+	handlers.SetAuthLimiter(services.BuildAuthLimiter(rdb, cfg), cfg.AuthTokenMaxFailures)
+	rp := &webauthn.RelyingParty{ID: cfg.WebAuthnRPID, Name: cfg.WebAuthnRPName, Origins: cfg.WebAuthnOrigins}
+	handlers.SetAPIKeyAuth(services.NewReplayGuard(rdb), []byte(cfg.APIKeySecret), cfg.APIKeyMaxSkew)
 
 	st, err := storage.New(cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket, cfg.S3UseSSL)
 	if err != nil {
@@ -64,12 +71,12 @@ func main() {
 
 	// 3. Создаём Gin-роутер и регистрируем /health
 	r := gin.Default()
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     cfg.CORSAllowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		AllowCredentials: true,
-	}))
+	// Лимиты попыток и списки IP API ключей опираются на IP клиента, поэтому
+	// X-Forwarded-For принимается только от TRUSTED_PROXIES
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("trusted proxies: %v", err)
+	}
+	r.Use(handlers.CORS(cfg.CORSAllowedOrigins))
 	r.GET("/health", handlers.Health(gormDB))
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	r.GET("/countries", handlers.GetCountries(gormDB))
@@ -83,29 +90,51 @@ func main() {
 	auth.POST("/refresh", handlers.Refresh(gormDB, cfg.TokenTypeTTL))
 	auth.GET("/recover/:username", handlers.RecoverChallenge(gormDB))
 	auth.POST("/recover", handlers.Recover(gormDB, cfg.TokenTypeTTL))
+	auth.POST("/webauthn/login/begin", handlers.WebAuthnLoginBegin(gormDB, rp))
+	auth.POST("/webauthn/login/finish", handlers.WebAuthnLoginFinish(gormDB, rp, cfg.TokenTypeTTL))
 	auth.Use(handlers.AuthMiddleware(gormDB))
 	auth.POST("/logout", handlers.Logout(gormDB))
 	auth.GET("/profile", handlers.Profile(gormDB))
 	auth.POST("/username", handlers.ChangeUsername(gormDB))
-	auth.POST("/pincode", handlers.SetPinCode(gormDB))
-	auth.POST("/2fa/enable", handlers.Enable2FA(gormDB))
-	auth.POST("/2fa/disable", handlers.Disable2FA(gormDB))
+	auth.POST("/pincode", handlers.SetPinCode(gormDB, rp))
+	auth.POST("/2fa/enable", handlers.Enable2FA(gormDB, rp))
+	auth.POST("/2fa/disable", handlers.Disable2FA(gormDB, rp))
+	auth.POST("/2fa/backup-codes", handlers.RegenerateBackupCodes(gormDB, rp))
 	auth.POST("/verify-password", handlers.VerifyPassword(gormDB))
 	auth.POST("/mnemonic/regenerate", handlers.RegenerateMnemonic(gormDB))
 	auth.POST("/password", handlers.ChangePassword(gormDB))
+	auth.GET("/sessions", handlers.ListSessions(gormDB))
+	auth.DELETE("/sessions/:id", handlers.RevokeSession(gormDB))
+	auth.POST("/sessions/logout-others", handlers.RevokeOtherSessions(gormDB))
+	auth.POST("/webauthn/register/begin", handlers.WebAuthnRegisterBegin(gormDB, rp))
+	auth.POST("/webauthn/register/finish", handlers.WebAuthnRegisterFinish(gormDB, rp))
+	auth.GET("/webauthn/credentials", handlers.ListWebAuthnCredentials(gormDB))
+	auth.DELETE("/webauthn/credentials/:id", handlers.DeleteWebAuthnCredential(gormDB, rp))
+	auth.POST("/webauthn/verify/begin", handlers.WebAuthnVerifyBegin(gormDB, rp))
+	auth.POST("/webauthn/verify/finish", handlers.WebAuthnVerifyFinish(gormDB, rp))
+	auth.POST("/step-up", handlers.StepUp(gormDB, rp, cfg.StepUpTTL))
+	auth.GET("/step-up/policies", handlers.ListStepUpPolicies(gormDB))
+	auth.PUT("/step-up/policies/:action", handlers.SetStepUpPolicy(gormDB, rp))
+	auth.DELETE("/step-up/policies/:action", handlers.DeleteStepUpPolicy(gormDB, rp))
+	auth.GET("/step-up/events", handlers.ListStepUpEvents(gormDB))
+	auth.POST("/api-keys", handlers.RequireStepUp(gormDB, rp, handlers.StepUpActionAPIKey), handlers.CreateAPIKey(gormDB))
+	auth.GET("/api-keys", handlers.ListAPIKeys(gormDB))
+	auth.DELETE("/api-keys/:id", handlers.RevokeAPIKey(gormDB))
 
 	api := r.Group("/")
 	api.Use(handlers.AuthMiddleware(gormDB))
 	api.GET("/client/payment-methods", handlers.ListClientPaymentMethods(gormDB))
-	api.POST("/client/payment-methods", handlers.CreateClientPaymentMethod(gormDB))
-	api.PUT("/client/payment-methods/:id", handlers.UpdateClientPaymentMethod(gormDB))
-	api.DELETE("/client/payment-methods/:id", handlers.DeleteClientPaymentMethod(gormDB))
+	api.POST("/client/payment-methods", handlers.RequireStepUp(gormDB, rp, handlers.StepUpActionPaymentMethod), handlers.CreateClientPaymentMethod(gormDB))
+	api.PUT("/client/payment-methods/:id", handlers.RequireStepUp(gormDB, rp, handlers.StepUpActionPaymentMethod), handlers.UpdateClientPaymentMethod(gormDB))
+	api.DELETE("/client/payment-methods/:id", handlers.RequireStepUp(gormDB, rp, handlers.StepUpActionPaymentMethod), handlers.DeleteClientPaymentMethod(gormDB))
 	api.GET("/client/wallets", handlers.ListClientWallets(gormDB))
 	api.POST("/client/wallets", handlers.CreateWallet(gormDB))
+	api.POST("/client/wallets/:id/rotate", handlers.RotateWallet(gormDB, cfg.WalletRotationGrace))
 	api.GET("/client/assets", handlers.GetClientAssets(gormDB))
 	api.GET("/client/balances", handlers.ListClientBalances(gormDB))
 	api.GET("/client/escrows", handlers.ListClientEscrows(gormDB))
 	api.GET("/client/escrows/:id", handlers.GetClientEscrow(gormDB))
+	api.GET("/client/reserves/proof", handlers.GetReservesProof(gormDB))
 
 	api.GET("/client/offers", handlers.ListClientOffers(gormDB))
 	api.POST("/client/offers", handlers.CreateOffer(gormDB))
@@ -117,16 +146,17 @@ func main() {
 	api.GET("/client/transactions/in", handlers.ListClientTransactionsIn(gormDB))
 	api.GET("/client/transactions/out", handlers.ListClientTransactionsOut(gormDB))
 	api.GET("/client/transactions/internal", handlers.ListClientTransactionsInternal(gormDB))
+	api.GET("/client/statements", handlers.GetClientStatement(gormDB))
 
 	api.POST("/client/order", handlers.CreateOrder(gormDB))
 	api.GET("/client/orders", handlers.ListClientOrders(gormDB))
 	api.GET("/orders/:id", handlers.GetOrder(gormDB))
 	api.GET("/orders/:id/actions", handlers.GetOrderActions(gormDB))
 	api.POST("/orders/:id/paid", handlers.MarkOrderPaid(gormDB))
-	api.POST("/orders/:id/release", handlers.ReleaseOrder(gormDB))
+	api.POST("/orders/:id/release", handlers.RequireStepUp(gormDB, rp, handlers.StepUpActionRelease), handlers.ReleaseOrder(gormDB))
 	api.POST("/orders/:id/cancel", handlers.CancelOrder(gormDB))
 	api.POST("/orders/:id/dispute", handlers.OpenDispute(gormDB))
-	api.POST("/orders/:id/dispute/resolve", handlers.ResolveDispute(gormDB))
+	api.POST("/orders/:id/dispute/resolve", handlers.RequireStepUp(gormDB, rp, handlers.StepUpActionResolve), handlers.ResolveDispute(gormDB))
 	api.GET("/orders/:id/messages", handlers.ListOrderMessages(gormDB))
 	api.POST("/orders/:id/messages", handlers.CreateOrderMessage(gormDB, st, chatCache))
 	api.PATCH("/orders/:id/messages/:msgId/read", handlers.ReadOrderMessage(gormDB))
@@ -146,41 +176,25 @@ func main() {
 	exp := handlers.NewOrderExpirer(gormDB, cfg.OrderExpirerInterval)
 	exp.Start()
 
-	if cfg.WatchersDebug {
-		btcW, err := btcwatcher.New(gormDB, cfg.BtcRPCHost, cfg.BtcRPCUser, cfg.BtcRPCPass, nil, true)
-		if err != nil {
-			log.Fatalf("btc watcher: %v", err)
-		}
-		if err := btcW.Start(); err != nil {
-			log.Fatalf("btc watcher start: %v", err)
-		}
-		ethW, err := ethwatcher.New(gormDB, cfg.EthRPCURL, true)
-		if err != nil {
-			log.Fatalf("eth watcher: %v", err)
-		}
-		if err := ethW.Start(); err != nil {
-			log.Fatalf("eth watcher start: %v", err)
-		}
-		mint := os.Getenv("USDC_MINT_ADDRESS")
-		solW, err := solwatcher.New(gormDB, "", mint, true)
-		if err != nil {
-			log.Fatalf("sol watcher: %v", err)
-		}
-		if err := solW.Start(); err != nil {
-			log.Fatalf("sol watcher start: %v", err)
-		}
-		xmrW, err := xmrwatcher.New(gormDB, cfg.MoneroRPCURL, 0, true)
+	// 3.2 Запускаем удаление просроченных токенов
+	handlers.NewTokenCleaner(gormDB, cfg.TokenCleanupInterval).Start()
+
+	// 3.3 Запускаем сверку балансов с движениями средств
+	if cfg.ReconcileInterval > 0 {
+		reconcile.Build(gormDB, cfg).Start(context.Background(), cfg.ReconcileInterval)
+	}
+
+	// 3.4 Запускаем наблюдатели сетей, если они не вынесены в cmd/watcher
+	if cfg.WatchersEmbedded {
+		sup, err := watchers.Build(gormDB, cfg)
 		if err != nil {
-			log.Fatalf("xmr watcher: %v", err)
+			log.Fatalf("watchers: %v", err)
 		}
-		xmrW.Start()
-		watchers := map[string]handlers.DebugDepositor{
-			"BTC":  btcW,
-			"ETH":  ethW,
-			"XMR":  xmrW,
-			"USDC": solW,
+		sup.Start()
+		api.GET("/watchers/status", handlers.WatchersStatus(sup))
+		if cfg.WatchersDebug {
+			r.POST("/debug/deposit", handlers.DebugDeposit(gormDB, handlers.DebugDepositors(sup)))
 		}
-		r.POST("/debug/deposit", handlers.DebugDeposit(gormDB, watchers))
 	}
 
 	// 4. Запускаем сервер
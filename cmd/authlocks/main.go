@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+
+	"ptop/config"
+	"ptop/internal/db"
+	"ptop/internal/models"
+	"ptop/internal/services"
+)
+
+// Просмотр блокировок защиты от перебора. Без флагов печатает в JSON
+// действующие блокировки и последние неудачные попытки; -unlock снимает
+// блокировку ключа, например login:user:alice или token:ip:203.0.113.5.
+func main() {
+	unlock := flag.String("unlock", "", "ключ блокировки, которую нужно снять")
+	limit := flag.Int("failures", 50, "сколько последних неудачных попыток показать")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config load failed: %v", err)
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPassword, DB: cfg.RedisDB})
+	limiter := services.BuildAuthLimiter(rdb, cfg)
+	ctx := context.Background()
+
+	if *unlock != "" {
+		if err := limiter.Unlock(ctx, *unlock); err != nil {
+			log.Fatalf("unlock: %v", err)
+		}
+		log.Printf("блокировка %s снята", *unlock)
+		return
+	}
+
+	locks, err := limiter.Locks(ctx)
+	if err != nil {
+		log.Fatalf("locks: %v", err)
+	}
+	gormDB, err := db.NewDB(cfg.DSN)
+	if err != nil {
+		log.Fatalf("db connect failed: %v", err)
+	}
+	var failures []models.AuthFailure
+	if err := gormDB.Order("created_at desc").Limit(*limit).Find(&failures).Error; err != nil {
+		log.Fatalf("failures: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(map[string]any{"locks": locks, "failures": failures})
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"ptop/config"
+	"ptop/internal/chains"
 	"ptop/internal/db"
 	"ptop/internal/models"
 )
@@ -13,6 +14,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("config load failed: %v", err)
 	}
+	// Режим сетей выбирается до деривации адресов и создания наблюдателей
+	if err := chains.SetMode(cfg.NetworkMode); err != nil {
+		log.Fatalf("network mode: %v", err)
+	}
 
 	gormDB, err := db.NewDB(cfg.DSN)
 	if err != nil {
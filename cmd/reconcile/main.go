@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"ptop/config"
+	"ptop/internal/db"
+	"ptop/internal/reconcile"
+)
+
+// Ручная сверка балансов. Без флагов выполняет один проход и печатает новые
+// расхождения в JSON; -resolve закрывает расхождение после проверки
+// оператором и размораживает баланс.
+func main() {
+	resolve := flag.String("resolve", "", "ID расхождения, проверенного оператором")
+	comment := flag.String("comment", "", "комментарий к решению")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config load failed: %v", err)
+	}
+	gormDB, err := db.NewDB(cfg.DSN)
+	if err != nil {
+		log.Fatalf("db connect failed: %v", err)
+	}
+
+	if *resolve != "" {
+		if err := reconcile.Resolve(gormDB, *resolve, *comment); err != nil {
+			log.Fatalf("resolve: %v", err)
+		}
+		log.Printf("расхождение %s закрыто", *resolve)
+		return
+	}
+
+	rec, mismatches, err := reconcile.Build(gormDB, cfg).RunOnce(context.Background())
+	if err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(map[string]any{"reconciliation": rec, "mismatches": mismatches})
+}
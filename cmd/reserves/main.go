@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ptop/config"
+	"ptop/internal/chains"
+	"ptop/internal/db"
+	"ptop/internal/reserves"
+)
+
+// Отчёт о резервах. Собирает обязательства и средства в сетях, подписывает
+// снимок ключом RESERVES_SIGNING_KEY, сохраняет его для
+// GET /client/reserves/proof и печатает отчёт в JSON. С флагом -keygen
+// печатает новый ключ подписи и выходит.
+func main() {
+	keygen := flag.Bool("keygen", false, "создать ключ подписи и выйти")
+	flag.Parse()
+
+	if *keygen {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			log.Fatalf("keygen: %v", err)
+		}
+		fmt.Printf("RESERVES_SIGNING_KEY=%s\npublic key: %s\n", hex.EncodeToString(priv.Seed()), hex.EncodeToString(pub))
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config load failed: %v", err)
+	}
+	// Режим сетей задаёт узлы и контракты, по которым считаются резервы
+	if err := chains.SetMode(cfg.NetworkMode); err != nil {
+		log.Fatalf("network mode: %v", err)
+	}
+	key, err := reserves.ParseSigningKey(cfg.ReservesSigningKey)
+	if err != nil {
+		log.Fatalf("RESERVES_SIGNING_KEY: %v", err)
+	}
+
+	gormDB, err := db.NewDB(cfg.DSN)
+	if err != nil {
+		log.Fatalf("db connect failed: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cache := make(map[string]chains.Holdings)
+	holdings := func(chain string) (chains.Holdings, error) {
+		if h, ok := cache[chain]; ok {
+			return h, nil
+		}
+		h, err := chains.NewHoldings(ctx, chain, cfg)
+		if err != nil {
+			return nil, err
+		}
+		cache[chain] = h
+		return h, nil
+	}
+
+	report, err := reserves.Generate(ctx, gormDB, holdings, cfg.SweepHotWallets)
+	if err != nil {
+		log.Fatalf("reserves: %v", err)
+	}
+	report.Sign(key)
+	if err := report.Save(gormDB); err != nil {
+		log.Fatalf("save snapshot: %v", err)
+	}
+	for _, a := range report.Assets {
+		if !a.Solvent {
+			log.Printf("актив %s: резервы %s, обязательства %s, проверено %t", a.Asset, a.Reserves, a.Liabilities, a.Verified)
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(map[string]any{"snapshot": report.Snapshot, "assets": report.Assets})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ptop/config"
+	"ptop/internal/chains"
+	"ptop/internal/db"
+	"ptop/internal/sweeper"
+)
+
+// Свип депозитов на горячие кошельки. С флагом -once выполняет один проход
+// и печатает план в JSON; -dry-run только показывает, что было бы переведено.
+func main() {
+	once := flag.Bool("once", false, "выполнить один проход и выйти")
+	dryRun := flag.Bool("dry-run", false, "только показать план свипа")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config load failed: %v", err)
+	}
+	// Режим сетей задаёт префиксы адресов горячих кошельков
+	if err := chains.SetMode(cfg.NetworkMode); err != nil {
+		log.Fatalf("network mode: %v", err)
+	}
+	if *dryRun {
+		cfg.SweepDryRun = true
+	}
+
+	gormDB, err := db.NewDB(cfg.DSN)
+	if err != nil {
+		log.Fatalf("db connect failed: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	sweepers, err := sweeper.Build(ctx, gormDB, cfg)
+	if err != nil {
+		log.Fatalf("sweeper: %v", err)
+	}
+
+	if *once {
+		enc := json.NewEncoder(os.Stdout)
+		for _, s := range sweepers {
+			plan, err := s.RunOnce(ctx)
+			if err != nil {
+				log.Printf("ошибка свипа: %v", err)
+				continue
+			}
+			for _, tx := range plan {
+				_ = enc.Encode(tx)
+			}
+		}
+		return
+	}
+
+	for _, s := range sweepers {
+		s.Start(ctx, cfg.SweepInterval)
+	}
+	log.Printf("sweeper started: %d chains, dry-run %t", len(sweepers), cfg.SweepDryRun)
+	<-ctx.Done()
+}
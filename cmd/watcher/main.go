@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"ptop/config"
+	"ptop/internal/chains"
+	"ptop/internal/db"
+	"ptop/internal/handlers"
+	"ptop/internal/watchers"
+)
+
+// Отдельный процесс наблюдателей сетей. В API при этом нужно выставить
+// WATCHERS_EMBEDDED=0, чтобы депозиты не обрабатывались дважды.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config load failed: %v", err)
+	}
+	// Режим сетей выбирается до деривации адресов и создания наблюдателей
+	if err := chains.SetMode(cfg.NetworkMode); err != nil {
+		log.Fatalf("network mode: %v", err)
+	}
+
+	env := os.Getenv("APP_ENV")
+	if env == "prod" || env == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	} else {
+		gin.SetMode(gin.DebugMode)
+	}
+
+	gormDB, err := db.NewDB(cfg.DSN)
+	if err != nil {
+		log.Fatalf("db connect failed: %v", err)
+	}
+
+	sup, err := watchers.Build(gormDB, cfg)
+	if err != nil {
+		log.Fatalf("watchers: %v", err)
+	}
+	sup.Start()
+
+	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("trusted proxies: %v", err)
+	}
+	r.GET("/health", handlers.Health(gormDB))
+	// Состояние содержит ошибки RPC, поэтому доступно только с access токеном
+	r.GET("/watchers/status", handlers.AuthMiddleware(gormDB), handlers.WatchersStatus(sup))
+	if cfg.WatchersDebug {
+		r.POST("/debug/deposit", handlers.DebugDeposit(gormDB, handlers.DebugDepositors(sup)))
+	}
+
+	addr := ":" + cfg.WatcherPort
+	log.Printf("watcher status listening on %s …", addr)
+	if err := r.Run(addr); err != nil {
+		log.Fatalf("server failed: %v", err)
+	}
+}
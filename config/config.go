@@ -10,20 +10,61 @@ import (
 	"github.com/joho/godotenv"
 )
 
+// UTXONode — параметры JSON-RPC узла Bitcoin или его форка.
+type UTXONode struct {
+	Host string
+	User string
+	Pass string
+}
+
 // Config хранит все настройки приложения
 type Config struct {
     Port                     string
     DSN                      string
     TokenTypeTTL             map[string]time.Duration
+    TokenCleanupInterval     time.Duration
     MaxActiveOffersPerClient int
     WatchersDebug            bool
     CORSAllowedOrigins       []string
     OrderExpirerInterval     time.Duration
-	BtcRPCHost               string
-	BtcRPCUser               string
-	BtcRPCPass               string
-	EthRPCURL                string
+	WatchersEnabled          []string
+	WatchersEmbedded         bool
+	WatcherBackoffMin        time.Duration
+	WatcherBackoffMax        time.Duration
+	WatcherPort              string
+	NetworkMode              string
+	UTXONodes                map[string]UTXONode
+	EVMRPCURLs               map[string]string
+	SolRPCURL                string
+	TronAPIURL               string
+	TronAPIKey               string
+	TronPollInterval         time.Duration
 	MoneroRPCURL             string
+	MoneroPollInterval       time.Duration
+	WalletRotationGrace      time.Duration
+	SweepChains              []string
+	SweepInterval            time.Duration
+	SweepDryRun              bool
+	SweepHotWallets          map[string]string
+	SweepMaxInputs           int
+	SweepSignerURL           string
+	SweepSignerToken         string
+	ReservesSigningKey       string
+	ReconcileInterval        time.Duration
+	ReconcileFreeze          bool
+	ReconcileAlertWebhook    string
+	AuthMaxFailures          int
+	AuthFailureWindow        time.Duration
+	AuthLockBase             time.Duration
+	AuthLockMax              time.Duration
+	AuthTokenMaxFailures     int
+	WebAuthnRPID             string
+	WebAuthnRPName           string
+	WebAuthnOrigins          []string
+	StepUpTTL                time.Duration
+	APIKeySecret             string
+	APIKeyMaxSkew            time.Duration
+	TrustedProxies           []string
 	RedisAddr                string
 	RedisPassword            string
 	RedisDB                  int
@@ -56,6 +97,8 @@ func Load() (*Config, error) {
 
 	accessTTL := parseDuration(os.Getenv("TOKEN_TTL_ACCESS"), 15*time.Minute)
 	refreshTTL := parseDuration(os.Getenv("TOKEN_TTL_REFRESH"), 7*24*time.Hour)
+	// Интервал удаления просроченных токенов
+	tokenCleanup := parseDuration(os.Getenv("TOKEN_CLEANUP_INTERVAL"), time.Hour)
 
 	maxOffers := 3
 	if v, err := strconv.Atoi(os.Getenv("MAX_ACTIVE_OFFERS")); err == nil {
@@ -64,17 +107,67 @@ func Load() (*Config, error) {
 
 	debug := os.Getenv("WATCHERS_DEBUG") == "1"
 
+	// Список сетей, за которыми следят наблюдатели. В режиме отладки по
+	// умолчанию запускаются все сети.
+	var watchersEnabled []string
+	for _, name := range strings.Split(os.Getenv("WATCHERS_ENABLED"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			watchersEnabled = append(watchersEnabled, name)
+		}
+	}
+	if len(watchersEnabled) == 0 && debug {
+		watchersEnabled = []string{"btc", "ltc", "doge", "bch", "eth", "arbitrum", "optimism", "base", "polygon", "bsc", "sol", "tron", "xmr"}
+	}
+	// Наблюдатели запускаются внутри API, если не вынесены в cmd/watcher
+	watchersEmbedded := true
+	if v := strings.ToLower(os.Getenv("WATCHERS_EMBEDDED")); v == "0" || v == "false" {
+		watchersEmbedded = false
+	}
+	backoffMin := parseDuration(os.Getenv("WATCHERS_BACKOFF_MIN"), time.Second)
+	backoffMax := parseDuration(os.Getenv("WATCHERS_BACKOFF_MAX"), time.Minute)
+	watcherPort := os.Getenv("WATCHER_PORT")
+	if watcherPort == "" {
+		watcherPort = "8081"
+	}
+
 	corsEnv := os.Getenv("CORS_ALLOWED_ORIGINS")
 	if corsEnv == "" {
 		corsEnv = "http://localhost:5173"
 	}
 	corsOrigins := strings.Split(corsEnv, ",")
 
-	btcHost := os.Getenv("BTC_RPC_HOST")
-	btcUser := os.Getenv("BTC_RPC_USER")
-	btcPass := os.Getenv("BTC_RPC_PASS")
-	ethURL := os.Getenv("ETH_RPC_URL")
+	// Узлы Bitcoin и его форков: <СЕТЬ>_RPC_HOST, <СЕТЬ>_RPC_USER, <СЕТЬ>_RPC_PASS
+	utxoNodes := make(map[string]UTXONode)
+	for _, name := range []string{"btc", "ltc", "doge", "bch"} {
+		prefix := strings.ToUpper(name)
+		utxoNodes[name] = UTXONode{
+			Host: os.Getenv(prefix + "_RPC_HOST"),
+			User: os.Getenv(prefix + "_RPC_USER"),
+			Pass: os.Getenv(prefix + "_RPC_PASS"),
+		}
+	}
+	// Узлы EVM-сетей: <СЕТЬ>_RPC_URL, например ETH_RPC_URL или ARBITRUM_RPC_URL
+	evmURLs := make(map[string]string)
+	for _, name := range []string{"eth", "arbitrum", "optimism", "base", "polygon", "bsc"} {
+		evmURLs[name] = os.Getenv(strings.ToUpper(name) + "_RPC_URL")
+	}
+	// Режим сетей: mainnet, testnet, signet или regtest
+	networkMode := strings.ToLower(strings.TrimSpace(os.Getenv("NETWORK_MODE")))
+	if networkMode == "" {
+		networkMode = "mainnet"
+	}
+	solURL := os.Getenv("SOL_RPC_URL")
+	tronURL := os.Getenv("TRON_API_URL")
+	if tronURL == "" {
+		tronURL = "https://api.trongrid.io"
+		if networkMode != "mainnet" {
+			tronURL = "https://nile.trongrid.io"
+		}
+	}
+	tronKey := os.Getenv("TRON_API_KEY")
+	tronPoll := parseDuration(os.Getenv("TRON_POLL_INTERVAL"), 10*time.Second)
 	moneroURL := os.Getenv("MONERO_RPC_URL")
+	moneroPoll := parseDuration(os.Getenv("MONERO_POLL_INTERVAL"), time.Minute)
 
 	s3Endpoint := os.Getenv("S3_ENDPOINT")
 	s3Access := os.Getenv("S3_ACCESS_KEY")
@@ -98,6 +191,83 @@ func Load() (*Config, error) {
         chatLimit = v
     }
 
+	// Свип депозитов на горячий кошелёк: сети, расписание, адреса
+	// <СЕТЬ>_HOT_WALLET и сервис подписи. По умолчанию включён dry-run.
+	var sweepChains []string
+	for _, name := range strings.Split(os.Getenv("SWEEP_CHAINS"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			sweepChains = append(sweepChains, name)
+		}
+	}
+	sweepInterval := parseDuration(os.Getenv("SWEEP_INTERVAL"), time.Hour)
+	sweepDryRun := true
+	if v := strings.ToLower(os.Getenv("SWEEP_DRY_RUN")); v == "0" || v == "false" {
+		sweepDryRun = false
+	}
+	hotWallets := make(map[string]string)
+	for _, name := range []string{"btc", "ltc", "doge", "bch", "eth", "arbitrum", "optimism", "base", "polygon", "bsc"} {
+		if addr := os.Getenv(strings.ToUpper(name) + "_HOT_WALLET"); addr != "" {
+			hotWallets[name] = addr
+		}
+	}
+	sweepMaxInputs := 100
+	if v, err := strconv.Atoi(os.Getenv("SWEEP_MAX_INPUTS")); err == nil && v > 0 {
+		sweepMaxInputs = v
+	}
+
+	// Сверка балансов: интервал (0 отключает), заморозка балансов с
+	// расхождением и вебхук оповещения
+	reconcileInterval := parseDuration(os.Getenv("RECONCILE_INTERVAL"), time.Hour)
+	reconcileFreeze := false
+	if v := strings.ToLower(os.Getenv("RECONCILE_FREEZE")); v == "1" || v == "true" {
+		reconcileFreeze = true
+	}
+
+	// Защита от перебора: неудачных попыток в окне до блокировки, первая
+	// и наибольшая длительность блокировки, порог неверных токенов с IP
+	authMaxFailures := 5
+	if v, err := strconv.Atoi(os.Getenv("AUTH_MAX_FAILURES")); err == nil && v > 0 {
+		authMaxFailures = v
+	}
+	authTokenMaxFailures := 50
+	if v, err := strconv.Atoi(os.Getenv("AUTH_TOKEN_MAX_FAILURES")); err == nil && v > 0 {
+		authTokenMaxFailures = v
+	}
+	authFailureWindow := parseDuration(os.Getenv("AUTH_FAILURE_WINDOW"), 15*time.Minute)
+	authLockBase := parseDuration(os.Getenv("AUTH_LOCK_BASE"), time.Minute)
+	authLockMax := parseDuration(os.Getenv("AUTH_LOCK_MAX"), 24*time.Hour)
+
+	// WebAuthn: RP ID — домен фронтенда, origins по умолчанию совпадают с CORS
+	webauthnRPID := os.Getenv("WEBAUTHN_RP_ID")
+	if webauthnRPID == "" {
+		webauthnRPID = "localhost"
+	}
+	webauthnRPName := os.Getenv("WEBAUTHN_RP_NAME")
+	if webauthnRPName == "" {
+		webauthnRPName = "ptop"
+	}
+	webauthnOrigins := corsOrigins
+	if v := os.Getenv("WEBAUTHN_ORIGINS"); v != "" {
+		webauthnOrigins = strings.Split(v, ",")
+	}
+	stepUpTTL := parseDuration(os.Getenv("STEP_UP_TTL"), 5*time.Minute)
+
+	// Секреты API ключей выводятся из API_KEY_SECRET; без него ключи отключены
+	apiKeySecret := os.Getenv("API_KEY_SECRET")
+	apiKeyMaxSkew := parseDuration(os.Getenv("API_KEY_MAX_SKEW"), 30*time.Second)
+
+	// Прокси, которым доверяется X-Forwarded-For; без списка IP клиента —
+	// адрес TCP-соединения
+	var trustedProxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			trustedProxies = append(trustedProxies, p)
+		}
+	}
+
+	// Сколько после ротации зачисляются депозиты на старый адрес кошелька
+	rotationGrace := parseDuration(os.Getenv("WALLET_ROTATION_GRACE"), 30*24*time.Hour)
+
     // Интервал фоновой задачи авто-отмены ордеров
     expirerInterval := parseDuration(os.Getenv("ORDER_EXPIRER_INTERVAL"), 30*time.Second)
 
@@ -108,14 +278,48 @@ func Load() (*Config, error) {
 			"access":  accessTTL,
 			"refresh": refreshTTL,
 		},
+		TokenCleanupInterval:     tokenCleanup,
 		MaxActiveOffersPerClient: maxOffers,
 		WatchersDebug:            debug,
+		WatchersEnabled:          watchersEnabled,
+		WatchersEmbedded:         watchersEmbedded,
+		WatcherBackoffMin:        backoffMin,
+		WatcherBackoffMax:        backoffMax,
+		WatcherPort:              watcherPort,
+		NetworkMode:              networkMode,
 		CORSAllowedOrigins:       corsOrigins,
-		BtcRPCHost:               btcHost,
-		BtcRPCUser:               btcUser,
-		BtcRPCPass:               btcPass,
-		EthRPCURL:                ethURL,
+		UTXONodes:                utxoNodes,
+		EVMRPCURLs:               evmURLs,
+		SolRPCURL:                solURL,
+		TronAPIURL:               tronURL,
+		TronAPIKey:               tronKey,
+		TronPollInterval:         tronPoll,
 		MoneroRPCURL:             moneroURL,
+		MoneroPollInterval:       moneroPoll,
+		WalletRotationGrace:      rotationGrace,
+		SweepChains:              sweepChains,
+		SweepInterval:            sweepInterval,
+		SweepDryRun:              sweepDryRun,
+		SweepHotWallets:          hotWallets,
+		SweepMaxInputs:           sweepMaxInputs,
+		SweepSignerURL:           os.Getenv("SWEEP_SIGNER_URL"),
+		SweepSignerToken:         os.Getenv("SWEEP_SIGNER_TOKEN"),
+		ReservesSigningKey:       os.Getenv("RESERVES_SIGNING_KEY"),
+		ReconcileInterval:        reconcileInterval,
+		ReconcileFreeze:          reconcileFreeze,
+		ReconcileAlertWebhook:    os.Getenv("RECONCILE_ALERT_WEBHOOK"),
+		AuthMaxFailures:          authMaxFailures,
+		AuthFailureWindow:        authFailureWindow,
+		AuthLockBase:             authLockBase,
+		AuthLockMax:              authLockMax,
+		AuthTokenMaxFailures:     authTokenMaxFailures,
+		WebAuthnRPID:             webauthnRPID,
+		WebAuthnRPName:           webauthnRPName,
+		WebAuthnOrigins:          webauthnOrigins,
+		StepUpTTL:                stepUpTTL,
+		APIKeySecret:             apiKeySecret,
+		APIKeyMaxSkew:            apiKeyMaxSkew,
+		TrustedProxies:           trustedProxies,
 		RedisAddr:                redisAddr,
 		RedisPassword:            redisPass,
 		RedisDB:                  redisDB,
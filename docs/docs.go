@@ -17,6 +17,7 @@ const docTemplate = `{
     "paths": {
         "/assets": {
             "get": {
+                "description": "Для криптоактивов возвращает параметры сетей: контракт, точность, минимальные суммы и порог подтверждений.",
                 "produces": [
                     "application/json"
                 ],
@@ -37,6 +38,69 @@ const docTemplate = `{
                 }
             }
         },
+        "/auth/2fa/backup-codes": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Заменяет резервные коды новыми; прежние, в том числе неиспользованные, перестают действовать. Нужны пароль и текущий код TOTP: резервный код не принимается, иначе один украденный код давал бы весь новый набор. Кроме того, нужно повышение прав для factors.change.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Новые резервные коды 2FA",
+                "parameters": [
+                    {
+                        "description": "пароль и код TOTP",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RegenerateBackupCodesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BackupCodesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/auth/2fa/disable": {
             "post": {
                 "security": [
@@ -44,6 +108,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
+                "description": "Нужны пароль и повышение прав для factors.change. Пока TOTP входит в политику повышения прав какого-либо действия, отключение отклоняется с 409.",
                 "consumes": [
                     "application/json"
                 ],
@@ -83,6 +148,24 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
                     }
                 }
             }
@@ -94,6 +177,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
+                "description": "Возвращает секрет TOTP и одноразовые резервные коды для входа без аутентификатора. Коды показываются один раз. Если у клиента уже есть факторы, нужно повышение прав для factors.change.",
                 "consumes": [
                     "application/json"
                 ],
@@ -133,13 +217,166 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Действующие и просроченные ключи без секретов; отозванные не показываются.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "API ключи клиента",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.APIKeyResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Создаёт ключ для ботов с областями read, offers, orders, withdrawals. Секрет возвращается один раз. Требует повышения прав (действие api_key.create).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Новый API ключ",
+                "parameters": [
+                    {
+                        "description": "параметры ключа",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateAPIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/api-keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Ключ перестаёт приниматься сразу после отзыва.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Отзыв API ключа",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID ключа",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
                     }
                 }
             }
         },
         "/auth/login": {
             "post": {
-                "description": "Аутентифицирует клиента и выдаёт пару токенов. При включённой 2FA требуется код.",
+                "description": "Аутентифицирует клиента и выдаёт пару токенов. При включённой 2FA требуется код TOTP или одноразовый резервный код.",
                 "consumes": [
                     "application/json"
                 ],
@@ -179,6 +416,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
                     }
                 }
             }
@@ -190,6 +433,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
+                "description": "Завершает текущую сессию; остальные сессии клиента продолжают действовать.",
                 "produces": [
                     "application/json"
                 ],
@@ -259,6 +503,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
                     }
                 }
             }
@@ -309,6 +559,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
                     }
                 }
             }
@@ -320,6 +576,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
+                "description": "Первый фактор устанавливается по паролю; если у клиента уже есть PIN-код, 2FA или passkey, нужно ещё повышение прав для factors.change (заголовки X-Step-Up-*).",
                 "consumes": [
                     "application/json"
                 ],
@@ -359,6 +616,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
                     }
                 }
             }
@@ -440,6 +709,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
                     }
                 }
             }
@@ -480,6 +755,7 @@ const docTemplate = `{
         },
         "/auth/refresh": {
             "post": {
+                "description": "Обменивает refresh токен на новую пару в той же сессии. Refresh токен одноразовый: повторное предъявление завершает сессию. Токен принимается только с тем же User-Agent, с которым был выдан.",
                 "consumes": [
                     "application/json"
                 ],
@@ -569,45 +845,29 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/username": {
-            "post": {
+        "/auth/sessions": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Устройства, с которых выполнен вход: IP, время последней активности и входа. Текущая сессия отмечена current.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "auth"
                 ],
-                "summary": "Смена имени пользователя",
-                "parameters": [
-                    {
-                        "description": "новое имя",
-                        "name": "input",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.ChangeUsernameRequest"
-                        }
-                    }
-                ],
+                "summary": "Активные сессии",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.StatusResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/handlers.ErrorResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.SessionResponse"
+                            }
                         }
                     },
                     "401": {
@@ -615,24 +875,583 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
-                    },
-                    "409": {
-                        "description": "Conflict",
-                        "schema": {
-                            "$ref": "#/definitions/handlers.ErrorResponse"
-                        }
                     }
                 }
             }
         },
-        "/auth/verify-password": {
+        "/auth/sessions/logout-others": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "consumes": [
+                "description": "Завершает все сессии клиента, кроме текущей.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Выход на других устройствах",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/sessions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Отзывает все токены сессии. Завершение текущей сессии равносильно выходу.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Завершение сессии",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID сессии",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/step-up": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Проверяет переданные факторы (PIN-код, код TOTP или резервный код, passkey) и выдаёт короткоживущий токен для заголовка X-Step-Up-Token. Токен действует только в текущей сессии и подтверждает проверенные факторы.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Токен повышенных прав",
+                "parameters": [
+                    {
+                        "description": "факторы",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/step-up/events": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Выдача токенов повышенных прав и подтверждения действий клиента, успешные и нет, от новых к старым.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Журнал повышения прав",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.StepUpEvent"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/step-up/policies": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Для каждого действия — факторы и признак all: все обязательны или достаточно одного.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Политики повышения прав",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.StepUpPolicyResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/step-up/policies/{action}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Требует для действия все перечисленные факторы. Факторы должны быть настроены и разрешены действием. Изменение подтверждается по текущей политике действия (заголовки X-Step-Up-*).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Политика повышения прав для действия",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "действие",
+                        "name": "action",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "факторы",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpPolicyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает правило по умолчанию: достаточно любого разрешённого фактора. Подтверждается по текущей политике действия.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Сброс политики повышения прав",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "действие",
+                        "name": "action",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/username": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Смена имени пользователя",
+                "parameters": [
+                    {
+                        "description": "новое имя",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ChangeUsernameRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/verify-password": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Проверка текущего пароля",
+                "parameters": [
+                    {
+                        "description": "пароль",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.VerifyPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.VerifyPasswordResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/credentials": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Passkeys клиента",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebAuthnCredential"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/credentials/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Нужны пароль и повышение прав для factors.change (заголовки X-Step-Up-*). Последний ключ нельзя удалить, пока passkey входит в политику повышения прав (409).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Удаление passkey",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID ключа",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "пароль",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeleteWebAuthnCredentialRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/login/begin": {
+            "post": {
+                "description": "Возвращает параметры для navigator.credentials.get. С именем пользователя браузеру передаются его ключи; без имени — пустой список, и браузер предложит любой passkey сайта.",
+                "consumes": [
                     "application/json"
                 ],
                 "produces": [
@@ -641,15 +1460,241 @@ const docTemplate = `{
                 "tags": [
                     "auth"
                 ],
-                "summary": "Проверка текущего пароля",
+                "summary": "Начало входа по passkey",
                 "parameters": [
                     {
-                        "description": "пароль",
+                        "description": "имя пользователя",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebAuthnLoginBeginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebAuthnAssertBeginResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/login/finish": {
+            "post": {
+                "description": "Проверяет подпись ключа и выдаёт токены. Ключ должен подтвердить пользователя (биометрия или PIN устройства), поэтому второй фактор не запрашивается.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Вход по passkey",
+                "parameters": [
+                    {
+                        "description": "ответ navigator.credentials.get",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.VerifyPasswordRequest"
+                            "$ref": "#/definitions/handlers.WebAuthnFinishRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/register/begin": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает параметры для navigator.credentials.create. Вызов действует 5 минут.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Начало регистрации passkey",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebAuthnRegisterBeginResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/register/finish": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Проверяет ответ аутентификатора и сохраняет ключ. Принимаются аттестации none и packed. Нужен пароль; если у клиента уже есть факторы, ещё и повышение прав для factors.change (заголовки X-Step-Up-*).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Завершение регистрации passkey",
+                "parameters": [
+                    {
+                        "description": "ответ navigator.credentials.create и пароль",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebAuthnRegisterFinishRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebAuthnCredential"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/verify/begin": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Вызов для проверки passkey как второго фактора перед чувствительным действием.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Начало подтверждения действия passkey",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebAuthnAssertBeginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/webauthn/verify/finish": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Подтверждение действия passkey",
+                "parameters": [
+                    {
+                        "description": "ответ navigator.credentials.get",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebAuthnFinishRequest"
                         }
                     }
                 ],
@@ -671,6 +1716,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
                     }
                 }
             }
@@ -682,6 +1733,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
+                "description": "Адрес кошелька возвращается для основной сети актива; адреса других сетей — в GET /client/wallets.",
                 "produces": [
                     "application/json"
                 ],
@@ -874,6 +1926,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "403": {
+                        "description": "баланс заморожен сверкой",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -926,6 +1984,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "баланс заморожен сверкой",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -1032,8 +2096,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.Offer"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "баланс заморожен сверкой",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -1138,6 +2208,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
+                    },
+                    "403": {
+                        "description": "баланс участника заморожен сверкой",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "слишком много неверных PIN-кодов",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LockedResponse"
+                        }
                     }
                 }
             }
@@ -1174,6 +2256,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
+                "description": "Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1208,6 +2291,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "нужно повышение прав",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
                     "409": {
                         "description": "Conflict",
                         "schema": {
@@ -1224,6 +2313,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
+                "description": "Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1265,6 +2355,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "нужно повышение прав",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -1279,6 +2375,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
+                "description": "Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.",
                 "tags": [
                     "client-payment-methods"
                 ],
@@ -1299,6 +2396,43 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.StatusResponse"
                         }
                     },
+                    "403": {
+                        "description": "нужно повышение прав",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StepUpRequiredResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/client/reserves/proof": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает последний подписанный снимок, лист клиента (соль и балансы) и путь до корня дерева Меркла с суммами. Клиент пересчитывает хеш листа sha256(0x00 || sha256(clientID \":\" salt) || балансы) и поднимается по пути до root; суммы корня должны совпасть с liabilities снимка.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reserves"
+                ],
+                "summary": "Доказательство включения балансов в отчёт о резервах",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/reserves.Proof"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -1308,6 +2442,68 @@ const docTemplate = `{
                 }
             }
         },
+        "/client/statements": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Депозиты, выводы, внутренние переводы, эскроу и сделки за период в хронологическом порядке с остатком после каждой строки. Остатки на начало и конец периода идут строками opening_balance и closing_balance. Сделки показываются для справки и остаток не меняют. По умолчанию — последние 30 дней в CSV.",
+                "produces": [
+                    "text/csv",
+                    "application/pdf"
+                ],
+                "tags": [
+                    "transactions"
+                ],
+                "summary": "Выписка по счёту",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "начало периода: RFC 3339 или YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "конец периода: RFC 3339 или YYYY-MM-DD (дата включается)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID или название актива",
+                        "name": "asset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "pdf"
+                        ],
+                        "type": "string",
+                        "description": "csv или pdf",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/client/transactions/in": {
             "get": {
                 "security": [
@@ -1438,6 +2634,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
+                "description": "Возвращает активные кошельки и адреса, заменённые при ротации (isEnabled=false). У заменённых указаны disabledAt и expiresAt, у нового адреса — previousID; вместе с index это история деривации адресов.",
                 "produces": [
                     "application/json"
                 ],
@@ -1463,7 +2660,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "При DEBUG_FAKE_NETWORK=true адрес генерируется без обращения к сети в формате fake:{asset}:{client}:{index}",
+                "description": "При DEBUG_FAKE_NETWORK=true адрес генерируется без обращения к сети в формате fake:{asset}:{client}:{index}\nПараметр network выбирает сеть депозита из сетей актива (например, eth или sol для USDT); по умолчанию используется основная сеть актива. Кошельки всех сетей пополняют один баланс.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1501,6 +2698,52 @@ const docTemplate = `{
                 }
             }
         },
+        "/client/wallets/{id}/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Выделяет новый индекс деривации и создаёт активный кошелёк с новым адресом. Старый кошелёк отключается; депозиты на его адрес зачисляются до expiresAt (льготный период WALLET_ROTATION_GRACE), после — отклоняются.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "wallets"
+                ],
+                "summary": "Выдать новый адрес кошелька",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Wallet"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/countries": {
             "get": {
                 "security": [
@@ -2013,7 +3256,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "DISPUTE -\u003e RELEASED/CANCELLED. Только арбитраж. Шлёт уведомления и WS.",
+                "description": "DISPUTE -\u003e RELEASED/CANCELLED. Только арбитраж. Шлёт уведомления и WS. Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.",
                 "consumes": [
                     "application/json"
                 ],
@@ -2056,7 +3299,7 @@ const docTemplate = `{
                         }
                     },
                     "403": {
-                        "description": "Forbidden",
+                        "description": "нет доступа, баланс заморожен сверкой или нужно повышение прав (StepUpRequiredResponse)",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -2332,7 +3575,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "PAID -\u003e RELEASED. Только продавец (offerOwner). Устанавливает releasedAt, шлёт уведомления и WS.",
+                "description": "PAID -\u003e RELEASED. Только продавец (offerOwner). Устанавливает releasedAt, шлёт уведомления и WS. Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.",
                 "produces": [
                     "application/json"
                 ],
@@ -2363,7 +3606,7 @@ const docTemplate = `{
                         }
                     },
                     "403": {
-                        "description": "Forbidden",
+                        "description": "нет доступа, баланс заморожен сверкой или нужно повышение прав (StepUpRequiredResponse)",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -2404,6 +3647,40 @@ const docTemplate = `{
                 }
             }
         },
+        "/watchers/status": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Для каждой сети возвращает отставание от вершины, время последнего блока и число перезапусков",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Состояние наблюдателей сетей",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/watchers.ChainStatus"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/ws/notifications": {
             "get": {
                 "description": "Подключает клиента к потоку уведомлений. После подключения сервер отправляет непрочитанные уведомления.",
@@ -2598,15 +3875,57 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "handlers.APIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "allowed_ips": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key_id": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "handlers.AssetWithWallet": {
             "type": "object",
             "properties": {
+                "addressType": {
+                    "description": "AddressType задаёт тип адресов депозита для сетей Bitcoin и его форков.",
+                    "type": "string"
+                },
                 "amount": {
                     "type": "number"
                 },
                 "amountEscrow": {
                     "type": "number"
                 },
+                "chain": {
+                    "type": "string"
+                },
                 "description": {
                     "type": "string"
                 },
@@ -2622,6 +3941,12 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
+                "networks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AssetNetwork"
+                    }
+                },
                 "type": {
                     "type": "string"
                 },
@@ -2630,6 +3955,17 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.BackupCodesResponse": {
+            "type": "object",
+            "properties": {
+                "backup_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "handlers.CancelOrderRequest": {
             "type": "object",
             "properties": {
@@ -2638,27 +3974,91 @@ const docTemplate = `{
                 }
             }
         },
-        "handlers.ChangePasswordRequest": {
+        "handlers.ChangePasswordRequest": {
+            "type": "object",
+            "properties": {
+                "confirm_password": {
+                    "type": "string"
+                },
+                "new_password": {
+                    "type": "string"
+                },
+                "old_password": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ChangeUsernameRequest": {
+            "type": "object",
+            "properties": {
+                "new_username": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.CreateAPIKeyRequest": {
+            "type": "object",
+            "required": [
+                "scopes"
+            ],
+            "properties": {
+                "allowed_ips": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.CreateAPIKeyResponse": {
             "type": "object",
             "properties": {
-                "confirm_password": {
+                "allowed_ips": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "new_password": {
+                "expires_at": {
                     "type": "string"
                 },
-                "old_password": {
+                "id": {
                     "type": "string"
-                }
-            }
-        },
-        "handlers.ChangeUsernameRequest": {
-            "type": "object",
-            "properties": {
-                "new_username": {
+                },
+                "key_id": {
                     "type": "string"
                 },
-                "password": {
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "secret": {
                     "type": "string"
                 }
             }
@@ -2701,6 +4101,17 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.DeleteWebAuthnCredentialRequest": {
+            "type": "object",
+            "required": [
+                "password"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
         "handlers.Disable2FARequest": {
             "type": "object",
             "properties": {
@@ -2728,6 +4139,12 @@ const docTemplate = `{
         "handlers.Enable2FAResponse": {
             "type": "object",
             "properties": {
+                "backup_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "secret": {
                     "type": "string"
                 },
@@ -2787,6 +4204,17 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.LockedResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "retry_after": {
+                    "type": "integer"
+                }
+            }
+        },
         "handlers.LoginRequest": {
             "type": "object",
             "properties": {
@@ -2949,6 +4377,9 @@ const docTemplate = `{
         "handlers.ProfileResponse": {
             "type": "object",
             "properties": {
+                "backup_codes_left": {
+                    "type": "integer"
+                },
                 "pincode_set": {
                     "type": "boolean"
                 },
@@ -3018,6 +4449,17 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.RegenerateBackupCodesRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
         "handlers.RegenerateMnemonicRequest": {
             "type": "object",
             "properties": {
@@ -3079,6 +4521,35 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.SessionResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "current": {
+                    "type": "boolean"
+                },
+                "device": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "lastSeenAt": {
+                    "type": "string"
+                },
+                "userAgent": {
+                    "type": "string"
+                }
+            }
+        },
         "handlers.SetPinCodeRequest": {
             "type": "object",
             "properties": {
@@ -3098,6 +4569,88 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.StepUpPolicyRequest": {
+            "type": "object",
+            "properties": {
+                "factors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.StepUpPolicyResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "all": {
+                    "type": "boolean"
+                },
+                "custom": {
+                    "type": "boolean"
+                },
+                "factors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.StepUpRequest": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "passkey": {
+                    "$ref": "#/definitions/handlers.WebAuthnFinishRequest"
+                },
+                "pincode": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.StepUpRequiredResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "all": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "factors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.StepUpResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "factors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "step_up_token": {
+                    "type": "string"
+                }
+            }
+        },
         "handlers.TokenResponse": {
             "type": "object",
             "properties": {
@@ -3130,29 +4683,190 @@ const docTemplate = `{
             "properties": {
                 "asset_id": {
                     "type": "string"
+                },
+                "network": {
+                    "description": "Network — сеть депозита, по умолчанию основная сеть актива.",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.WebAuthnAssertBeginResponse": {
+            "type": "object",
+            "properties": {
+                "challengeId": {
+                    "type": "string"
+                },
+                "publicKey": {
+                    "$ref": "#/definitions/webauthn.RequestOptions"
+                }
+            }
+        },
+        "handlers.WebAuthnAuthenticatorResponse": {
+            "type": "object",
+            "properties": {
+                "attestationObject": {
+                    "type": "string"
+                },
+                "authenticatorData": {
+                    "type": "string"
+                },
+                "clientDataJSON": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                },
+                "userHandle": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.WebAuthnCredentialJSON": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "rawId": {
+                    "type": "string"
+                },
+                "response": {
+                    "$ref": "#/definitions/handlers.WebAuthnAuthenticatorResponse"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.WebAuthnFinishRequest": {
+            "type": "object",
+            "required": [
+                "challengeId"
+            ],
+            "properties": {
+                "challengeId": {
+                    "type": "string"
+                },
+                "credential": {
+                    "$ref": "#/definitions/handlers.WebAuthnCredentialJSON"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.WebAuthnLoginBeginRequest": {
+            "type": "object",
+            "properties": {
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.WebAuthnRegisterBeginResponse": {
+            "type": "object",
+            "properties": {
+                "challengeId": {
+                    "type": "string"
+                },
+                "publicKey": {
+                    "$ref": "#/definitions/webauthn.CreationOptions"
+                }
+            }
+        },
+        "handlers.WebAuthnRegisterFinishRequest": {
+            "type": "object",
+            "required": [
+                "challengeId",
+                "password"
+            ],
+            "properties": {
+                "challengeId": {
+                    "type": "string"
+                },
+                "credential": {
+                    "$ref": "#/definitions/handlers.WebAuthnCredentialJSON"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Asset": {
+            "type": "object",
+            "properties": {
+                "addressType": {
+                    "description": "AddressType задаёт тип адресов депозита для сетей Bitcoin и его форков.",
+                    "type": "string"
+                },
+                "chain": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "isActive": {
+                    "type": "boolean"
+                },
+                "isConvertible": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "networks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AssetNetwork"
+                    }
+                },
+                "type": {
+                    "type": "string"
                 }
             }
         },
-        "models.Asset": {
+        "models.AssetNetwork": {
             "type": "object",
             "properties": {
-                "description": {
+                "assetID": {
                     "type": "string"
                 },
-                "id": {
+                "chain": {
                     "type": "string"
                 },
-                "isActive": {
-                    "type": "boolean"
+                "confirmations": {
+                    "type": "integer"
                 },
-                "isConvertible": {
+                "contract": {
+                    "type": "string"
+                },
+                "decimals": {
+                    "type": "integer"
+                },
+                "depositEnabled": {
                     "type": "boolean"
                 },
-                "name": {
+                "id": {
                     "type": "string"
                 },
-                "type": {
-                    "type": "string"
+                "minDeposit": {
+                    "type": "number"
+                },
+                "minWithdrawal": {
+                    "type": "number"
+                },
+                "sweepThreshold": {
+                    "description": "SweepThreshold — сумма на адресе депозита, начиная с которой средства\nпереводятся на горячий кошелёк. Ноль отключает свип.",
+                    "type": "number"
+                },
+                "withdrawalEnabled": {
+                    "type": "boolean"
                 }
             }
         },
@@ -3174,6 +4888,10 @@ const docTemplate = `{
                 "createdAt": {
                     "type": "string"
                 },
+                "frozenAt": {
+                    "description": "FrozenAt — баланс заморожен сверкой до решения оператора: ордера и\nобъявления по активу не создаются, средства по ордерам не выпускаются.",
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
@@ -3747,6 +5465,70 @@ const docTemplate = `{
                 }
             }
         },
+        "models.ReserveSnapshot": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "leafCount": {
+                    "type": "integer"
+                },
+                "liabilities": {
+                    "type": "object"
+                },
+                "publicKey": {
+                    "type": "string"
+                },
+                "reserves": {
+                    "type": "object"
+                },
+                "root": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.StepUpEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "factors": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "sessionId": {
+                    "type": "string"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "userAgent": {
+                    "type": "string"
+                }
+            }
+        },
         "models.TransactionIn": {
             "type": "object",
             "properties": {
@@ -3759,6 +5541,9 @@ const docTemplate = `{
                 "assetName": {
                     "type": "string"
                 },
+                "blockHeight": {
+                    "type": "integer"
+                },
                 "clientID": {
                     "type": "string"
                 },
@@ -3771,6 +5556,9 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "network": {
+                    "type": "string"
+                },
                 "status": {
                     "$ref": "#/definitions/models.TransactionInStatus"
                 },
@@ -3875,6 +5663,9 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "network": {
+                    "type": "string"
+                },
                 "status": {
                     "$ref": "#/definitions/models.TransactionOutStatus"
                 },
@@ -3915,19 +5706,248 @@ const docTemplate = `{
                 "createdAt": {
                     "type": "string"
                 },
+                "disabledAt": {
+                    "type": "string"
+                },
                 "enabledAt": {
                     "type": "string"
                 },
+                "expiresAt": {
+                    "description": "ExpiresAt — конец льготного периода после ротации: до него депозиты на\nстарый адрес зачисляются, после — отклоняются.",
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
                 "index": {
                     "type": "integer"
                 },
+                "isEnabled": {
+                    "type": "boolean"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "previousID": {
+                    "description": "PreviousID — кошелёк, адрес которого заменён этим при ротации.",
+                    "type": "string"
+                },
                 "value": {
                     "type": "string"
                 }
             }
+        },
+        "models.WebAuthnCredential": {
+            "type": "object",
+            "properties": {
+                "aaguid": {
+                    "type": "string"
+                },
+                "alg": {
+                    "type": "integer"
+                },
+                "backupEligible": {
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "credentialID": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "reserves.Proof": {
+            "type": "object",
+            "properties": {
+                "balances": {
+                    "type": "object"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "path": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/reserves.Step"
+                    }
+                },
+                "salt": {
+                    "type": "string"
+                },
+                "snapshot": {
+                    "$ref": "#/definitions/models.ReserveSnapshot"
+                }
+            }
+        },
+        "reserves.Step": {
+            "type": "object",
+            "properties": {
+                "hash": {
+                    "type": "string"
+                },
+                "left": {
+                    "description": "Left — сосед стоит слева от узла пути.",
+                    "type": "boolean"
+                },
+                "sums": {
+                    "type": "object"
+                }
+            }
+        },
+        "watchers.ChainStatus": {
+            "type": "object",
+            "properties": {
+                "chain": {
+                    "type": "string"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "lag": {
+                    "type": "integer"
+                },
+                "lastBlockAt": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "restarts": {
+                    "type": "integer"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "tip": {
+                    "type": "integer"
+                }
+            }
+        },
+        "webauthn.CreationOptions": {
+            "type": "object",
+            "properties": {
+                "attestation": {
+                    "type": "string"
+                },
+                "authenticatorSelection": {
+                    "$ref": "#/definitions/webauthn.authenticatorSelection"
+                },
+                "challenge": {
+                    "type": "string"
+                },
+                "excludeCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webauthn.CredentialDescriptor"
+                    }
+                },
+                "pubKeyCredParams": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webauthn.credParam"
+                    }
+                },
+                "rp": {
+                    "$ref": "#/definitions/webauthn.rpEntity"
+                },
+                "timeout": {
+                    "type": "integer"
+                },
+                "user": {
+                    "$ref": "#/definitions/webauthn.userEntity"
+                }
+            }
+        },
+        "webauthn.CredentialDescriptor": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.RequestOptions": {
+            "type": "object",
+            "properties": {
+                "allowCredentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/webauthn.CredentialDescriptor"
+                    }
+                },
+                "challenge": {
+                    "type": "string"
+                },
+                "rpId": {
+                    "type": "string"
+                },
+                "timeout": {
+                    "type": "integer"
+                },
+                "userVerification": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.authenticatorSelection": {
+            "type": "object",
+            "properties": {
+                "residentKey": {
+                    "type": "string"
+                },
+                "userVerification": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.credParam": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.rpEntity": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "webauthn.userEntity": {
+            "type": "object",
+            "properties": {
+                "displayName": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -0,0 +1,162 @@
+package btcwatcher
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Параметры основных сетей форков Bitcoin. Для деривации, проверки адресов и
+// разбора скриптов нужны только префиксы адресов и ключей, поэтому параметры
+// консенсуса не заполняются.
+var (
+	LitecoinMainNetParams = chaincfg.Params{
+		Name:             "litecoin",
+		Net:              wire.BitcoinNet(0xdbb6c0fb),
+		Bech32HRPSegwit:  "ltc",
+		PubKeyHashAddrID: 0x30,
+		ScriptHashAddrID: 0x32,
+		PrivateKeyID:     0xb0,
+		HDPrivateKeyID:   [4]byte{0x04, 0x88, 0xad, 0xe4},
+		HDPublicKeyID:    [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDCoinType:       2,
+	}
+
+	DogecoinMainNetParams = chaincfg.Params{
+		Name:             "dogecoin",
+		Net:              wire.BitcoinNet(0xc0c0c0c0),
+		PubKeyHashAddrID: 0x1e,
+		ScriptHashAddrID: 0x16,
+		PrivateKeyID:     0x9e,
+		HDPrivateKeyID:   [4]byte{0x02, 0xfa, 0xc3, 0x98},
+		HDPublicKeyID:    [4]byte{0x02, 0xfa, 0xca, 0xfd},
+		HDCoinType:       3,
+	}
+
+	// BitcoinCashMainNetParams использует legacy-формат адресов: CashAddr
+	// не поддерживается btcutil.
+	BitcoinCashMainNetParams = chaincfg.Params{
+		Name:             "bitcoincash",
+		Net:              wire.BitcoinNet(0xe8f3e1e3),
+		PubKeyHashAddrID: 0x00,
+		ScriptHashAddrID: 0x05,
+		PrivateKeyID:     0x80,
+		HDPrivateKeyID:   [4]byte{0x04, 0x88, 0xad, 0xe4},
+		HDPublicKeyID:    [4]byte{0x04, 0x88, 0xb2, 0x1e},
+		HDCoinType:       145,
+	}
+
+	// SigNetParams — параметры signet Bitcoin: префиксы адресов совпадают с
+	// testnet3, в btcd v0.20 этой сети нет.
+	SigNetParams = chaincfg.Params{
+		Name:             "signet",
+		Net:              wire.BitcoinNet(0x40cf030a),
+		Bech32HRPSegwit:  "tb",
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xef,
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		HDCoinType:       1,
+	}
+
+	LitecoinTestNetParams = chaincfg.Params{
+		Name:             "litecoin-testnet",
+		Net:              wire.BitcoinNet(0xf1c8d2fd),
+		Bech32HRPSegwit:  "tltc",
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0x3a,
+		PrivateKeyID:     0xef,
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		HDCoinType:       1,
+	}
+
+	LitecoinRegTestParams = chaincfg.Params{
+		Name:             "litecoin-regtest",
+		Net:              wire.BitcoinNet(0xdab5bffa),
+		Bech32HRPSegwit:  "rltc",
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0x3a,
+		PrivateKeyID:     0xef,
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		HDCoinType:       1,
+	}
+
+	DogecoinTestNetParams = chaincfg.Params{
+		Name:             "dogecoin-testnet",
+		Net:              wire.BitcoinNet(0xdcb7c1fc),
+		PubKeyHashAddrID: 0x71,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xf1,
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		HDCoinType:       1,
+	}
+
+	DogecoinRegTestParams = chaincfg.Params{
+		Name:             "dogecoin-regtest",
+		Net:              wire.BitcoinNet(0xdab5bffa),
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xef,
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		HDCoinType:       1,
+	}
+
+	BitcoinCashTestNetParams = chaincfg.Params{
+		Name:             "bitcoincash-testnet",
+		Net:              wire.BitcoinNet(0xf4f3e5f4),
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xef,
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		HDCoinType:       1,
+	}
+
+	BitcoinCashRegTestParams = chaincfg.Params{
+		Name:             "bitcoincash-regtest",
+		Net:              wire.BitcoinNet(0xfabfb5da),
+		PubKeyHashAddrID: 0x6f,
+		ScriptHashAddrID: 0xc4,
+		PrivateKeyID:     0xef,
+		HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+		HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+		HDCoinType:       1,
+	}
+)
+
+// Params возвращает параметры сети chain (btc, ltc, doge, bch) для режима
+// mode: mainnet, testnet, signet или regtest. Signet есть только у Bitcoin,
+// для форков в этом режиме используется testnet.
+func Params(chain, mode string) (*chaincfg.Params, error) {
+	if mode == "signet" && chain != "btc" {
+		mode = "testnet"
+	}
+	params := map[string]map[string]*chaincfg.Params{
+		"btc":  {"mainnet": &chaincfg.MainNetParams, "testnet": &chaincfg.TestNet3Params, "signet": &SigNetParams, "regtest": &chaincfg.RegressionNetParams},
+		"ltc":  {"mainnet": &LitecoinMainNetParams, "testnet": &LitecoinTestNetParams, "regtest": &LitecoinRegTestParams},
+		"doge": {"mainnet": &DogecoinMainNetParams, "testnet": &DogecoinTestNetParams, "regtest": &DogecoinRegTestParams},
+		"bch":  {"mainnet": &BitcoinCashMainNetParams, "testnet": &BitcoinCashTestNetParams, "regtest": &BitcoinCashRegTestParams},
+	}
+	p, ok := params[chain][mode]
+	if !ok {
+		return nil, fmt.Errorf("no %s params for %s", mode, chain)
+	}
+	return p, nil
+}
+
+func init() {
+	// Регистрация нужна btcutil для разбора bech32-адресов Litecoin. Адреса
+	// тестовых сетей разбирает ValidateAddress по префиксу bech32 сети, без
+	// регистрации: magic их regtest совпадают с regtest Bitcoin.
+	for _, p := range []*chaincfg.Params{&LitecoinMainNetParams, &DogecoinMainNetParams, &BitcoinCashMainNetParams} {
+		if err := chaincfg.Register(p); err != nil {
+			panic("register " + p.Name + ": " + err.Error())
+		}
+	}
+}
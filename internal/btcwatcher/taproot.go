@@ -0,0 +1,207 @@
+package btcwatcher
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bech32"
+)
+
+// btcutil v1.0.2 не знает Taproot (BIP341) и кодировку bech32m (BIP350),
+// поэтому адреса P2TR собираются и разбираются здесь.
+
+const (
+	bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32mConst  = 0x2bc830a3
+)
+
+// TaprootOutputKey возвращает выходной ключ P2TR для внутреннего ключа pub
+// без ветки скриптов (BIP86): Q = P + H_TapTweak(P)·G.
+func TaprootOutputKey(pub *btcec.PublicKey) []byte {
+	curve := btcec.S256()
+	x, y := pub.X, pub.Y
+	// BIP340 использует ключ с чётной координатой Y
+	if y.Bit(0) == 1 {
+		y = new(big.Int).Sub(curve.P, y)
+	}
+	px := padded(x)
+	tag := sha256.Sum256([]byte("TapTweak"))
+	h := sha256.New()
+	h.Write(tag[:])
+	h.Write(tag[:])
+	h.Write(px)
+	t := h.Sum(nil)
+	tx, ty := curve.ScalarBaseMult(t)
+	qx, _ := curve.Add(x, y, tx, ty)
+	return padded(qx)
+}
+
+func padded(n *big.Int) []byte {
+	b := make([]byte, 32)
+	return n.FillBytes(b)
+}
+
+// TaprootAddress кодирует выходной ключ P2TR в адрес bech32m сети params.
+func TaprootAddress(key []byte, params *chaincfg.Params) (string, error) {
+	if params.Bech32HRPSegwit == "" {
+		return "", fmt.Errorf("%s does not support segwit", params.Name)
+	}
+	if len(key) != 32 {
+		return "", errors.New("invalid taproot key length")
+	}
+	prog, err := bech32.ConvertBits(key, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	data := append([]byte{1}, prog...)
+	data = append(data, bech32mChecksum(params.Bech32HRPSegwit, data)...)
+	var sb strings.Builder
+	sb.WriteString(params.Bech32HRPSegwit)
+	sb.WriteByte('1')
+	for _, d := range data {
+		sb.WriteByte(bech32Charset[d])
+	}
+	return sb.String(), nil
+}
+
+// DecodeTaprootAddress проверяет адрес P2TR сети params и возвращает его
+// выходной ключ.
+func DecodeTaprootAddress(address string, params *chaincfg.Params) ([]byte, error) {
+	if params.Bech32HRPSegwit == "" {
+		return nil, fmt.Errorf("%s does not support segwit", params.Name)
+	}
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return nil, errors.New("mixed case address")
+	}
+	address = strings.ToLower(address)
+	sep := strings.LastIndexByte(address, '1')
+	if sep < 1 || address[:sep] != params.Bech32HRPSegwit || len(address)-sep-1 < 7 {
+		return nil, errors.New("invalid taproot address")
+	}
+	data := make([]byte, 0, len(address)-sep-1)
+	for _, c := range address[sep+1:] {
+		i := strings.IndexRune(bech32Charset, c)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid character %q", c)
+		}
+		data = append(data, byte(i))
+	}
+	if bech32Polymod(params.Bech32HRPSegwit, data) != bech32mConst {
+		return nil, errors.New("invalid bech32m checksum")
+	}
+	data = data[:len(data)-6]
+	if data[0] != 1 {
+		return nil, fmt.Errorf("unsupported witness version %d", data[0])
+	}
+	key, err := bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errors.New("invalid taproot key length")
+	}
+	return key, nil
+}
+
+// scriptAddresses возвращает адреса выхода со скриптом script, включая
+// выходы P2TR (OP_1 <32 байта>), которые txscript считает нестандартными.
+func scriptAddresses(script []byte, params *chaincfg.Params) []string {
+	if len(script) == 34 && script[0] == txscript.OP_1 && script[1] == txscript.OP_DATA_32 {
+		addr, err := TaprootAddress(script[2:], params)
+		if err != nil {
+			return nil
+		}
+		return []string{addr}
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(script, params)
+	if err != nil {
+		return nil
+	}
+	res := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		res = append(res, a.EncodeAddress())
+	}
+	return res
+}
+
+// ValidateAddress проверяет адрес любого поддерживаемого типа сети params:
+// P2PKH, P2SH, P2WPKH, P2WSH и P2TR. Адреса bech32 разбираются по префиксу
+// сети, поэтому параметры тестовых сетей не нужно регистрировать в chaincfg.
+func ValidateAddress(address string, params *chaincfg.Params) error {
+	if hrp := params.Bech32HRPSegwit; hrp != "" && strings.HasPrefix(strings.ToLower(address), hrp+"1") {
+		if _, err := DecodeTaprootAddress(address, params); err == nil {
+			return nil
+		}
+		return validateWitnessV0(address, hrp)
+	}
+	addr, err := btcutil.DecodeAddress(address, params)
+	if err != nil {
+		return err
+	}
+	if !addr.IsForNet(params) {
+		return fmt.Errorf("address %s is not for %s", address, params.Name)
+	}
+	return nil
+}
+
+// validateWitnessV0 проверяет адрес P2WPKH или P2WSH (bech32, версия 0).
+func validateWitnessV0(address, hrp string) error {
+	prefix, data, err := bech32.Decode(address)
+	if err != nil {
+		return err
+	}
+	if prefix != hrp || len(data) == 0 || data[0] != 0 {
+		return fmt.Errorf("invalid segwit address %s", address)
+	}
+	prog, err := bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return err
+	}
+	if len(prog) != 20 && len(prog) != 32 {
+		return fmt.Errorf("invalid witness program length %d", len(prog))
+	}
+	return nil
+}
+
+func bech32Polymod(hrp string, data []byte) int {
+	gen := []int{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	values := make([]int, 0, len(hrp)*2+1+len(data))
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, int(hrp[i]>>5))
+	}
+	values = append(values, 0)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, int(hrp[i]&31))
+	}
+	for _, d := range data {
+		values = append(values, int(d))
+	}
+	chk := 1
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (b>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32mChecksum(hrp string, data []byte) []byte {
+	values := append(append([]byte{}, data...), 0, 0, 0, 0, 0, 0)
+	mod := bech32Polymod(hrp, values) ^ bech32mConst
+	res := make([]byte, 6)
+	for i := range res {
+		res[i] = byte((mod >> uint(5*(5-i))) & 31)
+	}
+	return res
+}
@@ -1,31 +1,42 @@
 package btcwatcher
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/rpcclient"
-	"github.com/btcsuite/btcd/txscript"
-	"github.com/btcsuite/btcd/wire"
 	"github.com/shopspring/decimal"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
 
+	"ptop/internal/deposits"
 	"ptop/internal/models"
 )
 
-// Watcher следит за блоками Bitcoin и записывает подтвержденные депозиты.
+// pollInterval — период опроса узла на появление новых блоков.
+const pollInterval = 30 * time.Second
+
+// Watcher следит за блоками Bitcoin или его форка (Litecoin, Dogecoin,
+// Bitcoin Cash) и записывает подтвержденные депозиты.
 type Watcher struct {
-	client  *rpcclient.Client
-	db      *gorm.DB
-	params  *chaincfg.Params
-	debug   bool
-	debugCh chan debugDeposit
+	chain     string
+	client    *rpcclient.Client
+	db        *gorm.DB
+	params    *chaincfg.Params
+	debug     bool
+	debugCh   chan debugDeposit
+	debugOnce sync.Once
+
+	mu      sync.Mutex
+	height  uint64
+	tip     uint64
+	blockAt time.Time
 }
 
 type debugDeposit struct {
@@ -33,18 +44,19 @@ type debugDeposit struct {
 	amount   decimal.Decimal
 }
 
-// New создаёт нового наблюдателя.
-func New(db *gorm.DB, host, user, pass string, params *chaincfg.Params, debug bool) (*Watcher, error) {
+// New создаёт нового наблюдателя сети chain (значение assets.chain) с
+// префиксами адресов params.
+func New(db *gorm.DB, chain, host, user, pass string, params *chaincfg.Params, debug bool) (*Watcher, error) {
 	if params == nil {
 		params = &chaincfg.MainNetParams
 	}
-	w := &Watcher{db: db, params: params, debug: debug}
+	w := &Watcher{chain: chain, db: db, params: params, debug: debug}
 	if debug {
 		w.debugCh = make(chan debugDeposit)
 		return w, nil
 	}
 	if host == "" {
-		return nil, fmt.Errorf("btc rpc host required")
+		return nil, fmt.Errorf("%s rpc host required", chain)
 	}
 	cfg := &rpcclient.ConnConfig{
 		Host:         host,
@@ -53,11 +65,7 @@ func New(db *gorm.DB, host, user, pass string, params *chaincfg.Params, debug bo
 		HTTPPostMode: true,
 		DisableTLS:   true,
 	}
-	ntfnHandlers := rpcclient.NotificationHandlers{}
-	ntfnHandlers.OnBlockConnected = func(hash *chainhash.Hash, height int32, t time.Time) {
-		go w.handleBlock(hash)
-	}
-	client, err := rpcclient.New(cfg, &ntfnHandlers)
+	client, err := rpcclient.New(cfg, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -65,18 +73,84 @@ func New(db *gorm.DB, host, user, pass string, params *chaincfg.Params, debug bo
 	return w, nil
 }
 
-// Start запускает подписку на новые блоки.
+// Start запускает наблюдатель в фоне без перезапусков при ошибках.
 func (w *Watcher) Start() error {
 	if w.debug {
-		go w.debugLoop()
+		w.startDebug()
 		return nil
 	}
-	if err := w.client.NotifyBlocks(); err != nil {
-		return fmt.Errorf("notify blocks: %w", err)
+	go func() {
+		if err := w.Run(context.Background()); err != nil {
+			log.Printf("%s watcher: %v", w.chain, err)
+		}
+	}()
+	return nil
+}
+
+// Run опрашивает узел и обрабатывает новые блоки, пока не произойдёт
+// ошибка RPC или не будет отменён ctx.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.debug {
+		w.startDebug()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := w.poll(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Progress возвращает высоту последнего обработанного блока, высоту сети
+// и время последнего блока.
+func (w *Watcher) Progress() (height, tip uint64, blockAt time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.height, w.tip, w.blockAt
+}
+
+// poll обрабатывает все блоки между последним обработанным и вершиной сети.
+// При первом запуске обработка начинается с текущей вершины.
+func (w *Watcher) poll() error {
+	count, err := w.client.GetBlockCount()
+	if err != nil {
+		return fmt.Errorf("get block count: %w", err)
+	}
+	tip := uint64(count)
+	w.mu.Lock()
+	w.tip = tip
+	next := w.height + 1
+	if w.height == 0 {
+		next = tip
+	}
+	w.mu.Unlock()
+	for h := next; h <= tip; h++ {
+		hash, err := w.client.GetBlockHash(int64(h))
+		if err != nil {
+			return fmt.Errorf("get block hash %d: %w", h, err)
+		}
+		if err := w.handleBlock(hash.String(), h, tip); err != nil {
+			return err
+		}
+	}
+	if err := deposits.ConfirmPending(w.db, w.chain, tip); err != nil {
+		log.Printf("не удалось подтвердить депозиты: %v", err)
 	}
 	return nil
 }
 
+func (w *Watcher) startDebug() {
+	w.debugOnce.Do(func() { go w.debugLoop() })
+}
+
 func (w *Watcher) debugLoop() {
 	for dep := range w.debugCh {
 		w.createDebugDeposit(dep.walletID, dep.amount)
@@ -96,48 +170,70 @@ func (w *Watcher) createDebugDeposit(walletID string, amount decimal.Decimal) {
 		log.Printf("ошибка поиска кошелька: %v", err)
 		return
 	}
-	data, _ := json.Marshal(map[string]any{"debug": true})
-	dep := models.TransactionIn{
-		ClientID: wallet.ClientID,
-		WalletID: wallet.ID,
-		AssetID:  wallet.AssetID,
-		Amount:   amount,
-		Status:   "confirmed",
-		Data:     datatypes.JSON(data),
-	}
-	if err := w.db.Create(&dep).Error; err != nil {
+	dep := deposits.Deposit{Chain: w.chain, Wallet: wallet, Amount: amount, Data: map[string]any{"debug": true}}
+	if _, err := deposits.Record(w.db, dep, 0); err != nil {
 		log.Printf("не удалось сохранить депозит: %v", err)
-		return
 	}
-	if err := w.db.Model(&models.Balance{}).
-		Where("client_id = ? AND asset_id = ?", wallet.ClientID, wallet.AssetID).
-		Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-		log.Printf("не удалось обновить баланс: %v", err)
+}
+
+// block — ответ getblock с verbosity 2. Сырой блок не разбирается через
+// btcd/wire: блоки Dogecoin с AuxPoW и Litecoin с MWEB ему несовместимы.
+type block struct {
+	Hash string    `json:"hash"`
+	Time int64     `json:"time"`
+	Tx   []blockTx `json:"tx"`
+}
+
+type blockTx struct {
+	Txid string `json:"txid"`
+	Vout []struct {
+		Value        json.Number `json:"value"`
+		N            int         `json:"n"`
+		ScriptPubKey struct {
+			Hex string `json:"hex"`
+		} `json:"scriptPubKey"`
+	} `json:"vout"`
+}
+
+// getBlock запрашивает блок с разобранными транзакциями.
+func (w *Watcher) getBlock(hash string) (*block, error) {
+	rawHash, _ := json.Marshal(hash)
+	res, err := w.client.RawRequest("getblock", []json.RawMessage{rawHash, json.RawMessage("2")})
+	if err != nil {
+		return nil, err
+	}
+	var b block
+	if err := json.Unmarshal(res, &b); err != nil {
+		return nil, err
 	}
+	return &b, nil
 }
 
-// handleBlock обрабатывает подключенный блок.
-func (w *Watcher) handleBlock(hash *chainhash.Hash) {
-	block, err := w.client.GetBlock(hash)
+// handleBlock обрабатывает блок на указанной высоте.
+func (w *Watcher) handleBlock(hash string, height, tip uint64) error {
+	b, err := w.getBlock(hash)
 	if err != nil {
-		log.Printf("не удалось получить блок %s: %v", hash, err)
-		return
+		return fmt.Errorf("get block %s: %w", hash, err)
 	}
-	for _, tx := range block.Transactions {
-		w.processTx(tx, hash.String())
+	for _, tx := range b.Tx {
+		w.processTx(tx, hash, height, tip)
 	}
+	w.mu.Lock()
+	w.height = height
+	w.blockAt = time.Unix(b.Time, 0)
+	w.mu.Unlock()
+	return nil
 }
 
-func (w *Watcher) processTx(tx *wire.MsgTx, blockHash string) {
-	txid := tx.TxHash().String()
-	for i, out := range tx.TxOut {
-		_, addrs, _, err := txscript.ExtractPkScriptAddrs(out.PkScript, w.params)
-		if err != nil || len(addrs) == 0 {
+func (w *Watcher) processTx(tx blockTx, blockHash string, height, tip uint64) {
+	for _, out := range tx.Vout {
+		script, err := hex.DecodeString(out.ScriptPubKey.Hex)
+		if err != nil {
 			continue
 		}
-		for _, addr := range addrs {
+		for _, addr := range scriptAddresses(script, w.params) {
 			var wallet models.Wallet
-			if err := w.db.Where("value = ?", addr.EncodeAddress()).First(&wallet).Error; err != nil {
+			if err := w.db.Where("value = ? AND network = ?", addr, w.chain).First(&wallet).Error; err != nil {
 				if errors.Is(err, gorm.ErrRecordNotFound) {
 					continue
 				}
@@ -145,34 +241,37 @@ func (w *Watcher) processTx(tx *wire.MsgTx, blockHash string) {
 				continue
 			}
 			var existing models.TransactionIn
-			if err := w.db.Where("data ->> 'txid' = ? AND data ->> 'vout' = ?", txid, fmt.Sprintf("%d", i)).First(&existing).Error; err == nil {
+			if err := w.db.Where("network = ? AND data ->> 'txid' = ? AND CAST(data ->> 'vout' AS INTEGER) = ?", w.chain, tx.Txid, out.N).First(&existing).Error; err == nil {
 				continue
 			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 				log.Printf("ошибка проверки существующей транзакции: %v", err)
 				continue
 			}
-			amount := decimal.NewFromInt(out.Value).Div(decimal.NewFromInt(1e8))
-			data, _ := json.Marshal(map[string]any{
-				"txid":       txid,
-				"vout":       i,
-				"block_hash": blockHash,
-			})
-			dep := models.TransactionIn{
-				ClientID: wallet.ClientID,
-				WalletID: wallet.ID,
-				AssetID:  wallet.AssetID,
-				Amount:   amount,
-				Status:   "confirmed",
-				Data:     datatypes.JSON(data),
+			network, err := deposits.Network(w.db, wallet.AssetID, w.chain)
+			if err != nil {
+				log.Printf("ошибка базы данных: %v", err)
+				continue
 			}
-			if err := w.db.Create(&dep).Error; err != nil {
-				log.Printf("не удалось сохранить депозит: %v", err)
+			// Узел возвращает сумму в монетах; точность сети отсекает
+			// погрешность представления
+			amount, err := decimal.NewFromString(out.Value.String())
+			if err != nil {
+				log.Printf("некорректная сумма выхода %s:%d: %v", tx.Txid, out.N, err)
 				continue
 			}
-			if err := w.db.Model(&models.Balance{}).
-				Where("client_id = ? AND asset_id = ?", wallet.ClientID, wallet.AssetID).
-				Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-				log.Printf("не удалось обновить баланс: %v", err)
+			dep := deposits.Deposit{
+				Chain:       w.chain,
+				Wallet:      wallet,
+				Amount:      amount.Truncate(deposits.Decimals(network, 8)),
+				BlockHeight: height,
+				Data: map[string]any{
+					"txid":       tx.Txid,
+					"vout":       out.N,
+					"block_hash": blockHash,
+				},
+			}
+			if _, err := deposits.Record(w.db, dep, tip); err != nil {
+				log.Printf("не удалось сохранить депозит: %v", err)
 			}
 		}
 	}
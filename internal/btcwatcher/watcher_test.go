@@ -1,9 +1,19 @@
 package btcwatcher
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
 	"github.com/shopspring/decimal"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -16,7 +26,7 @@ func TestWatcherDebugDeposit(t *testing.T) {
 	if err != nil {
 		t.Fatalf("db open: %v", err)
 	}
-	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	client := models.Client{Username: "u"}
@@ -28,7 +38,7 @@ func TestWatcherDebugDeposit(t *testing.T) {
 	bal := models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero}
 	db.Create(&bal)
 
-	w, err := New(db, "", "", "", nil, true)
+	w, err := New(db, "btc", "", "", "", nil, true)
 	if err != nil {
 		t.Fatalf("watcher: %v", err)
 	}
@@ -51,3 +61,163 @@ func TestWatcherDebugDeposit(t *testing.T) {
 		t.Fatalf("balance amount %s", bal.Amount)
 	}
 }
+
+// stubNode имитирует JSON-RPC узел Litecoin: вершина сети задаётся tip, в
+// блоке 100 есть выход на кошелёк.
+func stubNode(t *testing.T, tip *atomic.Int64, script string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var result string
+		switch req.Method {
+		case "getblockcount":
+			result = fmt.Sprintf("%d", tip.Load())
+		case "getblockhash":
+			var h int64
+			json.Unmarshal(req.Params[0], &h)
+			result = fmt.Sprintf(`"%064x"`, h)
+		case "getblock":
+			var hash string
+			json.Unmarshal(req.Params[0], &hash)
+			txs := "[]"
+			if hash == fmt.Sprintf("%064x", 100) {
+				txs = `[{"txid":"ab01","vout":[
+					{"value":1.5,"n":0,"scriptPubKey":{"hex":"0014` + strings.Repeat("22", 20) + `"}},
+					{"value":0.12345678,"n":1,"scriptPubKey":{"hex":"` + script + `"}}]}]`
+			}
+			result = `{"hash":"` + hash + `","time":1700000000,"tx":` + txs + `}`
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"result":%s,"error":null,"id":%s}`, result, req.ID)
+	}))
+}
+
+func TestWatcherLitecoinBlocks(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:ltc_blocks?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	hash := bytes.Repeat([]byte{0x11}, 20)
+	addr, err := btcutil.NewAddressPubKeyHash(hash, &LitecoinMainNetParams)
+	if err != nil {
+		t.Fatalf("address: %v", err)
+	}
+	client := models.Client{Username: "u"}
+	db.Create(&client)
+	asset := models.Asset{Name: "LTC", Type: models.AssetTypeCrypto, Chain: "ltc"}
+	db.Create(&asset)
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: "ltc", Decimals: 8, Confirmations: 2})
+	wallet := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Network: "ltc", Value: addr.EncodeAddress(), DerivationIndex: 1}
+	db.Create(&wallet)
+	bal := models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero}
+	db.Create(&bal)
+
+	var tip atomic.Int64
+	tip.Store(100)
+	srv := stubNode(t, &tip, "76a914"+hex.EncodeToString(hash)+"88ac")
+	defer srv.Close()
+
+	w, err := New(db, "ltc", srv.Listener.Addr().String(), "user", "pass", &LitecoinMainNetParams, false)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	if err := w.poll(); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	var tx models.TransactionIn
+	if err := db.First(&tx).Error; err != nil {
+		t.Fatalf("tx: %v", err)
+	}
+	if tx.Status != models.TransactionInStatusProcessing || tx.Network != "ltc" || !tx.Amount.Equal(decimal.RequireFromString("0.12345678")) {
+		t.Fatalf("unexpected tx %+v", tx)
+	}
+
+	tip.Store(101)
+	if err := w.poll(); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	db.First(&bal, "id = ?", bal.ID)
+	if !bal.Amount.Equal(decimal.RequireFromString("0.12345678")) {
+		t.Fatalf("balance amount %s", bal.Amount)
+	}
+
+	// повторная обработка блока не создаёт второй депозит
+	w.height = 99
+	if err := w.poll(); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	var count int64
+	db.Model(&models.TransactionIn{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 deposit, got %d", count)
+	}
+	if height, top, _ := w.Progress(); height != 101 || top != 101 {
+		t.Fatalf("progress %d/%d", height, top)
+	}
+}
+
+func TestProcessTxSegwitAndTaproot(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:btc_segwit?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	client := models.Client{Username: "u"}
+	db.Create(&client)
+	other := models.Client{Username: "v"}
+	db.Create(&other)
+	asset := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, Chain: "btc"}
+	db.Create(&asset)
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: "btc", Decimals: 8, Confirmations: 1})
+	// выходы P2WPKH и P2TR из векторов BIP84 и BIP86
+	wpkh := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Network: "btc", Value: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", DerivationIndex: 0}
+	tr := models.Wallet{ClientID: other.ID, AssetID: asset.ID, Network: "btc", Value: "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr", DerivationIndex: 1}
+	db.Create(&wpkh)
+	db.Create(&tr)
+	db.Create(&models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero})
+	db.Create(&models.Balance{ClientID: other.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero})
+
+	key, err := DecodeTaprootAddress(tr.Value, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("decode taproot: %v", err)
+	}
+	if _, err := DecodeTaprootAddress(wpkh.Value, &chaincfg.MainNetParams); err == nil {
+		t.Fatalf("expected bech32 address to be rejected as taproot")
+	}
+	w, err := New(db, "btc", "", "", "", &chaincfg.MainNetParams, true)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	var tx blockTx
+	raw := fmt.Sprintf(`{"txid":"aa","vout":[
+		{"value":0.5,"n":0,"scriptPubKey":{"hex":"0014c0cebcd6c3d3ca8c75dc5ec62ebe55330ef910e2"}},
+		{"value":0.25,"n":1,"scriptPubKey":{"hex":"5120%s"}}]}`, hex.EncodeToString(key))
+	if err := json.Unmarshal([]byte(raw), &tx); err != nil {
+		t.Fatalf("tx json: %v", err)
+	}
+	w.processTx(tx, "hash", 10, 0)
+
+	var deps []models.TransactionIn
+	db.Order("amount DESC").Find(&deps)
+	if len(deps) != 2 || deps[0].WalletID != wpkh.ID || deps[1].WalletID != tr.ID {
+		t.Fatalf("unexpected deposits %+v", deps)
+	}
+	if !deps[1].Amount.Equal(decimal.RequireFromString("0.25")) {
+		t.Fatalf("taproot amount %s", deps[1].Amount)
+	}
+}
@@ -0,0 +1,134 @@
+package chains
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"ptop/config"
+	"ptop/internal/models"
+)
+
+const (
+	ChainBTC  = "btc"
+	ChainLTC  = "ltc"
+	ChainDOGE = "doge"
+	ChainBCH  = "bch"
+	ChainETH  = "eth"
+	ChainARB  = "arbitrum"
+	ChainOP   = "optimism"
+	ChainBASE = "base"
+	ChainPOL  = "polygon"
+	ChainBSC  = "bsc"
+	ChainSOL  = "sol"
+	ChainTRON = "tron"
+	ChainXMR  = "xmr"
+)
+
+// Chain — модуль сети: деривация и проверка адресов, ссылки на обозреватель
+// и создание наблюдателя депозитов.
+type Chain interface {
+	// DeriveAddress возвращает адрес депозита для индекса деривации.
+	// network содержит параметры актива в сети, например контракт токена.
+	// Сети с собственной нумерацией адресов возвращают свой индекс.
+	DeriveAddress(asset models.Asset, network models.AssetNetwork, clientID string, index uint32) (string, uint32, error)
+	// ValidateAddress проверяет адрес получателя в этой сети.
+	ValidateAddress(address string) error
+	// ExplorerURL возвращает ссылку на транзакцию в обозревателе блоков.
+	ExplorerURL(txID string) string
+	// NewWatcher создаёт наблюдатель депозитов сети.
+	NewWatcher(db *gorm.DB, cfg *config.Config) (Watcher, error)
+}
+
+// Watcher — наблюдатель депозитов сети.
+type Watcher interface {
+	Run(ctx context.Context) error
+	Progress() (height, tip uint64, blockAt time.Time)
+	TriggerDeposit(walletID string, amount decimal.Decimal)
+}
+
+var registry = struct {
+	sync.RWMutex
+	m map[string]Chain
+}{m: mustDefaultChains()}
+
+func mustDefaultChains() map[string]Chain {
+	m, err := defaultChains(ModeMainnet)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Register добавляет или заменяет модуль сети.
+func Register(name string, c Chain) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.m[name] = c
+}
+
+// Get возвращает модуль сети по имени.
+func Get(name string) (Chain, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	c, ok := registry.m[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown chain %q", name)
+	}
+	return c, nil
+}
+
+// ForAsset возвращает модуль сети, к которой привязан актив.
+func ForAsset(asset models.Asset) (Chain, error) {
+	if asset.Chain == "" {
+		return nil, fmt.Errorf("asset %s has no chain", asset.Name)
+	}
+	return Get(asset.Chain)
+}
+
+// AddressGroup возвращает сети, в которых адреса выводятся так же, как в
+// сети name, включая её саму. Для EVM-сетей это все зарегистрированные
+// EVM-сети: они делят индексы деривации, и клиент получает в них один адрес.
+func AddressGroup(name string) []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	if _, ok := registry.m[name].(evmChain); !ok {
+		return []string{name}
+	}
+	var names []string
+	for n, c := range registry.m {
+		if _, ok := c.(evmChain); ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddressScope возвращает ключ группы сетей с общими индексами деривации:
+// "evm" для EVM-сетей и имя сети для остальных.
+func AddressScope(name string) string {
+	registry.RLock()
+	defer registry.RUnlock()
+	if _, ok := registry.m[name].(evmChain); ok {
+		return "evm"
+	}
+	return name
+}
+
+// Names возвращает имена зарегистрированных сетей.
+func Names() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.m))
+	for name := range registry.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,276 @@
+package chains
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	solana "github.com/gagliardetto/solana-go"
+
+	"ptop/internal/models"
+	"ptop/internal/tronwatcher"
+)
+
+func testXpub(t *testing.T) string {
+	t.Helper()
+	master, err := hdkeychain.NewMaster(bytes.Repeat([]byte{0x03}, 32), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("master: %v", err)
+	}
+	xpub, err := master.Neuter()
+	if err != nil {
+		t.Fatalf("neuter: %v", err)
+	}
+	return xpub.String()
+}
+
+func TestForAsset(t *testing.T) {
+	if _, err := ForAsset(models.Asset{Name: "BTC"}); err == nil {
+		t.Fatalf("expected error for asset without chain")
+	}
+	if _, err := ForAsset(models.Asset{Name: "ADA", Chain: "ada"}); err == nil {
+		t.Fatalf("expected error for unknown chain")
+	}
+	c, err := ForAsset(models.Asset{Name: "BTC", Chain: ChainBTC})
+	if err != nil {
+		t.Fatalf("for asset: %v", err)
+	}
+	if u, ok := c.(utxoChain); !ok || u.name != ChainBTC {
+		t.Fatalf("unexpected chain %T", c)
+	}
+}
+
+func TestDeriveAndValidate(t *testing.T) {
+	asset := models.Asset{Xpub: testXpub(t)}
+	for _, name := range []string{ChainBTC, ChainLTC, ChainDOGE, ChainBCH, ChainETH, ChainTRON} {
+		c, err := Get(name)
+		if err != nil {
+			t.Fatalf("get %s: %v", name, err)
+		}
+		addr, idx, err := c.DeriveAddress(asset, models.AssetNetwork{Chain: name}, "client", 7)
+		if err != nil {
+			t.Fatalf("%s derive: %v", name, err)
+		}
+		if idx != 7 {
+			t.Fatalf("%s index %d", name, idx)
+		}
+		if err := c.ValidateAddress(addr); err != nil {
+			t.Fatalf("%s validate %s: %v", name, addr, err)
+		}
+		again, _, _ := c.DeriveAddress(asset, models.AssetNetwork{Chain: name}, "client", 7)
+		if again != addr {
+			t.Fatalf("%s derivation is not deterministic", name)
+		}
+		next, _, _ := c.DeriveAddress(asset, models.AssetNetwork{Chain: name}, "client", 8)
+		if next == addr {
+			t.Fatalf("%s same address for different index", name)
+		}
+		if err := c.ValidateAddress("not-an-address"); err == nil {
+			t.Fatalf("%s accepted invalid address", name)
+		}
+	}
+}
+
+func TestDeriveSolNativeAndMint(t *testing.T) {
+	c, _ := Get(ChainSOL)
+	asset := models.Asset{Xpub: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"}
+	owner, _, err := c.DeriveAddress(asset, models.AssetNetwork{Chain: ChainSOL}, "client", 0)
+	if err != nil {
+		t.Fatalf("derive native: %v", err)
+	}
+	usdc, _, err := c.DeriveAddress(asset, models.AssetNetwork{Chain: ChainSOL, Contract: "EPjFWdd5AufqSSqeM2qXznJkBLFKe4MtSnTwWehAw1v"}, "client", 0)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	// токены SPL принимаются на ATA адреса нативного SOL
+	ata, _, _ := solana.FindAssociatedTokenAddress(solana.MustPublicKeyFromBase58(owner), solana.MustPublicKeyFromBase58("EPjFWdd5AufqSSqeM2qXznJkBLFKe4MtSnTwWehAw1v"))
+	if usdc != ata.String() {
+		t.Fatalf("usdc account %s is not ata of %s", usdc, owner)
+	}
+	usdt, _, err := c.DeriveAddress(asset, models.AssetNetwork{Chain: ChainSOL, Contract: "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB"}, "client", 0)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	if usdc == usdt {
+		t.Fatalf("same token account for different mints")
+	}
+}
+
+func TestDeriveSegwitAndTaproot(t *testing.T) {
+	// векторы BIP84 и BIP86 для мнемоники "abandon ... about"
+	btc, _ := Get(ChainBTC)
+	zpub := models.Asset{Xpub: "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"}
+	if addr, _, err := btc.DeriveAddress(zpub, models.AssetNetwork{}, "c", 0); err != nil || addr != "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu" {
+		t.Fatalf("bip84 address %s: %v", addr, err)
+	}
+	if addr, _, err := btc.DeriveAddress(zpub, models.AssetNetwork{}, "c", 1); err != nil || addr != "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g" {
+		t.Fatalf("bip84 second address %s: %v", addr, err)
+	}
+	tr := models.Asset{
+		Xpub:        "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ",
+		AddressType: models.AddressTypeP2TR,
+	}
+	if addr, _, err := btc.DeriveAddress(tr, models.AssetNetwork{}, "c", 0); err != nil || addr != "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr" {
+		t.Fatalf("bip86 address %s: %v", addr, err)
+	}
+	if addr, _, err := btc.DeriveAddress(tr, models.AssetNetwork{}, "c", 1); err != nil || addr != "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh" {
+		t.Fatalf("bip86 second address %s: %v", addr, err)
+	}
+	doge, _ := Get(ChainDOGE)
+	if _, _, err := doge.DeriveAddress(tr, models.AssetNetwork{}, "c", 0); err == nil {
+		t.Fatalf("expected error for taproot on dogecoin")
+	}
+}
+
+func TestTronAddressMatchesEth(t *testing.T) {
+	asset := models.Asset{Xpub: testXpub(t)}
+	eth, _, err := evmChain{}.DeriveAddress(asset, models.AssetNetwork{}, "client", 3)
+	if err != nil {
+		t.Fatalf("eth: %v", err)
+	}
+	tron, _, err := tronChain{}.DeriveAddress(asset, models.AssetNetwork{}, "client", 3)
+	if err != nil {
+		t.Fatalf("tron: %v", err)
+	}
+	if !strings.HasPrefix(tron, "T") {
+		t.Fatalf("tron address %s", tron)
+	}
+	raw, err := tronwatcher.DecodeAddress(tron)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.EqualFold("0x"+hex.EncodeToString(raw), eth) {
+		t.Fatalf("tron %s does not match eth %s", tron, eth)
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	cases := []struct {
+		chain string
+		addr  string
+		ok    bool
+	}{
+		{ChainSOL, "EPjFWdd5AufqSSqeM2qXznJkBLFKe4MtSnTwWehAw1v", true},
+		{ChainSOL, "0x0000", false},
+		{ChainTRON, "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", true},
+		{ChainTRON, "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6u", false},
+		{ChainTRON, "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", false},
+		{ChainXMR, "4" + strings.Repeat("A", 94), true},
+		{ChainXMR, "8" + strings.Repeat("b", 105), true},
+		{ChainXMR, "1" + strings.Repeat("A", 94), false},
+		{ChainXMR, "4" + strings.Repeat("0", 94), false},
+		{ChainBTC, "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", true},
+		{ChainBTC, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", true},
+		{ChainBTC, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", true},
+		{ChainBTC, "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3", true},
+		{ChainBTC, "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr", true},
+		{ChainBTC, "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcq", false},
+		{ChainBTC, "ltc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", false},
+		{ChainDOGE, "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr", false},
+	}
+	for _, tc := range cases {
+		c, _ := Get(tc.chain)
+		err := c.ValidateAddress(tc.addr)
+		if (err == nil) != tc.ok {
+			t.Fatalf("%s %s: unexpected result %v", tc.chain, tc.addr, err)
+		}
+	}
+}
+
+func TestExplorerURL(t *testing.T) {
+	for _, name := range Names() {
+		c, _ := Get(name)
+		if u := c.ExplorerURL("abc"); !strings.HasPrefix(u, "https://") || !strings.HasSuffix(u, "/abc") {
+			t.Fatalf("%s explorer url %s", name, u)
+		}
+	}
+}
+
+func TestEVMAddressGroup(t *testing.T) {
+	group := AddressGroup(ChainARB)
+	if len(group) != 6 || group[0] != ChainARB || group[len(group)-1] != ChainPOL {
+		t.Fatalf("unexpected group %v", group)
+	}
+	if g := AddressGroup(ChainBTC); len(g) != 1 || g[0] != ChainBTC {
+		t.Fatalf("unexpected btc group %v", g)
+	}
+	if AddressScope(ChainBSC) != AddressScope(ChainETH) || AddressScope(ChainBTC) != ChainBTC {
+		t.Fatalf("unexpected scopes %s %s", AddressScope(ChainBSC), AddressScope(ChainBTC))
+	}
+	asset := models.Asset{Xpub: testXpub(t)}
+	eth, _ := Get(ChainETH)
+	bsc, _ := Get(ChainBSC)
+	a, _, _ := eth.DeriveAddress(asset, models.AssetNetwork{Chain: ChainETH}, "client", 4)
+	b, _, _ := bsc.DeriveAddress(asset, models.AssetNetwork{Chain: ChainBSC}, "client", 4)
+	if a != b {
+		t.Fatalf("evm addresses differ: %s %s", a, b)
+	}
+	if url := bsc.ExplorerURL("0xab"); url != "https://bscscan.com/tx/0xab" {
+		t.Fatalf("explorer %s", url)
+	}
+}
+
+func TestSetMode(t *testing.T) {
+	if err := SetMode("devnet"); err == nil {
+		t.Fatalf("expected error for unknown mode")
+	}
+	if err := SetMode(ModeTestnet); err != nil {
+		t.Fatalf("testnet: %v", err)
+	}
+	defer SetMode(ModeMainnet)
+	if Mode() != ModeTestnet {
+		t.Fatalf("mode %s", Mode())
+	}
+	btc, _ := Get(ChainBTC)
+	// вектор BIP84 для testnet: m/84'/1'/0'/0/0 мнемоники "abandon ... about"
+	vpub := models.Asset{Xpub: "vpub5Y6cjg78GGuNLsaPhmYsiw4gYX3HoQiRBiSwDaBXKUafCt9bNwWQiitDk5VZ5BVxYnQdwoTyXSs2JHRPAgjAvtbBrf8ZhDYe2jWAqvZVnsc"}
+	if addr, _, err := btc.DeriveAddress(vpub, models.AssetNetwork{}, "c", 0); err != nil || addr != "tb1q6rz28mcfaxtmd6v789l9rrlrusdprr9pqcpvkl" {
+		t.Fatalf("testnet address %s: %v", addr, err)
+	}
+	if err := btc.ValidateAddress("tb1q6rz28mcfaxtmd6v789l9rrlrusdprr9pqcpvkl"); err != nil {
+		t.Fatalf("testnet validate: %v", err)
+	}
+	if err := btc.ValidateAddress("bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"); err == nil {
+		t.Fatalf("expected mainnet address to be rejected on testnet")
+	}
+	if url := btc.ExplorerURL("ab"); url != "https://mempool.space/testnet/tx/ab" {
+		t.Fatalf("explorer %s", url)
+	}
+	eth, _ := Get(ChainETH)
+	if c := eth.(evmChain); c.chainID != 11155111 {
+		t.Fatalf("sepolia chain id %d", c.chainID)
+	}
+	sol, _ := Get(ChainSOL)
+	if url := sol.ExplorerURL("ab"); url != "https://solscan.io/tx/ab?cluster=devnet" {
+		t.Fatalf("sol explorer %s", url)
+	}
+	xmr, _ := Get(ChainXMR)
+	if err := xmr.ValidateAddress("5" + strings.Repeat("A", 94)); err != nil {
+		t.Fatalf("stagenet address: %v", err)
+	}
+	if err := xmr.ValidateAddress("4" + strings.Repeat("A", 94)); err == nil {
+		t.Fatalf("expected mainnet xmr address to be rejected on testnet")
+	}
+
+	if err := SetMode(ModeRegtest); err != nil {
+		t.Fatalf("regtest: %v", err)
+	}
+	btc, _ = Get(ChainBTC)
+	if addr, _, err := btc.DeriveAddress(vpub, models.AssetNetwork{}, "c", 0); err != nil || !strings.HasPrefix(addr, "bcrt1q") {
+		t.Fatalf("regtest address %s: %v", addr, err)
+	}
+	ltc, _ := Get(ChainLTC)
+	addr, _, err := ltc.DeriveAddress(vpub, models.AssetNetwork{}, "c", 0)
+	if err != nil || !strings.HasPrefix(addr, "rltc1q") {
+		t.Fatalf("litecoin regtest address %s: %v", addr, err)
+	}
+	if err := ltc.ValidateAddress(addr); err != nil {
+		t.Fatalf("litecoin regtest validate: %v", err)
+	}
+	if url := btc.ExplorerURL("ab"); url != "" {
+		t.Fatalf("regtest explorer %s", url)
+	}
+}
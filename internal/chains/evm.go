@@ -0,0 +1,73 @@
+package chains
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	btcec "github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+
+	"ptop/config"
+	"ptop/internal/ethwatcher"
+	"ptop/internal/models"
+)
+
+// evmChain — Ethereum или совместимая с ним сеть (L2, сайдчейн). Адреса всех
+// EVM-сетей выводятся одинаково, поэтому клиент получает один адрес во всех
+// таких сетях актива.
+type evmChain struct {
+	name     string
+	chainID  uint64
+	explorer string
+}
+
+func (evmChain) DeriveAddress(asset models.Asset, network models.AssetNetwork, clientID string, index uint32) (string, uint32, error) {
+	if asset.Xpub == "" {
+		return "", 0, errors.New("no xpub")
+	}
+	key, err := hdkeychain.NewKeyFromString(asset.Xpub)
+	if err != nil {
+		return "", 0, err
+	}
+	change, err := key.Child(0)
+	if err != nil {
+		return "", 0, err
+	}
+	child, err := change.Child(index)
+	if err != nil {
+		return "", 0, err
+	}
+	pk, err := child.ECPubKey()
+	if err != nil {
+		return "", 0, err
+	}
+	ecdsaPK := ecdsa.PublicKey{Curve: btcec.S256(), X: pk.X, Y: pk.Y}
+	addr := crypto.PubkeyToAddress(ecdsaPK)
+	return addr.Hex(), index, nil
+}
+
+func (evmChain) ValidateAddress(address string) error {
+	if !common.IsHexAddress(address) {
+		return fmt.Errorf("invalid evm address %q", address)
+	}
+	return nil
+}
+
+func (c evmChain) ExplorerURL(txID string) string {
+	if c.explorer == "" {
+		return ""
+	}
+	return c.explorer + txID
+}
+
+func (c evmChain) NewWatcher(db *gorm.DB, cfg *config.Config) (Watcher, error) {
+	w, err := ethwatcher.New(db, c.name, c.chainID, cfg.EVMRPCURLs[c.name], cfg.WatchersDebug)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
@@ -0,0 +1,144 @@
+package chains
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/shopspring/decimal"
+
+	"ptop/config"
+	"ptop/internal/models"
+)
+
+// HoldingsReader — сеть, которая умеет считать средства на адресах через
+// свой узел. Используется отчётом о резервах; сети без реализации в отчёте
+// считаются непроверенными.
+type HoldingsReader interface {
+	NewHoldings(ctx context.Context, cfg *config.Config) (Holdings, error)
+}
+
+// Holdings возвращает баланс актива сети на адресах платформы.
+type Holdings interface {
+	// Balance суммирует баланс нативной монеты или токена network.Contract
+	// на адресах addresses в единицах актива.
+	Balance(ctx context.Context, network models.AssetNetwork, addresses []string) (decimal.Decimal, error)
+}
+
+// NewHoldings создаёт чтение балансов сети name или возвращает ошибку, если
+// сеть его не поддерживает.
+func NewHoldings(ctx context.Context, name string, cfg *config.Config) (Holdings, error) {
+	c, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := c.(HoldingsReader)
+	if !ok {
+		return nil, fmt.Errorf("%s holdings are not supported", name)
+	}
+	return r.NewHoldings(ctx, cfg)
+}
+
+func (c utxoChain) NewHoldings(ctx context.Context, cfg *config.Config) (Holdings, error) {
+	node := cfg.UTXONodes[c.name]
+	if node.Host == "" {
+		return nil, fmt.Errorf("%s rpc host required", c.name)
+	}
+	client, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         node.Host,
+		User:         node.User,
+		Pass:         node.Pass,
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return utxoHoldings{client: client}, nil
+}
+
+// utxoHoldings считает непотраченные выходы адресов через scantxoutset:
+// адреса платформы не обязаны быть импортированы в кошелёк узла.
+type utxoHoldings struct {
+	client *rpcclient.Client
+}
+
+func (h utxoHoldings) Balance(ctx context.Context, network models.AssetNetwork, addresses []string) (decimal.Decimal, error) {
+	if len(addresses) == 0 {
+		return decimal.Zero, nil
+	}
+	descs := make([]map[string]string, 0, len(addresses))
+	for _, a := range addresses {
+		descs = append(descs, map[string]string{"desc": "addr(" + a + ")"})
+	}
+	action, _ := json.Marshal("start")
+	objects, _ := json.Marshal(descs)
+	res, err := h.client.RawRequest("scantxoutset", []json.RawMessage{action, objects})
+	if err != nil {
+		return decimal.Zero, err
+	}
+	var scan struct {
+		Success     bool        `json:"success"`
+		TotalAmount json.Number `json:"total_amount"`
+	}
+	if err := json.Unmarshal(res, &scan); err != nil {
+		return decimal.Zero, err
+	}
+	if !scan.Success {
+		return decimal.Zero, errors.New("scantxoutset failed")
+	}
+	return decimal.NewFromString(scan.TotalAmount.String())
+}
+
+func (c evmChain) NewHoldings(ctx context.Context, cfg *config.Config) (Holdings, error) {
+	url := cfg.EVMRPCURLs[c.name]
+	if url == "" {
+		return nil, fmt.Errorf("%s rpc url required", c.name)
+	}
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return evmHoldings{client: client}, nil
+}
+
+// evmHoldings читает балансы нативной монеты и токенов ERC20 (balanceOf).
+type evmHoldings struct {
+	client *ethclient.Client
+}
+
+// balanceOfSelector — первые 4 байта keccak256("balanceOf(address)").
+var balanceOfSelector = []byte{0x70, 0xa0, 0x82, 0x31}
+
+func (h evmHoldings) Balance(ctx context.Context, network models.AssetNetwork, addresses []string) (decimal.Decimal, error) {
+	total := new(big.Int)
+	for _, a := range addresses {
+		addr := common.HexToAddress(a)
+		if network.Contract == "" {
+			wei, err := h.client.BalanceAt(ctx, addr, nil)
+			if err != nil {
+				return decimal.Zero, err
+			}
+			total.Add(total, wei)
+			continue
+		}
+		contract := common.HexToAddress(network.Contract)
+		data := append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(addr.Bytes(), 32)...)
+		res, err := h.client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total.Add(total, new(big.Int).SetBytes(res))
+	}
+	decimals := network.Decimals
+	if network.Contract == "" {
+		decimals = 18
+	}
+	return decimal.NewFromBigInt(total, -decimals), nil
+}
@@ -0,0 +1,135 @@
+package chains
+
+import (
+	"fmt"
+
+	"ptop/internal/btcwatcher"
+)
+
+// Режимы сетей. В тестовых режимах модули сетей используют префиксы адресов,
+// chain ID и обозреватели тестовых сетей, чтобы staging работал на том же
+// коде, что и боевой контур.
+const (
+	ModeMainnet = "mainnet"
+	ModeTestnet = "testnet"
+	ModeSignet  = "signet"
+	ModeRegtest = "regtest"
+)
+
+// mode — текущий режим сетей, меняется под registry.
+var mode = ModeMainnet
+
+// evmNetwork — chain ID и обозреватель EVM-сети в одном режиме.
+type evmNetwork struct {
+	chainID  uint64
+	explorer string
+}
+
+// evmNetworks задаёт EVM-сети по режимам. Signet использует тестовые сети
+// (Sepolia и аналоги); в regtest chain ID локального узла не сверяется.
+var evmNetworks = map[string]map[string]evmNetwork{
+	ModeMainnet: {
+		ChainETH:  {1, "https://etherscan.io/tx/"},
+		ChainARB:  {42161, "https://arbiscan.io/tx/"},
+		ChainOP:   {10, "https://optimistic.etherscan.io/tx/"},
+		ChainBASE: {8453, "https://basescan.org/tx/"},
+		ChainPOL:  {137, "https://polygonscan.com/tx/"},
+		ChainBSC:  {56, "https://bscscan.com/tx/"},
+	},
+	ModeTestnet: {
+		ChainETH:  {11155111, "https://sepolia.etherscan.io/tx/"},
+		ChainARB:  {421614, "https://sepolia.arbiscan.io/tx/"},
+		ChainOP:   {11155420, "https://sepolia-optimism.etherscan.io/tx/"},
+		ChainBASE: {84532, "https://sepolia.basescan.org/tx/"},
+		ChainPOL:  {80002, "https://amoy.polygonscan.com/tx/"},
+		ChainBSC:  {97, "https://testnet.bscscan.com/tx/"},
+	},
+}
+
+// utxoExplorers задаёт обозреватели сетей Bitcoin и его форков по режимам.
+var utxoExplorers = map[string]map[string]string{
+	ModeMainnet: {
+		ChainBTC:  "https://mempool.space/tx/",
+		ChainLTC:  "https://litecoinspace.org/tx/",
+		ChainDOGE: "https://blockchair.com/dogecoin/transaction/",
+		ChainBCH:  "https://blockchair.com/bitcoin-cash/transaction/",
+	},
+	ModeTestnet: {
+		ChainBTC:  "https://mempool.space/testnet/tx/",
+		ChainLTC:  "https://litecoinspace.org/testnet/tx/",
+		ChainDOGE: "https://sochain.com/tx/DOGETEST/",
+		ChainBCH:  "https://tbch.loping.net/tx/",
+	},
+	ModeSignet: {
+		ChainBTC:  "https://mempool.space/signet/tx/",
+		ChainLTC:  "https://litecoinspace.org/testnet/tx/",
+		ChainDOGE: "https://sochain.com/tx/DOGETEST/",
+		ChainBCH:  "https://tbch.loping.net/tx/",
+	},
+}
+
+// defaultChains собирает модули сетей для режима m. Обозреватели есть не у
+// всех режимов: в regtest ссылки на транзакции не формируются.
+func defaultChains(m string) (map[string]Chain, error) {
+	evm := evmNetworks[m]
+	if m == ModeSignet {
+		evm = evmNetworks[ModeTestnet]
+	}
+	res := make(map[string]Chain)
+	for _, name := range []string{ChainBTC, ChainLTC, ChainDOGE, ChainBCH} {
+		params, err := btcwatcher.Params(name, m)
+		if err != nil {
+			return nil, err
+		}
+		res[name] = utxoChain{name: name, params: params, explorer: utxoExplorers[m][name]}
+	}
+	for _, name := range []string{ChainETH, ChainARB, ChainOP, ChainBASE, ChainPOL, ChainBSC} {
+		res[name] = evmChain{name: name, chainID: evm[name].chainID, explorer: evm[name].explorer}
+	}
+	switch m {
+	case ModeMainnet:
+		res[ChainSOL] = solChain{explorer: "https://solscan.io/tx/"}
+		res[ChainTRON] = tronChain{explorer: "https://tronscan.org/#/transaction/"}
+		res[ChainXMR] = xmrChain{prefixes: "48", explorer: "https://xmrchain.net/tx/"}
+	case ModeTestnet, ModeSignet:
+		res[ChainSOL] = solChain{explorer: "https://solscan.io/tx/", cluster: "devnet"}
+		res[ChainTRON] = tronChain{explorer: "https://nile.tronscan.org/#/transaction/"}
+		// Monero: адреса stagenet (5, 7) и testnet (9, A, B)
+		res[ChainXMR] = xmrChain{prefixes: "579AB", explorer: "https://stagenet.xmrchain.net/tx/"}
+	default:
+		res[ChainSOL] = solChain{}
+		res[ChainTRON] = tronChain{}
+		// monerod --regtest использует адреса основной сети
+		res[ChainXMR] = xmrChain{prefixes: "48"}
+	}
+	return res, nil
+}
+
+// Mode возвращает текущий режим сетей.
+func Mode() string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return mode
+}
+
+// SetMode переключает модули сетей по умолчанию на режим m: mainnet,
+// testnet, signet или regtest. Вызывается при старте до создания адресов и
+// наблюдателей; модули, добавленные через Register, сохраняются.
+func SetMode(m string) error {
+	switch m {
+	case ModeMainnet, ModeTestnet, ModeSignet, ModeRegtest:
+	default:
+		return fmt.Errorf("unknown network mode %q", m)
+	}
+	chains, err := defaultChains(m)
+	if err != nil {
+		return err
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	for name, c := range chains {
+		registry.m[name] = c
+	}
+	mode = m
+	return nil
+}
@@ -0,0 +1,73 @@
+package chains
+
+import (
+	"errors"
+	"fmt"
+
+	solana "github.com/gagliardetto/solana-go"
+	bip39 "github.com/tyler-smith/go-bip39"
+	ed25519hd "github.com/wealdtech/go-ed25519hd"
+	"gorm.io/gorm"
+
+	"ptop/config"
+	"ptop/internal/models"
+	"ptop/internal/solwatcher"
+)
+
+// solChain — Solana. cluster добавляется к ссылке обозревателя для
+// devnet и testnet.
+type solChain struct {
+	explorer string
+	cluster  string
+}
+
+func (solChain) DeriveAddress(asset models.Asset, network models.AssetNetwork, clientID string, index uint32) (string, uint32, error) {
+	if asset.Xpub == "" {
+		return "", 0, errors.New("no seed")
+	}
+	seed := bip39.NewSeed(asset.Xpub, "")
+	// ed25519hd допускает не больше трёх явно усиленных элементов и сам
+	// усиливает остальные.
+	path := fmt.Sprintf("m/44'/501'/0'/0/%d", index)
+	pub, _, err := ed25519hd.Keys(seed, path)
+	if err != nil {
+		return "", 0, err
+	}
+	pubKey := solana.PublicKeyFromBytes(pub)
+	// Нативный SOL принимается на сам адрес, токены SPL — на ATA для mint.
+	if network.Contract == "" {
+		return pubKey.String(), index, nil
+	}
+	mint, err := solana.PublicKeyFromBase58(network.Contract)
+	if err != nil {
+		return "", 0, fmt.Errorf("spl mint: %w", err)
+	}
+	ata, _, err := solana.FindAssociatedTokenAddress(pubKey, mint)
+	if err != nil {
+		return "", 0, err
+	}
+	return ata.String(), index, nil
+}
+
+func (solChain) ValidateAddress(address string) error {
+	_, err := solana.PublicKeyFromBase58(address)
+	return err
+}
+
+func (c solChain) ExplorerURL(txID string) string {
+	if c.explorer == "" {
+		return ""
+	}
+	if c.cluster != "" {
+		return c.explorer + txID + "?cluster=" + c.cluster
+	}
+	return c.explorer + txID
+}
+
+func (solChain) NewWatcher(db *gorm.DB, cfg *config.Config) (Watcher, error) {
+	w, err := solwatcher.New(db, cfg.SolRPCURL, cfg.WatchersDebug)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
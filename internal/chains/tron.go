@@ -0,0 +1,63 @@
+package chains
+
+import (
+	"errors"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+
+	"ptop/config"
+	"ptop/internal/models"
+	"ptop/internal/tronwatcher"
+)
+
+type tronChain struct {
+	explorer string
+}
+
+// DeriveAddress выводит адрес TRON из xpub так же, как адрес Ethereum, и
+// кодирует его в base58check с префиксом 0x41.
+func (tronChain) DeriveAddress(asset models.Asset, network models.AssetNetwork, clientID string, index uint32) (string, uint32, error) {
+	if asset.Xpub == "" {
+		return "", 0, errors.New("no xpub")
+	}
+	key, err := hdkeychain.NewKeyFromString(asset.Xpub)
+	if err != nil {
+		return "", 0, err
+	}
+	change, err := key.Child(0)
+	if err != nil {
+		return "", 0, err
+	}
+	child, err := change.Child(index)
+	if err != nil {
+		return "", 0, err
+	}
+	pk, err := child.ECPubKey()
+	if err != nil {
+		return "", 0, err
+	}
+	hash := crypto.Keccak256(pk.SerializeUncompressed()[1:])
+	return tronwatcher.EncodeAddress(hash[12:]), index, nil
+}
+
+func (tronChain) ValidateAddress(address string) error {
+	_, err := tronwatcher.DecodeAddress(address)
+	return err
+}
+
+func (c tronChain) ExplorerURL(txID string) string {
+	if c.explorer == "" {
+		return ""
+	}
+	return c.explorer + txID
+}
+
+func (tronChain) NewWatcher(db *gorm.DB, cfg *config.Config) (Watcher, error) {
+	w, err := tronwatcher.New(db, cfg.TronAPIURL, cfg.TronAPIKey, cfg.TronPollInterval, cfg.WatchersDebug)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
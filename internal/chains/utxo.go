@@ -0,0 +1,114 @@
+package chains
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"gorm.io/gorm"
+
+	"ptop/config"
+	"ptop/internal/btcwatcher"
+	"ptop/internal/models"
+)
+
+// utxoChain — сеть Bitcoin или его форка. Сети отличаются префиксами
+// адресов, обозревателем и узлом JSON-RPC.
+type utxoChain struct {
+	name     string
+	params   *chaincfg.Params
+	explorer string
+}
+
+// DeriveAddress выводит адрес типа addressType(asset). Для legacy-адресов
+// ключ актива — ключ цепочки, индекс берётся от него напрямую; для BIP84 и
+// BIP86 — ключ аккаунта m/84'/coin'/0' или m/86'/coin'/0', и адреса выдаются
+// во внешней цепочке 0.
+func (c utxoChain) DeriveAddress(asset models.Asset, network models.AssetNetwork, clientID string, index uint32) (string, uint32, error) {
+	if asset.Xpub == "" {
+		return "", 0, errors.New("no xpub")
+	}
+	key, err := hdkeychain.NewKeyFromString(asset.Xpub)
+	if err != nil {
+		return "", 0, err
+	}
+	typ := addressType(asset)
+	if typ == models.AddressTypeP2PKH {
+		child, err := key.Child(index)
+		if err != nil {
+			return "", 0, err
+		}
+		addr, err := child.Address(c.params)
+		if err != nil {
+			return "", 0, err
+		}
+		return addr.EncodeAddress(), index, nil
+	}
+	if typ != models.AddressTypeP2WPKH && typ != models.AddressTypeP2TR {
+		return "", 0, fmt.Errorf("unknown address type %q", typ)
+	}
+	if c.params.Bech32HRPSegwit == "" {
+		return "", 0, fmt.Errorf("%s does not support segwit", c.name)
+	}
+	external, err := key.Child(0)
+	if err != nil {
+		return "", 0, err
+	}
+	child, err := external.Child(index)
+	if err != nil {
+		return "", 0, err
+	}
+	pub, err := child.ECPubKey()
+	if err != nil {
+		return "", 0, err
+	}
+	if typ == models.AddressTypeP2TR {
+		addr, err := btcwatcher.TaprootAddress(btcwatcher.TaprootOutputKey(pub), c.params)
+		if err != nil {
+			return "", 0, err
+		}
+		return addr, index, nil
+	}
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pub.SerializeCompressed()), c.params)
+	if err != nil {
+		return "", 0, err
+	}
+	return addr.EncodeAddress(), index, nil
+}
+
+// addressType возвращает тип адресов актива: явно заданный, P2WPKH для
+// ключей zpub/vpub (SLIP-132) или P2PKH.
+func addressType(asset models.Asset) string {
+	if asset.AddressType != "" {
+		return asset.AddressType
+	}
+	if strings.HasPrefix(asset.Xpub, "zpub") || strings.HasPrefix(asset.Xpub, "vpub") {
+		return models.AddressTypeP2WPKH
+	}
+	return models.AddressTypeP2PKH
+}
+
+// ValidateAddress принимает адреса всех типов сети: P2PKH, P2SH, P2WPKH,
+// P2WSH и P2TR.
+func (c utxoChain) ValidateAddress(address string) error {
+	return btcwatcher.ValidateAddress(address, c.params)
+}
+
+func (c utxoChain) ExplorerURL(txID string) string {
+	if c.explorer == "" {
+		return ""
+	}
+	return c.explorer + txID
+}
+
+func (c utxoChain) NewWatcher(db *gorm.DB, cfg *config.Config) (Watcher, error) {
+	node := cfg.UTXONodes[c.name]
+	w, err := btcwatcher.New(db, c.name, node.Host, node.User, node.Pass, c.params, cfg.WatchersDebug)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
@@ -0,0 +1,98 @@
+package chains
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"ptop/config"
+	"ptop/internal/models"
+	"ptop/internal/xmrwatcher"
+)
+
+// moneroAlphabet — алфавит base58 в кодировке Monero.
+const moneroAlphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// xmrChain — Monero. prefixes — допустимые первые символы адресов сети.
+type xmrChain struct {
+	prefixes string
+	explorer string
+}
+
+// DeriveAddress создаёт подадрес через monero-wallet-rpc. Индекс задаёт
+// кошелёк, поэтому переданный index не используется.
+func (xmrChain) DeriveAddress(asset models.Asset, network models.AssetNetwork, clientID string, index uint32) (string, uint32, error) {
+	rpcURL := os.Getenv("MONERO_RPC_URL")
+	if rpcURL == "" {
+		rpcURL = "http://localhost:18083/json_rpc"
+	}
+	payload := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      "0",
+		"method":  "create_address",
+		"params": map[string]any{
+			"account_index": 0,
+			"label":         clientID,
+		},
+	}
+	body, _ := json.Marshal(payload)
+	resp, err := http.Post(rpcURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Result struct {
+			Address      string `json:"address"`
+			AddressIndex uint32 `json:"address_index"`
+		} `json:"result"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", 0, err
+	}
+	if res.Error != nil {
+		return "", 0, errors.New(res.Error.Message)
+	}
+	return res.Result.Address, res.Result.AddressIndex, nil
+}
+
+// ValidateAddress проверяет формат основного адреса, подадреса или
+// интегрированного адреса Monero.
+func (c xmrChain) ValidateAddress(address string) error {
+	if len(address) != 95 && len(address) != 106 {
+		return fmt.Errorf("invalid xmr address length %d", len(address))
+	}
+	if !strings.ContainsRune(c.prefixes, rune(address[0])) {
+		return fmt.Errorf("invalid xmr address prefix %q", address[0])
+	}
+	for _, r := range address {
+		if !strings.ContainsRune(moneroAlphabet, r) {
+			return fmt.Errorf("invalid xmr address character %q", r)
+		}
+	}
+	return nil
+}
+
+func (c xmrChain) ExplorerURL(txID string) string {
+	if c.explorer == "" {
+		return ""
+	}
+	return c.explorer + txID
+}
+
+func (xmrChain) NewWatcher(db *gorm.DB, cfg *config.Config) (Watcher, error) {
+	w, err := xmrwatcher.New(db, cfg.MoneroRPCURL, cfg.MoneroPollInterval, cfg.WatchersDebug)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
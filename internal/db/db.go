@@ -13,21 +13,54 @@ func NewDB(dsn string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
 	}
 
+	// Раньше токены хранились открытым текстом в колонке token. Такие токены
+	// удаляются вместе с колонкой, клиентам нужно войти заново
+	if db.Migrator().HasColumn(&models.Token{}, "token") {
+		if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.Token{}).Error; err != nil {
+			return nil, fmt.Errorf("delete plaintext tokens failed: %w", err)
+		}
+		if err := db.Migrator().DropColumn(&models.Token{}, "token"); err != nil {
+			return nil, fmt.Errorf("drop token column failed: %w", err)
+		}
+	}
+
+	if err := DedupWallets(db); err != nil {
+		return nil, fmt.Errorf("dedup wallets failed: %w", err)
+	}
+
 	if err := db.AutoMigrate(
 		&models.Client{},
 		&models.Token{},
+		&models.Session{},
+		&models.AuthFailure{},
+		&models.BackupCode{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnChallenge{},
+		&models.StepUpToken{},
+		&models.StepUpPolicy{},
+		&models.StepUpEvent{},
+		&models.APIKey{},
 		&models.Country{},
 		&models.PaymentMethod{},
 		&models.ClientPaymentMethod{},
 		&models.Asset{},
+		&models.AssetNetwork{},
 		&models.Offer{},
 		&models.Wallet{},
+		&models.DerivationCounter{},
+		&models.XMRSubaddress{},
 		&models.TransactionIn{},
+		&models.WatcherCursor{},
+		&models.Sweep{},
 		&models.TransactionOut{},
 		&models.TransactionInternal{},
 		&models.Balance{},
 		&models.Escrow{},
 		&models.Notification{},
+		&models.ReserveSnapshot{},
+		&models.ReserveLeaf{},
+		&models.Reconciliation{},
+		&models.BalanceMismatch{},
 	// &models.Product{}, и т.д.
 	); err != nil {
 		return nil, fmt.Errorf("auto migrate failed: %w", err)
@@ -42,5 +75,30 @@ func NewDB(dsn string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("auto migrate failed: %w", err)
 	}
 
+	if err := BackfillAssetChains(db); err != nil {
+		return nil, fmt.Errorf("backfill asset chains failed: %w", err)
+	}
+	if err := BackfillAssetNetworks(db); err != nil {
+		return nil, fmt.Errorf("backfill asset networks failed: %w", err)
+	}
+	// Уникальность активного кошелька учитывает сеть и распространяется только
+	// на включённые кошельки, чтобы после ротаций хранилась история адресов
+	for _, name := range []string{"idx_wallet_client_asset_active", "idx_wallet_client_asset_network_active"} {
+		if db.Migrator().HasIndex(&models.Wallet{}, name) {
+			if err := db.Migrator().DropIndex(&models.Wallet{}, name); err != nil {
+				return nil, fmt.Errorf("drop wallet index failed: %w", err)
+			}
+		}
+	}
+	if err := BackfillWalletNetworks(db); err != nil {
+		return nil, fmt.Errorf("backfill wallet networks failed: %w", err)
+	}
+	if err := BackfillXMRSubaddresses(db); err != nil {
+		return nil, fmt.Errorf("backfill xmr subaddresses failed: %w", err)
+	}
+	if err := BackfillDepositTxKeys(db); err != nil {
+		return nil, fmt.Errorf("backfill deposit tx keys failed: %w", err)
+	}
+
 	return db, nil
 }
@@ -1,9 +1,15 @@
 package db
 
 import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
 	"github.com/biter777/countries"
 	"gorm.io/gorm"
 
+	"ptop/internal/chains"
 	"ptop/internal/models"
 )
 
@@ -399,11 +405,348 @@ func SeedAssets(db *gorm.DB) error {
 		{Name: "GBP", Type: models.AssetTypeFiat, IsConvertible: true, IsActive: true},
 		{Name: "PLN", Type: models.AssetTypeFiat, IsConvertible: true, IsActive: true},
 		// crypto
-		{Name: "BTC", Type: models.AssetTypeCrypto, IsActive: true},
-		{Name: "ETH", Type: models.AssetTypeCrypto, IsActive: true},
-		{Name: "USDT", Type: models.AssetTypeCrypto, IsActive: true},
-		{Name: "USDC", Type: models.AssetTypeCrypto, IsActive: true},
-		{Name: "XMR", Type: models.AssetTypeCrypto, IsActive: true},
+		{Name: "BTC", Type: models.AssetTypeCrypto, Chain: "btc", IsActive: true},
+		{Name: "ETH", Type: models.AssetTypeCrypto, Chain: "eth", IsActive: true},
+		{Name: "USDT", Type: models.AssetTypeCrypto, Chain: "eth", IsActive: true},
+		{Name: "USDC", Type: models.AssetTypeCrypto, Chain: "sol", IsActive: true},
+		{Name: "XMR", Type: models.AssetTypeCrypto, Chain: "xmr", IsActive: true},
+	}
+	if err := db.Create(&assets).Error; err != nil {
+		return err
+	}
+	if err := BackfillAssetNetworks(db); err != nil {
+		return err
+	}
+	// USDT принимается также в TRON и Solana — там, где для режима известен
+	// контракт
+	mode := chains.Mode()
+	for _, a := range assets {
+		if a.Name != "USDT" {
+			continue
+		}
+		for _, chain := range []string{"tron", "sol"} {
+			d, ok := findNetworkDefault(mode, a.Name, chain)
+			if !ok || d.contract == "" {
+				log.Printf("сеть %s актива %s не создана: нет контракта токена для режима %s", chain, a.Name, mode)
+				continue
+			}
+			network := models.AssetNetwork{AssetID: a.ID, Chain: chain, Contract: d.contract, Decimals: d.decimals, Confirmations: d.confirmations}
+			if err := db.Create(&network).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// assetChainPrefixes задаёт сеть для криптоактивов, созданных до появления
+// колонки assets.chain.
+var assetChainPrefixes = []struct {
+	prefix string
+	chain  string
+}{
+	{"BTC", "btc"},
+	{"ETH", "eth"},
+	{"USDT", "eth"},
+	{"USDC", "sol"},
+	{"SOL", "sol"},
+	{"XMR", "xmr"},
+}
+
+// BackfillAssetChains заполняет пустую сеть криптоактивов по префиксу имени.
+func BackfillAssetChains(db *gorm.DB) error {
+	for _, p := range assetChainPrefixes {
+		if err := db.Model(&models.Asset{}).
+			Where("type = ? AND (chain IS NULL OR chain = '') AND name LIKE ?", models.AssetTypeCrypto, p.prefix+"%").
+			Update("chain", p.chain).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// networkDefault — параметры сети по умолчанию для криптоактивов с префиксом
+// имени prefix.
+type networkDefault struct {
+	prefix        string
+	chain         string
+	contract      string
+	decimals      int32
+	confirmations int
+}
+
+// assetNetworkDefaults задаёт параметры сети для криптоактивов по префиксу
+// имени. Для активов без записи здесь берётся точность сети по умолчанию.
+var assetNetworkDefaults = []networkDefault{
+	{"BTC", "btc", "", 8, 1},
+	{"ETH", "eth", "", 18, 1},
+	{"USDT", "eth", "0xdAC17F958D2ee523a2206206994597C13D831ec7", 6, 1},
+	{"USDC", "sol", "EPjFWdd5AufqSSqeM2qXznJkBLFKe4MtSnTwWehAw1v", 6, 1},
+	{"USDT", "tron", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", 6, 20},
+	{"USDT", "sol", "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB", 6, 1},
+	{"XMR", "xmr", "", 12, 10},
+}
+
+// testnetAssetNetworkDefaults — параметры сетей в режимах testnet и signet:
+// контракты токенов в Sepolia, Solana devnet и TRON Nile. Токен без
+// известного тестового контракта не получает запись по умолчанию и
+// настраивается вручную.
+var testnetAssetNetworkDefaults = []networkDefault{
+	{"BTC", "btc", "", 8, 1},
+	{"ETH", "eth", "", 18, 1},
+	{"USDC", "sol", "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU", 6, 1},
+	{"USDT", "tron", "TXYZopYRdj2D9XRtbG411XZZ3kM5VkAeBf", 6, 20},
+	{"XMR", "xmr", "", 12, 10},
+}
+
+// networkDefaults возвращает параметры сетей по умолчанию для режима mode.
+// В regtest контракты токенов развёртываются локально, поэтому по умолчанию
+// настраиваются только нативные монеты.
+func networkDefaults(mode string) []networkDefault {
+	switch mode {
+	case chains.ModeMainnet:
+		return assetNetworkDefaults
+	case chains.ModeTestnet, chains.ModeSignet:
+		return testnetAssetNetworkDefaults
+	}
+	var natives []networkDefault
+	for _, d := range assetNetworkDefaults {
+		if d.contract == "" {
+			natives = append(natives, d)
+		}
+	}
+	return natives
+}
+
+// findNetworkDefault ищет параметры сети chain актива name для режима mode.
+func findNetworkDefault(mode, name, chain string) (networkDefault, bool) {
+	for _, d := range networkDefaults(mode) {
+		if d.chain == chain && strings.HasPrefix(name, d.prefix) {
+			return d, true
+		}
+	}
+	return networkDefault{}, false
+}
+
+// chainDecimals — точность нативной монеты сети.
+var chainDecimals = map[string]int32{
+	"btc":      8,
+	"ltc":      8,
+	"doge":     8,
+	"bch":      8,
+	"eth":      18,
+	"arbitrum": 18,
+	"optimism": 18,
+	"base":     18,
+	"polygon":  18,
+	"bsc":      18,
+	"sol":      9,
+	"tron":     6,
+	"xmr":      12,
+}
+
+// BackfillAssetNetworks создаёт параметры сети для криптоактивов, у которых
+// задана assets.chain, но нет записи в asset_networks. Адрес минта USDC в
+// Solana можно переопределить переменной USDC_MINT_ADDRESS. Контракты токенов
+// выбираются по режиму сетей (chains.Mode).
+func BackfillAssetNetworks(db *gorm.DB) error {
+	mode := chains.Mode()
+	var assets []models.Asset
+	if err := db.Where("type = ? AND chain IS NOT NULL AND chain <> ''", models.AssetTypeCrypto).Find(&assets).Error; err != nil {
+		return err
+	}
+	for _, a := range assets {
+		var count int64
+		if err := db.Model(&models.AssetNetwork{}).Where("asset_id = ? AND chain = ?", a.ID, a.Chain).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		network := models.AssetNetwork{AssetID: a.ID, Chain: a.Chain, Decimals: chainDecimals[a.Chain], Confirmations: 1}
+		d, found := findNetworkDefault(mode, a.Name, a.Chain)
+		if found {
+			network.Contract = d.contract
+			network.Decimals = d.decimals
+			network.Confirmations = d.confirmations
+		}
+		if a.Chain == "sol" && strings.HasPrefix(a.Name, "USDC") {
+			if mint := os.Getenv("USDC_MINT_ADDRESS"); mint != "" {
+				network.Contract = mint
+				found = true
+			}
+		}
+		// Без контракта токен принимался бы как нативная монета сети
+		if !found && isToken(a) {
+			log.Printf("сеть %s актива %s не создана: нет контракта токена для режима %s", a.Chain, a.Name, mode)
+			continue
+		}
+		if err := db.Create(&network).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isToken сообщает, принимается ли актив в основной сети как токен.
+func isToken(a models.Asset) bool {
+	for _, d := range assetNetworkDefaults {
+		if d.chain == a.Chain && strings.HasPrefix(a.Name, d.prefix) {
+			return d.contract != ""
+		}
+	}
+	return false
+}
+
+// BackfillWalletNetworks проставляет сеть кошелькам и депозитам, созданным до
+// появления нескольких сетей у актива: кошелёк относится к основной сети
+// актива, депозит — к сети своего кошелька.
+func BackfillWalletNetworks(db *gorm.DB) error {
+	if err := db.Exec(`UPDATE wallets SET network = (SELECT chain FROM assets WHERE assets.id = wallets.asset_id)
+		WHERE (network IS NULL OR network = '') AND EXISTS (SELECT 1 FROM assets WHERE assets.id = wallets.asset_id AND assets.chain <> '')`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`UPDATE transaction_ins SET network = (SELECT network FROM wallets WHERE wallets.id = transaction_ins.wallet_id)
+		WHERE (network IS NULL OR network = '') AND EXISTS (SELECT 1 FROM wallets WHERE wallets.id = transaction_ins.wallet_id AND wallets.network <> '')`).Error
+}
+
+// BackfillXMRSubaddresses создаёт записи подадресов для кошельков Monero,
+// созданных до появления таблицы xmr_subaddresses. Такие кошельки хранят
+// индекс подадреса аккаунта 0 в derivation_index.
+func BackfillXMRSubaddresses(db *gorm.DB) error {
+	var wallets []models.Wallet
+	if err := db.Where("network = ? AND NOT EXISTS (SELECT 1 FROM xmr_subaddresses WHERE xmr_subaddresses.wallet_id = wallets.id)", "xmr").
+		Find(&wallets).Error; err != nil {
+		return err
+	}
+	for _, w := range wallets {
+		sub := models.XMRSubaddress{WalletID: w.ID, AddressIndex: w.DerivationIndex, Address: w.Value}
+		if err := db.Create(&sub).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillDepositTxKeys переносит хэш транзакции и индекс лога из data в
+// колонки tx_hash и log_index депозитов, сохранённых до появления
+// уникального индекса. Ключ получает только самый ранний депозит; повторные
+// зачисления остаются без ключа и выводятся в лог для ручной проверки.
+func BackfillDepositTxKeys(db *gorm.DB) error {
+	idx := func(table string) string {
+		return fmt.Sprintf("COALESCE(CAST(%s.data ->> 'log_index' AS INTEGER), -1)", table)
+	}
+	query := fmt.Sprintf(`UPDATE transaction_ins SET tx_hash = data ->> 'tx_hash', log_index = %[1]s
+		WHERE tx_hash IS NULL AND data ->> 'tx_hash' IS NOT NULL
+		AND id = (SELECT d.id FROM transaction_ins d WHERE d.network = transaction_ins.network
+			AND d.data ->> 'tx_hash' = transaction_ins.data ->> 'tx_hash' AND %[2]s = %[1]s
+			ORDER BY d.created_at, d.id LIMIT 1)
+		AND NOT EXISTS (SELECT 1 FROM transaction_ins n WHERE n.network = transaction_ins.network
+			AND n.tx_hash = transaction_ins.data ->> 'tx_hash' AND n.log_index = %[1]s)`, idx("transaction_ins"), idx("d"))
+	err := db.Exec(query).Error
+	if err != nil {
+		return err
+	}
+	var dups []string
+	if err := db.Model(&models.TransactionIn{}).
+		Where("tx_hash IS NULL AND data ->> 'tx_hash' IS NOT NULL").
+		Pluck("id", &dups).Error; err != nil {
+		return err
+	}
+	if len(dups) > 0 {
+		log.Printf("найдены повторные зачисления депозитов (%d): %s", len(dups), strings.Join(dups, ", "))
+	}
+	return nil
+}
+
+// DedupWallets убирает повторы кошельков перед созданием уникальных индексов
+// idx_wallet_asset_network_index и idx_wallet_asset_network_value: до них
+// параллельные запросы могли получить один индекс деривации или адрес. В
+// каждой группе повторов остаётся кошелёк с депозитами, а если их нет —
+// самый ранний; остальные удаляются и выводятся в лог. Если депозиты есть у
+// нескольких кошельков группы, миграция останавливается для ручного разбора.
+func DedupWallets(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&models.Wallet{}) ||
+		(m.HasIndex(&models.Wallet{}, "idx_wallet_asset_network_index") && m.HasIndex(&models.Wallet{}, "idx_wallet_asset_network_value")) {
+		return nil
+	}
+	network := "''"
+	if m.HasColumn(&models.Wallet{}, "network") {
+		network = "COALESCE(network, '')"
+	}
+	used := "false"
+	if m.HasTable(&models.TransactionIn{}) {
+		used = "EXISTS (SELECT 1 FROM transaction_ins WHERE transaction_ins.wallet_id = wallets.id)"
+	}
+	type walletRow struct {
+		ID              string
+		AssetID         string
+		Network         string
+		Value           string
+		DerivationIndex uint32
+		Used            bool
+	}
+	var rows []walletRow
+	if err := db.Table("wallets").
+		Select(fmt.Sprintf("id, asset_id, %s AS network, value, derivation_index, %s AS used", network, used)).
+		Order("created_at, id").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	removed := map[string]bool{}
+	var conflicts []string
+	dedup := func(key func(walletRow) string) {
+		kept := map[string]walletRow{}
+		for _, w := range rows {
+			if removed[w.ID] {
+				continue
+			}
+			k := key(w)
+			prev, ok := kept[k]
+			switch {
+			case !ok:
+				kept[k] = w
+			case !w.Used:
+				removed[w.ID] = true
+			case !prev.Used:
+				removed[prev.ID] = true
+				kept[k] = w
+			default:
+				conflicts = append(conflicts, prev.ID+"/"+w.ID)
+			}
+		}
+	}
+	dedup(func(w walletRow) string { return fmt.Sprintf("%s|%s|%d", w.AssetID, w.Network, w.DerivationIndex) })
+	dedup(func(w walletRow) string { return w.AssetID + "|" + w.Network + "|" + w.Value })
+	if len(conflicts) > 0 {
+		return fmt.Errorf("wallets with deposits share derivation index or address: %s", strings.Join(conflicts, ", "))
 	}
-	return db.Create(&assets).Error
+	if len(removed) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(removed))
+	for _, w := range rows {
+		if removed[w.ID] {
+			ids = append(ids, w.ID)
+		}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if tx.Migrator().HasTable(&models.XMRSubaddress{}) {
+			if err := tx.Where("wallet_id IN ?", ids).Delete(&models.XMRSubaddress{}).Error; err != nil {
+				return err
+			}
+		}
+		if tx.Migrator().HasColumn(&models.Wallet{}, "previous_id") {
+			if err := tx.Table("wallets").Where("previous_id IN ?", ids).Update("previous_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&models.Wallet{}).Error; err != nil {
+			return err
+		}
+		log.Printf("удалены повторные кошельки без депозитов (%d): %s", len(ids), strings.Join(ids, ", "))
+		return nil
+	})
 }
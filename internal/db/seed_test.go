@@ -2,11 +2,14 @@ package db
 
 import (
 	"testing"
+	"time"
 
 	"github.com/biter777/countries"
+	"gorm.io/datatypes"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"ptop/internal/chains"
 	"ptop/internal/models"
 )
 
@@ -43,7 +46,7 @@ func TestSeedPaymentMethodsAndAssets(t *testing.T) {
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
-	if err := gdb.AutoMigrate(&models.Country{}, &models.PaymentMethod{}, &models.Asset{}); err != nil {
+	if err := gdb.AutoMigrate(&models.Country{}, &models.PaymentMethod{}, &models.Asset{}, &models.AssetNetwork{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	if err := SeedCountries(gdb); err != nil {
@@ -69,12 +72,26 @@ func TestSeedPaymentMethodsAndAssets(t *testing.T) {
 	names := make(map[string]bool)
 	for _, a := range assets {
 		names[a.Name] = true
+		if a.Type == models.AssetTypeCrypto && a.Chain == "" {
+			t.Fatalf("asset %s has no chain", a.Name)
+		}
 	}
 	for _, n := range want {
 		if !names[n] {
 			t.Fatalf("missing asset %s", n)
 		}
 	}
+	var usdt models.Asset
+	if err := gdb.Preload("Networks").Where("name = ?", "USDT").First(&usdt).Error; err != nil {
+		t.Fatalf("usdt: %v", err)
+	}
+	chainsByName := map[string]bool{}
+	for _, n := range usdt.Networks {
+		chainsByName[n.Chain] = true
+	}
+	if len(usdt.Networks) != 3 || !chainsByName["eth"] || !chainsByName["tron"] || !chainsByName["sol"] {
+		t.Fatalf("usdt networks %+v", usdt.Networks)
+	}
 
 	var methods []models.PaymentMethod
 	flexepinCountries := append([]string{"Canada", "Australia"}, regionCountries["EU"]...)
@@ -143,3 +160,291 @@ func TestSeedPaymentMethodsAndAssets(t *testing.T) {
 		t.Fatalf("expected no duplicates after reseed")
 	}
 }
+
+func TestBackfillAssetChains(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:backfill_chains?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Asset{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	btc := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto}
+	usdt := models.Asset{Name: "USDT", Type: models.AssetTypeCrypto}
+	custom := models.Asset{Name: "BTC_custom", Type: models.AssetTypeCrypto, Chain: "ltc"}
+	usd := models.Asset{Name: "USD", Type: models.AssetTypeFiat}
+	for _, a := range []*models.Asset{&btc, &usdt, &custom, &usd} {
+		if err := gdb.Create(a).Error; err != nil {
+			t.Fatalf("create asset: %v", err)
+		}
+	}
+	if err := BackfillAssetChains(gdb); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	want := map[string]string{btc.ID: "btc", usdt.ID: "eth", custom.ID: "ltc", usd.ID: ""}
+	for id, chain := range want {
+		var a models.Asset
+		gdb.First(&a, "id = ?", id)
+		if a.Chain != chain {
+			t.Fatalf("asset %s: expected chain %q, got %q", a.Name, chain, a.Chain)
+		}
+	}
+}
+
+func TestBackfillAssetNetworks(t *testing.T) {
+	t.Setenv("USDC_MINT_ADDRESS", "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU")
+	gdb, err := gorm.Open(sqlite.Open("file:backfill_networks?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Asset{}, &models.AssetNetwork{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	usdt := models.Asset{Name: "USDT", Type: models.AssetTypeCrypto, Chain: "eth"}
+	usdc := models.Asset{Name: "USDC", Type: models.AssetTypeCrypto, Chain: "sol"}
+	xmr := models.Asset{Name: "XMR", Type: models.AssetTypeCrypto, Chain: "xmr"}
+	sol := models.Asset{Name: "SOL", Type: models.AssetTypeCrypto, Chain: "sol"}
+	usd := models.Asset{Name: "USD", Type: models.AssetTypeFiat}
+	for _, a := range []*models.Asset{&usdt, &usdc, &xmr, &sol, &usd} {
+		if err := gdb.Create(a).Error; err != nil {
+			t.Fatalf("create asset: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := BackfillAssetNetworks(gdb); err != nil {
+			t.Fatalf("backfill: %v", err)
+		}
+	}
+	var count int64
+	gdb.Model(&models.AssetNetwork{}).Count(&count)
+	if count != 4 {
+		t.Fatalf("expected 4 networks, got %d", count)
+	}
+	want := map[string]models.AssetNetwork{
+		usdt.ID: {Contract: "0xdAC17F958D2ee523a2206206994597C13D831ec7", Decimals: 6, Confirmations: 1},
+		usdc.ID: {Contract: "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU", Decimals: 6, Confirmations: 1},
+		xmr.ID:  {Decimals: 12, Confirmations: 10},
+		sol.ID:  {Decimals: 9, Confirmations: 1},
+	}
+	for id, exp := range want {
+		var n models.AssetNetwork
+		if err := gdb.First(&n, "asset_id = ?", id).Error; err != nil {
+			t.Fatalf("network: %v", err)
+		}
+		if n.Contract != exp.Contract || n.Decimals != exp.Decimals || n.Confirmations != exp.Confirmations || !n.DepositEnabled {
+			t.Fatalf("unexpected network %+v", n)
+		}
+	}
+}
+
+func TestBackfillAssetNetworksTestnet(t *testing.T) {
+	if err := chains.SetMode(chains.ModeTestnet); err != nil {
+		t.Fatalf("mode: %v", err)
+	}
+	defer chains.SetMode(chains.ModeMainnet)
+	gdb, err := gorm.Open(sqlite.Open("file:backfill_networks_testnet?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Asset{}, &models.AssetNetwork{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	usdt := models.Asset{Name: "USDT", Type: models.AssetTypeCrypto, Chain: "eth"}
+	usdc := models.Asset{Name: "USDC", Type: models.AssetTypeCrypto, Chain: "sol"}
+	eth := models.Asset{Name: "ETH", Type: models.AssetTypeCrypto, Chain: "eth"}
+	for _, a := range []*models.Asset{&usdt, &usdc, &eth} {
+		gdb.Create(a)
+	}
+	if err := BackfillAssetNetworks(gdb); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	// у USDT в Sepolia нет известного контракта — сеть настраивается вручную
+	var count int64
+	gdb.Model(&models.AssetNetwork{}).Where("asset_id = ?", usdt.ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no network for testnet usdt")
+	}
+	var n models.AssetNetwork
+	gdb.First(&n, "asset_id = ?", usdc.ID)
+	if n.Contract != "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU" {
+		t.Fatalf("devnet mint %s", n.Contract)
+	}
+	var native models.AssetNetwork
+	if err := gdb.First(&native, "asset_id = ?", eth.ID).Error; err != nil || native.Contract != "" || native.Decimals != 18 {
+		t.Fatalf("eth network %+v: %v", native, err)
+	}
+}
+
+func TestSeedAssetsTestnet(t *testing.T) {
+	if err := chains.SetMode(chains.ModeTestnet); err != nil {
+		t.Fatalf("mode: %v", err)
+	}
+	defer chains.SetMode(chains.ModeMainnet)
+	gdb, err := gorm.Open(sqlite.Open("file:seed_assets_testnet?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Asset{}, &models.AssetNetwork{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := SeedAssets(gdb); err != nil {
+		t.Fatalf("seed assets: %v", err)
+	}
+	var usdt models.Asset
+	if err := gdb.Preload("Networks").First(&usdt, "name = ?", "USDT").Error; err != nil {
+		t.Fatalf("usdt: %v", err)
+	}
+	// В тестовом режиме только TRON Nile: для Sepolia и devnet контрактов USDT нет
+	if len(usdt.Networks) != 1 || usdt.Networks[0].Chain != "tron" || usdt.Networks[0].Contract != "TXYZopYRdj2D9XRtbG411XZZ3kM5VkAeBf" {
+		t.Fatalf("unexpected testnet usdt networks %+v", usdt.Networks)
+	}
+}
+
+func TestBackfillWalletNetworks(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:backfill_wallets?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Asset{}, &models.Wallet{}, &models.TransactionIn{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	asset := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, Chain: "btc"}
+	gdb.Create(&asset)
+	legacy := models.Wallet{ClientID: "c1", AssetID: asset.ID, Value: "a", IsEnabled: true}
+	other := models.Wallet{ClientID: "c2", AssetID: asset.ID, Network: "ltc", Value: "b", IsEnabled: true}
+	gdb.Create(&legacy)
+	gdb.Create(&other)
+	dep := models.TransactionIn{ClientID: "c1", WalletID: legacy.ID, AssetID: asset.ID, Status: models.TransactionInStatusConfirmed}
+	gdb.Create(&dep)
+	if err := BackfillWalletNetworks(gdb); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	gdb.First(&legacy, "id = ?", legacy.ID)
+	gdb.First(&other, "id = ?", other.ID)
+	gdb.First(&dep, "id = ?", dep.ID)
+	if legacy.Network != "btc" || other.Network != "ltc" || dep.Network != "btc" {
+		t.Fatalf("unexpected networks %q %q %q", legacy.Network, other.Network, dep.Network)
+	}
+}
+
+func TestBackfillXMRSubaddresses(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:backfill_xmr?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Wallet{}, &models.XMRSubaddress{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	xmr := models.Wallet{ClientID: "c1", AssetID: "a1", Network: "xmr", Value: "8abc", DerivationIndex: 5}
+	btc := models.Wallet{ClientID: "c1", AssetID: "a2", Network: "btc", Value: "1abc", DerivationIndex: 5}
+	gdb.Create(&xmr)
+	gdb.Create(&btc)
+	for i := 0; i < 2; i++ {
+		if err := BackfillXMRSubaddresses(gdb); err != nil {
+			t.Fatalf("backfill: %v", err)
+		}
+	}
+	var subs []models.XMRSubaddress
+	gdb.Find(&subs)
+	if len(subs) != 1 || subs[0].WalletID != xmr.ID || subs[0].AddressIndex != 5 || subs[0].AccountIndex != 0 {
+		t.Fatalf("unexpected subaddresses %+v", subs)
+	}
+}
+
+func TestBackfillDepositTxKeys(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:backfill_tx_keys?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.TransactionIn{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	deposit := func(network, data string, at time.Time) models.TransactionIn {
+		dep := models.TransactionIn{ClientID: "c1", WalletID: "w1", AssetID: "a1", Status: models.TransactionInStatusConfirmed,
+			Network: network, Data: datatypes.JSON(data), CreatedAt: at}
+		gdb.Create(&dep)
+		return dep
+	}
+	now := time.Now()
+	first := deposit("tron", `{"tx_hash":"tx1","log_index":0}`, now)
+	dup := deposit("tron", `{"tx_hash":"tx1","log_index":0}`, now.Add(time.Second))
+	native := deposit("eth", `{"tx_hash":"tx1"}`, now)
+	debug := deposit("eth", `{"debug":true}`, now)
+	if err := BackfillDepositTxKeys(gdb); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	for _, dep := range []*models.TransactionIn{&first, &dup, &native, &debug} {
+		gdb.First(dep, "id = ?", dep.ID)
+	}
+	if first.TxHash == nil || *first.TxHash != "tx1" || first.LogIndex != 0 {
+		t.Fatalf("unexpected first %v %d", first.TxHash, first.LogIndex)
+	}
+	if native.TxHash == nil || native.LogIndex != -1 {
+		t.Fatalf("unexpected native %v %d", native.TxHash, native.LogIndex)
+	}
+	if dup.TxHash != nil || debug.TxHash != nil {
+		t.Fatalf("duplicate and debug deposits must stay without key")
+	}
+	// Повторный запуск ничего не меняет
+	if err := BackfillDepositTxKeys(gdb); err != nil {
+		t.Fatalf("second backfill: %v", err)
+	}
+}
+
+func TestDedupWallets(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:dedup_wallets?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := gdb.AutoMigrate(&models.Wallet{}, &models.XMRSubaddress{}, &models.TransactionIn{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	// Состояние до появления уникальных индексов
+	for _, name := range []string{"idx_wallet_asset_network_index", "idx_wallet_asset_network_value"} {
+		if err := gdb.Migrator().DropIndex(&models.Wallet{}, name); err != nil {
+			t.Fatalf("drop index: %v", err)
+		}
+	}
+	wallet := func(client, value string, idx uint32, at time.Time) models.Wallet {
+		w := models.Wallet{ClientID: client, AssetID: "a1", Network: "xmr", Value: value, DerivationIndex: idx, IsEnabled: true, CreatedAt: at}
+		gdb.Create(&w)
+		return w
+	}
+	now := time.Now()
+	first := wallet("c1", "addr0", 0, now)
+	funded := wallet("c2", "addr0", 0, now.Add(time.Second))
+	sameValue := wallet("c3", "addr0", 1, now.Add(2*time.Second))
+	unique := wallet("c4", "addr2", 2, now)
+	rotated := models.Wallet{ClientID: "c6", AssetID: "a1", Network: "xmr", Value: "addr3", DerivationIndex: 3, PreviousID: &first.ID}
+	gdb.Create(&rotated)
+	gdb.Create(&models.XMRSubaddress{WalletID: first.ID, AddressIndex: 0, Address: "addr0"})
+	gdb.Create(&models.TransactionIn{ClientID: "c2", WalletID: funded.ID, AssetID: "a1", Status: models.TransactionInStatusConfirmed})
+
+	if err := DedupWallets(gdb); err != nil {
+		t.Fatalf("dedup: %v", err)
+	}
+	var ids []string
+	gdb.Model(&models.Wallet{}).Order("derivation_index").Pluck("id", &ids)
+	if len(ids) != 3 || ids[0] != funded.ID || ids[1] != unique.ID || ids[2] != rotated.ID {
+		t.Fatalf("unexpected wallets %v (first %s sameValue %s)", ids, first.ID, sameValue.ID)
+	}
+	var subs int64
+	gdb.Model(&models.XMRSubaddress{}).Count(&subs)
+	gdb.First(&rotated, "id = ?", rotated.ID)
+	if subs != 0 || rotated.PreviousID != nil {
+		t.Fatalf("references to removed wallet remain")
+	}
+	if err := gdb.AutoMigrate(&models.Wallet{}); err != nil {
+		t.Fatalf("migrate after dedup: %v", err)
+	}
+
+	// Повторы с депозитами требуют ручного разбора
+	for _, name := range []string{"idx_wallet_asset_network_index", "idx_wallet_asset_network_value"} {
+		gdb.Migrator().DropIndex(&models.Wallet{}, name)
+	}
+	other := wallet("c5", "addr5", 0, now)
+	gdb.Create(&models.TransactionIn{ClientID: "c5", WalletID: other.ID, AssetID: "a1", Status: models.TransactionInStatusConfirmed})
+	if err := DedupWallets(gdb); err == nil {
+		t.Fatalf("expected error for funded duplicates")
+	}
+}
@@ -0,0 +1,213 @@
+package deposits
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"ptop/internal/models"
+)
+
+// ErrDuplicate возвращается Record, если перевод с теми же TxHash и
+// LogIndex в сети уже сохранён.
+var ErrDuplicate = errors.New("deposit already recorded")
+
+// Deposit — входящий перевод, найденный наблюдателем сети.
+type Deposit struct {
+	Chain  string
+	Wallet models.Wallet
+	Amount decimal.Decimal
+	// BlockHeight — высота блока с транзакцией, 0 для неподтверждённых.
+	BlockHeight uint64
+	// Reject — причина отклонения перевода наблюдателем; такой депозит
+	// сохраняется со статусом failed.
+	Reject string
+	// TxHash и LogIndex однозначно определяют перевод в сети; пустой TxHash
+	// отключает проверку повторов.
+	TxHash   string
+	LogIndex int
+	Data     map[string]any
+}
+
+// Network возвращает параметры актива в сети или nil, если сеть не настроена.
+func Network(db *gorm.DB, assetID, chain string) (*models.AssetNetwork, error) {
+	var n models.AssetNetwork
+	if err := db.Where("asset_id = ? AND chain = ?", assetID, chain).First(&n).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &n, nil
+}
+
+// Decimals возвращает точность актива в сети или def, если сеть не настроена.
+func Decimals(n *models.AssetNetwork, def int32) int32 {
+	if n == nil || n.Decimals == 0 {
+		return def
+	}
+	return n.Decimals
+}
+
+// Confirmations возвращает число подтверждений транзакции из блока height
+// при вершине сети tip.
+func Confirmations(height, tip uint64) int {
+	if height == 0 || tip < height {
+		return 0
+	}
+	return int(tip - height + 1)
+}
+
+// Record сохраняет депозит. Сумма ниже минимальной, депозит при
+// отключённых пополнениях или на адрес с истёкшим после ротации льготным
+// периодом сохраняется со статусом failed. Депозит с числом
+// подтверждений ниже порога сети сохраняется как processing и зачисляется
+// позже через ConfirmPending. tip = 0 означает, что сеть сообщает только
+// финальные транзакции, и депозит зачисляется сразу. Для уже сохранённого
+// перевода Record возвращает ErrDuplicate.
+func Record(db *gorm.DB, dep Deposit, tip uint64) (*models.TransactionIn, error) {
+	network, err := Network(db, dep.Wallet.AssetID, dep.Chain)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]any, len(dep.Data)+1)
+	for k, v := range dep.Data {
+		data[k] = v
+	}
+	status := models.TransactionInStatusConfirmed
+	switch {
+	case dep.Reject != "":
+		status = models.TransactionInStatusFailed
+		data["reason"] = dep.Reject
+	case dep.Wallet.ExpiresAt != nil && time.Now().After(*dep.Wallet.ExpiresAt):
+		status = models.TransactionInStatusFailed
+		data["reason"] = "address_expired"
+	case network != nil && !network.DepositEnabled:
+		status = models.TransactionInStatusFailed
+		data["reason"] = "deposits_disabled"
+	case network != nil && dep.Amount.LessThan(network.MinDeposit):
+		status = models.TransactionInStatusFailed
+		data["reason"] = "below_minimum"
+	case tip > 0 && Confirmations(dep.BlockHeight, tip) < threshold(dep.Chain, network):
+		status = models.TransactionInStatusProcessing
+	}
+	raw, _ := json.Marshal(data)
+	tx := models.TransactionIn{
+		ClientID:    dep.Wallet.ClientID,
+		WalletID:    dep.Wallet.ID,
+		AssetID:     dep.Wallet.AssetID,
+		Amount:      dep.Amount,
+		Status:      status,
+		Network:     dep.Chain,
+		BlockHeight: dep.BlockHeight,
+		Data:        datatypes.JSON(raw),
+	}
+	if dep.TxHash != "" {
+		tx.TxHash = &dep.TxHash
+		tx.LogIndex = dep.LogIndex
+	}
+	err = db.Transaction(func(t *gorm.DB) error {
+		// Уникальный индекс (network, tx_hash, log_index) не даёт зачислить
+		// перевод дважды при параллельной обработке одного блока
+		res := t.Clauses(clause.OnConflict{DoNothing: true}).Create(&tx)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrDuplicate
+		}
+		if status == models.TransactionInStatusConfirmed {
+			return credit(t, tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// ConfirmPending зачисляет депозиты сети в статусе processing, набравшие
+// порог подтверждений при вершине tip.
+func ConfirmPending(db *gorm.DB, chain string, tip uint64) error {
+	var pending []models.TransactionIn
+	if err := db.Where("network = ? AND status = ?", chain, models.TransactionInStatusProcessing).Find(&pending).Error; err != nil {
+		return err
+	}
+	thresholds := make(map[string]int)
+	for _, p := range pending {
+		need, ok := thresholds[p.AssetID]
+		if !ok {
+			network, err := Network(db, p.AssetID, chain)
+			if err != nil {
+				return err
+			}
+			need = threshold(chain, network)
+			thresholds[p.AssetID] = need
+		}
+		if Confirmations(p.BlockHeight, tip) < need {
+			continue
+		}
+		err := db.Transaction(func(t *gorm.DB) error {
+			res := t.Model(&models.TransactionIn{}).
+				Where("id = ? AND status = ?", p.ID, models.TransactionInStatusProcessing).
+				Update("status", models.TransactionInStatusConfirmed)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			return credit(t, p)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultConfirmations задаёт порог подтверждений для сетей, где один блок
+// недостаточен, если параметры актива в сети не настроены. Выходы Monero
+// разблокируются через 10 блоков.
+var defaultConfirmations = map[string]int{
+	"xmr": 10,
+}
+
+// threshold возвращает порог подтверждений сети, по умолчанию один блок.
+func threshold(chain string, n *models.AssetNetwork) int {
+	if n == nil || n.Confirmations < 1 {
+		if c, ok := defaultConfirmations[chain]; ok {
+			return c
+		}
+		return 1
+	}
+	return n.Confirmations
+}
+
+func credit(db *gorm.DB, tx models.TransactionIn) error {
+	return db.Model(&models.Balance{}).
+		Where("client_id = ? AND asset_id = ?", tx.ClientID, tx.AssetID).
+		Update("amount", gorm.Expr("amount + ?", tx.Amount)).Error
+}
+
+// Cursor возвращает последний обработанный блок сети; false — сеть ещё не
+// обрабатывалась.
+func Cursor(db *gorm.DB, chain string) (uint64, bool, error) {
+	var c models.WatcherCursor
+	if err := db.Where("network = ?", chain).Limit(1).Find(&c).Error; err != nil {
+		return 0, false, err
+	}
+	return c.Height, c.Network != "", nil
+}
+
+// SaveCursor запоминает последний обработанный блок сети.
+func SaveCursor(db *gorm.DB, chain string, height uint64) error {
+	c := models.WatcherCursor{Network: chain, Height: height}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "network"}},
+		DoUpdates: clause.AssignmentColumns([]string{"height", "updated_at"}),
+	}).Create(&c).Error
+}
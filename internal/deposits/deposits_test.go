@@ -0,0 +1,143 @@
+package deposits
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+)
+
+func setup(t *testing.T, network *models.AssetNetwork) (*gorm.DB, models.Wallet) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	client := models.Client{Username: "u"}
+	db.Create(&client)
+	asset := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, Chain: "btc"}
+	db.Create(&asset)
+	if network != nil {
+		network.AssetID = asset.ID
+		network.Chain = "btc"
+		if err := db.Create(network).Error; err != nil {
+			t.Fatalf("network: %v", err)
+		}
+	}
+	wallet := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Value: "addr"}
+	db.Create(&wallet)
+	db.Create(&models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero})
+	return db, wallet
+}
+
+func balance(t *testing.T, db *gorm.DB, wallet models.Wallet) decimal.Decimal {
+	t.Helper()
+	var b models.Balance
+	if err := db.Where("client_id = ? AND asset_id = ?", wallet.ClientID, wallet.AssetID).First(&b).Error; err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	return b.Amount
+}
+
+func TestRecordBelowMinimum(t *testing.T) {
+	db, wallet := setup(t, &models.AssetNetwork{Decimals: 8, MinDeposit: decimal.RequireFromString("0.001"), Confirmations: 1, DepositEnabled: true})
+	tx, err := Record(db, Deposit{Chain: "btc", Wallet: wallet, Amount: decimal.RequireFromString("0.0005"), BlockHeight: 10}, 10)
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if tx.Status != models.TransactionInStatusFailed {
+		t.Fatalf("status %s", tx.Status)
+	}
+	if !balance(t, db, wallet).IsZero() {
+		t.Fatalf("balance credited")
+	}
+}
+
+func TestRecordWaitsForConfirmations(t *testing.T) {
+	db, wallet := setup(t, &models.AssetNetwork{Decimals: 8, Confirmations: 3, DepositEnabled: true})
+	tx, err := Record(db, Deposit{Chain: "btc", Wallet: wallet, Amount: decimal.NewFromInt(1), BlockHeight: 10}, 10)
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if tx.Status != models.TransactionInStatusProcessing || tx.BlockHeight != 10 || tx.Network != "btc" {
+		t.Fatalf("unexpected tx %+v", tx)
+	}
+	if err := ConfirmPending(db, "btc", 11); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if !balance(t, db, wallet).IsZero() {
+		t.Fatalf("credited before threshold")
+	}
+	for i := 0; i < 2; i++ {
+		if err := ConfirmPending(db, "btc", 12); err != nil {
+			t.Fatalf("confirm: %v", err)
+		}
+	}
+	if !balance(t, db, wallet).Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("balance %s", balance(t, db, wallet))
+	}
+	db.First(tx, "id = ?", tx.ID)
+	if tx.Status != models.TransactionInStatusConfirmed {
+		t.Fatalf("status %s", tx.Status)
+	}
+}
+
+func TestRecordFinal(t *testing.T) {
+	db, wallet := setup(t, nil)
+	tx, err := Record(db, Deposit{Chain: "btc", Wallet: wallet, Amount: decimal.NewFromInt(2)}, 0)
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if tx.Status != models.TransactionInStatusConfirmed {
+		t.Fatalf("status %s", tx.Status)
+	}
+	if !balance(t, db, wallet).Equal(decimal.NewFromInt(2)) {
+		t.Fatalf("balance %s", balance(t, db, wallet))
+	}
+}
+
+func TestRecordRotatedAddress(t *testing.T) {
+	db, wallet := setup(t, nil)
+	later := time.Now().Add(time.Hour)
+	wallet.ExpiresAt = &later
+	tx, err := Record(db, Deposit{Chain: "btc", Wallet: wallet, Amount: decimal.NewFromInt(1)}, 0)
+	if err != nil || tx.Status != models.TransactionInStatusConfirmed {
+		t.Fatalf("deposit in grace period: %v %+v", err, tx)
+	}
+	earlier := time.Now().Add(-time.Minute)
+	wallet.ExpiresAt = &earlier
+	tx, err = Record(db, Deposit{Chain: "btc", Wallet: wallet, Amount: decimal.NewFromInt(1)}, 0)
+	if err != nil || tx.Status != models.TransactionInStatusFailed {
+		t.Fatalf("deposit after grace period: %v %+v", err, tx)
+	}
+	if !balance(t, db, wallet).Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("balance %s", balance(t, db, wallet))
+	}
+}
+
+func TestRecordDuplicate(t *testing.T) {
+	db, wallet := setup(t, nil)
+	dep := Deposit{Chain: "btc", Wallet: wallet, Amount: decimal.NewFromInt(1), TxHash: "tx1", LogIndex: 2}
+	if _, err := Record(db, dep, 0); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if _, err := Record(db, dep, 0); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate, got %v", err)
+	}
+	// Другой лог той же транзакции — отдельный перевод
+	dep.LogIndex = 3
+	if _, err := Record(db, dep, 0); err != nil {
+		t.Fatalf("record other log: %v", err)
+	}
+	if got := balance(t, db, wallet); !got.Equal(decimal.NewFromInt(2)) {
+		t.Fatalf("balance %s", got)
+	}
+}
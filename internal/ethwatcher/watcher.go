@@ -2,11 +2,12 @@ package ethwatcher
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"math/big"
+	"sync"
+	"time"
 
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -14,9 +15,9 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/shopspring/decimal"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
 
+	"ptop/internal/deposits"
 	"ptop/internal/models"
 )
 
@@ -28,13 +29,22 @@ type tokenInfo struct {
 	decimals int32
 }
 
-// Watcher отслеживает блоки Ethereum и события Transfer выбранных токенов.
+// Watcher отслеживает блоки EVM-сети (Ethereum, L2, сайдчейна) и события
+// Transfer выбранных токенов. Каждая сеть обслуживается своим экземпляром.
 type Watcher struct {
-	client  *ethclient.Client
-	db      *gorm.DB
-	tokens  map[common.Address]*tokenInfo
-	debug   bool
-	debugCh chan debugDeposit
+	chain     string
+	chainID   uint64
+	rpcURL    string
+	db        *gorm.DB
+	tokens    map[common.Address]*tokenInfo
+	debug     bool
+	debugCh   chan debugDeposit
+	debugOnce sync.Once
+
+	mu      sync.Mutex
+	height  uint64
+	tip     uint64
+	blockAt time.Time
 }
 
 type debugDeposit struct {
@@ -42,58 +52,72 @@ type debugDeposit struct {
 	amount   decimal.Decimal
 }
 
-// New создаёт новый наблюдатель.
-func New(db *gorm.DB, rpcURL string, debug bool) (*Watcher, error) {
-	tokens := make(map[common.Address]*tokenInfo)
-
-	var usdtAsset models.Asset
-	if err := db.Where("name = ?", "USDT").First(&usdtAsset).Error; err == nil {
-		tokens[common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")] = &tokenInfo{assetID: usdtAsset.ID, decimals: 6}
-	}
-	var usdcAsset models.Asset
-	if err := db.Where("name = ?", "USDC").First(&usdcAsset).Error; err == nil {
-		tokens[common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9EB0cE3606eB48")] = &tokenInfo{assetID: usdcAsset.ID, decimals: 6}
-	}
-
-	w := &Watcher{db: db, tokens: tokens, debug: debug}
+// New создаёт новый наблюдатель сети chain (значение asset_networks.chain)
+// с идентификатором chainID. Нулевой chainID отключает сверку с узлом.
+func New(db *gorm.DB, chain string, chainID uint64, rpcURL string, debug bool) (*Watcher, error) {
+	w := &Watcher{db: db, chain: chain, chainID: chainID, rpcURL: rpcURL, debug: debug}
 	if debug {
 		w.debugCh = make(chan debugDeposit)
 		return w, nil
 	}
 	if rpcURL == "" {
-		return nil, fmt.Errorf("eth rpc url required")
+		return nil, fmt.Errorf("%s rpc url required", chain)
 	}
-	client, err := ethclient.Dial(rpcURL)
-	if err != nil {
-		return nil, err
-	}
-	w.client = client
 	return w, nil
 }
 
-// Start запускает подписку на новые блоки и события Transfer.
+// Start запускает наблюдатель в фоне без перезапусков при ошибках.
 func (w *Watcher) Start() error {
 	if w.debug {
-		go w.debugLoop()
+		w.startDebug()
 		return nil
 	}
-	heads := make(chan *types.Header)
-	sub, err := w.client.SubscribeNewHead(context.Background(), heads)
-	if err != nil {
-		return err
-	}
 	go func() {
-		for {
-			select {
-			case err := <-sub.Err():
-				log.Printf("ошибка подписки на блоки: %v", err)
-				return
-			case head := <-heads:
-				go w.handleBlock(head.Hash(), head.Number.Uint64())
-			}
+		if err := w.Run(context.Background()); err != nil {
+			log.Printf("%s watcher: %v", w.chain, err)
 		}
 	}()
+	return nil
+}
+
+// Run подключается к узлу, подписывается на новые блоки и события Transfer
+// и обрабатывает их, пока одна из подписок не завершится ошибкой или не
+// будет отменён ctx.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.debug {
+		w.startDebug()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tokens, err := loadTokens(w.db, w.chain)
+	if err != nil {
+		return fmt.Errorf("load tokens: %w", err)
+	}
+	w.tokens = tokens
+	client, err := ethclient.DialContext(ctx, w.rpcURL)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer client.Close()
+	if w.chainID != 0 {
+		id, err := client.ChainID(ctx)
+		if err != nil {
+			return fmt.Errorf("chain id: %w", err)
+		}
+		if id.Uint64() != w.chainID {
+			return fmt.Errorf("chain id mismatch: node %s, expected %d", id, w.chainID)
+		}
+	}
+
+	heads := make(chan *types.Header)
+	sub, err := client.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return fmt.Errorf("subscribe heads: %w", err)
+	}
+	defer sub.Unsubscribe()
 
+	var logErr <-chan error
+	logsCh := make(chan types.Log)
 	if len(w.tokens) > 0 {
 		addresses := make([]common.Address, 0, len(w.tokens))
 		for addr := range w.tokens {
@@ -103,15 +127,71 @@ func (w *Watcher) Start() error {
 			Addresses: addresses,
 			Topics:    [][]common.Hash{{transferSigHash}},
 		}
-		logsCh := make(chan types.Log)
-		logSub, err := w.client.SubscribeFilterLogs(context.Background(), query, logsCh)
+		logSub, err := client.SubscribeFilterLogs(ctx, query, logsCh)
 		if err != nil {
-			return err
+			return fmt.Errorf("subscribe logs: %w", err)
 		}
-		go w.handleLogs(logSub, logsCh)
+		defer logSub.Unsubscribe()
+		logErr = logSub.Err()
 	}
 
-	return nil
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return subscriptionError("heads", err)
+		case err := <-logErr:
+			return subscriptionError("logs", err)
+		case head := <-heads:
+			tip := head.Number.Uint64()
+			w.mu.Lock()
+			w.tip = tip
+			w.mu.Unlock()
+			go w.handleBlock(client, head.Hash(), tip)
+			if err := deposits.ConfirmPending(w.db, w.chain, tip); err != nil {
+				log.Printf("не удалось подтвердить депозиты: %v", err)
+			}
+		case vLog := <-logsCh:
+			w.processLog(vLog)
+		}
+	}
+}
+
+// Progress возвращает номер последнего обработанного блока, номер последнего
+// полученного заголовка и время последнего блока.
+func (w *Watcher) Progress() (height, tip uint64, blockAt time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.height, w.tip, w.blockAt
+}
+
+// loadTokens загружает контракты ERC20 сети chain из настроек сетей активов.
+func loadTokens(db *gorm.DB, chain string) (map[common.Address]*tokenInfo, error) {
+	var networks []models.AssetNetwork
+	if err := db.Where("chain = ? AND contract <> ''", chain).Find(&networks).Error; err != nil {
+		return nil, err
+	}
+	tokens := make(map[common.Address]*tokenInfo, len(networks))
+	for _, n := range networks {
+		if !common.IsHexAddress(n.Contract) {
+			log.Printf("некорректный контракт %q актива %s", n.Contract, n.AssetID)
+			continue
+		}
+		tokens[common.HexToAddress(n.Contract)] = &tokenInfo{assetID: n.AssetID, decimals: n.Decimals}
+	}
+	return tokens, nil
+}
+
+func subscriptionError(name string, err error) error {
+	if err == nil {
+		return fmt.Errorf("subscription %s closed", name)
+	}
+	return fmt.Errorf("subscription %s: %w", name, err)
+}
+
+func (w *Watcher) startDebug() {
+	w.debugOnce.Do(func() { go w.debugLoop() })
 }
 
 func (w *Watcher) debugLoop() {
@@ -133,28 +213,14 @@ func (w *Watcher) createDebugDeposit(walletID string, amount decimal.Decimal) {
 		log.Printf("ошибка поиска кошелька: %v", err)
 		return
 	}
-	data, _ := json.Marshal(map[string]any{"debug": true})
-	dep := models.TransactionIn{
-		ClientID: wallet.ClientID,
-		WalletID: wallet.ID,
-		AssetID:  wallet.AssetID,
-		Amount:   amount,
-		Status:   models.TransactionInStatusConfirmed,
-		Data:     datatypes.JSON(data),
-	}
-	if err := w.db.Create(&dep).Error; err != nil {
+	dep := deposits.Deposit{Chain: w.chain, Wallet: wallet, Amount: amount, Data: map[string]any{"debug": true, "chain_id": w.chainID}}
+	if _, err := deposits.Record(w.db, dep, 0); err != nil {
 		log.Printf("не удалось сохранить депозит: %v", err)
-		return
-	}
-	if err := w.db.Model(&models.Balance{}).
-		Where("client_id = ? AND asset_id = ?", wallet.ClientID, wallet.AssetID).
-		Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-		log.Printf("не удалось обновить баланс: %v", err)
 	}
 }
 
-func (w *Watcher) handleBlock(hash common.Hash, number uint64) {
-	block, err := w.client.BlockByHash(context.Background(), hash)
+func (w *Watcher) handleBlock(client *ethclient.Client, hash common.Hash, number uint64) {
+	block, err := client.BlockByHash(context.Background(), hash)
 	if err != nil {
 		log.Printf("не удалось получить блок %s: %v", hash.Hex(), err)
 		return
@@ -162,61 +228,51 @@ func (w *Watcher) handleBlock(hash common.Hash, number uint64) {
 	for _, tx := range block.Transactions() {
 		w.processTx(tx, number)
 	}
+	w.mu.Lock()
+	if number > w.height {
+		w.height = number
+		w.blockAt = time.Unix(int64(block.Time()), 0)
+	}
+	w.mu.Unlock()
 }
 
+// processTx зачисляет перевод нативной монеты сети на кошельки активов без
+// контракта в этой сети.
 func (w *Watcher) processTx(tx *types.Transaction, blockNumber uint64) {
 	to := tx.To()
 	if to == nil || tx.Value().Sign() == 0 {
 		return
 	}
 	var wallet models.Wallet
-	if err := w.db.Where("value = ?", to.Hex()).First(&wallet).Error; err != nil {
+	if err := w.db.Joins("JOIN asset_networks ON asset_networks.asset_id = wallets.asset_id").
+		Where("wallets.value = ? AND wallets.network = ? AND asset_networks.chain = wallets.network AND asset_networks.contract = ''", to.Hex(), w.chain).
+		First(&wallet).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return
 		}
 		log.Printf("ошибка базы данных: %v", err)
 		return
 	}
-	var existing models.TransactionIn
-	if err := w.db.Where("data ->> 'tx_hash' = ?", tx.Hash().Hex()).First(&existing).Error; err == nil {
-		return
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		log.Printf("ошибка проверки существующей транзакции: %v", err)
+	network, err := deposits.Network(w.db, wallet.AssetID, w.chain)
+	if err != nil {
+		log.Printf("ошибка базы данных: %v", err)
 		return
 	}
-	amount := decimal.NewFromBigInt(tx.Value(), -18)
-	data, _ := json.Marshal(map[string]any{
-		"tx_hash":      tx.Hash().Hex(),
-		"block_number": blockNumber,
-	})
-	dep := models.TransactionIn{
-		ClientID: wallet.ClientID,
-		WalletID: wallet.ID,
-		AssetID:  wallet.AssetID,
-		Amount:   amount,
-		Status:   "confirmed",
-		Data:     datatypes.JSON(data),
+	dep := deposits.Deposit{
+		Chain:       w.chain,
+		Wallet:      wallet,
+		Amount:      decimal.NewFromBigInt(tx.Value(), -deposits.Decimals(network, 18)),
+		BlockHeight: blockNumber,
+		TxHash:      tx.Hash().Hex(),
+		LogIndex:    -1,
+		Data: map[string]any{
+			"tx_hash":      tx.Hash().Hex(),
+			"block_number": blockNumber,
+			"chain_id":     w.chainID,
+		},
 	}
-	if err := w.db.Create(&dep).Error; err != nil {
+	if _, err := deposits.Record(w.db, dep, w.tipAtLeast(dep.BlockHeight)); err != nil && !errors.Is(err, deposits.ErrDuplicate) {
 		log.Printf("не удалось сохранить депозит: %v", err)
-		return
-	}
-	if err := w.db.Model(&models.Balance{}).
-		Where("client_id = ? AND asset_id = ?", wallet.ClientID, wallet.AssetID).
-		Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-		log.Printf("не удалось обновить баланс: %v", err)
-	}
-}
-
-func (w *Watcher) handleLogs(sub ethereum.Subscription, logsCh <-chan types.Log) {
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Printf("ошибка подписки на логи: %v", err)
-			return
-		case vLog := <-logsCh:
-			w.processLog(vLog)
-		}
 	}
 }
 
@@ -227,43 +283,41 @@ func (w *Watcher) processLog(vLog types.Log) {
 	}
 	to := common.HexToAddress(vLog.Topics[2].Hex())
 	var wallet models.Wallet
-	if err := w.db.Where("value = ? AND asset_id = ?", to.Hex(), info.assetID).First(&wallet).Error; err != nil {
+	if err := w.db.Where("value = ? AND asset_id = ? AND network = ?", to.Hex(), info.assetID, w.chain).First(&wallet).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return
 		}
 		log.Printf("ошибка базы данных: %v", err)
 		return
 	}
-	var existing models.TransactionIn
-	if err := w.db.Where("data ->> 'tx_hash' = ? AND data ->> 'log_index' = ?", vLog.TxHash.Hex(), fmt.Sprintf("%d", vLog.Index)).First(&existing).Error; err == nil {
-		return
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		log.Printf("ошибка проверки существующей транзакции: %v", err)
-		return
-	}
 	value := new(big.Int).SetBytes(vLog.Data)
-	amount := decimal.NewFromBigInt(value, -info.decimals)
-	data, _ := json.Marshal(map[string]any{
-		"tx_hash":      vLog.TxHash.Hex(),
-		"log_index":    vLog.Index,
-		"block_number": vLog.BlockNumber,
-		"token":        vLog.Address.Hex(),
-	})
-	dep := models.TransactionIn{
-		ClientID: wallet.ClientID,
-		WalletID: wallet.ID,
-		AssetID:  wallet.AssetID,
-		Amount:   amount,
-		Status:   "confirmed",
-		Data:     datatypes.JSON(data),
+	dep := deposits.Deposit{
+		Chain:       w.chain,
+		Wallet:      wallet,
+		Amount:      decimal.NewFromBigInt(value, -info.decimals),
+		BlockHeight: vLog.BlockNumber,
+		TxHash:      vLog.TxHash.Hex(),
+		LogIndex:    int(vLog.Index),
+		Data: map[string]any{
+			"tx_hash":      vLog.TxHash.Hex(),
+			"log_index":    vLog.Index,
+			"block_number": vLog.BlockNumber,
+			"token":        vLog.Address.Hex(),
+			"chain_id":     w.chainID,
+		},
 	}
-	if err := w.db.Create(&dep).Error; err != nil {
+	if _, err := deposits.Record(w.db, dep, w.tipAtLeast(dep.BlockHeight)); err != nil && !errors.Is(err, deposits.ErrDuplicate) {
 		log.Printf("не удалось сохранить депозит: %v", err)
-		return
 	}
-	if err := w.db.Model(&models.Balance{}).
-		Where("client_id = ? AND asset_id = ?", wallet.ClientID, wallet.AssetID).
-		Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-		log.Printf("не удалось обновить баланс: %v", err)
+}
+
+// tipAtLeast возвращает вершину сети, но не ниже высоты h: лог или блок
+// может прийти раньше, чем обновится вершина.
+func (w *Watcher) tipAtLeast(h uint64) uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.tip < h {
+		return h
 	}
+	return w.tip
 }
@@ -1,9 +1,13 @@
 package ethwatcher
 
 import (
+	"encoding/json"
+	"math/big"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/shopspring/decimal"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -16,7 +20,7 @@ func TestWatcherDebugDeposit(t *testing.T) {
 	if err != nil {
 		t.Fatalf("db open: %v", err)
 	}
-	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	client := models.Client{Username: "u"}
@@ -28,7 +32,7 @@ func TestWatcherDebugDeposit(t *testing.T) {
 	bal := models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero}
 	db.Create(&bal)
 
-	w, err := New(db, "", true)
+	w, err := New(db, "eth", 1, "", true)
 	if err != nil {
 		t.Fatalf("watcher: %v", err)
 	}
@@ -51,3 +55,61 @@ func TestWatcherDebugDeposit(t *testing.T) {
 		t.Fatalf("balance amount %s", bal.Amount)
 	}
 }
+
+func TestWatcherTagsChainID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:eth_chain_id?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	client := models.Client{Username: "u"}
+	db.Create(&client)
+	asset := models.Asset{Name: "USDT", Type: models.AssetTypeCrypto, Chain: "eth"}
+	db.Create(&asset)
+	token := common.HexToAddress("0xFd086bC7CD5C481DCC9C85ebE478A1C0b69FCbb9")
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: "eth", Contract: "0xdAC17F958D2ee523a2206206994597C13D831ec7", Decimals: 6, Confirmations: 1})
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: "arbitrum", Contract: token.Hex(), Decimals: 6, Confirmations: 1})
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	// один адрес клиента в Ethereum и Arbitrum
+	db.Create(&models.Wallet{ClientID: client.ID, AssetID: asset.ID, Network: "eth", Value: to.Hex(), DerivationIndex: 0})
+	wallet := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Network: "arbitrum", Value: to.Hex(), DerivationIndex: 0}
+	db.Create(&wallet)
+	db.Create(&models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero})
+
+	w, err := New(db, "arbitrum", 42161, "http://127.0.0.1:1", false)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	if w.tokens, err = loadTokens(db, "arbitrum"); err != nil || len(w.tokens) != 1 {
+		t.Fatalf("tokens %v: %v", w.tokens, err)
+	}
+	vLog := types.Log{
+		Address:     token,
+		Topics:      []common.Hash{transferSigHash, common.BytesToHash(common.FromHex("0x22")), common.BytesToHash(to.Bytes())},
+		Data:        common.LeftPadBytes(big.NewInt(1500000).Bytes(), 32),
+		BlockNumber: 10,
+		TxHash:      common.HexToHash("0xaa"),
+		Index:       2,
+	}
+	w.processLog(vLog)
+	w.processLog(vLog)
+
+	var txs []models.TransactionIn
+	db.Find(&txs)
+	if len(txs) != 1 {
+		t.Fatalf("expected 1 deposit, got %d", len(txs))
+	}
+	tx := txs[0]
+	if tx.Network != "arbitrum" || tx.WalletID != wallet.ID || !tx.Amount.Equal(decimal.RequireFromString("1.5")) {
+		t.Fatalf("unexpected tx %+v", tx)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(tx.Data, &data); err != nil {
+		t.Fatalf("data: %v", err)
+	}
+	if data["chain_id"] != float64(42161) {
+		t.Fatalf("chain_id %v", data["chain_id"])
+	}
+}
@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+	"ptop/internal/notifications"
+	"ptop/internal/services"
+)
+
+// Заголовки подписанного запроса с API ключом. Подпись — hex HMAC-SHA256
+// секретом ключа от строки
+// "METHOD\nPATH?QUERY\nTIMESTAMP\nhex(SHA-256(тело))", TIMESTAMP — Unix-время
+// в секундах.
+const (
+	headerAPIKey       = "X-API-Key"
+	headerAPITimestamp = "X-API-Timestamp"
+	headerAPISignature = "X-API-Signature"
+)
+
+// apiKeyRouteScopes — изменяющие запросы, доступные API ключам, и нужная для
+// них область. Любой GET требует области read, остальные запросы и весь
+// /auth ключам недоступны.
+var apiKeyRouteScopes = map[string]string{
+	"POST /client/offers":                    models.APIScopeOffers,
+	"PUT /client/offers/:id":                 models.APIScopeOffers,
+	"POST /client/offers/:id/enable":         models.APIScopeOffers,
+	"POST /client/offers/:id/disable":        models.APIScopeOffers,
+	"DELETE /client/offers/:id":              models.APIScopeOffers,
+	"POST /client/order":                     models.APIScopeOrders,
+	"POST /client/orders":                    models.APIScopeOrders,
+	"POST /orders/:id/paid":                  models.APIScopeOrders,
+	"POST /orders/:id/release":               models.APIScopeOrders,
+	"POST /orders/:id/cancel":                models.APIScopeOrders,
+	"POST /orders/:id/dispute":               models.APIScopeOrders,
+	"POST /orders/:id/dispute/resolve":       models.APIScopeOrders,
+	"POST /orders/:id/messages":              models.APIScopeOrders,
+	"PATCH /orders/:id/messages/:msgId/read": models.APIScopeOrders,
+	"POST /notifications/:id/read":           models.APIScopeRead,
+	"POST /notifications/read-all":           models.APIScopeRead,
+}
+
+// apiKeyStepUpScopes — область ключа, которая заменяет повышение прав для
+// действия. Действия без области ключам недоступны.
+var apiKeyStepUpScopes = map[string]string{
+	StepUpActionRelease:    models.APIScopeOrders,
+	StepUpActionResolve:    models.APIScopeOrders,
+	StepUpActionWithdrawal: models.APIScopeWithdrawals,
+}
+
+var apiKeyScopes = []string{models.APIScopeRead, models.APIScopeOffers, models.APIScopeOrders, models.APIScopeWithdrawals}
+
+// Вход по API ключам: replayGuard запоминает подписи запросов, из
+// apiKeyMaster выводятся секреты ключей. Без них вход по ключам отключён.
+var (
+	replayGuard   *services.ReplayGuard
+	apiKeyMaster  []byte
+	apiKeyMaxSkew time.Duration
+)
+
+// SetAPIKeyAuth включает вход по API ключам. Секреты ключей выводятся из
+// master, подписанный запрос принимается, если его время отличается от
+// серверного не больше чем на maxSkew.
+func SetAPIKeyAuth(guard *services.ReplayGuard, master []byte, maxSkew time.Duration) {
+	replayGuard = guard
+	apiKeyMaster = master
+	apiKeyMaxSkew = maxSkew
+}
+
+// CreateAPIKeyRequest — новый ключ. AllowedIPs — адреса или подсети CIDR;
+// пустой список разрешает любой адрес. Без ExpiresAt ключ бессрочный.
+type CreateAPIKeyRequest struct {
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes" binding:"required"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	KeyID      string     `json:"key_id"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPIKeyResponse — созданный ключ; секрет показывается один раз.
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Secret string `json:"secret"`
+}
+
+func newAPIKeyResponse(k models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		KeyID:      k.KeyID,
+		Scopes:     splitList(k.Scopes),
+		AllowedIPs: splitList(k.AllowedIPs),
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+// apiKeySecret выводит секрет ключа из серверного секрета, поэтому в базе
+// он не хранится.
+func apiKeySecret(keyID string) []byte {
+	mac := hmac.New(sha256.New, apiKeyMaster)
+	mac.Write([]byte(keyID))
+	return []byte(hex.EncodeToString(mac.Sum(nil)))
+}
+
+// apiKeySignature подписывает запрос секретом ключа.
+func apiKeySignature(secret []byte, method, uri, timestamp string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + hex.EncodeToString(sum[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseAllowedIPs проверяет адреса и подсети и приводит их к каноническому
+// виду.
+func parseAllowedIPs(list []string) ([]string, bool) {
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if _, n, err := net.ParseCIDR(s); err == nil {
+			out = append(out, n.String())
+		} else if ip := net.ParseIP(s); ip != nil {
+			out = append(out, ip.String())
+		} else {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+// ipAllowed проверяет адрес по списку ключа. Адрес берётся из ClientIP:
+// X-Forwarded-For учитывается только от прокси из TRUSTED_PROXIES.
+func ipAllowed(allowed []string, addr string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, a := range allowed {
+		if _, n, err := net.ParseCIDR(a); err == nil {
+			if n.Contains(ip) {
+				return true
+			}
+		} else if other := net.ParseIP(a); other != nil && other.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyRouteScope возвращает область, нужную для запроса; пустая строка —
+// запрос ключам недоступен.
+func apiKeyRouteScope(c *gin.Context) string {
+	path := c.FullPath()
+	if path == "/auth" || strings.HasPrefix(path, "/auth/") {
+		return ""
+	}
+	if c.Request.Method == http.MethodGet {
+		return models.APIScopeRead
+	}
+	return apiKeyRouteScopes[c.Request.Method+" "+path]
+}
+
+// apiKeyAuth проверяет подписанный запрос с API ключом и передаёт его
+// дальше от имени владельца ключа.
+func apiKeyAuth(c *gin.Context, db *gorm.DB) {
+	if replayGuard == nil || len(apiKeyMaster) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "api keys disabled"})
+		return
+	}
+	keys := authKeys(c, authActionToken, "", "")
+	if !authAllowed(c, keys) {
+		return
+	}
+	fail := func(status int, msg string) {
+		if !authFailed(c, db, models.AuthFailure{Action: authActionToken}, keys) {
+			c.AbortWithStatusJSON(status, ErrorResponse{Error: msg})
+		}
+	}
+
+	var key models.APIKey
+	if err := db.Where("key_id = ? AND revoked_at IS NULL", c.GetHeader(headerAPIKey)).Limit(1).Find(&key).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+		return
+	}
+	if key.ID == "" {
+		fail(http.StatusUnauthorized, "invalid api key")
+		return
+	}
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "api key expired"})
+		return
+	}
+	if !ipAllowed(splitList(key.AllowedIPs), c.ClientIP()) {
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{Error: "ip not allowed"})
+		return
+	}
+	timestamp := c.GetHeader(headerAPITimestamp)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid timestamp"})
+		return
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > apiKeyMaxSkew || skew < -apiKeyMaxSkew {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid timestamp"})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "invalid body"})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	signature := strings.ToLower(c.GetHeader(headerAPISignature))
+	expected := apiKeySignature(apiKeySecret(key.KeyID), c.Request.Method, c.Request.URL.RequestURI(), timestamp, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		fail(http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	// Подпись запоминается, пока запрос с ней проходит проверку времени.
+	// Без Redis повтор нельзя исключить, поэтому запрос отклоняется
+	fresh, err := replayGuard.Claim(c.Request.Context(), key.KeyID+":"+signature, 2*apiKeyMaxSkew)
+	if err != nil {
+		log.Printf("ошибка проверки повтора запроса: %v", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, ErrorResponse{Error: "replay check unavailable"})
+		return
+	}
+	if !fresh {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "replayed request"})
+		return
+	}
+	scope := apiKeyRouteScope(c)
+	if scope == "" || !slices.Contains(splitList(key.Scopes), scope) {
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{Error: "insufficient scope"})
+		return
+	}
+	// Время последнего использования обновляется не чаще раза в минуту
+	db.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, time.Now().Add(-time.Minute)).
+		Update("last_used_at", time.Now())
+	c.Set("client_id", key.ClientID)
+	c.Set("api_key_id", key.ID)
+	c.Set("api_key_scopes", splitList(key.Scopes))
+	c.Next()
+}
+
+// apiKeyStepUp подтверждает действие запроса с API ключом: вместо
+// факторов нужна область ключа.
+func apiKeyStepUp(c *gin.Context, db *gorm.DB, action string) bool {
+	clientID := c.GetString("client_id")
+	scope := apiKeyStepUpScopes[action]
+	if scope == "" || !slices.Contains(c.GetStringSlice("api_key_scopes"), scope) {
+		auditStepUp(db, c, clientID, action, models.StepUpMethodAPIKey, nil, "insufficient scope")
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{Error: "insufficient scope"})
+		return false
+	}
+	auditStepUp(db, c, clientID, action, models.StepUpMethodAPIKey, nil, "")
+	return true
+}
+
+// CreateAPIKey godoc
+// @Summary Новый API ключ
+// @Description Создаёт ключ для ботов с областями read, offers, orders, withdrawals. Секрет возвращается один раз. Требует повышения прав (действие api_key.create).
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body CreateAPIKeyRequest true "параметры ключа"
+// @Success 201 {object} CreateAPIKeyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /auth/api-keys [post]
+func CreateAPIKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(apiKeyMaster) == 0 {
+			c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "api keys disabled"})
+			return
+		}
+		var r CreateAPIKeyRequest
+		if err := c.ShouldBindJSON(&r); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
+			return
+		}
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		for _, s := range r.Scopes {
+			if !slices.Contains(apiKeyScopes, s) {
+				c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid scope"})
+				return
+			}
+		}
+		var scopes []string
+		for _, s := range apiKeyScopes {
+			if slices.Contains(r.Scopes, s) {
+				scopes = append(scopes, s)
+			}
+		}
+		if len(scopes) == 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid scope"})
+			return
+		}
+		ips, ok := parseAllowedIPs(r.AllowedIPs)
+		if !ok {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid ip"})
+			return
+		}
+		if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid expiry"})
+			return
+		}
+		raw := make([]byte, 12)
+		if _, err := rand.Read(raw); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "internal error"})
+			return
+		}
+		key := models.APIKey{
+			ClientID:   clientID,
+			Name:       r.Name,
+			KeyID:      "ak_" + hex.EncodeToString(raw),
+			Scopes:     strings.Join(scopes, ","),
+			AllowedIPs: strings.Join(ips, ","),
+			ExpiresAt:  r.ExpiresAt,
+		}
+		if err := db.Create(&key).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		payload, err := json.Marshal(map[string]any{"name": key.Name, "scopes": scopes, "ip": c.ClientIP(), "device": deviceName(requestUserAgent(c))})
+		if err == nil {
+			n := models.Notification{ClientID: clientID, Type: "auth.api_key_created", Payload: payload, LinkTo: "/auth/api-keys"}
+			if err := db.Create(&n).Error; err == nil {
+				notifications.Broadcast(clientID, n)
+			}
+		}
+		c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKeyResponse: newAPIKeyResponse(key), Secret: string(apiKeySecret(key.KeyID))})
+	}
+}
+
+// ListAPIKeys godoc
+// @Summary API ключи клиента
+// @Description Действующие и просроченные ключи без секретов; отозванные не показываются.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} APIKeyResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/api-keys [get]
+func ListAPIKeys(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var keys []models.APIKey
+		if err := db.Where("client_id = ? AND revoked_at IS NULL", clientID).Order("created_at desc").Find(&keys).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		resp := make([]APIKeyResponse, len(keys))
+		for i, k := range keys {
+			resp[i] = newAPIKeyResponse(k)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// RevokeAPIKey godoc
+// @Summary Отзыв API ключа
+// @Description Ключ перестаёт приниматься сразу после отзыва.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "ID ключа"
+// @Success 200 {object} StatusResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /auth/api-keys/{id} [delete]
+func RevokeAPIKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		res := db.Model(&models.APIKey{}).
+			Where("id = ? AND client_id = ? AND revoked_at IS NULL", c.Param("id"), clientID).
+			Update("revoked_at", time.Now())
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "not found"})
+			return
+		}
+		c.JSON(http.StatusOK, StatusResponse{Status: "api key revoked"})
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"ptop/internal/models"
+)
+
+func TestAPIKeys(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	send := func(method, path, body, access string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "192.0.2.1:1234"
+		if access != "" {
+			req.Header.Set("Authorization", "Bearer "+access)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	signed := func(key CreateAPIKeyResponse, method, path, body string, at time.Time) map[string]string {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		return map[string]string{
+			headerAPIKey:       key.KeyID,
+			headerAPITimestamp: ts,
+			headerAPISignature: apiKeySignature([]byte(key.Secret), method, path, ts, []byte(body)),
+		}
+	}
+	create := func(access, body string) CreateAPIKeyResponse {
+		w := send("POST", "/auth/api-keys", body, access, map[string]string{"X-Step-Up-Pincode": "1234"})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create api key %d: %s", w.Code, w.Body.String())
+		}
+		var key CreateAPIKeyResponse
+		json.Unmarshal(w.Body.Bytes(), &key)
+		return key
+	}
+
+	w := send("POST", "/auth/register", `{"username":"botowner","password":"pass","password_confirm":"pass"}`, "", nil)
+	var tok tokenResp
+	json.Unmarshal(w.Body.Bytes(), &tok)
+	send("POST", "/auth/pincode", `{"password":"pass","pincode":"1234"}`, tok.AccessToken, nil)
+
+	// Создание ключа требует повышения прав
+	if w := send("POST", "/auth/api-keys", `{"scopes":["read"]}`, tok.AccessToken, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without step-up, got %d", w.Code)
+	}
+	if w := send("POST", "/auth/api-keys", `{"scopes":["admin"]}`, tok.AccessToken, map[string]string{"X-Step-Up-Pincode": "1234"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown scope, got %d", w.Code)
+	}
+	if w := send("POST", "/auth/api-keys", `{"scopes":["read"],"allowed_ips":["bad"]}`, tok.AccessToken, map[string]string{"X-Step-Up-Pincode": "1234"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid ip, got %d", w.Code)
+	}
+	key := create(tok.AccessToken, `{"name":"bot","scopes":["orders","read"],"allowed_ips":["192.0.2.0/24"]}`)
+	if key.Secret == "" || len(key.Scopes) != 2 || key.Scopes[0] != models.APIScopeRead {
+		t.Fatalf("unexpected key %+v", key)
+	}
+
+	// Подписанный GET с областью read
+	now := time.Now()
+	if w := send("GET", "/client/orders?limit=5", "", "", signed(key, "GET", "/client/orders?limit=5", "", now)); w.Code != http.StatusOK {
+		t.Fatalf("expected signed GET, got %d: %s", w.Code, w.Body.String())
+	}
+	// Повтор того же запроса отклоняется
+	if w := send("GET", "/client/orders?limit=5", "", "", signed(key, "GET", "/client/orders?limit=5", "", now)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on replay, got %d", w.Code)
+	}
+	if w := send("GET", "/client/orders", "", "", signed(key, "GET", "/client/orders", "", now.Add(-time.Minute))); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on stale timestamp, got %d", w.Code)
+	}
+	// Подпись покрывает тело запроса
+	headers := signed(key, "POST", "/orders/missing/release", "{}", now)
+	if w := send("POST", "/orders/missing/release", `{"x":1}`, "", headers); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on tampered body, got %d", w.Code)
+	}
+	// Область orders заменяет повышение прав при release
+	if w := send("POST", "/orders/missing/release", "{}", "", signed(key, "POST", "/orders/missing/release", "{}", now)); w.Code != http.StatusNotFound {
+		t.Fatalf("expected release to pass step-up, got %d: %s", w.Code, w.Body.String())
+	}
+	// Без области offers и для /auth ключ не действует
+	if w := send("POST", "/client/offers", "{}", "", signed(key, "POST", "/client/offers", "{}", now)); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without offers scope, got %d", w.Code)
+	}
+	if w := send("GET", "/auth/profile", "", "", signed(key, "GET", "/auth/profile", "", now)); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for /auth, got %d", w.Code)
+	}
+	var events int64
+	db.Model(&models.StepUpEvent{}).Where("method = ? AND success = ?", models.StepUpMethodAPIKey, true).Count(&events)
+	if events != 1 {
+		t.Fatalf("expected api key step-up audit, got %d", events)
+	}
+
+	// Адрес вне списка разрешённых
+	foreign := create(tok.AccessToken, `{"scopes":["read"],"allowed_ips":["198.51.100.7"]}`)
+	if w := send("GET", "/client/orders", "", "", signed(foreign, "GET", "/client/orders", "", now)); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for foreign ip, got %d", w.Code)
+	}
+	// Подменённый X-Forwarded-For без доверенного прокси не учитывается
+	forged := signed(foreign, "GET", "/client/orders?forged", "", now)
+	forged["X-Forwarded-For"] = "198.51.100.7"
+	if w := send("GET", "/client/orders?forged", "", "", forged); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for forged X-Forwarded-For, got %d", w.Code)
+	}
+
+	// Просроченный ключ
+	expiring := create(tok.AccessToken, `{"scopes":["read"],"expires_at":"`+now.Add(time.Hour).Format(time.RFC3339)+`"}`)
+	db.Model(&models.APIKey{}).Where("key_id = ?", expiring.KeyID).Update("expires_at", now.Add(-time.Minute))
+	if w := send("GET", "/client/orders", "", "", signed(expiring, "GET", "/client/orders", "", now)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for expired key, got %d", w.Code)
+	}
+
+	// Отозванный ключ перестаёт приниматься
+	if w := send("DELETE", "/auth/api-keys/"+key.ID, "", tok.AccessToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("revoke %d", w.Code)
+	}
+	if w := send("GET", "/client/orders", "", "", signed(key, "GET", "/client/orders", "", time.Now())); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for revoked key, got %d", w.Code)
+	}
+	w = send("GET", "/auth/api-keys", "", tok.AccessToken, nil)
+	var keys []APIKeyResponse
+	json.Unmarshal(w.Body.Bytes(), &keys)
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 active keys, got %d", len(keys))
+	}
+
+	var client models.Client
+	db.Where("username = ?", "botowner").First(&client)
+	var notes int64
+	db.Model(&models.Notification{}).Where("client_id = ? AND type = ?", client.ID, "auth.api_key_created").Count(&notes)
+	if notes != 3 {
+		t.Fatalf("expected 3 api key notifications, got %d", notes)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"log"
 	"math/rand"
 	"net/http"
 	"regexp"
@@ -18,7 +19,7 @@ import (
 	"gorm.io/gorm"
 
 	"ptop/internal/models"
-	"ptop/internal/utils"
+	"ptop/internal/webauthn"
 )
 
 // Общие структуры запросов и ответов для Swagger и тестов
@@ -92,8 +93,9 @@ type Enable2FARequest struct {
 }
 
 type Enable2FAResponse struct {
-	Secret string `json:"secret"`
-	URL    string `json:"url"`
+	Secret      string   `json:"secret"`
+	URL         string   `json:"url"`
+	BackupCodes []string `json:"backup_codes"`
 }
 
 type Disable2FARequest struct {
@@ -117,9 +119,10 @@ type RegenerateMnemonicResponse struct {
 }
 
 type ProfileResponse struct {
-	Username     string `json:"username"`
-	TwoFAEnabled bool   `json:"twofa_enabled"`
-	PinCodeSet   bool   `json:"pincode_set"`
+	Username        string `json:"username"`
+	TwoFAEnabled    bool   `json:"twofa_enabled"`
+	PinCodeSet      bool   `json:"pincode_set"`
+	BackupCodesLeft int64  `json:"backup_codes_left"`
 }
 
 type StatusResponse struct {
@@ -192,15 +195,14 @@ func Register(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
 			return
 		}
-		accessStr, _ := utils.GenerateNanoID()
-		refreshStr, _ := utils.GenerateNanoID()
-		access := models.Token{ClientID: client.ID, Token: accessStr, Type: "access", ExpiresAt: time.Now().Add(ttl["access"])}
-		refresh := models.Token{ClientID: client.ID, Token: refreshStr, Type: "refresh", ExpiresAt: time.Now().Add(ttl["refresh"])}
-		db.Create(&access)
-		db.Create(&refresh)
+		tokens, err := login(db, c, client.ID, ttl, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
 		c.JSON(http.StatusOK, RegisterResponse{
-			AccessToken:  accessStr,
-			RefreshToken: refreshStr,
+			AccessToken:  tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
 			Mnemonic:     respMn,
 		})
 	}
@@ -208,7 +210,7 @@ func Register(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 
 // Login godoc
 // @Summary Вход клиента
-// @Description Аутентифицирует клиента и выдаёт пару токенов. При включённой 2FA требуется код.
+// @Description Аутентифицирует клиента и выдаёт пару токенов. При включённой 2FA требуется код TOTP или одноразовый резервный код.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -216,6 +218,7 @@ func Register(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 // @Success 200 {object} TokenResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
 // @Router /auth/login [post]
 func Login(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -224,33 +227,43 @@ func Login(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
 			return
 		}
+		keys := authKeys(c, authActionLogin, "user", r.Username)
+		if !authAllowed(c, keys) {
+			return
+		}
 		var client models.Client
+		fail := func(msg string) {
+			if !authFailed(c, db, models.AuthFailure{Action: authActionLogin, Username: r.Username, ClientID: client.ID}, keys) {
+				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: msg})
+			}
+		}
 		if err := db.Where("username = ?", r.Username).First(&client).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid credentials"})
+			fail("invalid credentials")
 			return
 		}
 		if client.Password == nil || bcrypt.CompareHashAndPassword([]byte(*client.Password), []byte(r.Password)) != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid credentials"})
+			fail("invalid credentials")
 			return
 		}
 		if client.TwoFAEnabled {
-			if r.Code == "" || client.TOTPSecret == nil || !totp.Validate(r.Code, *client.TOTPSecret) {
-				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid code"})
+			if !check2FACode(db, c, client, r.Code) {
+				fail("invalid code")
 				return
 			}
 		}
-		accessStr, _ := utils.GenerateNanoID()
-		refreshStr, _ := utils.GenerateNanoID()
-		access := models.Token{ClientID: client.ID, Token: accessStr, Type: "access", ExpiresAt: time.Now().Add(ttl["access"])}
-		refresh := models.Token{ClientID: client.ID, Token: refreshStr, Type: "refresh", ExpiresAt: time.Now().Add(ttl["refresh"])}
-		db.Create(&access)
-		db.Create(&refresh)
-		c.JSON(http.StatusOK, TokenResponse{AccessToken: accessStr, RefreshToken: refreshStr})
+		authSucceeded(c, keys)
+		tokens, err := login(db, c, client.ID, ttl, true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, tokens)
 	}
 }
 
 // Refresh godoc
 // @Summary Обновление access токена
+// @Description Обменивает refresh токен на новую пару в той же сессии. Refresh токен одноразовый: повторное предъявление завершает сессию. Токен принимается только с тем же User-Agent, с которым был выдан.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -267,22 +280,66 @@ func Refresh(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 			return
 		}
 		var token models.Token
-		if err := db.Where("token = ? AND type = ?", r.RefreshToken, "refresh").First(&token).Error; err != nil {
+		if err := db.Where("token_hash = ? AND type = ?", hashToken(r.RefreshToken), "refresh").First(&token).Error; err != nil {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid token"})
 			return
 		}
+		if token.RevokedAt != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "token revoked"})
+			return
+		}
 		if token.ExpiresAt.Before(time.Now()) {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "token expired"})
 			return
 		}
-		db.Delete(&token)
-		accessStr, _ := utils.GenerateNanoID()
-		refreshStr, _ := utils.GenerateNanoID()
-		access := models.Token{ClientID: token.ClientID, Token: accessStr, Type: "access", ExpiresAt: time.Now().Add(ttl["access"])}
-		refresh := models.Token{ClientID: token.ClientID, Token: refreshStr, Type: "refresh", ExpiresAt: time.Now().Add(ttl["refresh"])}
-		db.Create(&access)
-		db.Create(&refresh)
-		c.JSON(http.StatusOK, TokenResponse{AccessToken: accessStr, RefreshToken: refreshStr})
+		// Повторное предъявление проверяется до привязки к клиенту: обменянный
+		// токен с чужим User-Agent тоже считается перехваченным
+		reused := token.UsedAt != nil
+		if !reused && token.UserAgent != requestUserAgent(c) {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "token binding mismatch"})
+			return
+		}
+		var tokens TokenResponse
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if reused {
+				return nil
+			}
+			// Условное обновление не даёт обменять один refresh токен дважды
+			// параллельными запросами
+			res := tx.Model(&models.Token{}).Where("id = ? AND used_at IS NULL", token.ID).Update("used_at", time.Now())
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				reused = true
+				return nil
+			}
+			// Access токены прежней пары больше не нужны
+			if err := tx.Model(&models.Token{}).
+				Where("session_id = ? AND type = ? AND revoked_at IS NULL", token.SessionID, "access").
+				Update("revoked_at", time.Now()).Error; err != nil {
+				return err
+			}
+			var err error
+			tokens, err = issueTokens(tx, c, token.ClientID, token.SessionID, ttl)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		if reused {
+			// Уже обменянный токен предъявлен снова: его мог перехватить
+			// злоумышленник, поэтому сессия завершается целиком
+			log.Printf("повторное использование refresh токена клиента %s, сессия %s завершена", token.ClientID, token.SessionID)
+			if err := revokeSessions(db, token.SessionID); err != nil {
+				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+				return
+			}
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "token reused"})
+			return
+		}
+		c.JSON(http.StatusOK, tokens)
 	}
 }
 
@@ -328,6 +385,7 @@ func RecoverChallenge(db *gorm.DB) gin.HandlerFunc {
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
 // @Router /auth/recover [post]
 func Recover(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -336,9 +394,18 @@ func Recover(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
 			return
 		}
+		keys := authKeys(c, authActionRecover, "user", r.Username)
+		if !authAllowed(c, keys) {
+			return
+		}
 		var client models.Client
+		fail := func(status int, msg string) {
+			if !authFailed(c, db, models.AuthFailure{Action: authActionRecover, Username: r.Username, ClientID: client.ID}, keys) {
+				c.JSON(status, ErrorResponse{Error: msg})
+			}
+		}
 		if err := db.Where("username = ?", r.Username).First(&client).Error; err != nil {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "not found"})
+			fail(http.StatusNotFound, "not found")
 			return
 		}
 		var hashes []string
@@ -352,15 +419,16 @@ func Recover(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 		}
 		for _, p := range r.Phrases {
 			if p.Position <= 0 || p.Position > len(hashes) {
-				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid position"})
+				fail(http.StatusUnauthorized, "invalid position")
 				return
 			}
 			h := sha256.Sum256([]byte(p.Word))
 			if hex.EncodeToString(h[:]) != hashes[p.Position-1] {
-				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid phrase"})
+				fail(http.StatusUnauthorized, "invalid phrase")
 				return
 			}
 		}
+		authSucceeded(c, keys)
 		if r.NewPassword != r.PasswordConfirm {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "passwords do not match"})
 			return
@@ -376,18 +444,21 @@ func Recover(db *gorm.DB, ttl map[string]time.Duration) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
 			return
 		}
-		accessStr, _ := utils.GenerateNanoID()
-		refreshStr, _ := utils.GenerateNanoID()
-		access := models.Token{ClientID: client.ID, Token: accessStr, Type: "access", ExpiresAt: time.Now().Add(ttl["access"])}
-		refresh := models.Token{ClientID: client.ID, Token: refreshStr, Type: "refresh", ExpiresAt: time.Now().Add(ttl["refresh"])}
-		db.Create(&access)
-		db.Create(&refresh)
-		c.JSON(http.StatusOK, TokenResponse{AccessToken: accessStr, RefreshToken: refreshStr})
+		tokens, err := login(db, c, client.ID, ttl, true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, tokens)
 	}
 }
 
 func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if c.GetHeader(headerAPIKey) != "" {
+			apiKeyAuth(c, db)
+			return
+		}
 		header := c.GetHeader("Authorization")
 		parts := strings.SplitN(header, " ", 2)
 		tokenStr := ""
@@ -400,23 +471,37 @@ func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 				return
 			}
 		}
+		// Перебор токенов ограничивается по IP, в том числе для WebSocket,
+		// где токен передаётся в query
+		keys := authKeys(c, authActionToken, "", "")
+		if !authAllowed(c, keys) {
+			return
+		}
 		var token models.Token
-		if err := db.Where("token = ? AND type = ?", tokenStr, "access").First(&token).Error; err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		if err := db.Where("token_hash = ? AND type = ? AND revoked_at IS NULL", hashToken(tokenStr), "access").First(&token).Error; err != nil {
+			if !authFailed(c, db, models.AuthFailure{Action: authActionToken}, keys) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			}
 			return
 		}
+		// Просроченные токены удаляет TokenCleaner
 		if token.ExpiresAt.Before(time.Now()) {
-			db.Delete(&token)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token expired"})
 			return
 		}
+		// Время последней активности сессии обновляется не чаще раза в минуту
+		db.Model(&models.Session{}).
+			Where("id = ? AND last_seen_at < ?", token.SessionID, time.Now().Add(-time.Minute)).
+			Updates(map[string]any{"last_seen_at": time.Now(), "ip": c.ClientIP()})
 		c.Set("client_id", token.ClientID)
+		c.Set("session_id", token.SessionID)
 		c.Next()
 	}
 }
 
 // Logout godoc
 // @Summary Выход клиента
+// @Description Завершает текущую сессию; остальные сессии клиента продолжают действовать.
 // @Tags auth
 // @Security BearerAuth
 // @Produce json
@@ -431,7 +516,12 @@ func Logout(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 		clientID, _ := clientIDVal.(string)
-		db.Where("client_id = ?", clientID).Delete(&models.Token{})
+		sessionID := c.GetString("session_id")
+		if err := db.Where("client_id = ? AND session_id = ?", clientID, sessionID).Delete(&models.Token{}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		db.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", sessionID).Update("revoked_at", time.Now())
 		c.JSON(http.StatusOK, StatusResponse{Status: "logged out"})
 	}
 }
@@ -446,6 +536,7 @@ func Logout(db *gorm.DB) gin.HandlerFunc {
 // @Success 200 {object} VerifyPasswordResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
 // @Router /auth/verify-password [post]
 func VerifyPassword(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -465,14 +556,30 @@ func VerifyPassword(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
 			return
 		}
-		if client.Password == nil || bcrypt.CompareHashAndPassword([]byte(*client.Password), []byte(r.Password)) != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid password"})
+		if !checkPassword(c, db, client, r.Password) {
 			return
 		}
 		c.JSON(http.StatusOK, VerifyPasswordResponse{Verified: true})
 	}
 }
 
+// checkPassword проверяет пароль клиента с учётом лимита попыток. При
+// неверном пароле или блокировке отвечает сам и возвращает false.
+func checkPassword(c *gin.Context, db *gorm.DB, client models.Client, password string) bool {
+	keys := authKeys(c, authActionVerifyPassword, "client", client.ID)
+	if !authAllowed(c, keys) {
+		return false
+	}
+	if client.Password == nil || bcrypt.CompareHashAndPassword([]byte(*client.Password), []byte(password)) != nil {
+		if !authFailed(c, db, models.AuthFailure{Action: authActionVerifyPassword, Username: client.Username, ClientID: client.ID}, keys) {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid password"})
+		}
+		return false
+	}
+	authSucceeded(c, keys)
+	return true
+}
+
 // RegenerateMnemonic godoc
 // @Summary Перегенерация мнемонической фразы
 // @Tags auth
@@ -483,6 +590,7 @@ func VerifyPassword(db *gorm.DB) gin.HandlerFunc {
 // @Success 200 {object} RegenerateMnemonicResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
 // @Router /auth/mnemonic/regenerate [post]
 func RegenerateMnemonic(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -502,8 +610,7 @@ func RegenerateMnemonic(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
 			return
 		}
-		if client.Password == nil || bcrypt.CompareHashAndPassword([]byte(*client.Password), []byte(r.Password)) != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid password"})
+		if !checkPassword(c, db, client, r.Password) {
 			return
 		}
 		entropy, err := bip39.NewEntropy(128)
@@ -544,6 +651,7 @@ func RegenerateMnemonic(db *gorm.DB) gin.HandlerFunc {
 // @Success 200 {object} StatusResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
 // @Router /auth/password [post]
 func ChangePassword(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -567,8 +675,7 @@ func ChangePassword(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
 			return
 		}
-		if client.Password == nil || bcrypt.CompareHashAndPassword([]byte(*client.Password), []byte(r.OldPassword)) != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid password"})
+		if !checkPassword(c, db, client, r.OldPassword) {
 			return
 		}
 		hash, err := bcrypt.GenerateFromPassword([]byte(r.NewPassword), bcrypt.DefaultCost)
@@ -607,10 +714,16 @@ func Profile(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
 			return
 		}
+		left, err := backupCodesLeft(db, clientID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
 		c.JSON(http.StatusOK, ProfileResponse{
-			Username:     client.Username,
-			TwoFAEnabled: client.TwoFAEnabled,
-			PinCodeSet:   client.PinCode != nil,
+			Username:        client.Username,
+			TwoFAEnabled:    client.TwoFAEnabled,
+			PinCodeSet:      client.PinCode != nil,
+			BackupCodesLeft: left,
 		})
 	}
 }
@@ -626,6 +739,7 @@ func Profile(db *gorm.DB) gin.HandlerFunc {
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
 // @Router /auth/username [post]
 func ChangeUsername(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -645,8 +759,7 @@ func ChangeUsername(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
 			return
 		}
-		if client.Password == nil || bcrypt.CompareHashAndPassword([]byte(*client.Password), []byte(r.Password)) != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid password"})
+		if !checkPassword(c, db, client, r.Password) {
 			return
 		}
 		var count int64
@@ -666,6 +779,7 @@ func ChangeUsername(db *gorm.DB) gin.HandlerFunc {
 
 // SetPinCode godoc
 // @Summary Установка PIN-кода
+// @Description Первый фактор устанавливается по паролю; если у клиента уже есть PIN-код, 2FA или passkey, нужно ещё повышение прав для factors.change (заголовки X-Step-Up-*).
 // @Tags auth
 // @Security BearerAuth
 // @Accept json
@@ -674,8 +788,10 @@ func ChangeUsername(db *gorm.DB) gin.HandlerFunc {
 // @Success 200 {object} StatusResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse
+// @Failure 429 {object} LockedResponse
 // @Router /auth/pincode [post]
-func SetPinCode(db *gorm.DB) gin.HandlerFunc {
+func SetPinCode(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
 	re := regexp.MustCompile(`^[0-9]{4}$`)
 	return func(c *gin.Context) {
 		var r SetPinCodeRequest
@@ -698,8 +814,10 @@ func SetPinCode(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
 			return
 		}
-		if client.Password == nil || bcrypt.CompareHashAndPassword([]byte(*client.Password), []byte(r.Password)) != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid password"})
+		if !checkPassword(c, db, client, r.Password) {
+			return
+		}
+		if !confirmFactorChange(c, db, rp, client) {
 			return
 		}
 		hash, err := bcrypt.GenerateFromPassword([]byte(r.PinCode), bcrypt.DefaultCost)
@@ -719,6 +837,7 @@ func SetPinCode(db *gorm.DB) gin.HandlerFunc {
 
 // Enable2FA godoc
 // @Summary Включение двухфакторной аутентификации
+// @Description Возвращает секрет TOTP и одноразовые резервные коды для входа без аутентификатора. Коды показываются один раз. Если у клиента уже есть факторы, нужно повышение прав для factors.change.
 // @Tags auth
 // @Security BearerAuth
 // @Accept json
@@ -727,8 +846,10 @@ func SetPinCode(db *gorm.DB) gin.HandlerFunc {
 // @Success 200 {object} Enable2FAResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse
+// @Failure 429 {object} LockedResponse
 // @Router /auth/2fa/enable [post]
-func Enable2FA(db *gorm.DB) gin.HandlerFunc {
+func Enable2FA(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var r Enable2FARequest
 		if err := c.BindJSON(&r); err != nil {
@@ -746,14 +867,16 @@ func Enable2FA(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
 			return
 		}
-		if client.Password == nil || bcrypt.CompareHashAndPassword([]byte(*client.Password), []byte(r.Password)) != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid password"})
+		if !checkPassword(c, db, client, r.Password) {
 			return
 		}
 		if client.TwoFAEnabled {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "2fa already enabled"})
 			return
 		}
+		if !confirmFactorChange(c, db, rp, client) {
+			return
+		}
 		key, err := totp.Generate(totp.GenerateOpts{Issuer: "ptop", AccountName: client.Username})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "totp error"})
@@ -762,16 +885,26 @@ func Enable2FA(db *gorm.DB) gin.HandlerFunc {
 		secret := key.Secret()
 		client.TwoFAEnabled = true
 		client.TOTPSecret = &secret
-		if err := db.Save(&client).Error; err != nil {
+		var codes []string
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&client).Error; err != nil {
+				return err
+			}
+			var err error
+			codes, err = generateBackupCodes(tx, client.ID)
+			return err
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
 			return
 		}
-		c.JSON(http.StatusOK, Enable2FAResponse{Secret: secret, URL: key.URL()})
+		c.JSON(http.StatusOK, Enable2FAResponse{Secret: secret, URL: key.URL(), BackupCodes: codes})
 	}
 }
 
 // Disable2FA godoc
 // @Summary Отключение двухфакторной аутентификации
+// @Description Нужны пароль и повышение прав для factors.change. Пока TOTP входит в политику повышения прав какого-либо действия, отключение отклоняется с 409.
 // @Tags auth
 // @Security BearerAuth
 // @Accept json
@@ -780,8 +913,11 @@ func Enable2FA(db *gorm.DB) gin.HandlerFunc {
 // @Success 200 {object} StatusResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
 // @Router /auth/2fa/disable [post]
-func Disable2FA(db *gorm.DB) gin.HandlerFunc {
+func Disable2FA(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var r Disable2FARequest
 		if err := c.BindJSON(&r); err != nil {
@@ -799,17 +935,34 @@ func Disable2FA(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
 			return
 		}
-		if client.Password == nil || bcrypt.CompareHashAndPassword([]byte(*client.Password), []byte(r.Password)) != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid password"})
+		if !checkPassword(c, db, client, r.Password) {
 			return
 		}
 		if !client.TwoFAEnabled {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "2fa not enabled"})
 			return
 		}
+		inPolicy, err := factorInPolicy(db, client.ID, FactorTOTP)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		if inPolicy {
+			c.JSON(http.StatusConflict, ErrorResponse{Error: "factor required by step-up policy"})
+			return
+		}
+		if !confirmFactorChange(c, db, rp, client) {
+			return
+		}
 		client.TwoFAEnabled = false
 		client.TOTPSecret = nil
-		if err := db.Save(&client).Error; err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&client).Error; err != nil {
+				return err
+			}
+			return tx.Where("client_id = ?", client.ID).Delete(&models.BackupCode{}).Error
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
 			return
 		}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+	"ptop/internal/services"
+)
+
+// Действия, попытки которых ограничиваются отдельно.
+const (
+	authActionLogin          = "login"
+	authActionRecover        = "recover"
+	authActionVerifyPassword = "verify_password"
+	authActionPinCode        = "pincode"
+	authActionToken          = "token"
+)
+
+// authLimiter ограничивает неудачные попытки аутентификации; nil отключает
+// ограничение.
+var authLimiter *services.AuthLimiter
+
+// authTokenMaxFailures — порог неверных токенов с одного IP. Он выше
+// порога паролей: за NAT старые токены предъявляют многие клиенты.
+var authTokenMaxFailures int
+
+// SetAuthLimiter включает защиту от перебора для входа, восстановления,
+// проверки пароля и PIN-кода и для токенов в REST и WebSocket.
+func SetAuthLimiter(l *services.AuthLimiter, tokenMaxFailures int) {
+	authLimiter = l
+	authTokenMaxFailures = tokenMaxFailures
+}
+
+// LockedResponse — ответ 429 при блокировке; RetryAfter в секундах
+// дублирует заголовок Retry-After.
+type LockedResponse struct {
+	Error      string `json:"error"`
+	RetryAfter int    `json:"retry_after"`
+}
+
+// authKeySet — ключи ограничения одной попытки. IP и пара субъекта с IP
+// блокируются с растущей длительностью. Субъект (имя пользователя или
+// клиент) ограничивается мягко: иначе любой мог бы запереть чужую учётную
+// запись неверными паролями.
+type authKeySet struct {
+	hard     []string
+	throttle []string
+}
+
+// authKeys строит ключи ограничения действия по IP запроса и, если задан
+// субъект, по субъекту с IP и по субъекту.
+func authKeys(c *gin.Context, action, scope, subject string) authKeySet {
+	ip := c.ClientIP()
+	keys := authKeySet{hard: []string{action + ":ip:" + ip}}
+	if subject != "" {
+		subjectKey := action + ":" + scope + ":" + subject
+		keys.hard = append(keys.hard, subjectKey+":ip:"+ip)
+		keys.throttle = []string{subjectKey}
+	}
+	return keys
+}
+
+func (k authKeySet) all() []string {
+	return append(append([]string{}, k.hard...), k.throttle...)
+}
+
+func abortLocked(c *gin.Context, retry time.Duration) {
+	secs := int(math.Ceil(retry.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(secs))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, LockedResponse{Error: "too many attempts", RetryAfter: secs})
+}
+
+// authAllowed отвечает 429, если один из ключей заблокирован. Ошибки Redis
+// не блокируют вход.
+func authAllowed(c *gin.Context, keys authKeySet) bool {
+	if authLimiter == nil {
+		return true
+	}
+	retry, err := authLimiter.Locked(c.Request.Context(), keys.all()...)
+	if err != nil {
+		log.Printf("ошибка проверки блокировки: %v", err)
+		return true
+	}
+	if retry > 0 {
+		abortLocked(c, retry)
+		return false
+	}
+	return true
+}
+
+// authFailed записывает неудачную попытку и учитывает её в лимите. Если
+// попытка привела к блокировке, отвечает 429 и возвращает true — вызывающий
+// не пишет свой ответ.
+func authFailed(c *gin.Context, db *gorm.DB, f models.AuthFailure, keys authKeySet) bool {
+	var retry time.Duration
+	if authLimiter != nil {
+		limit := 0
+		if f.Action == authActionToken {
+			limit = authTokenMaxFailures
+		}
+		var err error
+		if retry, err = authLimiter.Fail(c.Request.Context(), limit, keys.hard...); err != nil {
+			log.Printf("ошибка учёта неудачной попытки: %v", err)
+		}
+		throttle, err := authLimiter.Throttle(c.Request.Context(), keys.throttle...)
+		if err != nil {
+			log.Printf("ошибка учёта неудачной попытки: %v", err)
+		}
+		retry = max(retry, throttle)
+	}
+	f.IP = c.ClientIP()
+	f.UserAgent = requestUserAgent(c)
+	f.Locked = retry > 0
+	if err := db.Create(&f).Error; err != nil {
+		log.Printf("ошибка записи неудачной попытки: %v", err)
+	}
+	if retry > 0 {
+		abortLocked(c, retry)
+		return true
+	}
+	return false
+}
+
+// authSucceeded сбрасывает счётчики субъекта после успешной проверки.
+// Счётчик IP не сбрасывается, чтобы перебор чужих учётных записей нельзя
+// было обнулить входом в свою.
+func authSucceeded(c *gin.Context, keys authKeySet) {
+	if authLimiter == nil || len(keys.throttle) == 0 {
+		return
+	}
+	subject := append([]string{}, keys.hard[1:]...)
+	if err := authLimiter.Reset(c.Request.Context(), append(subject, keys.throttle...)...); err != nil {
+		log.Printf("ошибка сброса счётчика попыток: %v", err)
+	}
+}
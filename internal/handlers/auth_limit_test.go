@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"ptop/internal/models"
+)
+
+func TestLoginLockout(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	post := func(path, body, ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":40000"
+		r.ServeHTTP(w, req)
+		return w
+	}
+	post("/auth/register", `{"username":"bruteuser","password":"pass","password_confirm":"pass"}`, "198.51.100.1")
+
+	// Пятая неудача подряд с разных IP замедляет попытки входа под именем
+	for i := 1; i <= 4; i++ {
+		if w := post("/auth/login", `{"username":"bruteuser","password":"wrong"}`, "198.51.100.2"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d", i, w.Code)
+		}
+	}
+	w := post("/auth/login", `{"username":"bruteuser","password":"wrong"}`, "198.51.100.3")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	var resp LockedResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.RetryAfter != 60 || w.Header().Get("Retry-After") != strconv.Itoa(resp.RetryAfter) {
+		t.Fatalf("unexpected lock response %+v %q", resp, w.Header().Get("Retry-After"))
+	}
+
+	// Замедление действует и для верного пароля с другого IP, но не дольше
+	// AUTH_LOCK_BASE и без удвоения
+	if w := post("/auth/login", `{"username":"bruteuser","password":"pass"}`, "198.51.100.4"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for throttled user, got %d", w.Code)
+	}
+	locks, _ := authLimiter.Locks(context.Background())
+	for _, l := range locks {
+		if l.Key == "login:user:bruteuser" && l.RetryAfter > time.Minute {
+			t.Fatalf("username lock must not exceed lock base: %+v", l)
+		}
+	}
+	var failures []models.AuthFailure
+	db.Where("action = ? AND username = ?", authActionLogin, "bruteuser").Order("created_at").Find(&failures)
+	if len(failures) != 5 || !failures[4].Locked || failures[4].IP != "198.51.100.3" {
+		t.Fatalf("unexpected failures %+v", failures)
+	}
+
+	// IP, с которого перебирают разные имена, тоже блокируется
+	for i := 0; i < 5; i++ {
+		w = post("/auth/login", `{"username":"user`+strconv.Itoa(i)+`","password":"x"}`, "198.51.100.9")
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected ip lock, got %d", w.Code)
+	}
+}
+
+func TestLoginLockoutForgedForwardedFor(t *testing.T) {
+	_, r, _ := setupTest(t)
+
+	// Подменённый X-Forwarded-For не обходит блокировку IP
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 6; i++ {
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBufferString(`{"username":"xff`+strconv.Itoa(i)+`","password":"x"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "203.0.113."+strconv.Itoa(i))
+		req.RemoteAddr = "198.51.100.20:40000"
+		r.ServeHTTP(w, req)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected ip lock despite forged X-Forwarded-For, got %d", w.Code)
+	}
+}
+
+func TestChangePasswordLockout(t *testing.T) {
+	_, r, _ := setupTest(t)
+
+	post := func(path, body, access string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if access != "" {
+			req.Header.Set("Authorization", "Bearer "+access)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := post("/auth/register", `{"username":"stolenuser","password":"pass","password_confirm":"pass"}`, "")
+	var tok tokenResp
+	json.Unmarshal(w.Body.Bytes(), &tok)
+
+	// Украденный токен не даёт подбирать пароль через смену пароля
+	const body = `{"old_password":"wrong","new_password":"pass2","confirm_password":"pass2"}`
+	for i := 1; i <= 4; i++ {
+		if w := post("/auth/password", body, tok.AccessToken); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d", i, w.Code)
+		}
+	}
+	if w := post("/auth/password", body, tok.AccessToken); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", w.Code)
+	}
+	// Счётчик общий с другими проверками пароля
+	if w := post("/auth/verify-password", `{"password":"pass"}`, tok.AccessToken); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected verify-password to be locked, got %d", w.Code)
+	}
+}
+
+func TestTokenLockoutWebSocket(t *testing.T) {
+	_, r, _ := setupTest(t)
+	SetAuthLimiter(authLimiter, 3)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/ws/orders?token=guess", nil)
+		req.RemoteAddr = "192.0.2.7:40000"
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get()
+	get()
+	if w := get(); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", w.Code)
+	}
+	if w := get(); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected lock to persist, got %d", w.Code)
+	}
+}
@@ -214,6 +214,7 @@ func TestProfileAndSettings(t *testing.T) {
 	req, _ = http.NewRequest("POST", "/auth/2fa/enable", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+log.AccessToken)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
 		t.Fatalf("enable2fa %d", w.Code)
@@ -224,6 +225,7 @@ func TestProfileAndSettings(t *testing.T) {
 	req, _ = http.NewRequest("POST", "/auth/2fa/disable", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+log.AccessToken)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
 		t.Fatalf("disable2fa %d", w.Code)
@@ -250,6 +252,8 @@ func TestLogout(t *testing.T) {
 	req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(w, req)
+	var reg registerResp
+	json.Unmarshal(w.Body.Bytes(), &reg)
 
 	body = `{"username":"logoutuser","password":"pass"}`
 	w = httptest.NewRecorder()
@@ -271,9 +275,10 @@ func TestLogout(t *testing.T) {
 	if err := db.Where("username = ?", "logoutuser").First(&client).Error; err != nil {
 		t.Fatalf("client lookup: %v", err)
 	}
+	// Удаляются только токены текущей сессии, сессия регистрации остаётся
 	var count int64
 	db.Model(&models.Token{}).Where("client_id = ?", client.ID).Count(&count)
-	if count != 0 {
+	if count != 2 {
 		t.Fatalf("tokens left %d", count)
 	}
 
@@ -284,6 +289,14 @@ func TestLogout(t *testing.T) {
 	if w.Code != http.StatusUnauthorized {
 		t.Fatalf("profile status %d", w.Code)
 	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/auth/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+reg.AccessToken)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("other session profile status %d", w.Code)
+	}
 }
 
 func TestVerifyPassword(t *testing.T) {
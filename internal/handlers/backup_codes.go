@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+	"ptop/internal/notifications"
+	"ptop/internal/webauthn"
+)
+
+// backupCodeCount — сколько резервных кодов выдаётся за раз.
+const backupCodeCount = 10
+
+// backupCodeAlphabet не содержит похожих символов (0/o, 1/l/i).
+const backupCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+type RegenerateBackupCodesRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// BackupCodesResponse — новые резервные коды; показываются один раз.
+type BackupCodesResponse struct {
+	BackupCodes []string `json:"backup_codes"`
+}
+
+// normalizeBackupCode убирает дефисы, пробелы и регистр, чтобы код можно
+// было ввести в любом виде.
+func normalizeBackupCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// newBackupCode возвращает код вида xxxx-xxxx.
+func newBackupCode() (string, error) {
+	// Байты выше кратного длине алфавита отбрасываются, чтобы символы были
+	// равновероятны
+	limit := byte(256 / len(backupCodeAlphabet) * len(backupCodeAlphabet))
+	out := make([]byte, 0, 8)
+	buf := make([]byte, 16)
+	for len(out) < 8 {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, v := range buf {
+			if v < limit && len(out) < 8 {
+				out = append(out, backupCodeAlphabet[int(v)%len(backupCodeAlphabet)])
+			}
+		}
+	}
+	return string(out[:4]) + "-" + string(out[4:]), nil
+}
+
+// generateBackupCodes заменяет резервные коды клиента новыми и возвращает
+// их в открытом виде.
+func generateBackupCodes(db *gorm.DB, clientID string) ([]string, error) {
+	if err := db.Where("client_id = ?", clientID).Delete(&models.BackupCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, backupCodeCount)
+	rows := make([]models.BackupCode, backupCodeCount)
+	for i := range codes {
+		code, err := newBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		rows[i] = models.BackupCode{ClientID: clientID, Hash: hashToken(normalizeBackupCode(code))}
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// backupCodesLeft возвращает число неиспользованных резервных кодов.
+func backupCodesLeft(db *gorm.DB, clientID string) (int64, error) {
+	var n int64
+	err := db.Model(&models.BackupCode{}).Where("client_id = ? AND used_at IS NULL", clientID).Count(&n).Error
+	return n, err
+}
+
+// useBackupCode погашает резервный код и уведомляет клиента. Условное
+// обновление не даёт использовать один код двумя параллельными запросами.
+func useBackupCode(db *gorm.DB, c *gin.Context, clientID, code string) bool {
+	code = normalizeBackupCode(code)
+	if code == "" {
+		return false
+	}
+	res := db.Model(&models.BackupCode{}).
+		Where("client_id = ? AND code_hash = ? AND used_at IS NULL", clientID, hashToken(code)).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		log.Printf("ошибка погашения резервного кода: %v", res.Error)
+		return false
+	}
+	if res.RowsAffected == 0 {
+		return false
+	}
+	left, err := backupCodesLeft(db, clientID)
+	if err != nil {
+		log.Printf("ошибка подсчёта резервных кодов: %v", err)
+	}
+	payload, err := json.Marshal(map[string]any{"remaining": left, "ip": c.ClientIP(), "device": deviceName(requestUserAgent(c))})
+	if err == nil {
+		n := models.Notification{ClientID: clientID, Type: "auth.backup_code_used", Payload: payload, LinkTo: "/auth/profile"}
+		if err := db.Create(&n).Error; err == nil {
+			notifications.Broadcast(clientID, n)
+		}
+	}
+	return true
+}
+
+// check2FACode принимает код TOTP или неиспользованный резервный код.
+func check2FACode(db *gorm.DB, c *gin.Context, client models.Client, code string) bool {
+	if code == "" || client.TOTPSecret == nil {
+		return false
+	}
+	if totp.Validate(code, *client.TOTPSecret) {
+		return true
+	}
+	return useBackupCode(db, c, client.ID, code)
+}
+
+// RegenerateBackupCodes godoc
+// @Summary Новые резервные коды 2FA
+// @Description Заменяет резервные коды новыми; прежние, в том числе неиспользованные, перестают действовать. Нужны пароль и текущий код TOTP: резервный код не принимается, иначе один украденный код давал бы весь новый набор. Кроме того, нужно повышение прав для factors.change.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body RegenerateBackupCodesRequest true "пароль и код TOTP"
+// @Success 200 {object} BackupCodesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse
+// @Failure 429 {object} LockedResponse
+// @Router /auth/2fa/backup-codes [post]
+func RegenerateBackupCodes(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r RegenerateBackupCodesRequest
+		if err := c.BindJSON(&r); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
+			return
+		}
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var client models.Client
+		if err := db.Where("id = ?", clientID).First(&client).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
+			return
+		}
+		if !checkPassword(c, db, client, r.Password) {
+			return
+		}
+		if !client.TwoFAEnabled {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "2fa not enabled"})
+			return
+		}
+		// Код проверяется с тем же лимитом, что и факторы повышения прав
+		keys := authKeys(c, authActionStepUp, "client", clientID)
+		if !authAllowed(c, keys) {
+			return
+		}
+		if r.Code == "" || !totp.Validate(r.Code, *client.TOTPSecret) {
+			if !authFailed(c, db, models.AuthFailure{Action: authActionStepUp, Username: client.Username, ClientID: clientID}, keys) {
+				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid code"})
+			}
+			return
+		}
+		authSucceeded(c, keys)
+		if !confirmFactorChange(c, db, rp, client) {
+			return
+		}
+		var codes []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			codes, err = generateBackupCodes(tx, clientID)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, BackupCodesResponse{BackupCodes: codes})
+	}
+}
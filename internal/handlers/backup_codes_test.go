@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+
+	"ptop/internal/models"
+)
+
+func TestBackupCodes(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	send := func(method, path, body, access string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if access != "" {
+			req.Header.Set("Authorization", "Bearer "+access)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	profile := func(access string) ProfileResponse {
+		var p ProfileResponse
+		json.Unmarshal(send("GET", "/auth/profile", "", access, nil).Body.Bytes(), &p)
+		return p
+	}
+
+	w := send("POST", "/auth/register", `{"username":"backupuser","password":"pass","password_confirm":"pass"}`, "", nil)
+	var tok tokenResp
+	json.Unmarshal(w.Body.Bytes(), &tok)
+
+	w = send("POST", "/auth/2fa/enable", `{"password":"pass"}`, tok.AccessToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable2fa %d", w.Code)
+	}
+	var enabled Enable2FAResponse
+	json.Unmarshal(w.Body.Bytes(), &enabled)
+	if len(enabled.BackupCodes) != backupCodeCount {
+		t.Fatalf("expected %d backup codes, got %d", backupCodeCount, len(enabled.BackupCodes))
+	}
+	if p := profile(tok.AccessToken); p.BackupCodesLeft != backupCodeCount {
+		t.Fatalf("expected %d codes left, got %d", backupCodeCount, p.BackupCodesLeft)
+	}
+
+	// TOTP по-прежнему принимается
+	code, _ := totp.GenerateCode(enabled.Secret, time.Now())
+	w = send("POST", "/auth/login", `{"username":"backupuser","password":"pass","code":"`+code+`"}`, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login with totp %d", w.Code)
+	}
+
+	// Резервный код принимается один раз и в любом регистре
+	backup := strings.ToUpper(enabled.BackupCodes[0])
+	w = send("POST", "/auth/login", `{"username":"backupuser","password":"pass","code":"`+backup+`"}`, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login with backup code %d", w.Code)
+	}
+	w = send("POST", "/auth/login", `{"username":"backupuser","password":"pass","code":"`+backup+`"}`, "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on reused backup code, got %d", w.Code)
+	}
+	if p := profile(tok.AccessToken); p.BackupCodesLeft != backupCodeCount-1 {
+		t.Fatalf("expected %d codes left, got %d", backupCodeCount-1, p.BackupCodesLeft)
+	}
+	var client models.Client
+	db.Where("username = ?", "backupuser").First(&client)
+	var notes []models.Notification
+	db.Where("client_id = ? AND type = ?", client.ID, "auth.backup_code_used").Find(&notes)
+	if len(notes) != 1 {
+		t.Fatalf("expected 1 backup code notification, got %d", len(notes))
+	}
+	var payload map[string]any
+	json.Unmarshal(notes[0].Payload, &payload)
+	if payload["remaining"] != float64(backupCodeCount-1) {
+		t.Fatalf("unexpected payload %s", notes[0].Payload)
+	}
+
+	// Новые коды заменяют прежние
+	w = send("POST", "/auth/2fa/backup-codes", `{"password":"wrong"}`, tok.AccessToken, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong password, got %d", w.Code)
+	}
+	// Нужен код TOTP; резервный код вместо него не принимается
+	w = send("POST", "/auth/2fa/backup-codes", `{"password":"pass"}`, tok.AccessToken, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without code, got %d", w.Code)
+	}
+	w = send("POST", "/auth/2fa/backup-codes", `{"password":"pass","code":"`+enabled.BackupCodes[2]+`"}`, tok.AccessToken, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with backup code, got %d", w.Code)
+	}
+	code, _ = totp.GenerateCode(enabled.Secret, time.Now())
+	w = send("POST", "/auth/2fa/backup-codes", `{"password":"pass","code":"`+code+`"}`, tok.AccessToken, map[string]string{"X-Step-Up-Code": code})
+	if w.Code != http.StatusOK {
+		t.Fatalf("regenerate %d", w.Code)
+	}
+	var regenerated BackupCodesResponse
+	json.Unmarshal(w.Body.Bytes(), &regenerated)
+	if len(regenerated.BackupCodes) != backupCodeCount {
+		t.Fatalf("expected %d new codes, got %d", backupCodeCount, len(regenerated.BackupCodes))
+	}
+	w = send("POST", "/auth/login", `{"username":"backupuser","password":"pass","code":"`+enabled.BackupCodes[1]+`"}`, "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on replaced backup code, got %d", w.Code)
+	}
+	if p := profile(tok.AccessToken); p.BackupCodesLeft != backupCodeCount {
+		t.Fatalf("expected %d codes left after regenerate, got %d", backupCodeCount, p.BackupCodesLeft)
+	}
+
+	// Отключение 2FA удаляет коды
+	w = send("POST", "/auth/2fa/disable", `{"password":"pass"}`, tok.AccessToken, map[string]string{"X-Step-Up-Code": code})
+	if w.Code != http.StatusOK {
+		t.Fatalf("disable2fa %d", w.Code)
+	}
+	if p := profile(tok.AccessToken); p.BackupCodesLeft != 0 {
+		t.Fatalf("expected no codes after disable, got %d", p.BackupCodesLeft)
+	}
+}
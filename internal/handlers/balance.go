@@ -32,3 +32,17 @@ func ListClientBalances(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, balances)
 	}
 }
+
+// frozenBalance сообщает, заморожен ли сверкой баланс кого-либо из клиентов
+// по одному из активов. Проверка стоит на всех действиях, которые резервируют
+// или передают средства другому клиенту: создание, изменение и включение
+// объявлений, создание ордера, выпуск средств и решение спора в пользу
+// покупателя. Отмена ордера возвращает эскроу тому же клиенту и не
+// блокируется; выводов и переводов в API нет.
+func frozenBalance(db *gorm.DB, clientIDs []string, assetIDs ...string) bool {
+	var count int64
+	db.Model(&models.Balance{}).
+		Where("client_id IN ? AND asset_id IN ? AND frozen_at IS NOT NULL", clientIDs, assetIDs).
+		Count(&count)
+	return count > 0
+}
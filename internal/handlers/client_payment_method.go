@@ -44,6 +44,7 @@ func ListClientPaymentMethods(db *gorm.DB) gin.HandlerFunc {
 
 // CreateClientPaymentMethod godoc
 // @Summary Создать платёжный метод клиента
+// @Description Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.
 // @Tags client-payment-methods
 // @Security BearerAuth
 // @Accept json
@@ -51,6 +52,7 @@ func ListClientPaymentMethods(db *gorm.DB) gin.HandlerFunc {
 // @Param input body CreateClientPaymentMethodRequest true "данные"
 // @Success 200 {object} models.ClientPaymentMethod
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse "нужно повышение прав"
 // @Failure 409 {object} ErrorResponse
 // @Router /client/payment-methods [post]
 func CreateClientPaymentMethod(db *gorm.DB) gin.HandlerFunc {
@@ -95,6 +97,7 @@ func CreateClientPaymentMethod(db *gorm.DB) gin.HandlerFunc {
 
 // UpdateClientPaymentMethod godoc
 // @Summary Изменить платёжный метод клиента
+// @Description Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.
 // @Tags client-payment-methods
 // @Security BearerAuth
 // @Accept json
@@ -103,6 +106,7 @@ func CreateClientPaymentMethod(db *gorm.DB) gin.HandlerFunc {
 // @Param input body CreateClientPaymentMethodRequest true "данные"
 // @Success 200 {object} models.ClientPaymentMethod
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse "нужно повышение прав"
 // @Failure 404 {object} ErrorResponse
 // @Router /client/payment-methods/{id} [put]
 func UpdateClientPaymentMethod(db *gorm.DB) gin.HandlerFunc {
@@ -150,10 +154,12 @@ func UpdateClientPaymentMethod(db *gorm.DB) gin.HandlerFunc {
 
 // DeleteClientPaymentMethod godoc
 // @Summary Удалить платёжный метод клиента
+// @Description Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.
 // @Tags client-payment-methods
 // @Security BearerAuth
 // @Param id path string true "ID"
 // @Success 200 {object} StatusResponse
+// @Failure 403 {object} StepUpRequiredResponse "нужно повышение прав"
 // @Failure 404 {object} ErrorResponse
 // @Router /client/payment-methods/{id} [delete]
 func DeleteClientPaymentMethod(db *gorm.DB) gin.HandlerFunc {
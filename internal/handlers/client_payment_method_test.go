@@ -39,12 +39,24 @@ func TestClientPaymentMethods(t *testing.T) {
 	}
 	token1 := reg1.AccessToken
 
+	// set pincode for user1
+	w = httptest.NewRecorder()
+	pinBody := `{"password":"pass","pincode":"1234"}`
+	req, _ = http.NewRequest("POST", "/auth/pincode", bytes.NewBufferString(pinBody))
+	req.Header.Set("Authorization", "Bearer "+token1)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("user1 pincode status %d", w.Code)
+	}
+
 	// create client payment method
 	createBody := fmt.Sprintf(`{"country_id":"%s","payment_method_id":"%s","city":"Moscow","post_code":"101000","detailed_information":"info","name":"Main"}`, country.ID, method.ID)
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("POST", "/client/payment-methods", bytes.NewBufferString(createBody))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token1)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
 		t.Fatalf("create status %d", w.Code)
@@ -62,6 +74,7 @@ func TestClientPaymentMethods(t *testing.T) {
 	req, _ = http.NewRequest("POST", "/client/payment-methods", bytes.NewBufferString(createBody))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token1)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusConflict {
 		t.Fatalf("duplicate status %d", w.Code)
@@ -92,6 +105,7 @@ func TestClientPaymentMethods(t *testing.T) {
 	req, _ = http.NewRequest("PUT", "/client/payment-methods/"+created.ID, bytes.NewBufferString(updateBody))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token1)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
 		t.Fatalf("update status %d", w.Code)
@@ -119,10 +133,22 @@ func TestClientPaymentMethods(t *testing.T) {
 	}
 	token2 := reg2.AccessToken
 
+	// set pincode for user2
+	w = httptest.NewRecorder()
+	pinBody = `{"password":"pass","pincode":"1234"}`
+	req, _ = http.NewRequest("POST", "/auth/pincode", bytes.NewBufferString(pinBody))
+	req.Header.Set("Authorization", "Bearer "+token2)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("user2 pincode status %d", w.Code)
+	}
+
 	// attempt delete by other user should fail
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("DELETE", "/client/payment-methods/"+created.ID, nil)
 	req.Header.Set("Authorization", "Bearer "+token2)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusNotFound {
 		t.Fatalf("delete foreign status %d", w.Code)
@@ -132,6 +158,7 @@ func TestClientPaymentMethods(t *testing.T) {
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("DELETE", "/client/payment-methods/"+created.ID, nil)
 	req.Header.Set("Authorization", "Bearer "+token1)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
 		t.Fatalf("delete status %d", w.Code)
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORS разрешает запросы веб-клиента с origins. Кроме обычных заголовков
+// браузер должен передавать факторы повышения прав X-Step-Up-*, иначе
+// preflight отклоняет release, решение спора и смену факторов, и читать
+// Retry-After ответов 429.
+func CORS(origins []string) gin.HandlerFunc {
+	return cors.New(cors.Config{
+		AllowOrigins: origins,
+		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders: []string{
+			"Origin", "Content-Type", "Authorization",
+			headerStepUpToken, headerStepUpPinCode, headerStepUpCode, headerStepUpPasskey,
+		},
+		ExposeHeaders:    []string{"Retry-After"},
+		AllowCredentials: true,
+	})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSStepUpPreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORS([]string{"http://localhost:5173"}))
+	r.POST("/orders/:id/release", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Браузер отправит запрос, только если заголовок есть в
+	// Access-Control-Allow-Headers ответа на preflight
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/orders/1/release", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "authorization, content-type, x-step-up-token")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected preflight to pass, got %d", w.Code)
+	}
+	allowed := strings.ToLower(w.Header().Get("Access-Control-Allow-Headers"))
+	for _, h := range []string{headerStepUpToken, headerStepUpPinCode, headerStepUpCode, headerStepUpPasskey} {
+		if !strings.Contains(allowed, strings.ToLower(h)) {
+			t.Fatalf("%s not allowed: %q", h, allowed)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/orders/1/release", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	r.ServeHTTP(w, req)
+	if !strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "Retry-After") {
+		t.Fatalf("Retry-After not exposed: %q", w.Header().Get("Access-Control-Expose-Headers"))
+	}
+}
@@ -3,19 +3,32 @@ package handlers
 import (
 	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 
 	"ptop/internal/models"
+	"ptop/internal/watchers"
 )
 
 type DebugDepositor interface {
 	TriggerDeposit(walletID string, amount decimal.Decimal)
 }
 
+// DebugDepositors собирает наблюдатели супервизора, поддерживающие тестовые
+// депозиты, по именам сетей.
+func DebugDepositors(sup *watchers.Supervisor) map[string]DebugDepositor {
+	res := make(map[string]DebugDepositor)
+	for _, chain := range sup.Chains() {
+		w, _ := sup.Watcher(chain)
+		if d, ok := w.(DebugDepositor); ok {
+			res[chain] = d
+		}
+	}
+	return res
+}
+
 type DebugDepositRequest struct {
 	WalletID string `json:"wallet_id" binding:"required"`
 	Amount   string `json:"amount" binding:"required"`
@@ -45,7 +58,7 @@ func DebugDeposit(db *gorm.DB, watchers map[string]DebugDepositor) gin.HandlerFu
 			return
 		}
 		var wal models.Wallet
-		if err := db.Preload("Asset").Where("id = ?", req.WalletID).First(&wal).Error; err != nil {
+		if err := db.Where("id = ?", req.WalletID).First(&wal).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "wallet not found"})
 				return
@@ -53,18 +66,7 @@ func DebugDeposit(db *gorm.DB, watchers map[string]DebugDepositor) gin.HandlerFu
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error"})
 			return
 		}
-		var watcher DebugDepositor
-		name := strings.ToUpper(wal.Asset.Name)
-		switch {
-		case strings.HasPrefix(name, "BTC"):
-			watcher = watchers["BTC"]
-		case strings.HasPrefix(name, "ETH"), strings.HasPrefix(name, "USDT"):
-			watcher = watchers["ETH"]
-		case strings.HasPrefix(name, "XMR"):
-			watcher = watchers["XMR"]
-		case strings.HasPrefix(name, "USDC"):
-			watcher = watchers["USDC"]
-		}
+		watcher := watchers[wal.Network]
 		if watcher == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "watcher not available"})
 			return
@@ -14,6 +14,7 @@ import (
 	"gorm.io/gorm"
 
 	"ptop/internal/btcwatcher"
+	"ptop/internal/chains"
 	"ptop/internal/models"
 	"ptop/internal/solwatcher"
 )
@@ -24,18 +25,18 @@ func TestDebugDepositBTC(t *testing.T) {
 	if err != nil {
 		t.Fatalf("db open: %v", err)
 	}
-	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	client := models.Client{Username: "u"}
 	if err := db.Create(&client).Error; err != nil {
 		t.Fatalf("create client: %v", err)
 	}
-	asset := models.Asset{Name: "BTC"}
+	asset := models.Asset{Name: "BTC", Chain: chains.ChainBTC}
 	if err := db.Create(&asset).Error; err != nil {
 		t.Fatalf("create asset: %v", err)
 	}
-	wallet := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Value: "addr", DerivationIndex: 1}
+	wallet := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Network: asset.Chain, Value: "addr", DerivationIndex: 1}
 	if err := db.Create(&wallet).Error; err != nil {
 		t.Fatalf("create wallet: %v", err)
 	}
@@ -43,14 +44,14 @@ func TestDebugDepositBTC(t *testing.T) {
 	if err := db.Create(&bal).Error; err != nil {
 		t.Fatalf("create balance: %v", err)
 	}
-	w, err := btcwatcher.New(db, "", "", "", nil, true)
+	w, err := btcwatcher.New(db, chains.ChainBTC, "", "", "", nil, true)
 	if err != nil {
 		t.Fatalf("watcher: %v", err)
 	}
 	if err := w.Start(); err != nil {
 		t.Fatalf("start: %v", err)
 	}
-	watchers := map[string]DebugDepositor{"BTC": w}
+	watchers := map[string]DebugDepositor{chains.ChainBTC: w}
 	r := gin.Default()
 	r.POST("/debug/deposit", DebugDeposit(db, watchers))
 
@@ -84,18 +85,18 @@ func TestDebugDepositUSDC(t *testing.T) {
 	if err != nil {
 		t.Fatalf("db open: %v", err)
 	}
-	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	client := models.Client{Username: "u"}
 	if err := db.Create(&client).Error; err != nil {
 		t.Fatalf("create client: %v", err)
 	}
-	asset := models.Asset{Name: "USDC"}
+	asset := models.Asset{Name: "USDC", Chain: chains.ChainSOL}
 	if err := db.Create(&asset).Error; err != nil {
 		t.Fatalf("create asset: %v", err)
 	}
-	wallet := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Value: "addr", DerivationIndex: 1}
+	wallet := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Network: asset.Chain, Value: "addr", DerivationIndex: 1}
 	if err := db.Create(&wallet).Error; err != nil {
 		t.Fatalf("create wallet: %v", err)
 	}
@@ -103,14 +104,14 @@ func TestDebugDepositUSDC(t *testing.T) {
 	if err := db.Create(&bal).Error; err != nil {
 		t.Fatalf("create balance: %v", err)
 	}
-	w, err := solwatcher.New(db, "", "11111111111111111111111111111111", true)
+	w, err := solwatcher.New(db, "", true)
 	if err != nil {
 		t.Fatalf("watcher: %v", err)
 	}
 	if err := w.Start(); err != nil {
 		t.Fatalf("start: %v", err)
 	}
-	watchers := map[string]DebugDepositor{"USDC": w}
+	watchers := map[string]DebugDepositor{chains.ChainSOL: w}
 	r := gin.Default()
 	r.POST("/debug/deposit", DebugDeposit(db, watchers))
 
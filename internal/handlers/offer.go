@@ -33,6 +33,7 @@ type OfferRequest struct {
 // @Param input body OfferRequest true "данные"
 // @Success 200 {object} models.Offer
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "баланс заморожен сверкой"
 // @Router /client/offers [post]
 func CreateOffer(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -52,6 +53,10 @@ func CreateOffer(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid type"})
 			return
 		}
+		if frozenBalance(db, []string{clientID}, r.FromAssetID, r.ToAssetID) {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "balance frozen"})
+			return
+		}
 
 		maxAmount, err := decimal.NewFromString(r.MaxAmount)
 		if err != nil {
@@ -137,6 +142,7 @@ func CreateOffer(db *gorm.DB) gin.HandlerFunc {
 // @Param input body OfferRequest true "данные"
 // @Success 200 {object} models.Offer
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "баланс заморожен сверкой"
 // @Failure 404 {object} ErrorResponse
 // @Router /client/offers/{id} [put]
 func UpdateOffer(db *gorm.DB) gin.HandlerFunc {
@@ -162,6 +168,10 @@ func UpdateOffer(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid type"})
 			return
 		}
+		if frozenBalance(db, []string{clientID}, r.FromAssetID, r.ToAssetID) {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "balance frozen"})
+			return
+		}
 		maxAmount, err := decimal.NewFromString(r.MaxAmount)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid max_amount"})
@@ -235,6 +245,7 @@ func UpdateOffer(db *gorm.DB) gin.HandlerFunc {
 // @Param id path string true "ID"
 // @Success 200 {object} models.Offer
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "баланс заморожен сверкой"
 // @Failure 404 {object} ErrorResponse
 // @Router /client/offers/{id}/enable [post]
 func EnableOffer(db *gorm.DB, maxActive int) gin.HandlerFunc {
@@ -255,6 +266,10 @@ func EnableOffer(db *gorm.DB, maxActive int) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "already enabled"})
 			return
 		}
+		if frozenBalance(db, []string{clientID}, offer.FromAssetID, offer.ToAssetID) {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "balance frozen"})
+			return
+		}
 		var count int64
 		db.Model(&models.Offer{}).Where("client_id = ? AND is_enabled = ? AND ttl > ?", clientID, true, time.Now()).Count(&count)
 		if count >= int64(maxActive) {
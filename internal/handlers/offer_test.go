@@ -632,3 +632,55 @@ func TestListOffersPagination(t *testing.T) {
 		t.Fatalf("unexpected pagination result")
 	}
 }
+
+func TestEnableOfferFrozenBalance(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	body := `{"username":"frozenuser","password":"pass","password_confirm":"pass"}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &tok)
+	var client models.Client
+	db.Where("username = ?", "frozenuser").First(&client)
+
+	fiat := models.Asset{Name: "USD_frozen", Type: models.AssetTypeFiat, IsActive: true}
+	crypto := models.Asset{Name: "BTC_frozen", Type: models.AssetTypeCrypto, IsActive: true}
+	db.Create(&fiat)
+	db.Create(&crypto)
+	now := time.Now()
+	db.Create(&models.Balance{ClientID: client.ID, AssetID: crypto.ID, FrozenAt: &now})
+	offer := models.Offer{MaxAmount: decimal.NewFromInt(10), MinAmount: decimal.NewFromInt(1), Amount: decimal.NewFromInt(5), Price: decimal.NewFromInt(1),
+		Type: models.OfferTypeSell, FromAssetID: crypto.ID, ToAssetID: fiat.ID, TTL: now, ClientID: client.ID}
+	db.Create(&offer)
+
+	enable := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/client/offers/"+offer.ID+"/enable", nil)
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := enable(); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for frozen balance, got %d", code)
+	}
+	body = `{"type":"sell","from_asset_id":"` + crypto.ID + `","to_asset_id":"` + fiat.ID + `","max_amount":"10","min_amount":"1","amount":"5","price":"1"}`
+	for _, target := range []struct{ method, path string }{{"POST", "/client/offers"}, {"PUT", "/client/offers/" + offer.ID}} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(target.method, target.path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("expected 403 for %s %s with frozen balance, got %d", target.method, target.path, w.Code)
+		}
+	}
+	db.Model(&models.Balance{}).Where("client_id = ?", client.ID).Update("frozen_at", nil)
+	if code := enable(); code != http.StatusOK {
+		t.Fatalf("expected 200 after unfreeze, got %d", code)
+	}
+}
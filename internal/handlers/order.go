@@ -32,6 +32,8 @@ type OrderRequest struct {
 // @Success 200 {object} models.Order
 // @Failure 400 {object} ErrorResponse "нельзя создавать ордер на своё предложение"
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "баланс участника заморожен сверкой"
+// @Failure 429 {object} LockedResponse "слишком много неверных PIN-кодов"
 // @Router /client/orders [post]
 func CreateOrder(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -56,10 +58,17 @@ func CreateOrder(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
 			return
 		}
+		keys := authKeys(c, authActionPinCode, "client", clientID)
+		if !authAllowed(c, keys) {
+			return
+		}
 		if r.PinCode == "" || client.PinCode == nil || bcrypt.CompareHashAndPassword([]byte(*client.PinCode), []byte(r.PinCode)) != nil {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid pincode"})
+			if !authFailed(c, db, models.AuthFailure{Action: authActionPinCode, Username: client.Username, ClientID: clientID}, keys) {
+				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid pincode"})
+			}
 			return
 		}
+		authSucceeded(c, keys)
 		var offer models.Offer
 		if err := db.Preload("FromAsset").Preload("ToAsset").Where("id = ?", r.OfferID).First(&offer).Error; err != nil {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid offer"})
@@ -69,6 +78,10 @@ func CreateOrder(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "cannot order own offer"})
 			return
 		}
+		if frozenBalance(db, []string{clientID, offer.ClientID}, offer.FromAssetID, offer.ToAssetID) {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "balance frozen"})
+			return
+		}
 		order := models.Order{
 			OfferID:               offer.ID,
 			BuyerID:               clientID,
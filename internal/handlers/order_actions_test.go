@@ -58,6 +58,17 @@ func TestGetOrderActions(t *testing.T) {
 	var buyer models.Client
 	db.Where("username = ?", "buyer").First(&buyer)
 
+	// set pincode for seller
+	w = httptest.NewRecorder()
+	body = `{"password":"pass","pincode":"1234"}`
+	req, _ = http.NewRequest("POST", "/auth/pincode", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+sellerTok.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("seller pincode status %d", w.Code)
+	}
+
 	// set pincode for buyer
 	w = httptest.NewRecorder()
 	body = `{"password":"pass","pincode":"1234"}`
@@ -174,6 +185,7 @@ func TestGetOrderActions(t *testing.T) {
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("POST", "/orders/"+ord.ID+"/release", nil)
 	req.Header.Set("Authorization", "Bearer "+sellerTok.AccessToken)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
 		t.Fatalf("release %d", w.Code)
@@ -114,14 +114,14 @@ func MarkOrderPaid(db *gorm.DB) gin.HandlerFunc {
 
 // ReleaseOrder godoc
 // @Summary Выпустить средства (завершить ордер)
-// @Description PAID -> RELEASED. Только продавец (offerOwner). Устанавливает releasedAt, шлёт уведомления и WS.
+// @Description PAID -> RELEASED. Только продавец (offerOwner). Устанавливает releasedAt, шлёт уведомления и WS. Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.
 // @Tags orders
 // @Security BearerAuth
 // @Produce json
 // @Param id path string true "ID ордера"
 // @Success 200 {object} models.OrderFull
 // @Failure 400 {object} ErrorResponse
-// @Failure 403 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "нет доступа, баланс заморожен сверкой или нужно повышение прав (StepUpRequiredResponse)"
 // @Failure 404 {object} ErrorResponse
 // @Router /orders/{id}/release [post]
 func ReleaseOrder(db *gorm.DB) gin.HandlerFunc {
@@ -146,6 +146,10 @@ func ReleaseOrder(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusForbidden, ErrorResponse{Error: "forbidden"})
 			return
 		}
+		if frozenBalance(db, []string{order.BuyerID, order.SellerID}, order.FromAssetID, order.ToAssetID) {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "balance frozen"})
+			return
+		}
 		now := time.Now()
 		res := db.Model(&models.Order{}).
 			Where("id = ? AND status = ?", order.ID, models.OrderStatusPaid).
@@ -344,7 +348,7 @@ func OpenDispute(db *gorm.DB) gin.HandlerFunc {
 
 // ResolveDispute godoc
 // @Summary Решить спор
-// @Description DISPUTE -> RELEASED/CANCELLED. Только арбитраж. Шлёт уведомления и WS.
+// @Description DISPUTE -> RELEASED/CANCELLED. Только арбитраж. Шлёт уведомления и WS. Требует повышения прав: токен в X-Step-Up-Token или факторы в X-Step-Up-Pincode, X-Step-Up-Code, X-Step-Up-Passkey.
 // @Tags orders
 // @Security BearerAuth
 // @Accept json
@@ -353,7 +357,7 @@ func OpenDispute(db *gorm.DB) gin.HandlerFunc {
 // @Param input body handlers.ResolveDisputeRequest true "результат спора"
 // @Success 200 {object} models.OrderFull
 // @Failure 400 {object} ErrorResponse
-// @Failure 403 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "нет доступа, баланс заморожен сверкой или нужно повышение прав (StepUpRequiredResponse)"
 // @Failure 404 {object} ErrorResponse
 // @Router /orders/{id}/dispute/resolve [post]
 func ResolveDispute(db *gorm.DB) gin.HandlerFunc {
@@ -386,6 +390,10 @@ func ResolveDispute(db *gorm.DB) gin.HandlerFunc {
 		}
 		upd := map[string]any{"status": models.OrderStatus(r.Result)}
 		if r.Result == string(models.OrderStatusReleased) {
+			if frozenBalance(db, []string{order.BuyerID, order.SellerID}, order.FromAssetID, order.ToAssetID) {
+				c.JSON(http.StatusForbidden, ErrorResponse{Error: "balance frozen"})
+				return
+			}
 			upd["released_at"] = time.Now()
 		} else {
 			if r.Comment != nil {
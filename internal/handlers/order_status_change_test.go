@@ -61,6 +61,17 @@ func TestOrderStatusChangeFlow(t *testing.T) {
 	var buyer models.Client
 	db.Where("username = ?", "buyer").First(&buyer)
 
+	// set pincode for seller
+	w = httptest.NewRecorder()
+	body = `{"password":"pass","pincode":"1234"}`
+	req, _ = http.NewRequest("POST", "/auth/pincode", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+sellerTok.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("seller pincode status %d", w.Code)
+	}
+
 	// set pincode for buyer
 	w = httptest.NewRecorder()
 	body = `{"password":"pass","pincode":"1234"}`
@@ -152,6 +163,7 @@ func TestOrderStatusChangeFlow(t *testing.T) {
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("POST", "/orders/"+ord.ID+"/release", nil)
 	req.Header.Set("Authorization", "Bearer "+sellerTok.AccessToken)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
 		t.Fatalf("release %d", w.Code)
@@ -236,6 +248,17 @@ func TestResolveDisputeFlow(t *testing.T) {
 	}
 	json.Unmarshal(w.Body.Bytes(), &arbTok)
 
+	// set pincode for arb
+	w = httptest.NewRecorder()
+	body = `{"password":"pass","pincode":"1234"}`
+	req, _ = http.NewRequest("POST", "/auth/pincode", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+arbTok.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("arb pincode status %d", w.Code)
+	}
+
 	// set pincode for buyer
 	w = httptest.NewRecorder()
 	body = `{"password":"pass","pincode":"1234"}`
@@ -347,6 +370,7 @@ func TestResolveDisputeFlow(t *testing.T) {
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("POST", "/orders/"+ord.ID+"/dispute/resolve", bytes.NewBufferString("{\"result\":\"CANCELLED\",\"comment\":\"arb\"}"))
 	req.Header.Set("Authorization", "Bearer "+arbTok.AccessToken)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
@@ -404,6 +428,7 @@ func TestResolveDisputeFlow(t *testing.T) {
 	w = httptest.NewRecorder()
 	req, _ = http.NewRequest("POST", "/orders/"+ord2.ID+"/dispute/resolve", bytes.NewBufferString("{\"result\":\"RELEASED\"}"))
 	req.Header.Set("Authorization", "Bearer "+arbTok.AccessToken)
+	req.Header.Set("X-Step-Up-Pincode", "1234")
 	req.Header.Set("Content-Type", "application/json")
 	r.ServeHTTP(w, req)
 	if w.Code != http.StatusOK {
@@ -414,3 +439,68 @@ func TestResolveDisputeFlow(t *testing.T) {
 		t.Fatalf("unexpected order2 resolve %#v", ofull)
 	}
 }
+
+func TestReleaseFrozenBalance(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	send := func(method, path, body, access string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if access != "" {
+			req.Header.Set("Authorization", "Bearer "+access)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	register := func(username string) (string, models.Client) {
+		w := send("POST", "/auth/register", `{"username":"`+username+`","password":"pass","password_confirm":"pass"}`, "", nil)
+		var tok tokenResp
+		json.Unmarshal(w.Body.Bytes(), &tok)
+		send("POST", "/auth/pincode", `{"password":"pass","pincode":"1234"}`, tok.AccessToken, nil)
+		var client models.Client
+		db.Where("username = ?", username).First(&client)
+		return tok.AccessToken, client
+	}
+	sellerTok, seller := register("frozenseller")
+	arbTok, _ := register("frozenarb")
+	_, buyer := register("frozenbuyer")
+
+	fiat := models.Asset{Name: "USD_frozen_release", Type: models.AssetTypeFiat, IsActive: true}
+	crypto := models.Asset{Name: "BTC_frozen_release", Type: models.AssetTypeCrypto, IsActive: true}
+	db.Create(&fiat)
+	db.Create(&crypto)
+	offer := models.Offer{MaxAmount: decimal.NewFromInt(10), MinAmount: decimal.NewFromInt(1), Amount: decimal.NewFromInt(5), Price: decimal.NewFromInt(1),
+		FromAssetID: crypto.ID, ToAssetID: fiat.ID, TTL: time.Now().Add(time.Hour), ClientID: seller.ID}
+	db.Create(&offer)
+	order := func(status models.OrderStatus) models.Order {
+		o := models.Order{OfferID: offer.ID, BuyerID: buyer.ID, SellerID: seller.ID, AuthorID: buyer.ID, OfferOwnerID: seller.ID,
+			FromAssetID: crypto.ID, ToAssetID: fiat.ID, Amount: decimal.NewFromInt(2), Price: decimal.NewFromInt(1),
+			Status: status, ExpiresAt: time.Now().Add(time.Hour)}
+		db.Create(&o)
+		return o
+	}
+	paid := order(models.OrderStatusPaid)
+	disputed := order(models.OrderStatusDispute)
+	now := time.Now()
+	db.Create(&models.Balance{ClientID: buyer.ID, AssetID: crypto.ID, FrozenAt: &now})
+
+	pin := map[string]string{"X-Step-Up-Pincode": "1234"}
+	if w := send("POST", "/orders/"+paid.ID+"/release", "", sellerTok, pin); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 release with frozen balance, got %d", w.Code)
+	}
+	if w := send("POST", "/orders/"+disputed.ID+"/dispute/resolve", `{"result":"RELEASED"}`, arbTok, pin); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 resolve with frozen balance, got %d", w.Code)
+	}
+	// возврат эскроу продавцу не блокируется
+	if w := send("POST", "/orders/"+disputed.ID+"/dispute/resolve", `{"result":"CANCELLED"}`, arbTok, pin); w.Code != http.StatusOK {
+		t.Fatalf("expected cancel to pass, got %d: %s", w.Code, w.Body.String())
+	}
+	db.Model(&models.Balance{}).Where("client_id = ?", buyer.ID).Update("frozen_at", nil)
+	if w := send("POST", "/orders/"+paid.ID+"/release", "", sellerTok, pin); w.Code != http.StatusOK {
+		t.Fatalf("expected release after unfreeze, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -56,6 +56,7 @@ type AssetWithWallet struct {
 
 // GetAssets godoc
 // @Summary Список активных активов
+// @Description Для криптоактивов возвращает параметры сетей: контракт, точность, минимальные суммы и порог подтверждений.
 // @Tags reference
 // @Produce json
 // @Success 200 {array} models.Asset
@@ -63,7 +64,7 @@ type AssetWithWallet struct {
 func GetAssets(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var assets []models.Asset
-		if err := db.Where("is_active = ?", true).Find(&assets).Error; err != nil {
+		if err := db.Preload("Networks").Where("is_active = ?", true).Find(&assets).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
 			return
 		}
@@ -73,6 +74,7 @@ func GetAssets(db *gorm.DB) gin.HandlerFunc {
 
 // GetClientAssets godoc
 // @Summary Список активных активов с адресами кошельков и балансами клиента
+// @Description Адрес кошелька возвращается для основной сети актива; адреса других сетей — в GET /client/wallets.
 // @Tags reference
 // @Security BearerAuth
 // @Produce json
@@ -89,7 +91,7 @@ func GetClientAssets(db *gorm.DB) gin.HandlerFunc {
 		var assets []AssetWithWallet
 		if err := db.Model(&models.Asset{}).
 			Select("assets.id, assets.name, assets.description, assets.type, assets.is_active, assets.is_convertible, COALESCE(wallets.value, '') AS value, COALESCE(balances.amount, 0) AS amount,  COALESCE(balances.amount_escrow, 0) AS amount_escrow").
-			Joins("LEFT JOIN wallets ON wallets.asset_id = assets.id AND wallets.client_id = ? AND wallets.is_enabled = ?", clientID, true).
+			Joins("LEFT JOIN wallets ON wallets.asset_id = assets.id AND wallets.network = assets.chain AND wallets.client_id = ? AND wallets.is_enabled = ?", clientID, true).
 			Joins("LEFT JOIN balances ON balances.asset_id = assets.id AND balances.client_id = ?", clientID).
 			Where("assets.is_active = ? AND assets.type = ?", true, models.AssetTypeCrypto).
 			Scan(&assets).Error; err != nil {
@@ -30,6 +30,7 @@ func TestReferenceHandlers(t *testing.T) {
 	db.Create(&activeAsset)
 	db.Create(&cryptoAsset)
 	db.Create(&inactiveAsset)
+	db.Create(&models.AssetNetwork{AssetID: cryptoAsset.ID, Chain: "btc", Decimals: 8, Confirmations: 2})
 
 	// register user
 	body := `{"username":"refuser","password":"pass","password_confirm":"pass"}`
@@ -112,6 +113,9 @@ func TestReferenceHandlers(t *testing.T) {
 			t.Fatalf("inactive asset returned: %+v", a)
 		}
 		names[a.Name] = true
+		if a.Name == "BTC" && (len(a.Networks) != 1 || a.Networks[0].Chain != "btc" || a.Networks[0].Confirmations != 2) {
+			t.Fatalf("asset networks %+v", a.Networks)
+		}
 	}
 	if !names["Ruble"] || !names["BTC"] {
 		t.Fatalf("assets names %+v", names)
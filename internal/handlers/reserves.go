@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"ptop/internal/reserves"
+)
+
+// GetReservesProof godoc
+// @Summary Доказательство включения балансов в отчёт о резервах
+// @Description Возвращает последний подписанный снимок, лист клиента (соль и балансы) и путь до корня дерева Меркла с суммами. Клиент пересчитывает хеш листа sha256(0x00 || sha256(clientID ":" salt) || балансы) и поднимается по пути до root; суммы корня должны совпасть с liabilities снимка.
+// @Tags reserves
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} reserves.Proof
+// @Failure 404 {object} ErrorResponse
+// @Router /client/reserves/proof [get]
+func GetReservesProof(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		proof, err := reserves.ProofFor(db, clientIDVal.(string))
+		switch {
+		case errors.Is(err, reserves.ErrNoSnapshot):
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "no snapshot"})
+			return
+		case errors.Is(err, reserves.ErrNotIncluded):
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "not included"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, proof)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"ptop/internal/chains"
+	"ptop/internal/models"
+	"ptop/internal/reserves"
+)
+
+func TestReservesProof(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	body := `{"username":"reserveuser","password":"pass","password_confirm":"pass"}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &tok)
+	var client models.Client
+	db.Where("username = ?", "reserveuser").First(&client)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/client/reserves/proof", nil)
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := get(); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without snapshot, got %d", w.Code)
+	}
+
+	asset := models.Asset{Name: "USD_reserves", Type: models.AssetTypeFiat}
+	db.Create(&asset)
+	db.Create(&models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.NewFromInt(10), AmountEscrow: decimal.NewFromInt(2)})
+	db.Create(&models.Balance{ClientID: "other", AssetID: asset.ID, Amount: decimal.NewFromInt(7), AmountEscrow: decimal.Zero})
+	report, err := reserves.Generate(context.Background(), db, func(string) (chains.Holdings, error) { return nil, nil }, nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if err := report.Save(db); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	w = get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("proof status %d: %s", w.Code, w.Body.String())
+	}
+	var proof reserves.Proof
+	if err := json.Unmarshal(w.Body.Bytes(), &proof); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !proof.Balances["USD_reserves"].Equal(decimal.NewFromInt(12)) || len(proof.Path) != 1 {
+		t.Fatalf("unexpected proof %+v", proof)
+	}
+	if err := reserves.VerifyProof(proof, client.ID); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+)
+
+// SessionResponse — активная сессия клиента.
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions godoc
+// @Summary Активные сессии
+// @Description Устройства, с которых выполнен вход: IP, время последней активности и входа. Текущая сессия отмечена current.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} SessionResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/sessions [get]
+func ListSessions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var sessions []models.Session
+		if err := db.Where("client_id = ? AND revoked_at IS NULL AND expires_at > ?", clientID, time.Now()).
+			Order("last_seen_at desc").Find(&sessions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		current := c.GetString("session_id")
+		resp := make([]SessionResponse, len(sessions))
+		for i, s := range sessions {
+			resp[i] = SessionResponse{Session: s, Current: s.ID == current}
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// RevokeSession godoc
+// @Summary Завершение сессии
+// @Description Отзывает все токены сессии. Завершение текущей сессии равносильно выходу.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Success 200 {object} StatusResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /auth/sessions/{id} [delete]
+func RevokeSession(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var sess models.Session
+		if err := db.Where("id = ? AND client_id = ? AND revoked_at IS NULL", c.Param("id"), clientID).First(&sess).Error; err != nil {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "not found"})
+			return
+		}
+		if err := revokeSessions(db, sess.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, StatusResponse{Status: "session revoked"})
+	}
+}
+
+// RevokeOtherSessions godoc
+// @Summary Выход на других устройствах
+// @Description Завершает все сессии клиента, кроме текущей.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} StatusResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/sessions/logout-others [post]
+func RevokeOtherSessions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var ids []string
+		if err := db.Model(&models.Session{}).
+			Where("client_id = ? AND id <> ? AND revoked_at IS NULL", clientID, c.GetString("session_id")).
+			Pluck("id", &ids).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		if err := revokeSessions(db, ids...); err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, StatusResponse{Status: "other sessions revoked"})
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ptop/internal/models"
+)
+
+func TestSessions(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	const (
+		desktop = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36"
+		phone   = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"
+	)
+	send := func(method, path, body, ua, access string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", ua)
+		if access != "" {
+			req.Header.Set("Authorization", "Bearer "+access)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	loginAs := func(ua string) tokenResp {
+		w := send("POST", "/auth/login", `{"username":"sessuser","password":"pass"}`, ua, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("login status %d", w.Code)
+		}
+		var tok tokenResp
+		json.Unmarshal(w.Body.Bytes(), &tok)
+		return tok
+	}
+	list := func(access string) []SessionResponse {
+		w := send("GET", "/auth/sessions", "", desktop, access)
+		if w.Code != http.StatusOK {
+			t.Fatalf("sessions status %d", w.Code)
+		}
+		var sessions []SessionResponse
+		json.Unmarshal(w.Body.Bytes(), &sessions)
+		return sessions
+	}
+
+	send("POST", "/auth/register", `{"username":"sessuser","password":"pass","password_confirm":"pass"}`, desktop, "")
+	var client models.Client
+	db.Where("username = ?", "sessuser").First(&client)
+
+	// Вход с известного устройства уведомления не создаёт, с нового — создаёт
+	cur := loginAs(desktop)
+	other := loginAs(phone)
+	third := loginAs(phone)
+	var notes []models.Notification
+	db.Where("client_id = ? AND type = ?", client.ID, "auth.new_device").Find(&notes)
+	if len(notes) != 1 {
+		t.Fatalf("expected 1 new device notification, got %d", len(notes))
+	}
+
+	sessions := list(cur.AccessToken)
+	if len(sessions) != 4 {
+		t.Fatalf("expected 4 sessions, got %d", len(sessions))
+	}
+	var current, phoneSession SessionResponse
+	for _, s := range sessions {
+		if s.Current {
+			current = s
+		}
+		if s.Device == "Safari on iOS" && phoneSession.ID == "" {
+			phoneSession = s
+		}
+	}
+	if current.Device != "Chrome on Windows" || phoneSession.ID == "" {
+		t.Fatalf("unexpected sessions %+v", sessions)
+	}
+
+	// Чужую или неизвестную сессию завершить нельзя
+	if w := send("DELETE", "/auth/sessions/unknown", "", desktop, cur.AccessToken); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	if w := send("DELETE", "/auth/sessions/"+phoneSession.ID, "", desktop, cur.AccessToken); w.Code != http.StatusOK {
+		t.Fatalf("revoke status %d", w.Code)
+	}
+	if len(list(cur.AccessToken)) != 3 {
+		t.Fatalf("session not revoked")
+	}
+
+	if w := send("POST", "/auth/sessions/logout-others", "", desktop, cur.AccessToken); w.Code != http.StatusOK {
+		t.Fatalf("logout others status %d", w.Code)
+	}
+	sessions = list(cur.AccessToken)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Fatalf("expected only current session, got %+v", sessions)
+	}
+	for _, tok := range []tokenResp{other, third} {
+		if w := send("GET", "/auth/profile", "", phone, tok.AccessToken); w.Code != http.StatusUnauthorized {
+			t.Fatalf("revoked session access status %d", w.Code)
+		}
+		if w := send("POST", "/auth/refresh", `{"refresh_token":"`+tok.RefreshToken+`"}`, phone, ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("revoked session refresh status %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+	"ptop/internal/statements"
+)
+
+// parseStatementTime разбирает границу периода: RFC 3339 или дату
+// YYYY-MM-DD (UTC). Дата в to включается в период целиком.
+func parseStatementTime(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// GetClientStatement godoc
+// @Summary Выписка по счёту
+// @Description Депозиты, выводы, внутренние переводы, эскроу и сделки за период в хронологическом порядке с остатком после каждой строки. Остатки на начало и конец периода идут строками opening_balance и closing_balance. Сделки показываются для справки и остаток не меняют. По умолчанию — последние 30 дней в CSV.
+// @Tags transactions
+// @Security BearerAuth
+// @Produce text/csv
+// @Produce application/pdf
+// @Param from query string false "начало периода: RFC 3339 или YYYY-MM-DD"
+// @Param to query string false "конец периода: RFC 3339 или YYYY-MM-DD (дата включается)"
+// @Param asset query string false "ID или название актива"
+// @Param format query string false "csv или pdf" Enums(csv, pdf)
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Router /client/statements [get]
+func GetClientStatement(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID := clientIDVal.(string)
+
+		to := time.Now().UTC()
+		if v := c.Query("to"); v != "" {
+			t, err := parseStatementTime(v, true)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid to"})
+				return
+			}
+			to = t
+		}
+		from := to.AddDate(0, 0, -30)
+		if v := c.Query("from"); v != "" {
+			t, err := parseStatementTime(v, false)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid from"})
+				return
+			}
+			from = t
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid period"})
+			return
+		}
+		format := c.DefaultQuery("format", "csv")
+		if format != "csv" && format != "pdf" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid format"})
+			return
+		}
+		var asset models.Asset
+		if v := c.Query("asset"); v != "" {
+			if err := db.Where("id = ? OR name = ?", v, v).First(&asset).Error; err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid asset"})
+				return
+			}
+		}
+
+		st, err := statements.Build(db, clientID, from, to, asset.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		st.Asset = asset.Name
+		name := "statement-" + from.UTC().Format("20060102") + "-" + to.UTC().Format("20060102") + "." + format
+		c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+		if format == "pdf" {
+			c.Header("Content-Type", "application/pdf")
+			err = statements.WritePDF(c.Writer, st)
+		} else {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			err = statements.WriteCSV(c.Writer, st)
+		}
+		if err != nil {
+			c.Error(err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"ptop/internal/models"
+)
+
+func TestGetClientStatement(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	body := `{"username":"stmtuser","password":"pass","password_confirm":"pass"}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &tok)
+	var client models.Client
+	db.Where("username = ?", "stmtuser").First(&client)
+
+	asset := models.Asset{Name: "BTC_stmt", Type: models.AssetTypeCrypto, IsActive: true}
+	db.Create(&asset)
+	now := time.Now()
+	db.Create(&models.TransactionIn{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.NewFromInt(3), Status: models.TransactionInStatusConfirmed, CreatedAt: now.Add(-time.Hour)})
+	db.Create(&models.TransactionOut{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.NewFromInt(1), Status: models.TransactionOutStatusPending, CreatedAt: now.Add(-time.Minute)})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/client/statements"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w = get("?asset=BTC_stmt")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") ||
+		!strings.Contains(w.Header().Get("Content-Disposition"), ".csv") {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if len(rows) != 5 || rows[2][1] != "deposit" || rows[3][1] != "withdrawal" || rows[3][4] != "2" || rows[4][4] != "2" {
+		t.Fatalf("unexpected csv %v", rows)
+	}
+
+	w = get("?format=pdf&from=" + now.AddDate(0, 0, -1).Format(time.DateOnly) + "&to=" + now.Format(time.DateOnly))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/pdf" || !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("unexpected pdf response %d %v", w.Code, w.Header())
+	}
+
+	for query, msg := range map[string]string{
+		"?format=xls":                    "invalid format",
+		"?from=yesterday":                "invalid from",
+		"?asset=UNKNOWN":                 "invalid asset",
+		"?from=2025-02-01&to=2025-01-01": "invalid period",
+	} {
+		w = get(query)
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusBadRequest || resp.Error != msg {
+			t.Fatalf("%s: expected 400 %q, got %d %q", query, msg, w.Code, resp.Error)
+		}
+	}
+}
@@ -0,0 +1,576 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+	"ptop/internal/utils"
+	"ptop/internal/webauthn"
+)
+
+// Факторы повышения прав.
+const (
+	FactorPinCode = "pincode"
+	FactorTOTP    = "totp"
+	FactorPasskey = "passkey"
+)
+
+// Действия, которые требуют повышения прав.
+const (
+	StepUpActionRelease       = "order.release"
+	StepUpActionResolve       = "dispute.resolve"
+	StepUpActionPaymentMethod = "payment_method.change"
+	StepUpActionWithdrawal    = "withdrawal"
+	StepUpActionAPIKey        = "api_key.create"
+	StepUpActionFactors       = "factors.change"
+)
+
+// stepUpActions — факторы, которыми можно подтвердить действие. Без
+// политики клиента достаточно любого из них.
+var stepUpActions = map[string][]string{
+	StepUpActionRelease:       {FactorPinCode, FactorTOTP, FactorPasskey},
+	StepUpActionResolve:       {FactorPinCode, FactorTOTP, FactorPasskey},
+	StepUpActionPaymentMethod: {FactorPinCode, FactorTOTP, FactorPasskey},
+	StepUpActionWithdrawal:    {FactorTOTP, FactorPasskey},
+	StepUpActionAPIKey:        {FactorPinCode, FactorTOTP, FactorPasskey},
+	StepUpActionFactors:       {FactorPinCode, FactorTOTP, FactorPasskey},
+}
+
+const authActionStepUp = "step_up"
+
+// Заголовки, в которых факторы передаются вместе с запросом действия.
+// Passkey — ответ /auth/webauthn/verify/finish в JSON, закодированный base64url.
+const (
+	headerStepUpToken   = "X-Step-Up-Token"
+	headerStepUpPinCode = "X-Step-Up-Pincode"
+	headerStepUpCode    = "X-Step-Up-Code"
+	headerStepUpPasskey = "X-Step-Up-Passkey"
+)
+
+// StepUpRequest — факторы для подтверждения. Code — код TOTP или
+// резервный код, Passkey — ответ на вызов /auth/webauthn/verify/begin.
+type StepUpRequest struct {
+	PinCode string                 `json:"pincode"`
+	Code    string                 `json:"code"`
+	Passkey *WebAuthnFinishRequest `json:"passkey"`
+}
+
+// StepUpResponse — токен повышенных прав для заголовка X-Step-Up-Token.
+type StepUpResponse struct {
+	StepUpToken string    `json:"step_up_token"`
+	Factors     []string  `json:"factors"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// StepUpRequiredResponse — ответ 403: какие факторы нужны для действия.
+// All означает, что обязательны все факторы, иначе достаточно одного.
+// Ошибка «step-up factor not configured» означает, что у клиента нет
+// нужных факторов и их сначала надо настроить.
+type StepUpRequiredResponse struct {
+	Error   string   `json:"error"`
+	Action  string   `json:"action"`
+	Factors []string `json:"factors"`
+	All     bool     `json:"all"`
+}
+
+// StepUpPolicyRequest — факторы, которые клиент требует для действия.
+type StepUpPolicyRequest struct {
+	Factors []string `json:"factors"`
+}
+
+// StepUpPolicyResponse — действующая политика действия; Custom отмечает
+// политику, заданную клиентом.
+type StepUpPolicyResponse struct {
+	Action  string   `json:"action"`
+	Factors []string `json:"factors"`
+	All     bool     `json:"all"`
+	Custom  bool     `json:"custom"`
+}
+
+// configuredFactors возвращает факторы, которые клиент настроил.
+func configuredFactors(db *gorm.DB, client models.Client) ([]string, error) {
+	var factors []string
+	if client.PinCode != nil {
+		factors = append(factors, FactorPinCode)
+	}
+	if client.TwoFAEnabled {
+		factors = append(factors, FactorTOTP)
+	}
+	var passkeys int64
+	if err := db.Model(&models.WebAuthnCredential{}).Where("client_id = ?", client.ID).Count(&passkeys).Error; err != nil {
+		return nil, err
+	}
+	if passkeys > 0 {
+		factors = append(factors, FactorPasskey)
+	}
+	return factors, nil
+}
+
+// stepUpRequirement возвращает факторы действия и признак «все
+// обязательны»: политику клиента или правило по умолчанию. Факторы
+// политики не отбрасываются, даже если клиент их не настроил, — иначе
+// отключение фактора ослабляло бы политику.
+func stepUpRequirement(db *gorm.DB, clientID, action string) ([]string, bool, bool, error) {
+	var policies []models.StepUpPolicy
+	if err := db.Where("client_id = ? AND action = ?", clientID, action).Limit(1).Find(&policies).Error; err != nil {
+		return nil, false, false, err
+	}
+	if len(policies) > 0 {
+		return splitList(policies[0].Factors), true, true, nil
+	}
+	return stepUpActions[action], false, false, nil
+}
+
+// factorInPolicy сообщает, требует ли фактор какая-либо политика клиента.
+func factorInPolicy(db *gorm.DB, clientID, factor string) (bool, error) {
+	var policies []models.StepUpPolicy
+	if err := db.Where("client_id = ?", clientID).Find(&policies).Error; err != nil {
+		return false, err
+	}
+	for _, p := range policies {
+		if slices.Contains(splitList(p.Factors), factor) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// splitList разбирает список через запятую.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// stepUpSatisfied проверяет, что подтверждённые факторы покрывают требование.
+func stepUpSatisfied(verified, required []string, all bool) bool {
+	for _, f := range required {
+		has := slices.Contains(verified, f)
+		if all && !has {
+			return false
+		}
+		if !all && has {
+			return true
+		}
+	}
+	return all && len(required) > 0
+}
+
+// verifyStepUpFactors проверяет переданные факторы и возвращает
+// подтверждённые. При неверном факторе отвечает 401 или 429 и возвращает
+// ok=false; reason уходит в журнал.
+func verifyStepUpFactors(c *gin.Context, db *gorm.DB, rp *webauthn.RelyingParty, client models.Client, r StepUpRequest) (verified []string, reason string, ok bool) {
+	if r.PinCode == "" && r.Code == "" && r.Passkey == nil {
+		return nil, "", true
+	}
+	keys := authKeys(c, authActionStepUp, "client", client.ID)
+	if !authAllowed(c, keys) {
+		return nil, "locked", false
+	}
+	fail := func(msg string) {
+		if !authFailed(c, db, models.AuthFailure{Action: authActionStepUp, Username: client.Username, ClientID: client.ID}, keys) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: msg})
+		}
+	}
+	if r.PinCode != "" {
+		if client.PinCode == nil || bcrypt.CompareHashAndPassword([]byte(*client.PinCode), []byte(r.PinCode)) != nil {
+			fail("invalid pincode")
+			return nil, "invalid pincode", false
+		}
+		verified = append(verified, FactorPinCode)
+	}
+	if r.Code != "" {
+		if !client.TwoFAEnabled || !check2FACode(db, c, client, r.Code) {
+			fail("invalid code")
+			return nil, "invalid code", false
+		}
+		verified = append(verified, FactorTOTP)
+	}
+	if r.Passkey != nil {
+		if _, err := verifyPasskey(db, rp, *r.Passkey, models.WebAuthnPurposeVerify, client.ID, false); err != nil {
+			fail("invalid passkey")
+			return nil, "invalid passkey", false
+		}
+		verified = append(verified, FactorPasskey)
+	}
+	authSucceeded(c, keys)
+	return verified, "", true
+}
+
+// auditStepUp записывает попытку повышения прав в журнал.
+func auditStepUp(db *gorm.DB, c *gin.Context, clientID, action, method string, factors []string, reason string) {
+	ev := models.StepUpEvent{
+		ClientID:  clientID,
+		SessionID: c.GetString("session_id"),
+		Action:    action,
+		Method:    method,
+		Factors:   strings.Join(factors, ","),
+		Success:   reason == "",
+		Reason:    reason,
+		IP:        c.ClientIP(),
+		UserAgent: requestUserAgent(c),
+	}
+	if err := db.Create(&ev).Error; err != nil {
+		log.Printf("ошибка записи журнала повышения прав: %v", err)
+	}
+}
+
+// inlineStepUp читает факторы из заголовков запроса.
+func inlineStepUp(c *gin.Context) (StepUpRequest, bool) {
+	r := StepUpRequest{PinCode: c.GetHeader(headerStepUpPinCode), Code: c.GetHeader(headerStepUpCode)}
+	if raw := c.GetHeader(headerStepUpPasskey); raw != "" {
+		data, err := webauthn.Encoding.DecodeString(strings.TrimRight(raw, "="))
+		if err != nil {
+			return r, false
+		}
+		var p WebAuthnFinishRequest
+		if err := json.Unmarshal(data, &p); err != nil {
+			return r, false
+		}
+		r.Passkey = &p
+	}
+	return r, true
+}
+
+// stepUp проверяет, что запрос подтверждён факторами, которых требует
+// действие: токеном повышенных прав или факторами в заголовках. Каждая
+// попытка записывается в журнал. Для запроса с API ключом вместо факторов
+// нужна область ключа. Если подтверждения нет, отвечает и возвращает false.
+// Клиент без нужных факторов получает 403, пока не настроит их: первый
+// PIN-код устанавливается по паролю.
+func stepUp(c *gin.Context, db *gorm.DB, rp *webauthn.RelyingParty, action string) bool {
+	if c.GetString("api_key_id") != "" {
+		return apiKeyStepUp(c, db, action)
+	}
+	clientIDVal, ok := c.Get("client_id")
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+		return false
+	}
+	clientID, _ := clientIDVal.(string)
+	var client models.Client
+	if err := db.Where("id = ?", clientID).First(&client).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
+		return false
+	}
+	configured, err := configuredFactors(db, client)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+		return false
+	}
+	required, all, _, err := stepUpRequirement(db, clientID, action)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+		return false
+	}
+	if !stepUpSatisfied(configured, required, all) {
+		// Нечем подтвердить: сначала нужно настроить фактор, например PIN-код
+		c.AbortWithStatusJSON(http.StatusForbidden, StepUpRequiredResponse{Error: "step-up factor not configured", Action: action, Factors: required, All: all})
+		return false
+	}
+
+	var verified []string
+	method := models.StepUpMethodInline
+	if tokenStr := c.GetHeader(headerStepUpToken); tokenStr != "" {
+		method = models.StepUpMethodToken
+		var token models.StepUpToken
+		if err := db.Where("token_hash = ? AND client_id = ? AND session_id = ? AND expires_at > ?",
+			hashToken(tokenStr), clientID, c.GetString("session_id"), time.Now()).First(&token).Error; err != nil {
+			auditStepUp(db, c, clientID, action, method, nil, "invalid token")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid step-up token"})
+			return false
+		}
+		verified = splitList(token.Factors)
+	} else {
+		r, ok := inlineStepUp(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Error: "invalid passkey"})
+			return false
+		}
+		var reason string
+		if verified, reason, ok = verifyStepUpFactors(c, db, rp, client, r); !ok {
+			auditStepUp(db, c, clientID, action, method, nil, reason)
+			return false
+		}
+		if len(verified) == 0 {
+			// Факторы не переданы: клиент узнаёт, какие нужны
+			c.AbortWithStatusJSON(http.StatusForbidden, StepUpRequiredResponse{Error: "step-up required", Action: action, Factors: required, All: all})
+			return false
+		}
+	}
+	if !stepUpSatisfied(verified, required, all) {
+		auditStepUp(db, c, clientID, action, method, verified, "insufficient factors")
+		c.AbortWithStatusJSON(http.StatusForbidden, StepUpRequiredResponse{Error: "step-up required", Action: action, Factors: required, All: all})
+		return false
+	}
+	auditStepUp(db, c, clientID, action, method, verified, "")
+	return true
+}
+
+// confirmFactorChange подтверждает изменение факторов клиента. Если
+// факторы уже настроены, нужно повышение прав для factors.change: иначе
+// украденный токен позволил бы добавить свой ключ и с ним проходить
+// повышение прав. Первый фактор настраивается по паролю, который
+// обработчик проверяет сам.
+func confirmFactorChange(c *gin.Context, db *gorm.DB, rp *webauthn.RelyingParty, client models.Client) bool {
+	configured, err := configuredFactors(db, client)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+		return false
+	}
+	if len(configured) == 0 {
+		return true
+	}
+	return stepUp(c, db, rp, StepUpActionFactors)
+}
+
+// RequireStepUp требует повышения прав для действия action перед
+// обработчиком. Ставится после AuthMiddleware.
+func RequireStepUp(db *gorm.DB, rp *webauthn.RelyingParty, action string) gin.HandlerFunc {
+	if _, ok := stepUpActions[action]; !ok {
+		panic("step-up: unknown action " + action)
+	}
+	return func(c *gin.Context) {
+		if !stepUp(c, db, rp, action) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// StepUp godoc
+// @Summary Токен повышенных прав
+// @Description Проверяет переданные факторы (PIN-код, код TOTP или резервный код, passkey) и выдаёт короткоживущий токен для заголовка X-Step-Up-Token. Токен действует только в текущей сессии и подтверждает проверенные факторы.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body StepUpRequest true "факторы"
+// @Success 200 {object} StepUpResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
+// @Router /auth/step-up [post]
+func StepUp(db *gorm.DB, rp *webauthn.RelyingParty, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r StepUpRequest
+		if err := c.BindJSON(&r); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
+			return
+		}
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var client models.Client
+		if err := db.Where("id = ?", clientID).First(&client).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
+			return
+		}
+		verified, reason, ok := verifyStepUpFactors(c, db, rp, client, r)
+		if !ok {
+			auditStepUp(db, c, clientID, "", models.StepUpMethodGrant, nil, reason)
+			return
+		}
+		if len(verified) == 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "no factors"})
+			return
+		}
+		tokenStr, err := utils.GenerateNanoID()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "token error"})
+			return
+		}
+		token := models.StepUpToken{
+			ClientID:  clientID,
+			SessionID: c.GetString("session_id"),
+			Hash:      hashToken(tokenStr),
+			Factors:   strings.Join(verified, ","),
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		if err := db.Create(&token).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		auditStepUp(db, c, clientID, "", models.StepUpMethodGrant, verified, "")
+		c.JSON(http.StatusOK, StepUpResponse{StepUpToken: tokenStr, Factors: verified, ExpiresAt: token.ExpiresAt})
+	}
+}
+
+// ListStepUpPolicies godoc
+// @Summary Политики повышения прав
+// @Description Для каждого действия — факторы и признак all: все обязательны или достаточно одного.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} StepUpPolicyResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/step-up/policies [get]
+func ListStepUpPolicies(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var client models.Client
+		if err := db.Where("id = ?", clientID).First(&client).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
+			return
+		}
+		actions := make([]string, 0, len(stepUpActions))
+		for a := range stepUpActions {
+			actions = append(actions, a)
+		}
+		sort.Strings(actions)
+		resp := make([]StepUpPolicyResponse, 0, len(actions))
+		for _, a := range actions {
+			factors, all, custom, err := stepUpRequirement(db, clientID, a)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+				return
+			}
+			resp = append(resp, StepUpPolicyResponse{Action: a, Factors: factors, All: all, Custom: custom})
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// SetStepUpPolicy godoc
+// @Summary Политика повышения прав для действия
+// @Description Требует для действия все перечисленные факторы. Факторы должны быть настроены и разрешены действием. Изменение подтверждается по текущей политике действия (заголовки X-Step-Up-*).
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param action path string true "действие"
+// @Param input body StepUpPolicyRequest true "факторы"
+// @Success 200 {object} StepUpPolicyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /auth/step-up/policies/{action} [put]
+func SetStepUpPolicy(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		action := c.Param("action")
+		allowed, ok := stepUpActions[action]
+		if !ok {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "unknown action"})
+			return
+		}
+		var r StepUpPolicyRequest
+		if err := c.ShouldBindJSON(&r); err != nil || len(r.Factors) == 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
+			return
+		}
+		if !stepUp(c, db, rp, action) {
+			return
+		}
+		clientID := c.GetString("client_id")
+		var client models.Client
+		if err := db.Where("id = ?", clientID).First(&client).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
+			return
+		}
+		configured, err := configuredFactors(db, client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		var factors []string
+		for _, f := range r.Factors {
+			if !slices.Contains(allowed, f) {
+				c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid factor"})
+				return
+			}
+			if !slices.Contains(configured, f) {
+				c.JSON(http.StatusBadRequest, ErrorResponse{Error: "factor not configured"})
+				return
+			}
+			if !slices.Contains(factors, f) {
+				factors = append(factors, f)
+			}
+		}
+		policy := models.StepUpPolicy{ClientID: clientID, Action: action}
+		if err := db.Where(policy).Assign(models.StepUpPolicy{Factors: strings.Join(factors, ",")}).FirstOrCreate(&policy).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, StepUpPolicyResponse{Action: action, Factors: factors, All: true, Custom: true})
+	}
+}
+
+// DeleteStepUpPolicy godoc
+// @Summary Сброс политики повышения прав
+// @Description Возвращает правило по умолчанию: достаточно любого разрешённого фактора. Подтверждается по текущей политике действия.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Param action path string true "действие"
+// @Success 200 {object} StatusResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /auth/step-up/policies/{action} [delete]
+func DeleteStepUpPolicy(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		action := c.Param("action")
+		if _, ok := stepUpActions[action]; !ok {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "unknown action"})
+			return
+		}
+		if !stepUp(c, db, rp, action) {
+			return
+		}
+		if err := db.Where("client_id = ? AND action = ?", c.GetString("client_id"), action).Delete(&models.StepUpPolicy{}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, StatusResponse{Status: "policy reset"})
+	}
+}
+
+// ListStepUpEvents godoc
+// @Summary Журнал повышения прав
+// @Description Выдача токенов повышенных прав и подтверждения действий клиента, успешные и нет, от новых к старым.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Param limit query int false "limit"
+// @Param offset query int false "offset"
+// @Success 200 {array} models.StepUpEvent
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/step-up/events [get]
+func ListStepUpEvents(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		limit, offset := parsePagination(c)
+		var events []models.StepUpEvent
+		if err := db.Where("client_id = ?", clientID).Order("created_at desc").
+			Limit(limit).Offset(offset).Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, events)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+
+	"ptop/internal/models"
+	"ptop/internal/webauthn"
+	"ptop/internal/webauthn/webauthntest"
+)
+
+func TestStepUp(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	send := func(method, path, body, access string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if access != "" {
+			req.Header.Set("Authorization", "Bearer "+access)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(code string) string {
+		w := send("POST", "/auth/login", `{"username":"stepuser","password":"pass","code":"`+code+`"}`, "", nil)
+		var tok tokenResp
+		json.Unmarshal(w.Body.Bytes(), &tok)
+		return tok.AccessToken
+	}
+	// Удаление несуществующего способа оплаты отвечает 404 только после
+	// успешного повышения прав
+	const action = "/client/payment-methods/missing"
+
+	send("POST", "/auth/register", `{"username":"stepuser","password":"pass","password_confirm":"pass"}`, "", nil)
+	access := login("")
+	var client models.Client
+	db.Where("username = ?", "stepuser").First(&client)
+
+	w := send("DELETE", action, "", access, nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without factors, got %d", w.Code)
+	}
+	var required StepUpRequiredResponse
+	json.Unmarshal(w.Body.Bytes(), &required)
+	if required.Error != "step-up factor not configured" || required.Action != StepUpActionPaymentMethod || required.All || len(required.Factors) != 3 {
+		t.Fatalf("unexpected requirement %+v", required)
+	}
+
+	send("POST", "/auth/pincode", `{"password":"pass","pincode":"1234"}`, access, nil)
+	if w := send("DELETE", action, "", access, map[string]string{"X-Step-Up-Pincode": "0000"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong pincode, got %d", w.Code)
+	}
+	if w := send("DELETE", action, "", access, map[string]string{"X-Step-Up-Pincode": "1234"}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected step-up with pincode, got %d", w.Code)
+	}
+
+	// Passkey в заголовке: ответ на вызов verify/begin
+	auth := webauthntest.New("localhost", "http://localhost:5173")
+	w = send("POST", "/auth/webauthn/register/begin", "", access, nil)
+	var reg WebAuthnRegisterBeginResponse
+	json.Unmarshal(w.Body.Bytes(), &reg)
+	created, _ := auth.Create(reg.PublicKey)
+	body, _ := json.Marshal(map[string]any{"challengeId": reg.ChallengeID, "credential": created, "password": "pass"})
+	// Второй фактор добавляется только с повышением прав
+	if w := send("POST", "/auth/webauthn/register/finish", string(body), access, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 adding passkey without step-up, got %d", w.Code)
+	}
+	if w := send("POST", "/auth/webauthn/register/finish", string(body), access, map[string]string{"X-Step-Up-Pincode": "1234"}); w.Code != http.StatusCreated {
+		t.Fatalf("register passkey %d", w.Code)
+	}
+	passkeyHeader := func() string {
+		w := send("POST", "/auth/webauthn/verify/begin", "", access, nil)
+		var begin WebAuthnAssertBeginResponse
+		json.Unmarshal(w.Body.Bytes(), &begin)
+		assertion, _ := auth.Get(begin.PublicKey)
+		raw, _ := json.Marshal(WebAuthnFinishRequest{ChallengeID: begin.ChallengeID, Credential: WebAuthnCredentialJSON{
+			ID: assertion.ID, RawID: assertion.RawID, Type: assertion.Type,
+			Response: WebAuthnAuthenticatorResponse(assertion.Response),
+		}})
+		return webauthn.Encoding.EncodeToString(raw)
+	}
+	if w := send("DELETE", action, "", access, map[string]string{"X-Step-Up-Passkey": passkeyHeader()}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected step-up with passkey, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Политика клиента: нужны и PIN-код, и passkey
+	w = send("PUT", "/auth/step-up/policies/"+StepUpActionPaymentMethod, `{"factors":["pincode","totp"]}`, access, map[string]string{"X-Step-Up-Pincode": "1234"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unconfigured factor, got %d", w.Code)
+	}
+	w = send("PUT", "/auth/step-up/policies/"+StepUpActionPaymentMethod, `{"factors":["pincode","passkey"]}`, access, map[string]string{"X-Step-Up-Pincode": "1234"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("set policy %d: %s", w.Code, w.Body.String())
+	}
+	w = send("DELETE", action, "", access, map[string]string{"X-Step-Up-Pincode": "1234"})
+	json.Unmarshal(w.Body.Bytes(), &required)
+	if w.Code != http.StatusForbidden || !required.All || len(required.Factors) != 2 {
+		t.Fatalf("expected 403 requiring all factors, got %d %+v", w.Code, required)
+	}
+	if w := send("DELETE", action, "", access, map[string]string{"X-Step-Up-Pincode": "1234", "X-Step-Up-Passkey": passkeyHeader()}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected step-up with both factors, got %d", w.Code)
+	}
+	// Остальные действия подтверждаются по правилу по умолчанию
+	w = send("GET", "/auth/step-up/policies", "", access, nil)
+	var policies []StepUpPolicyResponse
+	json.Unmarshal(w.Body.Bytes(), &policies)
+	for _, p := range policies {
+		if p.Custom != (p.Action == StepUpActionPaymentMethod) {
+			t.Fatalf("unexpected policies %+v", policies)
+		}
+	}
+
+	// Фактор из политики нельзя убрать, а сменить PIN-код можно только
+	// с повышением прав
+	w = send("GET", "/auth/webauthn/credentials", "", access, nil)
+	var creds []models.WebAuthnCredential
+	json.Unmarshal(w.Body.Bytes(), &creds)
+	if w := send("DELETE", "/auth/webauthn/credentials/"+creds[0].ID, `{"password":"pass"}`, access, nil); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting policy passkey, got %d", w.Code)
+	}
+	if w := send("POST", "/auth/pincode", `{"password":"pass","pincode":"0000"}`, access, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 changing pincode without step-up, got %d", w.Code)
+	}
+
+	// Токен повышенных прав с TOTP и PIN-кодом
+	send("POST", "/auth/2fa/enable", `{"password":"pass"}`, access, map[string]string{"X-Step-Up-Pincode": "1234"})
+	db.Where("id = ?", client.ID).First(&client)
+	code, _ := totp.GenerateCode(*client.TOTPSecret, time.Now())
+	w = send("POST", "/auth/step-up", `{"pincode":"1234","code":"`+code+`"}`, access, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("step-up token %d", w.Code)
+	}
+	var grant StepUpResponse
+	json.Unmarshal(w.Body.Bytes(), &grant)
+	if len(grant.Factors) != 2 {
+		t.Fatalf("unexpected grant %+v", grant)
+	}
+	// Без passkey токен не покрывает политику, для правила по умолчанию хватает
+	if w := send("DELETE", action, "", access, map[string]string{"X-Step-Up-Token": grant.StepUpToken}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 with insufficient token, got %d", w.Code)
+	}
+	if w := send("DELETE", "/auth/step-up/policies/"+StepUpActionPaymentMethod, "", access, map[string]string{"X-Step-Up-Pincode": "1234", "X-Step-Up-Passkey": passkeyHeader()}); w.Code != http.StatusOK {
+		t.Fatalf("reset policy %d", w.Code)
+	}
+	if w := send("DELETE", action, "", access, map[string]string{"X-Step-Up-Token": grant.StepUpToken}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected step-up with token, got %d", w.Code)
+	}
+	// Токен действует только в своей сессии
+	other := login(code)
+	if other == "" {
+		t.Fatalf("second login failed")
+	}
+	if w := send("DELETE", action, "", other, map[string]string{"X-Step-Up-Token": grant.StepUpToken}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for token from another session, got %d", w.Code)
+	}
+
+	var failed, succeeded int64
+	db.Model(&models.StepUpEvent{}).Where("client_id = ? AND success = ?", client.ID, false).Count(&failed)
+	db.Model(&models.StepUpEvent{}).Where("client_id = ? AND success = ?", client.ID, true).Count(&succeeded)
+	if failed != 4 || succeeded != 10 {
+		t.Fatalf("unexpected audit: %d failed, %d succeeded", failed, succeeded)
+	}
+	w = send("GET", "/auth/step-up/events?limit=1", "", access, nil)
+	var events []models.StepUpEvent
+	json.Unmarshal(w.Body.Bytes(), &events)
+	if len(events) != 1 || events[0].Success || events[0].Method != models.StepUpMethodToken {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"ptop/internal/models"
 	"ptop/internal/services"
 	storage "ptop/internal/services/storage"
+	"ptop/internal/webauthn"
 )
 
 // setupTest создаёт in-memory БД и маршруты для тестов.
@@ -31,6 +32,9 @@ func (d *dummyStorage) GetURL(ctx context.Context, objectName string, expiry tim
 
 var _ storage.Storage = (*dummyStorage)(nil)
 
+// testRP — проверяющая сторона WebAuthn тестовых маршрутов.
+var testRP = &webauthn.RelyingParty{ID: "localhost", Name: "ptop", Origins: []string{"http://localhost:5173"}}
+
 func setupTest(t *testing.T) (*gorm.DB, *gin.Engine, map[string]time.Duration) {
 	t.Helper()
 	gin.SetMode(gin.TestMode)
@@ -43,12 +47,23 @@ func setupTest(t *testing.T) (*gorm.DB, *gin.Engine, map[string]time.Duration) {
 	if err := db.AutoMigrate(
 		&models.Client{},
 		&models.Token{},
+		&models.Session{},
+		&models.AuthFailure{},
+		&models.BackupCode{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnChallenge{},
+		&models.StepUpToken{},
+		&models.StepUpPolicy{},
+		&models.StepUpEvent{},
+		&models.APIKey{},
 		&models.Country{},
 		&models.PaymentMethod{},
 		&models.ClientPaymentMethod{},
 		&models.Asset{},
+		&models.AssetNetwork{},
 		&models.Offer{},
 		&models.Wallet{},
+		&models.DerivationCounter{},
 		&models.Balance{},
 		&models.Escrow{},
 		&models.Order{},
@@ -58,6 +73,8 @@ func setupTest(t *testing.T) (*gorm.DB, *gin.Engine, map[string]time.Duration) {
 		&models.TransactionOut{},
 		&models.TransactionInternal{},
 		&models.Notification{},
+		&models.ReserveSnapshot{},
+		&models.ReserveLeaf{},
 	); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
@@ -67,25 +84,49 @@ func setupTest(t *testing.T) (*gorm.DB, *gin.Engine, map[string]time.Duration) {
 	mr := miniredis.RunT(t)
 	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
 	cache := services.NewChatCache(rdb, 50)
+	SetAuthLimiter(services.NewAuthLimiter(rdb, services.AuthLimitOptions{}), 50)
+	SetAPIKeyAuth(services.NewReplayGuard(rdb), []byte("test-api-key-secret"), 30*time.Second)
 	store := &dummyStorage{}
 
 	r := gin.Default()
+	// Как в cmd/api без TRUSTED_PROXIES: IP клиента — адрес соединения
+	r.SetTrustedProxies(nil)
 	auth := r.Group("/auth")
 	auth.POST("/register", Register(db, ttl))
 	auth.POST("/login", Login(db, ttl))
 	auth.POST("/refresh", Refresh(db, ttl))
 	auth.GET("/recover/:username", RecoverChallenge(db))
 	auth.POST("/recover", Recover(db, ttl))
+	auth.POST("/webauthn/login/begin", WebAuthnLoginBegin(db, testRP))
+	auth.POST("/webauthn/login/finish", WebAuthnLoginFinish(db, testRP, ttl))
 	auth.Use(AuthMiddleware(db))
 	auth.POST("/logout", Logout(db))
 	auth.GET("/profile", Profile(db))
 	auth.POST("/username", ChangeUsername(db))
-	auth.POST("/pincode", SetPinCode(db))
-	auth.POST("/2fa/enable", Enable2FA(db))
-	auth.POST("/2fa/disable", Disable2FA(db))
+	auth.POST("/pincode", SetPinCode(db, testRP))
+	auth.POST("/2fa/enable", Enable2FA(db, testRP))
+	auth.POST("/2fa/disable", Disable2FA(db, testRP))
+	auth.POST("/2fa/backup-codes", RegenerateBackupCodes(db, testRP))
 	auth.POST("/verify-password", VerifyPassword(db))
 	auth.POST("/mnemonic/regenerate", RegenerateMnemonic(db))
 	auth.POST("/password", ChangePassword(db))
+	auth.GET("/sessions", ListSessions(db))
+	auth.DELETE("/sessions/:id", RevokeSession(db))
+	auth.POST("/sessions/logout-others", RevokeOtherSessions(db))
+	auth.POST("/webauthn/register/begin", WebAuthnRegisterBegin(db, testRP))
+	auth.POST("/webauthn/register/finish", WebAuthnRegisterFinish(db, testRP))
+	auth.GET("/webauthn/credentials", ListWebAuthnCredentials(db))
+	auth.DELETE("/webauthn/credentials/:id", DeleteWebAuthnCredential(db, testRP))
+	auth.POST("/webauthn/verify/begin", WebAuthnVerifyBegin(db, testRP))
+	auth.POST("/webauthn/verify/finish", WebAuthnVerifyFinish(db, testRP))
+	auth.POST("/step-up", StepUp(db, testRP, 5*time.Minute))
+	auth.GET("/step-up/policies", ListStepUpPolicies(db))
+	auth.PUT("/step-up/policies/:action", SetStepUpPolicy(db, testRP))
+	auth.DELETE("/step-up/policies/:action", DeleteStepUpPolicy(db, testRP))
+	auth.GET("/step-up/events", ListStepUpEvents(db))
+	auth.POST("/api-keys", RequireStepUp(db, testRP, StepUpActionAPIKey), CreateAPIKey(db))
+	auth.GET("/api-keys", ListAPIKeys(db))
+	auth.DELETE("/api-keys/:id", RevokeAPIKey(db))
 
 	api := r.Group("/")
 	api.Use(AuthMiddleware(db))
@@ -94,27 +135,30 @@ func setupTest(t *testing.T) (*gorm.DB, *gin.Engine, map[string]time.Duration) {
 	api.GET("/assets", GetAssets(db))
 	api.GET("/client/assets", GetClientAssets(db))
 	api.GET("/client/payment-methods", ListClientPaymentMethods(db))
-	api.POST("/client/payment-methods", CreateClientPaymentMethod(db))
-	api.PUT("/client/payment-methods/:id", UpdateClientPaymentMethod(db))
-	api.DELETE("/client/payment-methods/:id", DeleteClientPaymentMethod(db))
+	api.POST("/client/payment-methods", RequireStepUp(db, testRP, StepUpActionPaymentMethod), CreateClientPaymentMethod(db))
+	api.PUT("/client/payment-methods/:id", RequireStepUp(db, testRP, StepUpActionPaymentMethod), UpdateClientPaymentMethod(db))
+	api.DELETE("/client/payment-methods/:id", RequireStepUp(db, testRP, StepUpActionPaymentMethod), DeleteClientPaymentMethod(db))
 	api.GET("/client/wallets", ListClientWallets(db))
 	api.POST("/client/wallets", CreateWallet(db))
+	api.POST("/client/wallets/:id/rotate", RotateWallet(db, time.Hour))
 	api.GET("/client/balances", ListClientBalances(db))
 	api.GET("/client/escrows", ListClientEscrows(db))
 	api.GET("/client/escrows/:id", GetClientEscrow(db))
+	api.GET("/client/reserves/proof", GetReservesProof(db))
 	api.GET("/client/transactions/in", ListClientTransactionsIn(db))
 	api.GET("/client/transactions/out", ListClientTransactionsOut(db))
 	api.GET("/client/transactions/internal", ListClientTransactionsInternal(db))
+	api.GET("/client/statements", GetClientStatement(db))
 	api.GET("/client/orders", ListClientOrders(db))
 	api.POST("/client/orders", CreateOrder(db))
 	api.GET("/orders/:id", GetOrder(db))
 	api.GET("/orders/:id/actions", GetOrderActions(db))
 	// order status change endpoints
 	api.POST("/orders/:id/paid", MarkOrderPaid(db))
-	api.POST("/orders/:id/release", ReleaseOrder(db))
+	api.POST("/orders/:id/release", RequireStepUp(db, testRP, StepUpActionRelease), ReleaseOrder(db))
 	api.POST("/orders/:id/cancel", CancelOrder(db))
 	api.POST("/orders/:id/dispute", OpenDispute(db))
-	api.POST("/orders/:id/dispute/resolve", ResolveDispute(db))
+	api.POST("/orders/:id/dispute/resolve", RequireStepUp(db, testRP, StepUpActionResolve), ResolveDispute(db))
 	api.GET("/orders/:id/messages", ListOrderMessages(db))
 	api.POST("/orders/:id/messages", CreateOrderMessage(db, store, cache))
 	api.PATCH("/orders/:id/messages/:msgId/read", ReadOrderMessage(db))
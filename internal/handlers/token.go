@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+	"ptop/internal/notifications"
+	"ptop/internal/utils"
+)
+
+// hashToken возвращает SHA-256 токена: в БД хранится только он.
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// requestUserAgent возвращает User-Agent запроса в пределах колонки БД.
+func requestUserAgent(c *gin.Context) string {
+	ua := c.Request.UserAgent()
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+	return ua
+}
+
+// startSession открывает сессию нового входа. При notify клиент получает
+// уведомление, если раньше не входил с таким User-Agent.
+func startSession(db *gorm.DB, c *gin.Context, clientID string, notify bool) (models.Session, error) {
+	ua := requestUserAgent(c)
+	var known int64
+	if notify {
+		if err := db.Model(&models.Session{}).Where("client_id = ? AND user_agent = ?", clientID, ua).Count(&known).Error; err != nil {
+			return models.Session{}, err
+		}
+	}
+	now := time.Now()
+	sess := models.Session{ClientID: clientID, Device: deviceName(ua), UserAgent: ua, IP: c.ClientIP(), LastSeenAt: now, ExpiresAt: now}
+	if err := db.Create(&sess).Error; err != nil {
+		return models.Session{}, err
+	}
+	if notify && known == 0 {
+		payload, err := json.Marshal(map[string]string{"sessionId": sess.ID, "device": sess.Device, "ip": sess.IP})
+		if err == nil {
+			n := models.Notification{ClientID: clientID, Type: "auth.new_device", Payload: payload, LinkTo: "/auth/sessions"}
+			if err := db.Create(&n).Error; err == nil {
+				notifications.Broadcast(clientID, n)
+			}
+		}
+	}
+	return sess, nil
+}
+
+// issueTokens выдаёт пару access/refresh токенов в сессии sessionID,
+// запоминает IP и User-Agent запроса и продлевает сессию до срока refresh
+// токена.
+func issueTokens(db *gorm.DB, c *gin.Context, clientID, sessionID string, ttl map[string]time.Duration) (TokenResponse, error) {
+	accessStr, err := utils.GenerateNanoID()
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	refreshStr, err := utils.GenerateNanoID()
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	ua := requestUserAgent(c)
+	now := time.Now()
+	tokens := []models.Token{
+		{ClientID: clientID, Hash: hashToken(accessStr), Type: "access", SessionID: sessionID,
+			IP: c.ClientIP(), UserAgent: ua, ExpiresAt: now.Add(ttl["access"])},
+		{ClientID: clientID, Hash: hashToken(refreshStr), Type: "refresh", SessionID: sessionID,
+			IP: c.ClientIP(), UserAgent: ua, ExpiresAt: now.Add(ttl["refresh"])},
+	}
+	if err := db.Create(&tokens).Error; err != nil {
+		return TokenResponse{}, err
+	}
+	if err := db.Model(&models.Session{}).Where("id = ?", sessionID).
+		Updates(map[string]any{"ip": c.ClientIP(), "last_seen_at": now, "expires_at": now.Add(ttl["refresh"])}).Error; err != nil {
+		return TokenResponse{}, err
+	}
+	return TokenResponse{AccessToken: accessStr, RefreshToken: refreshStr}, nil
+}
+
+// login открывает сессию и выдаёт первую пару токенов.
+func login(db *gorm.DB, c *gin.Context, clientID string, ttl map[string]time.Duration, notify bool) (TokenResponse, error) {
+	var tokens TokenResponse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		sess, err := startSession(tx, c, clientID, notify)
+		if err != nil {
+			return err
+		}
+		tokens, err = issueTokens(tx, c, clientID, sess.ID, ttl)
+		return err
+	})
+	return tokens, err
+}
+
+// revokeSessions завершает сессии и отзывает все их токены.
+func revokeSessions(db *gorm.DB, sessionIDs ...string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).
+			Where("id IN ? AND revoked_at IS NULL", sessionIDs).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Token{}).
+			Where("session_id IN ? AND revoked_at IS NULL", sessionIDs).
+			Update("revoked_at", now).Error
+	})
+}
+
+// deviceName описывает устройство по User-Agent: браузер и ОС.
+func deviceName(ua string) string {
+	if ua == "" {
+		return "unknown"
+	}
+	browser := ""
+	for _, b := range []struct{ marker, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"YaBrowser/", "Yandex Browser"}, {"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"}, {"Safari/", "Safari"},
+	} {
+		if strings.Contains(ua, b.marker) {
+			browser = b.name
+			break
+		}
+	}
+	platform := ""
+	for _, o := range []struct{ marker, name string }{
+		{"Android", "Android"}, {"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Windows", "Windows"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.marker) {
+			platform = o.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	if len(ua) > 100 {
+		return ua[:100]
+	}
+	return ua
+}
+
+// TokenCleaner периодически удаляет просроченные токены и завершённые
+// сессии. Использованные refresh токены хранятся до истечения срока, чтобы
+// распознать их повторное предъявление.
+type TokenCleaner struct {
+	db       *gorm.DB
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+func NewTokenCleaner(db *gorm.DB, interval time.Duration) *TokenCleaner {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &TokenCleaner{db: db, interval: interval, stopCh: make(chan struct{})}
+}
+
+// Start запускает очистку в отдельной горутине
+func (t *TokenCleaner) Start() {
+	ticker := time.NewTicker(t.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.cleanOnce()
+			case <-t.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop останавливает очистку
+func (t *TokenCleaner) Stop() { close(t.stopCh) }
+
+func (t *TokenCleaner) cleanOnce() {
+	now := time.Now()
+	res := t.db.Where("expires_at <= ?", now).Delete(&models.Token{})
+	if res.Error != nil {
+		log.Printf("ошибка очистки токенов: %v", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("удалено просроченных токенов: %d", res.RowsAffected)
+	}
+	// Завершённые сессии удаляются вместе с последними токенами: пока жив
+	// использованный refresh токен, его повтор должен находить сессию
+	if err := t.db.Where("(expires_at <= ? OR revoked_at IS NOT NULL) AND NOT EXISTS (SELECT 1 FROM tokens WHERE tokens.session_id = sessions.id)", now).
+		Delete(&models.Session{}).Error; err != nil {
+		log.Printf("ошибка очистки сессий: %v", err)
+	}
+	if err := t.db.Where("expires_at <= ?", now).Delete(&models.WebAuthnChallenge{}).Error; err != nil {
+		log.Printf("ошибка очистки вызовов WebAuthn: %v", err)
+	}
+	if err := t.db.Where("expires_at <= ?", now).Delete(&models.StepUpToken{}).Error; err != nil {
+		log.Printf("ошибка очистки токенов повышения прав: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ptop/internal/models"
+)
+
+func TestRefreshRotationAndReuse(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	post := func(path, body, ua string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", ua)
+		req.RemoteAddr = "203.0.113.5:40000"
+		r.ServeHTTP(w, req)
+		return w
+	}
+	profile := func(access string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/auth/profile", nil)
+		req.Header.Set("Authorization", "Bearer "+access)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	w := post("/auth/register", `{"username":"rotuser","password":"pass","password_confirm":"pass"}`, "app/1.0")
+	var first tokenResp
+	json.Unmarshal(w.Body.Bytes(), &first)
+
+	// В БД только хеши, с метаданными запроса
+	var stored []models.Token
+	db.Where("token_hash IN ?", []string{first.AccessToken, first.RefreshToken}).Find(&stored)
+	if len(stored) != 0 {
+		t.Fatalf("plaintext tokens stored")
+	}
+	var refresh models.Token
+	if err := db.Where("token_hash = ?", hashToken(first.RefreshToken)).First(&refresh).Error; err != nil {
+		t.Fatalf("refresh token: %v", err)
+	}
+	if refresh.UserAgent != "app/1.0" || refresh.IP != "203.0.113.5" || refresh.SessionID == "" {
+		t.Fatalf("unexpected token metadata %+v", refresh)
+	}
+
+	// Другой User-Agent не может обменять токен
+	w = post("/auth/refresh", `{"refresh_token":"`+first.RefreshToken+`"}`, "curl/8")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on binding mismatch, got %d", w.Code)
+	}
+
+	w = post("/auth/refresh", `{"refresh_token":"`+first.RefreshToken+`"}`, "app/1.0")
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh status %d", w.Code)
+	}
+	var second tokenResp
+	json.Unmarshal(w.Body.Bytes(), &second)
+	if profile(first.AccessToken) != http.StatusUnauthorized || profile(second.AccessToken) != http.StatusOK {
+		t.Fatalf("old access token must be revoked after rotation")
+	}
+	var next models.Token
+	db.Where("token_hash = ?", hashToken(second.RefreshToken)).First(&next)
+	if next.SessionID != refresh.SessionID {
+		t.Fatalf("rotation must keep the session")
+	}
+
+	// Повторное предъявление завершает сессию, даже с другим User-Agent
+	w = post("/auth/refresh", `{"refresh_token":"`+first.RefreshToken+`"}`, "curl/8")
+	var resp ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusUnauthorized || resp.Error != "token reused" {
+		t.Fatalf("expected token reused, got %d %q", w.Code, resp.Error)
+	}
+	if profile(second.AccessToken) != http.StatusUnauthorized {
+		t.Fatalf("session access token must be revoked")
+	}
+	w = post("/auth/refresh", `{"refresh_token":"`+second.RefreshToken+`"}`, "app/1.0")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("session refresh token must be revoked, got %d", w.Code)
+	}
+}
+
+func TestRefreshLongUserAgent(t *testing.T) {
+	_, r, _ := setupTest(t)
+
+	// User-Agent WebView длиннее колонки: токен привязан к его началу
+	ua := "Mozilla/5.0 (Linux; Android 14) " + strings.Repeat("InApp/1.0 ", 40)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", ua)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := post("/auth/register", `{"username":"longua","password":"pass","password_confirm":"pass"}`)
+	var tok tokenResp
+	json.Unmarshal(w.Body.Bytes(), &tok)
+	for i := 0; i < 2; i++ {
+		w = post("/auth/refresh", `{"refresh_token":"`+tok.RefreshToken+`"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("refresh %d with long user agent: %d %s", i, w.Code, w.Body.String())
+		}
+		json.Unmarshal(w.Body.Bytes(), &tok)
+	}
+}
+
+func TestTokenCleaner(t *testing.T) {
+	db, _, _ := setupTest(t)
+	db.Create(&models.Token{ClientID: "c1", Hash: hashToken("old"), Type: "access", ExpiresAt: time.Now().Add(-time.Minute)})
+	db.Create(&models.Token{ClientID: "c1", Hash: hashToken("new"), Type: "access", ExpiresAt: time.Now().Add(time.Minute)})
+
+	now := time.Now()
+	db.Create(&models.Session{ID: "ended", ClientID: "c1", ExpiresAt: now.Add(time.Hour), RevokedAt: &now})
+	db.Create(&models.Session{ID: "active", ClientID: "c1", ExpiresAt: now.Add(time.Hour)})
+
+	NewTokenCleaner(db, time.Minute).cleanOnce()
+
+	var sessions []string
+	db.Model(&models.Session{}).Pluck("id", &sessions)
+	if len(sessions) != 1 || sessions[0] != "active" {
+		t.Fatalf("unexpected sessions %v", sessions)
+	}
+
+	var hashes []string
+	db.Model(&models.Token{}).Pluck("token_hash", &hashes)
+	if len(hashes) != 1 || hashes[0] != hashToken("new") {
+		t.Fatalf("unexpected tokens %v", hashes)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -14,11 +15,14 @@ import (
 
 type WalletRequest struct {
 	AssetID string `json:"asset_id"`
+	// Network — сеть депозита, по умолчанию основная сеть актива.
+	Network string `json:"network"`
 }
 
 // CreateWallet godoc
 // @Summary Создать кошелёк
 // @Description При DEBUG_FAKE_NETWORK=true адрес генерируется без обращения к сети в формате fake:{asset}:{client}:{index}
+// @Description Параметр network выбирает сеть депозита из сетей актива (например, eth или sol для USDT); по умолчанию используется основная сеть актива. Кошельки всех сетей пополняют один баланс.
 // @Tags wallets
 // @Security BearerAuth
 // @Accept json
@@ -45,33 +49,127 @@ func CreateWallet(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid asset"})
 			return
 		}
+		if r.Network == "" {
+			r.Network = asset.Chain
+		}
 		var count int64
-		db.Model(&models.Wallet{}).Where("client_id = ? AND asset_id = ? AND is_enabled = ?", clientID, r.AssetID, true).Count(&count)
+		db.Model(&models.Wallet{}).Where("client_id = ? AND asset_id = ? AND network = ? AND is_enabled = ?", clientID, r.AssetID, r.Network, true).Count(&count)
 		if count > 0 {
 			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "wallet exists"})
 			return
 		}
-		// в режиме DEBUG_FAKE_NETWORK возвращает фейковый адрес
-		val, idx, err := services.GetAddress(db, clientID, r.AssetID)
-		if err != nil {
+		// Адрес выделяется в транзакции создания кошелька, чтобы блокировка
+		// счётчика индексов держалась до появления кошелька. В режиме
+		// DEBUG_FAKE_NETWORK адрес фейковый
+		w := models.Wallet{
+			ClientID:  clientID,
+			AssetID:   r.AssetID,
+			Network:   r.Network,
+			IsEnabled: true,
+			EnabledAt: time.Now(),
+		}
+		var addrErr error
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if w.Value, w.DerivationIndex, addrErr = services.GetAddress(tx, clientID, r.AssetID, r.Network); addrErr != nil {
+				return addrErr
+			}
+			if err := tx.Create(&w).Error; err != nil {
+				return err
+			}
+			return services.LinkWallet(tx, w)
+		})
+		if errors.Is(err, services.ErrUnknownNetwork) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid network"})
+			return
+		}
+		if errors.Is(err, services.ErrDepositsDisabled) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "deposits disabled"})
+			return
+		}
+		if addrErr != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "address error"})
 			return
 		}
-		now := time.Now()
-		w := models.Wallet{
-			ClientID:        clientID,
-			AssetID:         r.AssetID,
-			Value:           val,
-			DerivationIndex: idx,
-			IsEnabled:       true,
-			EnabledAt:       now,
-		}
-		if err := db.Create(&w).Error; err != nil {
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
 			return
 		}
+		// Кошельки всех сетей актива зачисляют средства на один баланс
 		b := models.Balance{ClientID: clientID, AssetID: r.AssetID, Amount: decimal.Zero, AmountEscrow: decimal.Zero}
-		if err := db.Create(&b).Error; err != nil {
+		if err := db.Where("client_id = ? AND asset_id = ?", clientID, r.AssetID).FirstOrCreate(&b).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// RotateWallet godoc
+// @Summary Выдать новый адрес кошелька
+// @Description Выделяет новый индекс деривации и создаёт активный кошелёк с новым адресом. Старый кошелёк отключается; депозиты на его адрес зачисляются до expiresAt (льготный период WALLET_ROTATION_GRACE), после — отклоняются.
+// @Tags wallets
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "ID"
+// @Success 200 {object} models.Wallet
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /client/wallets/{id}/rotate [post]
+func RotateWallet(db *gorm.DB, grace time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID := clientIDVal.(string)
+		var old models.Wallet
+		if err := db.Where("id = ? AND client_id = ? AND is_enabled = ?", id, clientID, true).First(&old).Error; err != nil {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "not found"})
+			return
+		}
+		now := time.Now()
+		expires := now.Add(grace)
+		w := models.Wallet{
+			ClientID:   clientID,
+			AssetID:    old.AssetID,
+			Network:    old.Network,
+			IsEnabled:  true,
+			EnabledAt:  now,
+			PreviousID: &old.ID,
+		}
+		var addrErr error
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if w.Value, w.DerivationIndex, addrErr = services.NewAddress(tx, clientID, old.AssetID, old.Network); addrErr != nil {
+				return addrErr
+			}
+			res := tx.Model(&models.Wallet{}).Where("id = ? AND is_enabled = ?", old.ID, true).
+				Updates(map[string]any{"is_enabled": false, "disabled_at": now, "expires_at": expires})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			if err := tx.Create(&w).Error; err != nil {
+				return err
+			}
+			return services.LinkWallet(tx, w)
+		})
+		if errors.Is(err, services.ErrDepositsDisabled) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "deposits disabled"})
+			return
+		}
+		if addrErr != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "address error"})
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "not found"})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
 			return
 		}
@@ -81,6 +179,7 @@ func CreateWallet(db *gorm.DB) gin.HandlerFunc {
 
 // ListClientWallets godoc
 // @Summary Список кошельков клиента
+// @Description Возвращает активные кошельки и адреса, заменённые при ротации (isEnabled=false). У заменённых указаны disabledAt и expiresAt, у нового адреса — previousID; вместе с index это история деривации адресов.
 // @Tags wallets
 // @Security BearerAuth
 // @Produce json
@@ -95,7 +194,7 @@ func ListClientWallets(db *gorm.DB) gin.HandlerFunc {
 		}
 		clientID := clientIDVal.(string)
 		var wallets []models.Wallet
-		if err := db.Where("client_id = ? AND is_enabled = ?", clientID, true).Find(&wallets).Error; err != nil {
+		if err := db.Where("client_id = ?", clientID).Order("is_enabled DESC, created_at DESC").Find(&wallets).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
 			return
 		}
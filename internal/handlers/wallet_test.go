@@ -48,7 +48,7 @@ func TestWalletHandlers(t *testing.T) {
 	if err != nil {
 		t.Fatalf("neuter: %v", err)
 	}
-	crypto := models.Asset{Name: "BTC_wallet", Type: models.AssetTypeCrypto, Xpub: xpub.String(), IsActive: true}
+	crypto := models.Asset{Name: "BTC_wallet", Type: models.AssetTypeCrypto, Chain: "btc", Xpub: xpub.String(), IsActive: true}
 	fiat := models.Asset{Name: "USD_wallet", Type: models.AssetTypeFiat, IsActive: true}
 	if err := db.Create(&crypto).Error; err != nil {
 		t.Fatalf("asset: %v", err)
@@ -119,11 +119,12 @@ func TestWalletHandlers(t *testing.T) {
 	}
 	var list []models.Wallet
 	json.Unmarshal(w.Body.Bytes(), &list)
-	if len(list) != 1 {
-		t.Fatalf("expected 1 wallet, got %d", len(list))
+	if len(list) != 2 {
+		t.Fatalf("expected 2 wallets, got %d", len(list))
 	}
-	if list[0].ID == wal.ID {
-		t.Fatalf("disabled wallet returned")
+	// отключённый кошелёк возвращается последним как неактивный
+	if list[0].ID == wal.ID || !list[0].IsEnabled || list[1].ID != wal.ID || list[1].IsEnabled {
+		t.Fatalf("unexpected wallets order %+v", list)
 	}
 }
 
@@ -178,3 +179,168 @@ func TestCreateWalletFake(t *testing.T) {
 		t.Fatalf("expected %s, got %s", exp, wal.Value)
 	}
 }
+
+func TestCreateWalletNetworks(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	body := `{"username":"netuser","password":"pass","password_confirm":"pass"}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	body = `{"username":"netuser","password":"pass"}`
+	req, _ = http.NewRequest("POST", "/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status %d", w.Code)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &tok)
+
+	master, err := hdkeychain.NewMaster(bytes.Repeat([]byte{0x04}, 32), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("master: %v", err)
+	}
+	xpub, err := master.Neuter()
+	if err != nil {
+		t.Fatalf("neuter: %v", err)
+	}
+	usdt := models.Asset{Name: "USDT_net", Type: models.AssetTypeCrypto, Chain: "eth", Xpub: xpub.String(), IsActive: true}
+	if err := db.Create(&usdt).Error; err != nil {
+		t.Fatalf("asset: %v", err)
+	}
+	networks := []models.AssetNetwork{
+		{AssetID: usdt.ID, Chain: "eth", Contract: "0xdAC17F958D2ee523a2206206994597C13D831ec7", Decimals: 6},
+		{AssetID: usdt.ID, Chain: "sol", Contract: "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB", Decimals: 6,
+			Xpub: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"},
+	}
+	if err := db.Create(&networks).Error; err != nil {
+		t.Fatalf("networks: %v", err)
+	}
+
+	create := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/client/wallets", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w = create(`{"asset_id":"` + usdt.ID + `"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("eth status %d", w.Code)
+	}
+	var ethWallet models.Wallet
+	json.Unmarshal(w.Body.Bytes(), &ethWallet)
+	if ethWallet.Network != "eth" {
+		t.Fatalf("default network %q", ethWallet.Network)
+	}
+
+	w = create(`{"asset_id":"` + usdt.ID + `","network":"sol"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("sol status %d", w.Code)
+	}
+	var solWallet models.Wallet
+	json.Unmarshal(w.Body.Bytes(), &solWallet)
+	if solWallet.Network != "sol" || solWallet.Value == ethWallet.Value {
+		t.Fatalf("unexpected sol wallet %+v", solWallet)
+	}
+
+	if w = create(`{"asset_id":"` + usdt.ID + `","network":"sol"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("dup status %d", w.Code)
+	}
+	if w = create(`{"asset_id":"` + usdt.ID + `","network":"xmr"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown network status %d", w.Code)
+	}
+
+	var balances int64
+	db.Model(&models.Balance{}).Where("asset_id = ?", usdt.ID).Count(&balances)
+	if balances != 1 {
+		t.Fatalf("expected 1 balance, got %d", balances)
+	}
+}
+
+func TestRotateWallet(t *testing.T) {
+	t.Setenv("DEBUG_FAKE_NETWORK", "true")
+	db, r, _ := setupTest(t)
+
+	body := `{"username":"rotuser","password":"pass","password_confirm":"pass"}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	w = httptest.NewRecorder()
+	body = `{"username":"rotuser","password":"pass"}`
+	req, _ = http.NewRequest("POST", "/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status %d", w.Code)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &tok)
+
+	asset := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, Chain: "btc", IsActive: true}
+	if err := db.Create(&asset).Error; err != nil {
+		t.Fatalf("asset: %v", err)
+	}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/client/wallets", bytes.NewBufferString(`{"asset_id":"`+asset.ID+`"}`))
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create status %d", w.Code)
+	}
+	var first models.Wallet
+	json.Unmarshal(w.Body.Bytes(), &first)
+
+	rotate := func(id string) (int, models.Wallet) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/client/wallets/"+id+"/rotate", nil)
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		r.ServeHTTP(w, req)
+		var wal models.Wallet
+		json.Unmarshal(w.Body.Bytes(), &wal)
+		return w.Code, wal
+	}
+	code, second := rotate(first.ID)
+	if code != http.StatusOK {
+		t.Fatalf("rotate status %d", code)
+	}
+	if second.Value == first.Value || second.DerivationIndex != 1 || second.PreviousID == nil || *second.PreviousID != first.ID {
+		t.Fatalf("unexpected rotated wallet %+v", second)
+	}
+	// отключённый кошелёк повторно не ротируется
+	if code, _ := rotate(first.ID); code != http.StatusNotFound {
+		t.Fatalf("rotate disabled status %d", code)
+	}
+	code, third := rotate(second.ID)
+	if code != http.StatusOK || third.DerivationIndex != 2 {
+		t.Fatalf("second rotate %d %+v", code, third)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/client/wallets", nil)
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	r.ServeHTTP(w, req)
+	var list []models.Wallet
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list) != 3 || list[0].ID != third.ID || !list[0].IsEnabled {
+		t.Fatalf("unexpected wallets %+v", list)
+	}
+	for _, wal := range list[1:] {
+		if wal.IsEnabled || wal.DisabledAt == nil || wal.ExpiresAt == nil || !wal.ExpiresAt.After(time.Now()) {
+			t.Fatalf("unexpected old wallet %+v", wal)
+		}
+	}
+}
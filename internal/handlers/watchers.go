@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ptop/internal/watchers"
+)
+
+// WatchersStatus godoc
+// @Summary Состояние наблюдателей сетей
+// @Description Для каждой сети возвращает отставание от вершины, время последнего блока и число перезапусков
+// @Tags health
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} watchers.ChainStatus
+// @Failure 401 {object} ErrorResponse
+// @Router /watchers/status [get]
+func WatchersStatus(sup *watchers.Supervisor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, sup.Status())
+	}
+}
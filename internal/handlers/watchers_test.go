@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ptop/internal/chains"
+	"ptop/internal/watchers"
+)
+
+type stubWatcher struct{}
+
+func (stubWatcher) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (stubWatcher) Progress() (uint64, uint64, time.Time) {
+	return 95, 100, time.Now()
+}
+
+func TestWatchersStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sup := watchers.NewSupervisor(time.Millisecond, time.Millisecond)
+	sup.Add(chains.ChainBTC, stubWatcher{})
+	r := gin.Default()
+	r.GET("/watchers/status", WatchersStatus(sup))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/watchers/status", nil)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	var resp []watchers.ChainStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 1 || resp[0].Chain != chains.ChainBTC || resp[0].Lag != 5 {
+		t.Fatalf("unexpected status %+v", resp)
+	}
+}
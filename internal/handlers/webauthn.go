@@ -0,0 +1,594 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+	"ptop/internal/webauthn"
+)
+
+const authActionWebAuthn = "webauthn"
+
+// webauthnChallengeTTL — сколько действует вызов одной церемонии.
+const webauthnChallengeTTL = 5 * time.Minute
+
+var (
+	errWebAuthnChallenge  = errors.New("invalid challenge")
+	errWebAuthnCredential = errors.New("invalid credential")
+)
+
+// WebAuthnAuthenticatorResponse — поле response ответа браузера; бинарные
+// значения передаются в base64url.
+type WebAuthnAuthenticatorResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject,omitempty"`
+	AuthenticatorData string `json:"authenticatorData,omitempty"`
+	Signature         string `json:"signature,omitempty"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// WebAuthnCredentialJSON — результат navigator.credentials.create/get.
+type WebAuthnCredentialJSON struct {
+	ID       string                        `json:"id"`
+	RawID    string                        `json:"rawId"`
+	Type     string                        `json:"type"`
+	Response WebAuthnAuthenticatorResponse `json:"response"`
+}
+
+// WebAuthnFinishRequest завершает церемонию: ID вызова из begin и ответ
+// аутентификатора. Name — название ключа при регистрации.
+type WebAuthnFinishRequest struct {
+	ChallengeID string                 `json:"challengeId" binding:"required"`
+	Name        string                 `json:"name"`
+	Credential  WebAuthnCredentialJSON `json:"credential"`
+}
+
+// WebAuthnRegisterFinishRequest — ответ аутентификатора и пароль клиента:
+// добавление ключа подтверждается как смена фактора.
+type WebAuthnRegisterFinishRequest struct {
+	WebAuthnFinishRequest
+	Password string `json:"password" binding:"required"`
+}
+
+// DeleteWebAuthnCredentialRequest — пароль для удаления ключа.
+type DeleteWebAuthnCredentialRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// WebAuthnRegisterBeginResponse — параметры для navigator.credentials.create.
+type WebAuthnRegisterBeginResponse struct {
+	ChallengeID string                   `json:"challengeId"`
+	PublicKey   webauthn.CreationOptions `json:"publicKey"`
+}
+
+// WebAuthnLoginBeginRequest — имя пользователя необязательно: без него
+// браузер предложит любой passkey сайта.
+type WebAuthnLoginBeginRequest struct {
+	Username string `json:"username"`
+}
+
+// WebAuthnAssertBeginResponse — параметры для navigator.credentials.get.
+type WebAuthnAssertBeginResponse struct {
+	ChallengeID string                  `json:"challengeId"`
+	PublicKey   webauthn.RequestOptions `json:"publicKey"`
+}
+
+// newWebAuthnChallenge сохраняет одноразовый вызов церемонии.
+func newWebAuthnChallenge(db *gorm.DB, clientID, purpose string) (models.WebAuthnChallenge, error) {
+	raw, err := webauthn.NewChallenge()
+	if err != nil {
+		return models.WebAuthnChallenge{}, err
+	}
+	ch := models.WebAuthnChallenge{
+		ClientID:  clientID,
+		Purpose:   purpose,
+		Challenge: raw,
+		ExpiresAt: time.Now().Add(webauthnChallengeTTL),
+	}
+	err = db.Create(&ch).Error
+	return ch, err
+}
+
+// takeWebAuthnChallenge находит и удаляет вызов: повторно предъявить тот же
+// ответ аутентификатора нельзя. Пустой clientID не проверяется.
+func takeWebAuthnChallenge(db *gorm.DB, id, purpose, clientID string) (models.WebAuthnChallenge, error) {
+	var ch models.WebAuthnChallenge
+	q := db.Where("id = ? AND purpose = ? AND expires_at > ?", id, purpose, time.Now())
+	if clientID != "" {
+		q = q.Where("client_id = ?", clientID)
+	}
+	if err := q.First(&ch).Error; err != nil {
+		return ch, errWebAuthnChallenge
+	}
+	res := db.Where("id = ?", ch.ID).Delete(&models.WebAuthnChallenge{})
+	if res.Error != nil {
+		return ch, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ch, errWebAuthnChallenge
+	}
+	return ch, nil
+}
+
+// clientCredentialIDs возвращает ID ключей клиента для excludeCredentials и
+// allowCredentials.
+func clientCredentialIDs(db *gorm.DB, clientID string) ([][]byte, error) {
+	var ids []string
+	if err := db.Model(&models.WebAuthnCredential{}).Where("client_id = ?", clientID).Pluck("credential_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	out := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		if raw, err := webauthn.Encoding.DecodeString(id); err == nil {
+			out = append(out, raw)
+		}
+	}
+	return out, nil
+}
+
+// verifyPasskey завершает церемонию get: проверяет вызов, подпись и
+// счётчик ключа и обновляет их. Пустой clientID означает вход без пароля,
+// когда клиент определяется ключом.
+func verifyPasskey(db *gorm.DB, rp *webauthn.RelyingParty, r WebAuthnFinishRequest, purpose, clientID string, requireUV bool) (models.WebAuthnCredential, error) {
+	var cred models.WebAuthnCredential
+	ch, err := takeWebAuthnChallenge(db, r.ChallengeID, purpose, clientID)
+	if err != nil {
+		return cred, err
+	}
+	credID := r.Credential.RawID
+	if credID == "" {
+		credID = r.Credential.ID
+	}
+	q := db.Where("credential_id = ?", credID)
+	if ch.ClientID != "" {
+		q = q.Where("client_id = ?", ch.ClientID)
+	}
+	if err := q.First(&cred).Error; err != nil {
+		return cred, errWebAuthnCredential
+	}
+	resp := r.Credential.Response
+	// userHandle — ID клиента, под которым ключ создавался
+	if resp.UserHandle != "" {
+		if handle, err := webauthn.Encoding.DecodeString(resp.UserHandle); err != nil || string(handle) != cred.ClientID {
+			return cred, errWebAuthnCredential
+		}
+	}
+	var a webauthn.Assertion
+	if a.ClientDataJSON, err = webauthn.Encoding.DecodeString(resp.ClientDataJSON); err != nil {
+		return cred, webauthn.ErrClientData
+	}
+	if a.AuthenticatorData, err = webauthn.Encoding.DecodeString(resp.AuthenticatorData); err != nil {
+		return cred, webauthn.ErrAuthData
+	}
+	if a.Signature, err = webauthn.Encoding.DecodeString(resp.Signature); err != nil {
+		return cred, webauthn.ErrSignature
+	}
+	count, err := rp.VerifyAssertion(webauthn.Credential{
+		PublicKey: cred.PublicKey,
+		Alg:       cred.Alg,
+		SignCount: cred.SignCount,
+	}, ch.Challenge, a, requireUV)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCount) {
+			log.Printf("webauthn: счётчик ключа %s клиента %s не вырос, возможна копия ключа", cred.ID, cred.ClientID)
+		}
+		return cred, err
+	}
+	now := time.Now()
+	cred.SignCount = count
+	cred.LastUsedAt = &now
+	if err := db.Model(&models.WebAuthnCredential{}).Where("id = ?", cred.ID).
+		Updates(map[string]any{"sign_count": count, "last_used_at": now}).Error; err != nil {
+		return cred, err
+	}
+	return cred, nil
+}
+
+// WebAuthnRegisterBegin godoc
+// @Summary Начало регистрации passkey
+// @Description Возвращает параметры для navigator.credentials.create. Вызов действует 5 минут.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} WebAuthnRegisterBeginResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/webauthn/register/begin [post]
+func WebAuthnRegisterBegin(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var client models.Client
+		if err := db.Where("id = ?", clientID).First(&client).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
+			return
+		}
+		exclude, err := clientCredentialIDs(db, clientID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		ch, err := newWebAuthnChallenge(db, clientID, models.WebAuthnPurposeRegister)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		user := webauthn.User{ID: []byte(client.ID), Name: client.Username, DisplayName: client.Username}
+		c.JSON(http.StatusOK, WebAuthnRegisterBeginResponse{
+			ChallengeID: ch.ID,
+			PublicKey:   rp.CreationOptions(ch.Challenge, user, exclude),
+		})
+	}
+}
+
+// WebAuthnRegisterFinish godoc
+// @Summary Завершение регистрации passkey
+// @Description Проверяет ответ аутентификатора и сохраняет ключ. Принимаются аттестации none и packed. Нужен пароль; если у клиента уже есть факторы, ещё и повышение прав для factors.change (заголовки X-Step-Up-*).
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body WebAuthnRegisterFinishRequest true "ответ navigator.credentials.create и пароль"
+// @Success 201 {object} models.WebAuthnCredential
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
+// @Router /auth/webauthn/register/finish [post]
+func WebAuthnRegisterFinish(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r WebAuthnRegisterFinishRequest
+		if err := c.ShouldBindJSON(&r); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
+			return
+		}
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var client models.Client
+		if err := db.Where("id = ?", clientID).First(&client).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
+			return
+		}
+		if !checkPassword(c, db, client, r.Password) || !confirmFactorChange(c, db, rp, client) {
+			return
+		}
+		ch, err := takeWebAuthnChallenge(db, r.ChallengeID, models.WebAuthnPurposeRegister, clientID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid challenge"})
+			return
+		}
+		clientData, err1 := webauthn.Encoding.DecodeString(r.Credential.Response.ClientDataJSON)
+		attestation, err2 := webauthn.Encoding.DecodeString(r.Credential.Response.AttestationObject)
+		if err1 != nil || err2 != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid credential"})
+			return
+		}
+		cred, err := rp.VerifyRegistration(ch.Challenge, clientData, attestation, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "verification failed"})
+			return
+		}
+		credID := webauthn.Encoding.EncodeToString(cred.ID)
+		var count int64
+		if err := db.Model(&models.WebAuthnCredential{}).Where("credential_id = ?", credID).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, ErrorResponse{Error: "credential exists"})
+			return
+		}
+		name := r.Name
+		if name == "" {
+			name = deviceName(requestUserAgent(c))
+		}
+		if len(name) > 100 {
+			name = name[:100]
+		}
+		item := models.WebAuthnCredential{
+			ClientID:       clientID,
+			CredentialID:   credID,
+			PublicKey:      cred.PublicKey,
+			Alg:            cred.Alg,
+			SignCount:      cred.SignCount,
+			AAGUID:         cred.AAGUID,
+			Name:           name,
+			BackupEligible: cred.BackupEligible,
+		}
+		if err := db.Create(&item).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusCreated, item)
+	}
+}
+
+// ListWebAuthnCredentials godoc
+// @Summary Passkeys клиента
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.WebAuthnCredential
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/webauthn/credentials [get]
+func ListWebAuthnCredentials(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var creds []models.WebAuthnCredential
+		if err := db.Where("client_id = ?", clientID).Order("created_at desc").Find(&creds).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, creds)
+	}
+}
+
+// DeleteWebAuthnCredential godoc
+// @Summary Удаление passkey
+// @Description Нужны пароль и повышение прав для factors.change (заголовки X-Step-Up-*). Последний ключ нельзя удалить, пока passkey входит в политику повышения прав (409).
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "ID ключа"
+// @Param input body DeleteWebAuthnCredentialRequest true "пароль"
+// @Success 200 {object} StatusResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} StepUpRequiredResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
+// @Router /auth/webauthn/credentials/{id} [delete]
+func DeleteWebAuthnCredential(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r DeleteWebAuthnCredentialRequest
+		if err := c.ShouldBindJSON(&r); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
+			return
+		}
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		var client models.Client
+		if err := db.Where("id = ?", clientID).First(&client).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid client"})
+			return
+		}
+		if !checkPassword(c, db, client, r.Password) {
+			return
+		}
+		// Последний ключ нельзя удалить, пока passkey входит в политику
+		var passkeys int64
+		if err := db.Model(&models.WebAuthnCredential{}).Where("client_id = ?", clientID).Count(&passkeys).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		if passkeys == 1 {
+			inPolicy, err := factorInPolicy(db, clientID, FactorPasskey)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+				return
+			}
+			if inPolicy {
+				c.JSON(http.StatusConflict, ErrorResponse{Error: "factor required by step-up policy"})
+				return
+			}
+		}
+		if !confirmFactorChange(c, db, rp, client) {
+			return
+		}
+		res := db.Where("id = ? AND client_id = ?", c.Param("id"), clientID).Delete(&models.WebAuthnCredential{})
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		if res.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "not found"})
+			return
+		}
+		c.JSON(http.StatusOK, StatusResponse{Status: "credential deleted"})
+	}
+}
+
+// WebAuthnLoginBegin godoc
+// @Summary Начало входа по passkey
+// @Description Возвращает параметры для navigator.credentials.get. С именем пользователя браузеру передаются его ключи; без имени — пустой список, и браузер предложит любой passkey сайта.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body WebAuthnLoginBeginRequest false "имя пользователя"
+// @Success 200 {object} WebAuthnAssertBeginResponse
+// @Router /auth/webauthn/login/begin [post]
+func WebAuthnLoginBegin(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r WebAuthnLoginBeginRequest
+		_ = c.ShouldBindJSON(&r)
+		var clientID string
+		var allow [][]byte
+		if r.Username != "" {
+			// Неизвестное имя не отличается от имени без ключей, чтобы ответ
+			// не раскрывал существование учётной записи
+			var client models.Client
+			if err := db.Where("username = ?", r.Username).First(&client).Error; err == nil {
+				ids, err := clientCredentialIDs(db, client.ID)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+					return
+				}
+				if len(ids) > 0 {
+					clientID, allow = client.ID, ids
+				}
+			}
+		}
+		ch, err := newWebAuthnChallenge(db, clientID, models.WebAuthnPurposeLogin)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, WebAuthnAssertBeginResponse{
+			ChallengeID: ch.ID,
+			PublicKey:   rp.RequestOptions(ch.Challenge, allow, "required"),
+		})
+	}
+}
+
+// WebAuthnLoginFinish godoc
+// @Summary Вход по passkey
+// @Description Проверяет подпись ключа и выдаёт токены. Ключ должен подтвердить пользователя (биометрия или PIN устройства), поэтому второй фактор не запрашивается.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body WebAuthnFinishRequest true "ответ navigator.credentials.get"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
+// @Router /auth/webauthn/login/finish [post]
+func WebAuthnLoginFinish(db *gorm.DB, rp *webauthn.RelyingParty, ttl map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r WebAuthnFinishRequest
+		if err := c.ShouldBindJSON(&r); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
+			return
+		}
+		keys := authKeys(c, authActionWebAuthn, "", "")
+		if !authAllowed(c, keys) {
+			return
+		}
+		cred, err := verifyPasskey(db, rp, r, models.WebAuthnPurposeLogin, "", true)
+		// Клиент известен, если ключ найден: попытки учитываются и по нему
+		if cred.ClientID != "" {
+			keys = authKeys(c, authActionWebAuthn, "client", cred.ClientID)
+		}
+		if err != nil {
+			if !authFailed(c, db, models.AuthFailure{Action: authActionWebAuthn, ClientID: cred.ClientID}, keys) {
+				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: webauthnError(err)})
+			}
+			return
+		}
+		if !authAllowed(c, keys) {
+			return
+		}
+		authSucceeded(c, keys)
+		tokens, err := login(db, c, cred.ClientID, ttl, true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// WebAuthnVerifyBegin godoc
+// @Summary Начало подтверждения действия passkey
+// @Description Вызов для проверки passkey как второго фактора перед чувствительным действием.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} WebAuthnAssertBeginResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/webauthn/verify/begin [post]
+func WebAuthnVerifyBegin(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		allow, err := clientCredentialIDs(db, clientID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		if len(allow) == 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "no credentials"})
+			return
+		}
+		ch, err := newWebAuthnChallenge(db, clientID, models.WebAuthnPurposeVerify)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "db error"})
+			return
+		}
+		c.JSON(http.StatusOK, WebAuthnAssertBeginResponse{
+			ChallengeID: ch.ID,
+			PublicKey:   rp.RequestOptions(ch.Challenge, allow, "preferred"),
+		})
+	}
+}
+
+// WebAuthnVerifyFinish godoc
+// @Summary Подтверждение действия passkey
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param input body WebAuthnFinishRequest true "ответ navigator.credentials.get"
+// @Success 200 {object} VerifyPasswordResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} LockedResponse
+// @Router /auth/webauthn/verify/finish [post]
+func WebAuthnVerifyFinish(db *gorm.DB, rp *webauthn.RelyingParty) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r WebAuthnFinishRequest
+		if err := c.ShouldBindJSON(&r); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid json"})
+			return
+		}
+		clientIDVal, ok := c.Get("client_id")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "no client"})
+			return
+		}
+		clientID, _ := clientIDVal.(string)
+		keys := authKeys(c, authActionWebAuthn, "client", clientID)
+		if !authAllowed(c, keys) {
+			return
+		}
+		if _, err := verifyPasskey(db, rp, r, models.WebAuthnPurposeVerify, clientID, false); err != nil {
+			if !authFailed(c, db, models.AuthFailure{Action: authActionWebAuthn, ClientID: clientID}, keys) {
+				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: webauthnError(err)})
+			}
+			return
+		}
+		authSucceeded(c, keys)
+		c.JSON(http.StatusOK, VerifyPasswordResponse{Verified: true})
+	}
+}
+
+// webauthnError — текст ошибки для клиента: вызов и ключ различаются,
+// остальные ошибки проверки не детализируются.
+func webauthnError(err error) string {
+	switch {
+	case errors.Is(err, errWebAuthnChallenge):
+		return "invalid challenge"
+	case errors.Is(err, errWebAuthnCredential):
+		return "invalid credential"
+	}
+	return "verification failed"
+}
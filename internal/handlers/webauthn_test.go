@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ptop/internal/models"
+	"ptop/internal/webauthn"
+	"ptop/internal/webauthn/webauthntest"
+)
+
+func TestWebAuthnPasskeys(t *testing.T) {
+	db, r, _ := setupTest(t)
+
+	send := func(method, path string, body any, access string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		if access != "" {
+			req.Header.Set("Authorization", "Bearer "+access)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/auth/register", map[string]string{"username": "passkeyuser", "password": "pass", "password_confirm": "pass"}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("register status %d", w.Code)
+	}
+	var tok tokenResp
+	json.Unmarshal(w.Body.Bytes(), &tok)
+	var client models.Client
+	db.Where("username = ?", "passkeyuser").First(&client)
+
+	auth := webauthntest.New("localhost", "http://localhost:5173")
+
+	// Регистрация ключа
+	w = send("POST", "/auth/webauthn/register/begin", nil, tok.AccessToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("register begin status %d", w.Code)
+	}
+	var reg WebAuthnRegisterBeginResponse
+	json.Unmarshal(w.Body.Bytes(), &reg)
+	created, err := auth.Create(reg.PublicKey)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	w = send("POST", "/auth/webauthn/register/finish", map[string]any{"challengeId": reg.ChallengeID, "name": "Ноутбук", "credential": created, "password": "wrong"}, tok.AccessToken)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong password, got %d", w.Code)
+	}
+	w = send("POST", "/auth/webauthn/register/finish", map[string]any{"challengeId": reg.ChallengeID, "name": "Ноутбук", "credential": created, "password": "pass"}, tok.AccessToken)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register finish status %d: %s", w.Code, w.Body.String())
+	}
+	// С настроенным passkey следующий ключ добавляется только с повышением прав
+	w = send("POST", "/auth/webauthn/register/finish", map[string]any{"challengeId": reg.ChallengeID, "credential": created, "password": "pass"}, tok.AccessToken)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without step-up, got %d", w.Code)
+	}
+
+	w = send("GET", "/auth/webauthn/credentials", nil, tok.AccessToken)
+	var creds []models.WebAuthnCredential
+	json.Unmarshal(w.Body.Bytes(), &creds)
+	if len(creds) != 1 || creds[0].Name != "Ноутбук" || creds[0].CredentialID != created.ID {
+		t.Fatalf("unexpected credentials %+v", creds)
+	}
+
+	// Вход без пароля: браузер сам выбирает ключ
+	w = send("POST", "/auth/webauthn/login/begin", nil, "")
+	var begin WebAuthnAssertBeginResponse
+	json.Unmarshal(w.Body.Bytes(), &begin)
+	if len(begin.PublicKey.AllowCredentials) != 0 || begin.PublicKey.UserVerification != "required" {
+		t.Fatalf("unexpected login options %+v", begin.PublicKey)
+	}
+	assertion, err := auth.Get(begin.PublicKey)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	w = send("POST", "/auth/webauthn/login/finish", map[string]any{"challengeId": begin.ChallengeID, "credential": assertion}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login finish status %d: %s", w.Code, w.Body.String())
+	}
+	var passkeyTok tokenResp
+	json.Unmarshal(w.Body.Bytes(), &passkeyTok)
+	w = send("GET", "/auth/profile", nil, passkeyTok.AccessToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("profile with passkey token status %d", w.Code)
+	}
+
+	// С именем пользователя браузеру передаются его ключи
+	w = send("POST", "/auth/webauthn/login/begin", map[string]string{"username": "passkeyuser"}, "")
+	json.Unmarshal(w.Body.Bytes(), &begin)
+	if len(begin.PublicKey.AllowCredentials) != 1 {
+		t.Fatalf("expected allowCredentials, got %+v", begin.PublicKey)
+	}
+
+	// Без подтверждения пользователя вход не выполняется
+	auth.UserVerified = false
+	assertion, _ = auth.Get(begin.PublicKey)
+	w = send("POST", "/auth/webauthn/login/finish", map[string]any{"challengeId": begin.ChallengeID, "credential": assertion}, "")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without user verification, got %d", w.Code)
+	}
+
+	// Второй фактор: достаточно присутствия пользователя
+	w = send("POST", "/auth/webauthn/verify/begin", nil, tok.AccessToken)
+	json.Unmarshal(w.Body.Bytes(), &begin)
+	assertion, _ = auth.Get(begin.PublicKey)
+	w = send("POST", "/auth/webauthn/verify/finish", map[string]any{"challengeId": begin.ChallengeID, "credential": assertion}, tok.AccessToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify finish status %d: %s", w.Code, w.Body.String())
+	}
+
+	// Счётчик подписей, который не вырос, означает копию ключа
+	auth.Counter = false
+	w = send("POST", "/auth/webauthn/verify/begin", nil, tok.AccessToken)
+	json.Unmarshal(w.Body.Bytes(), &begin)
+	assertion, _ = auth.Get(begin.PublicKey)
+	w = send("POST", "/auth/webauthn/verify/finish", map[string]any{"challengeId": begin.ChallengeID, "credential": assertion}, tok.AccessToken)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 on stale counter, got %d", w.Code)
+	}
+
+	// Удаление ключа: пароль и повышение прав уже настроенным фактором
+	w = send("DELETE", "/auth/webauthn/credentials/"+creds[0].ID, map[string]string{"password": "pass"}, tok.AccessToken)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 deleting without step-up, got %d", w.Code)
+	}
+	auth.Counter = true
+	w = send("POST", "/auth/webauthn/verify/begin", nil, tok.AccessToken)
+	json.Unmarshal(w.Body.Bytes(), &begin)
+	assertion, _ = auth.Get(begin.PublicKey)
+	raw, _ := json.Marshal(map[string]any{"challengeId": begin.ChallengeID, "credential": assertion})
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(map[string]string{"password": "pass"})
+	req, _ := http.NewRequest("DELETE", "/auth/webauthn/credentials/"+creds[0].ID, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	req.Header.Set("X-Step-Up-Passkey", webauthn.Encoding.EncodeToString(raw))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete status %d: %s", w.Code, w.Body.String())
+	}
+	w = send("POST", "/auth/webauthn/verify/begin", nil, tok.AccessToken)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without credentials, got %d", w.Code)
+	}
+	var failures int64
+	db.Model(&models.AuthFailure{}).Where("action = ? AND client_id = ?", authActionWebAuthn, client.ID).Count(&failures)
+	if failures != 2 {
+		t.Fatalf("expected 2 recorded failures, got %d", failures)
+	}
+}
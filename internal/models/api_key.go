@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"ptop/internal/utils"
+)
+
+// Области доступа API ключа.
+const (
+	APIScopeRead        = "read"
+	APIScopeOffers      = "offers"
+	APIScopeOrders      = "orders"
+	APIScopeWithdrawals = "withdrawals"
+)
+
+// APIKey — ключ API клиента для ботов. Секрет не хранится: он выводится из
+// KeyID и серверного ключа API_KEY_SECRET. Scopes и AllowedIPs — списки
+// через запятую; пустой AllowedIPs разрешает любой адрес.
+type APIKey struct {
+	ID         string     `gorm:"primaryKey;size:21" json:"id"`
+	ClientID   string     `gorm:"size:21;not null;index" json:"-"`
+	Name       string     `gorm:"type:varchar(100)" json:"name"`
+	KeyID      string     `gorm:"type:varchar(32);not null;uniqueIndex" json:"keyId"`
+	Scopes     string     `gorm:"type:varchar(64);not null" json:"scopes"`
+	AllowedIPs string     `gorm:"type:varchar(1024)" json:"allowedIps"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `gorm:"index" json:"-"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+func (k *APIKey) BeforeCreate(tx *gorm.DB) (err error) {
+	if k.ID == "" {
+		k.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
@@ -11,6 +11,14 @@ const (
 	AssetTypeCrypto = "crypto"
 )
 
+// Типы адресов депозита в сетях Bitcoin и его форков. Пустой тип означает
+// P2WPKH для ключей zpub/vpub и P2PKH для остальных.
+const (
+	AddressTypeP2PKH  = "p2pkh"  // legacy, BIP44
+	AddressTypeP2WPKH = "p2wpkh" // native SegWit, BIP84
+	AddressTypeP2TR   = "p2tr"   // Taproot, BIP86
+)
+
 type Asset struct {
 	ID            string `gorm:"primaryKey;size:21" json:"id"`
 	Name          string `gorm:"type:varchar(255);unique;not null" json:"name"`
@@ -18,7 +26,12 @@ type Asset struct {
 	Type          string `gorm:"type:varchar(10);not null" json:"type"`
 	IsActive      bool   `gorm:"not null;default:false" json:"isActive"`
 	IsConvertible bool   `gorm:"not null;default:false" json:"isConvertible"`
+	Chain         string `gorm:"type:varchar(20);index" json:"chain,omitempty"`
 	Xpub          string `gorm:"type:varchar(255)" json:"-"`
+	// AddressType задаёт тип адресов депозита для сетей Bitcoin и его форков.
+	AddressType string `gorm:"type:varchar(10)" json:"addressType,omitempty"`
+
+	Networks []AssetNetwork `gorm:"foreignKey:AssetID" json:"networks,omitempty"`
 }
 
 func (a *Asset) BeforeCreate(tx *gorm.DB) (err error) {
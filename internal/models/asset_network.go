@@ -0,0 +1,36 @@
+package models
+
+import (
+	"ptop/internal/utils"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// AssetNetwork описывает параметры актива в конкретной сети: контракт
+// токена, точность, минимальные суммы и порог подтверждений.
+type AssetNetwork struct {
+	ID       string `gorm:"primaryKey;size:21" json:"id"`
+	AssetID  string `gorm:"size:21;not null;uniqueIndex:idx_asset_network" json:"assetID"`
+	Asset    Asset  `gorm:"foreignKey:AssetID" json:"-"`
+	Chain    string `gorm:"type:varchar(20);not null;uniqueIndex:idx_asset_network" json:"chain"`
+	Contract string `gorm:"type:varchar(255)" json:"contract,omitempty"`
+	// Xpub переопределяет ключ актива для сетей с другой схемой деривации.
+	Xpub              string          `gorm:"type:text" json:"-"`
+	Decimals          int32           `gorm:"not null" json:"decimals"`
+	MinDeposit        decimal.Decimal `gorm:"type:decimal(32,8);not null;default:0" json:"minDeposit"`
+	MinWithdrawal     decimal.Decimal `gorm:"type:decimal(32,8);not null;default:0" json:"minWithdrawal"`
+	Confirmations     int             `gorm:"not null;default:1" json:"confirmations"`
+	DepositEnabled    bool            `gorm:"not null;default:true" json:"depositEnabled"`
+	WithdrawalEnabled bool            `gorm:"not null;default:true" json:"withdrawalEnabled"`
+	// SweepThreshold — сумма на адресе депозита, начиная с которой средства
+	// переводятся на горячий кошелёк. Ноль отключает свип.
+	SweepThreshold decimal.Decimal `gorm:"type:decimal(32,8);not null;default:0" json:"sweepThreshold"`
+}
+
+func (n *AssetNetwork) BeforeCreate(tx *gorm.DB) (err error) {
+	if n.ID == "" {
+		n.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
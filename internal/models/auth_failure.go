@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"ptop/internal/utils"
+)
+
+// AuthFailure — неудачная попытка аутентификации: вход, восстановление,
+// проверка пароля или PIN-кода, неверный токен. Locked отмечает попытку,
+// после которой ключ был заблокирован.
+type AuthFailure struct {
+	ID        string    `gorm:"primaryKey;size:21" json:"id"`
+	Action    string    `gorm:"type:varchar(32);not null;index" json:"action"`
+	Username  string    `gorm:"type:varchar(255);index" json:"username,omitempty"`
+	ClientID  string    `gorm:"size:21;index" json:"clientID,omitempty"`
+	IP        string    `gorm:"type:varchar(45);index" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(255)" json:"userAgent"`
+	Locked    bool      `gorm:"not null;default:false" json:"locked"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+func (f *AuthFailure) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.ID == "" {
+		f.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
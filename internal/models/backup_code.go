@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"ptop/internal/utils"
+)
+
+// BackupCode — одноразовый резервный код двухфакторной аутентификации.
+// Хранится только SHA-256 кода; UsedAt отмечает использованный код.
+type BackupCode struct {
+	ID        string     `gorm:"primaryKey;size:21" json:"id"`
+	ClientID  string     `gorm:"size:21;not null;index" json:"-"`
+	Hash      string     `gorm:"column:code_hash;type:varchar(64);not null;index" json:"-"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+func (b *BackupCode) BeforeCreate(tx *gorm.DB) (err error) {
+	if b.ID == "" {
+		b.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
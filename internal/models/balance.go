@@ -19,6 +19,9 @@ type Balance struct {
 	AmountEscrow decimal.Decimal `gorm:"type:decimal(32,8);not null" json:"amountEscrow"`
 	CreatedAt    time.Time       `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt    time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
+	// FrozenAt — баланс заморожен сверкой до решения оператора: ордера и
+	// объявления по активу не создаются, средства по ордерам не выпускаются.
+	FrozenAt *time.Time `gorm:"index" json:"frozenAt,omitempty"`
 }
 
 func (b *Balance) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,11 @@
+package models
+
+// DerivationCounter хранит следующий свободный индекс деривации актива в
+// группе сетей с общей схемой адресов (Scope). Строка блокируется на время
+// выделения индекса, поэтому параллельные запросы не получают один адрес.
+type DerivationCounter struct {
+	AssetID   string `gorm:"primaryKey;size:21" json:"assetID"`
+	Asset     Asset  `gorm:"foreignKey:AssetID" json:"-"`
+	Scope     string `gorm:"primaryKey;type:varchar(20)" json:"scope"`
+	NextIndex uint32 `gorm:"not null;default:0" json:"nextIndex"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"ptop/internal/utils"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Reconciliation — проход сверки балансов с движениями средств.
+type Reconciliation struct {
+	ID         string    `gorm:"primaryKey;size:21" json:"id"`
+	Checked    int       `gorm:"not null" json:"checked"`
+	Mismatches int       `gorm:"not null" json:"mismatches"`
+	StartedAt  time.Time `gorm:"not null;index" json:"startedAt"`
+	FinishedAt time.Time `gorm:"not null" json:"finishedAt"`
+}
+
+func (r *Reconciliation) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
+
+// BalanceMismatch — расхождение баланса клиента с суммой его движений.
+// Запись остаётся открытой, пока оператор не отметит её решённой.
+type BalanceMismatch struct {
+	ID               string          `gorm:"primaryKey;size:21" json:"id"`
+	ReconciliationID string          `gorm:"size:21;not null;index" json:"reconciliationID"`
+	ClientID         string          `gorm:"size:21;not null;index:idx_mismatch_balance" json:"clientID"`
+	AssetID          string          `gorm:"size:21;not null;index:idx_mismatch_balance" json:"assetID"`
+	Amount           decimal.Decimal `gorm:"type:decimal(32,8);not null" json:"amount"`
+	ExpectedAmount   decimal.Decimal `gorm:"type:decimal(32,8);not null" json:"expectedAmount"`
+	AmountEscrow     decimal.Decimal `gorm:"type:decimal(32,8);not null" json:"amountEscrow"`
+	ExpectedEscrow   decimal.Decimal `gorm:"type:decimal(32,8);not null" json:"expectedEscrow"`
+	Frozen           bool            `gorm:"not null;default:false" json:"frozen"`
+	ResolvedAt       *time.Time      `gorm:"index" json:"resolvedAt,omitempty"`
+	Comment          string          `gorm:"type:text" json:"comment,omitempty"`
+	CreatedAt        time.Time       `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+func (m *BalanceMismatch) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.ID == "" {
+		m.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"ptop/internal/utils"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ReserveSnapshot — отчёт о резервах: корень дерева Меркла обязательств
+// перед клиентами, суммы обязательств и средства в сетях по активам и
+// подпись отчёта.
+type ReserveSnapshot struct {
+	ID          string         `gorm:"primaryKey;size:21" json:"id"`
+	Root        string         `gorm:"type:varchar(64);not null" json:"root"`
+	Liabilities datatypes.JSON `gorm:"type:json" json:"liabilities" swaggertype:"object"`
+	Reserves    datatypes.JSON `gorm:"type:json" json:"reserves" swaggertype:"object"`
+	LeafCount   int            `gorm:"not null" json:"leafCount"`
+	PublicKey   string         `gorm:"type:varchar(64)" json:"publicKey,omitempty"`
+	Signature   string         `gorm:"type:varchar(128)" json:"signature,omitempty"`
+	CreatedAt   time.Time      `gorm:"not null;index" json:"createdAt"`
+}
+
+func (s *ReserveSnapshot) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
+
+// ReserveLeaf — лист дерева обязательств: балансы клиента по активам на
+// момент отчёта. Соль скрывает клиента в опубликованных хешах.
+type ReserveLeaf struct {
+	SnapshotID string          `gorm:"primaryKey;size:21" json:"snapshotID"`
+	Snapshot   ReserveSnapshot `gorm:"foreignKey:SnapshotID" json:"-"`
+	Index      int             `gorm:"primaryKey;column:leaf_index" json:"index"`
+	ClientID   string          `gorm:"size:21;not null;index" json:"clientID"`
+	Salt       string          `gorm:"type:varchar(32);not null" json:"salt"`
+	Hash       string          `gorm:"type:varchar(64);not null" json:"hash"`
+	Balances   datatypes.JSON  `gorm:"type:json" json:"balances" swaggertype:"object"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"ptop/internal/utils"
+)
+
+// Session — вход клиента с одного устройства. Объединяет access и refresh
+// токены этого входа; ExpiresAt совпадает со сроком последнего refresh
+// токена.
+type Session struct {
+	ID         string     `gorm:"primaryKey;size:21" json:"id"`
+	ClientID   string     `gorm:"size:21;not null;index" json:"-"`
+	Device     string     `gorm:"type:varchar(100)" json:"device"`
+	UserAgent  string     `gorm:"type:varchar(255)" json:"userAgent"`
+	IP         string     `gorm:"type:varchar(45)" json:"ip"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expiresAt"`
+	RevokedAt  *time.Time `gorm:"index" json:"-"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
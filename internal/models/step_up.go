@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"ptop/internal/utils"
+)
+
+// StepUpToken — короткоживущий токен повышенных прав. Действует только в
+// сессии, где выдан, и подтверждает перечисленные в Factors факторы
+// (через запятую). Хранится SHA-256 токена.
+type StepUpToken struct {
+	ID        string    `gorm:"primaryKey;size:21" json:"id"`
+	ClientID  string    `gorm:"size:21;not null;index" json:"-"`
+	SessionID string    `gorm:"size:21;index" json:"-"`
+	Hash      string    `gorm:"column:token_hash;type:varchar(64);not null;uniqueIndex" json:"-"`
+	Factors   string    `gorm:"type:varchar(64);not null" json:"factors"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+func (s *StepUpToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
+
+// StepUpPolicy — политика клиента для действия: все факторы из Factors
+// (через запятую) обязательны. Без политики достаточно любого фактора,
+// разрешённого действием.
+type StepUpPolicy struct {
+	ID        string    `gorm:"primaryKey;size:21" json:"-"`
+	ClientID  string    `gorm:"size:21;not null;uniqueIndex:idx_step_up_policy" json:"-"`
+	Action    string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_step_up_policy" json:"action"`
+	Factors   string    `gorm:"type:varchar(64);not null" json:"factors"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (s *StepUpPolicy) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
+
+// Способы подтверждения действия в журнале.
+const (
+	StepUpMethodGrant  = "grant"
+	StepUpMethodToken  = "token"
+	StepUpMethodInline = "inline"
+	StepUpMethodAPIKey = "api_key"
+)
+
+// StepUpEvent — запись журнала повышения прав: выдача токена или проверка
+// факторов перед действием, успешная или нет.
+type StepUpEvent struct {
+	ID        string    `gorm:"primaryKey;size:21" json:"id"`
+	ClientID  string    `gorm:"size:21;not null;index" json:"-"`
+	SessionID string    `gorm:"size:21" json:"sessionId"`
+	Action    string    `gorm:"type:varchar(32);index" json:"action"`
+	Method    string    `gorm:"type:varchar(16);not null" json:"method"`
+	Factors   string    `gorm:"type:varchar(64)" json:"factors"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Reason    string    `gorm:"type:varchar(64)" json:"reason,omitempty"`
+	IP        string    `gorm:"type:varchar(45)" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(255)" json:"userAgent"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+func (s *StepUpEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
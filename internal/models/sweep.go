@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"ptop/internal/utils"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type SweepKind string
+
+const (
+	// SweepKindSweep — перевод средств с адресов депозита на горячий кошелёк.
+	SweepKindSweep SweepKind = "sweep"
+	// SweepKindTopUp — пополнение адреса депозита нативной монетой для оплаты
+	// газа перевода токена.
+	SweepKindTopUp SweepKind = "topup"
+)
+
+type SweepStatus string
+
+const (
+	// SweepStatusSent — транзакция отправлена и ждёт подтверждений.
+	SweepStatusSent      SweepStatus = "sent"
+	SweepStatusConfirmed SweepStatus = "confirmed"
+	// SweepStatusFailed — транзакцию не удалось подписать или отправить.
+	SweepStatusFailed SweepStatus = "failed"
+	// SweepStatusDropped — отправленная транзакция откатилась или пропала из
+	// сети; её депозиты снова доступны для свипа.
+	SweepStatusDropped SweepStatus = "dropped"
+)
+
+// Sweep — транзакция свипа: консолидация депозитов на горячем кошельке или
+// пополнение адреса депозита газом. Входы хранятся в Data.
+type Sweep struct {
+	ID        string          `gorm:"primaryKey;size:21" json:"id"`
+	Network   string          `gorm:"type:varchar(20);not null;index" json:"network"`
+	AssetID   string          `gorm:"size:21;not null" json:"assetID"`
+	Asset     Asset           `gorm:"foreignKey:AssetID" json:"-"`
+	Kind      SweepKind       `gorm:"type:varchar(10);not null" json:"kind"`
+	ToAddress string          `gorm:"type:varchar(255);not null;index" json:"toAddress"`
+	Amount    decimal.Decimal `gorm:"type:decimal(32,18);not null" json:"amount"`
+	Fee       decimal.Decimal `gorm:"type:decimal(32,18);not null;default:0" json:"fee"`
+	TxID      string          `gorm:"type:varchar(255)" json:"txID,omitempty"`
+	Status    SweepStatus     `gorm:"type:varchar(20);not null" json:"status"`
+	Error     string          `gorm:"type:text" json:"error,omitempty"`
+	Data      datatypes.JSON  `gorm:"type:json" json:"data" swaggertype:"object"`
+	CreatedAt time.Time       `gorm:"autoCreateTime" json:"createdAt"`
+	// ConfirmedAt — время, когда транзакция набрала подтверждения сети.
+	ConfirmedAt *time.Time `json:"confirmedAt,omitempty"`
+}
+
+func (s *Sweep) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
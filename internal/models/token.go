@@ -7,13 +7,22 @@ import (
 	"ptop/internal/utils"
 )
 
+// Token — выданный клиенту access или refresh токен. Сам токен не хранится,
+// только его SHA-256. Токены одного входа принадлежат сессии SessionID: при
+// обновлении пары сессия сохраняется, а при повторном использовании refresh
+// токена отзывается целиком.
 type Token struct {
-	ID        string    `gorm:"primaryKey;size:21"`
-	ClientID  string    `gorm:"index;not null"`
-	Token     string    `gorm:"type:varchar(255);not null;unique"`
-	Type      string    `gorm:"type:varchar(10);not null"`
-	ExpiresAt time.Time `gorm:"not null"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
+	ID        string     `gorm:"primaryKey;size:21"`
+	ClientID  string     `gorm:"index;not null"`
+	Hash      string     `gorm:"column:token_hash;type:varchar(64);not null;uniqueIndex"`
+	Type      string     `gorm:"type:varchar(10);not null"`
+	SessionID string     `gorm:"size:21;index"`
+	IP        string     `gorm:"type:varchar(45)"`
+	UserAgent string     `gorm:"type:varchar(255)"`
+	UsedAt    *time.Time // refresh токен обменян на новую пару
+	RevokedAt *time.Time `gorm:"index"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
 }
 
 func (t *Token) BeforeCreate(tx *gorm.DB) (err error) {
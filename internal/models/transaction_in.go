@@ -20,19 +20,32 @@ const (
 )
 
 type TransactionIn struct {
-	ID        string              `gorm:"primaryKey;size:21" json:"id"`
-	ClientID  string              `gorm:"size:21;not null"`
-	Client    Client              `gorm:"foreignKey:ClientID" json:"-"`
-	WalletID  string              `gorm:"size:21;not null"`
-	Wallet    Wallet              `gorm:"foreignKey:WalletID" json:"-"`
-	AssetID   string              `gorm:"size:21;not null"`
-	Asset     Asset               `gorm:"foreignKey:AssetID" json:"-"`
-	AssetName string              `gorm:"->;column:asset_name" json:"assetName"`
-	Amount    decimal.Decimal     `gorm:"type:decimal(32,8);not null" json:"amount"`
-	Status    TransactionInStatus `gorm:"type:varchar(20);not null" json:"status"`
-	Data      datatypes.JSON      `gorm:"type:json" swaggertype:"object"`
-	CreatedAt time.Time           `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt time.Time           `gorm:"autoUpdateTime" json:"updatedAt"`
+	ID          string              `gorm:"primaryKey;size:21" json:"id"`
+	ClientID    string              `gorm:"size:21;not null"`
+	Client      Client              `gorm:"foreignKey:ClientID" json:"-"`
+	WalletID    string              `gorm:"size:21;not null"`
+	Wallet      Wallet              `gorm:"foreignKey:WalletID" json:"-"`
+	AssetID     string              `gorm:"size:21;not null"`
+	Asset       Asset               `gorm:"foreignKey:AssetID" json:"-"`
+	AssetName   string              `gorm:"->;column:asset_name" json:"assetName"`
+	Amount      decimal.Decimal     `gorm:"type:decimal(32,8);not null" json:"amount"`
+	Status      TransactionInStatus `gorm:"type:varchar(20);not null" json:"status"`
+	Network     string              `gorm:"type:varchar(20);index;uniqueIndex:idx_transaction_in_tx" json:"network,omitempty"`
+	BlockHeight uint64              `gorm:"not null;default:0" json:"blockHeight,omitempty"`
+	Data        datatypes.JSON      `gorm:"type:json" swaggertype:"object"`
+	CreatedAt   time.Time           `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time           `gorm:"autoUpdateTime" json:"updatedAt"`
+	// TxHash и LogIndex однозначно определяют перевод в сети, если сеть их
+	// сообщает; у нативных переводов EVM LogIndex равен -1.
+	TxHash   *string `gorm:"type:varchar(100);uniqueIndex:idx_transaction_in_tx" json:"-"`
+	LogIndex int     `gorm:"not null;default:0;uniqueIndex:idx_transaction_in_tx" json:"-"`
+	// SweepID — свип, которым депозит переводится на горячий кошелёк. Пока
+	// транзакция свипа не подтверждена, депозит не планируется повторно; если
+	// она выпала из сети, SweepID сбрасывается.
+	SweepID *string `gorm:"size:21;index" json:"-"`
+	// SweptAt — время подтверждения свипа: только после него депозит
+	// считается переведённым.
+	SweptAt *time.Time `json:"-"`
 }
 
 func (t *TransactionIn) BeforeCreate(tx *gorm.DB) (err error) {
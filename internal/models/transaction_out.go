@@ -29,6 +29,7 @@ type TransactionOut struct {
 	Amount      decimal.Decimal      `gorm:"type:decimal(32,8);not null"`
 	FromAddress string               `gorm:"type:varchar(255)"`
 	ToAddress   string               `gorm:"type:varchar(255)"`
+	Network     string               `gorm:"type:varchar(20);index" json:"network,omitempty"`
 	Status      TransactionOutStatus `gorm:"type:varchar(20);not null"`
         Data        datatypes.JSON       `gorm:"type:json" swaggertype:"object"`
 	CreatedAt   time.Time            `gorm:"autoCreateTime"`
@@ -10,16 +10,22 @@ import (
 
 type Wallet struct {
 	ID              string     `gorm:"primaryKey;size:21" json:"id"`
-	ClientID        string     `gorm:"size:21;not null;uniqueIndex:idx_wallet_client_asset_active" json:"clientID"`
+	ClientID        string     `gorm:"size:21;not null;uniqueIndex:idx_wallet_client_asset_network_enabled,where:is_enabled = true" json:"clientID"`
 	Client          Client     `gorm:"foreignKey:ClientID" json:"-"`
-	AssetID         string     `gorm:"size:21;not null;uniqueIndex:idx_wallet_client_asset_active" json:"assetID"`
+	AssetID         string     `gorm:"size:21;not null;uniqueIndex:idx_wallet_client_asset_network_enabled;uniqueIndex:idx_wallet_asset_network_index;uniqueIndex:idx_wallet_asset_network_value" json:"assetID"`
 	Asset           Asset      `gorm:"foreignKey:AssetID" json:"-"`
-	Value           string     `gorm:"type:varchar(255);not null" json:"value"`
-	DerivationIndex uint32     `gorm:"not null" json:"index"`
+	Network         string     `gorm:"type:varchar(20);not null;default:'';uniqueIndex:idx_wallet_client_asset_network_enabled;uniqueIndex:idx_wallet_asset_network_index;uniqueIndex:idx_wallet_asset_network_value" json:"network"`
+	Value           string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_wallet_asset_network_value" json:"value"`
+	DerivationIndex uint32     `gorm:"not null;uniqueIndex:idx_wallet_asset_network_index" json:"index"`
 	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"createdAt"`
-	IsEnabled       bool       `gorm:"not null;default:true;uniqueIndex:idx_wallet_client_asset_active" json:"-"`
+	IsEnabled       bool       `gorm:"not null;default:true" json:"isEnabled"`
 	EnabledAt       time.Time  `gorm:"autoCreateTime" json:"enabledAt"`
-	DisabledAt      *time.Time `json:"-"`
+	DisabledAt      *time.Time `json:"disabledAt,omitempty"`
+	// ExpiresAt — конец льготного периода после ротации: до него депозиты на
+	// старый адрес зачисляются, после — отклоняются.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// PreviousID — кошелёк, адрес которого заменён этим при ротации.
+	PreviousID *string `gorm:"size:21" json:"previousID,omitempty"`
 }
 
 func (w *Wallet) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// WatcherCursor — последний обработанный блок сети. По нему наблюдатель
+// продолжает обработку после перезапуска.
+type WatcherCursor struct {
+	Network   string    `gorm:"primaryKey;type:varchar(20)" json:"network"`
+	Height    uint64    `gorm:"not null" json:"height"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
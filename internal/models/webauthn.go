@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"ptop/internal/utils"
+)
+
+// WebAuthnCredential — passkey клиента. CredentialID хранится в base64url,
+// PublicKey — в формате COSE, как его вернул аутентификатор.
+type WebAuthnCredential struct {
+	ID             string     `gorm:"primaryKey;size:21" json:"id"`
+	ClientID       string     `gorm:"size:21;not null;index" json:"-"`
+	CredentialID   string     `gorm:"type:varchar(255);not null;uniqueIndex" json:"credentialID"`
+	PublicKey      []byte     `gorm:"not null" json:"-"`
+	Alg            int64      `gorm:"not null" json:"alg"`
+	SignCount      uint32     `gorm:"not null;default:0" json:"-"`
+	AAGUID         string     `gorm:"type:varchar(32)" json:"aaguid"`
+	Name           string     `gorm:"type:varchar(100)" json:"name"`
+	BackupEligible bool       `gorm:"not null;default:false" json:"backupEligible"`
+	LastUsedAt     *time.Time `json:"lastUsedAt"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+func (w *WebAuthnCredential) BeforeCreate(tx *gorm.DB) (err error) {
+	if w.ID == "" {
+		w.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
+
+// Назначение вызова WebAuthn.
+const (
+	WebAuthnPurposeRegister = "register"
+	WebAuthnPurposeLogin    = "login"
+	WebAuthnPurposeVerify   = "verify"
+)
+
+// WebAuthnChallenge — одноразовый вызов церемонии WebAuthn. Для входа без
+// пароля ClientID пуст, пока пользователь не выбран ключом.
+type WebAuthnChallenge struct {
+	ID        string    `gorm:"primaryKey;size:21" json:"id"`
+	ClientID  string    `gorm:"size:21;index" json:"-"`
+	Purpose   string    `gorm:"type:varchar(16);not null" json:"-"`
+	Challenge []byte    `gorm:"not null" json:"-"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
+}
+
+func (w *WebAuthnChallenge) BeforeCreate(tx *gorm.DB) (err error) {
+	if w.ID == "" {
+		w.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"ptop/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// XMRSubaddress связывает подадрес кошелька monero-wallet-rpc (номер
+// аккаунта и индекс подадреса) с кошельком клиента.
+type XMRSubaddress struct {
+	ID           string    `gorm:"primaryKey;size:21" json:"id"`
+	WalletID     string    `gorm:"size:21;not null;uniqueIndex" json:"walletID"`
+	Wallet       Wallet    `gorm:"foreignKey:WalletID" json:"-"`
+	AccountIndex uint32    `gorm:"not null;uniqueIndex:idx_xmr_subaddress" json:"accountIndex"`
+	AddressIndex uint32    `gorm:"not null;uniqueIndex:idx_xmr_subaddress" json:"addressIndex"`
+	Address      string    `gorm:"type:varchar(255);not null" json:"address"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+func (s *XMRSubaddress) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID, err = utils.GenerateNanoID()
+	}
+	return
+}
@@ -0,0 +1,74 @@
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"ptop/internal/models"
+)
+
+// Alerter оповещает операторов о новых расхождениях балансов.
+type Alerter interface {
+	Alert(ctx context.Context, rec models.Reconciliation, mismatches []models.BalanceMismatch) error
+}
+
+// LogAlerter пишет расхождения в лог.
+type LogAlerter struct{}
+
+func (LogAlerter) Alert(ctx context.Context, rec models.Reconciliation, mismatches []models.BalanceMismatch) error {
+	for _, m := range mismatches {
+		log.Printf("расхождение баланса %s: клиент %s, актив %s, баланс %s/%s, по движениям %s/%s, заморожен %t",
+			m.ID, m.ClientID, m.AssetID, m.Amount, m.AmountEscrow, m.ExpectedAmount, m.ExpectedEscrow, m.Frozen)
+	}
+	return nil
+}
+
+// WebhookAlerter отправляет проход сверки и новые расхождения POST-запросом
+// в JSON: {"reconciliation": ..., "mismatches": [...]}.
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (a WebhookAlerter) Alert(ctx context.Context, rec models.Reconciliation, mismatches []models.BalanceMismatch) error {
+	body, err := json.Marshal(map[string]any{"reconciliation": rec, "mismatches": mismatches})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// Alerters рассылает оповещение всем получателям.
+type Alerters []Alerter
+
+func (as Alerters) Alert(ctx context.Context, rec models.Reconciliation, mismatches []models.BalanceMismatch) error {
+	var firstErr error
+	for _, a := range as {
+		if err := a.Alert(ctx, rec, mismatches); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,252 @@
+// Package reconcile сверяет балансы клиентов с движениями средств.
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"ptop/config"
+	"ptop/internal/models"
+)
+
+// Options — настройки сверки.
+type Options struct {
+	// Freeze замораживает балансы с расхождением до решения оператора.
+	Freeze bool
+	// Alerter получает новые расхождения; по умолчанию LogAlerter.
+	Alerter Alerter
+}
+
+// Reconciler пересчитывает балансы по депозитам, выводам, внутренним
+// переводам и эскроу и сравнивает их с таблицей balances.
+type Reconciler struct {
+	db   *gorm.DB
+	opts Options
+}
+
+func New(db *gorm.DB, opts Options) *Reconciler {
+	if opts.Alerter == nil {
+		opts.Alerter = LogAlerter{}
+	}
+	return &Reconciler{db: db, opts: opts}
+}
+
+// key — баланс клиента по активу.
+type key struct {
+	ClientID string
+	AssetID  string
+}
+
+// expected — балансы, рассчитанные по движениям средств.
+type expected struct {
+	amount decimal.Decimal
+	escrow decimal.Decimal
+}
+
+// ledger суммирует движения средств. Доступный баланс — подтверждённые
+// депозиты минус выводы (кроме неудачных и отменённых), плюс полученные и
+// минус отправленные подтверждённые внутренние переводы, минус средства в
+// эскроу; баланс эскроу — сумма эскроу клиента.
+func ledger(db *gorm.DB) (map[key]expected, error) {
+	res := make(map[key]expected)
+	add := func(query *gorm.DB, sign int64, escrow bool) error {
+		var rows []struct {
+			ClientID string
+			AssetID  string
+			Amount   decimal.Decimal
+		}
+		if err := query.Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			k := key{r.ClientID, r.AssetID}
+			e := res[k]
+			amount := r.Amount.Mul(decimal.NewFromInt(sign))
+			if escrow {
+				e.escrow = e.escrow.Add(r.Amount)
+			}
+			e.amount = e.amount.Add(amount)
+			res[k] = e
+		}
+		return nil
+	}
+	queries := []struct {
+		query  *gorm.DB
+		sign   int64
+		escrow bool
+	}{
+		{db.Table("transaction_ins").Select("client_id, asset_id, SUM(amount) AS amount").
+			Where("status = ?", models.TransactionInStatusConfirmed).Group("client_id, asset_id"), 1, false},
+		{db.Table("transaction_outs").Select("client_id, asset_id, SUM(amount) AS amount").
+			Where("status NOT IN ?", []models.TransactionOutStatus{models.TransactionOutStatusFailed, models.TransactionOutStatusCancelled}).
+			Group("client_id, asset_id"), -1, false},
+		{db.Table("transaction_internals").Select("to_client_id AS client_id, asset_id, SUM(amount) AS amount").
+			Where("status = ? AND to_client_id <> ''", models.TransactionInternalStatusConfirmed).Group("to_client_id, asset_id"), 1, false},
+		{db.Table("transaction_internals").Select("from_client_id AS client_id, asset_id, SUM(amount) AS amount").
+			Where("status = ? AND from_client_id <> ''", models.TransactionInternalStatusConfirmed).Group("from_client_id, asset_id"), -1, false},
+		{db.Table("escrows").Select("client_id, asset_id, SUM(amount) AS amount").Group("client_id, asset_id"), -1, true},
+	}
+	for _, q := range queries {
+		if err := add(q.query, q.sign, q.escrow); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// RunOnce выполняет сверку: сохраняет проход и новые расхождения, при
+// Freeze замораживает балансы и отправляет оповещение. Уже открытые
+// расхождения по тому же балансу повторно не сохраняются.
+func (r *Reconciler) RunOnce(ctx context.Context) (models.Reconciliation, []models.BalanceMismatch, error) {
+	rec := models.Reconciliation{StartedAt: time.Now()}
+	var (
+		ledgerRows map[key]expected
+		balances   []models.Balance
+	)
+	// Балансы и движения читаются из одного снимка БД, чтобы зачисление
+	// между запросами не выглядело расхождением
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if ledgerRows, err = ledger(tx); err != nil {
+			return err
+		}
+		return tx.Find(&balances).Error
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return rec, nil, err
+	}
+
+	var found []models.BalanceMismatch
+	seen := make(map[key]bool, len(balances))
+	for _, b := range balances {
+		k := key{b.ClientID, b.AssetID}
+		seen[k] = true
+		e := ledgerRows[k]
+		if !b.Amount.Equal(e.amount) || !b.AmountEscrow.Equal(e.escrow) {
+			found = append(found, mismatch(k, b.Amount, b.AmountEscrow, e))
+		}
+	}
+	for k, e := range ledgerRows {
+		if !seen[k] && (!e.amount.IsZero() || !e.escrow.IsZero()) {
+			found = append(found, mismatch(k, decimal.Zero, decimal.Zero, e))
+		}
+	}
+	rec.Checked = len(balances)
+	rec.Mismatches = len(found)
+
+	var fresh []models.BalanceMismatch
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rec.FinishedAt = time.Now()
+		if err := tx.Create(&rec).Error; err != nil {
+			return err
+		}
+		for _, m := range found {
+			var open int64
+			if err := tx.Model(&models.BalanceMismatch{}).
+				Where("client_id = ? AND asset_id = ? AND resolved_at IS NULL", m.ClientID, m.AssetID).
+				Count(&open).Error; err != nil {
+				return err
+			}
+			if open > 0 {
+				continue
+			}
+			m.ReconciliationID = rec.ID
+			if r.opts.Freeze {
+				res := tx.Model(&models.Balance{}).
+					Where("client_id = ? AND asset_id = ? AND frozen_at IS NULL", m.ClientID, m.AssetID).
+					Update("frozen_at", rec.FinishedAt)
+				if res.Error != nil {
+					return res.Error
+				}
+				m.Frozen = res.RowsAffected > 0
+			}
+			if err := tx.Create(&m).Error; err != nil {
+				return err
+			}
+			fresh = append(fresh, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return rec, nil, err
+	}
+	if len(fresh) > 0 {
+		if err := r.opts.Alerter.Alert(ctx, rec, fresh); err != nil {
+			log.Printf("оповещение о расхождениях балансов не отправлено: %v", err)
+		}
+	}
+	return rec, fresh, nil
+}
+
+func mismatch(k key, amount, escrow decimal.Decimal, e expected) models.BalanceMismatch {
+	return models.BalanceMismatch{
+		ClientID:       k.ClientID,
+		AssetID:        k.AssetID,
+		Amount:         amount,
+		ExpectedAmount: e.amount,
+		AmountEscrow:   escrow,
+		ExpectedEscrow: e.escrow,
+	}
+}
+
+// Start запускает сверку по расписанию в отдельной горутине до отмены ctx.
+func (r *Reconciler) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, _, err := r.RunOnce(ctx); err != nil {
+					log.Printf("ошибка сверки балансов: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+var ErrMismatchNotFound = errors.New("mismatch not found")
+
+// Resolve закрывает расхождение после проверки оператором и размораживает
+// баланс, если по нему не осталось открытых расхождений.
+func Resolve(db *gorm.DB, id, comment string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var m models.BalanceMismatch
+		if err := tx.Where("id = ? AND resolved_at IS NULL", id).First(&m).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMismatchNotFound
+			}
+			return err
+		}
+		if err := tx.Model(&m).Updates(map[string]any{"resolved_at": time.Now(), "comment": comment}).Error; err != nil {
+			return err
+		}
+		var open int64
+		if err := tx.Model(&models.BalanceMismatch{}).
+			Where("client_id = ? AND asset_id = ? AND resolved_at IS NULL", m.ClientID, m.AssetID).
+			Count(&open).Error; err != nil || open > 0 {
+			return err
+		}
+		return tx.Model(&models.Balance{}).
+			Where("client_id = ? AND asset_id = ?", m.ClientID, m.AssetID).
+			Update("frozen_at", nil).Error
+	})
+}
+
+// Build создаёт сверку по настройкам RECONCILE_*: оповещения пишутся в лог
+// и, если задан вебхук, отправляются в него.
+func Build(db *gorm.DB, cfg *config.Config) *Reconciler {
+	alerter := Alerters{LogAlerter{}}
+	if cfg.ReconcileAlertWebhook != "" {
+		alerter = append(alerter, WebhookAlerter{URL: cfg.ReconcileAlertWebhook})
+	}
+	return New(db, Options{Freeze: cfg.ReconcileFreeze, Alerter: alerter})
+}
@@ -0,0 +1,95 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+)
+
+type recordAlerter struct{ alerts [][]models.BalanceMismatch }
+
+func (a *recordAlerter) Alert(ctx context.Context, rec models.Reconciliation, mismatches []models.BalanceMismatch) error {
+	a.alerts = append(a.alerts, mismatches)
+	return nil
+}
+
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.Wallet{}, &models.Balance{}, &models.Escrow{},
+		&models.TransactionIn{}, &models.TransactionOut{}, &models.TransactionInternal{},
+		&models.Reconciliation{}, &models.BalanceMismatch{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func dec(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func TestReconcile(t *testing.T) {
+	db := setupDB(t)
+	asset := "asset1"
+	// c1: депозит 10, вывод 2, отменённый вывод 5, получил 3, в эскроу 4
+	db.Create(&models.TransactionIn{ClientID: "c1", AssetID: asset, Amount: dec("10"), Status: models.TransactionInStatusConfirmed})
+	db.Create(&models.TransactionIn{ClientID: "c1", AssetID: asset, Amount: dec("7"), Status: models.TransactionInStatusProcessing})
+	db.Create(&models.TransactionOut{ClientID: "c1", AssetID: asset, Amount: dec("2"), Status: models.TransactionOutStatusPending})
+	db.Create(&models.TransactionOut{ClientID: "c1", AssetID: asset, Amount: dec("5"), Status: models.TransactionOutStatusCancelled})
+	db.Create(&models.TransactionInternal{FromClientID: "c2", ToClientID: "c1", AssetID: asset, Amount: dec("3"), Status: models.TransactionInternalStatusConfirmed})
+	db.Create(&models.Escrow{ClientID: "c1", AssetID: asset, Amount: dec("4")})
+	db.Create(&models.Balance{ClientID: "c1", AssetID: asset, Amount: dec("7"), AmountEscrow: dec("4")})
+	// c2: депозит 5, отправил 3, но баланс не списан
+	db.Create(&models.TransactionIn{ClientID: "c2", AssetID: asset, Amount: dec("5"), Status: models.TransactionInStatusConfirmed})
+	db.Create(&models.Balance{ClientID: "c2", AssetID: asset, Amount: dec("5"), AmountEscrow: decimal.Zero})
+	// c3: депозит без строки баланса
+	db.Create(&models.TransactionIn{ClientID: "c3", AssetID: asset, Amount: dec("1"), Status: models.TransactionInStatusConfirmed})
+
+	alerter := &recordAlerter{}
+	r := New(db, Options{Freeze: true, Alerter: alerter})
+	rec, found, err := r.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if rec.Checked != 2 || rec.Mismatches != 2 || len(found) != 2 || len(alerter.alerts) != 1 {
+		t.Fatalf("unexpected result %+v %+v", rec, found)
+	}
+	byClient := map[string]models.BalanceMismatch{}
+	for _, m := range found {
+		byClient[m.ClientID] = m
+	}
+	if m := byClient["c2"]; !m.ExpectedAmount.Equal(dec("2")) || !m.Amount.Equal(dec("5")) || !m.Frozen {
+		t.Fatalf("c2 mismatch %+v", m)
+	}
+	if m := byClient["c3"]; !m.ExpectedAmount.Equal(dec("1")) || m.Frozen {
+		t.Fatalf("c3 mismatch %+v", m)
+	}
+	var b models.Balance
+	db.Where("client_id = ?", "c2").First(&b)
+	if b.FrozenAt == nil {
+		t.Fatalf("c2 balance not frozen")
+	}
+
+	// открытые расхождения повторно не сохраняются и не оповещаются
+	if _, found, _ = r.RunOnce(context.Background()); len(found) != 0 || len(alerter.alerts) != 1 {
+		t.Fatalf("duplicate mismatches %+v", found)
+	}
+
+	if err := Resolve(db, byClient["c2"].ID, "списание восстановлено"); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if err := Resolve(db, byClient["c2"].ID, ""); err != ErrMismatchNotFound {
+		t.Fatalf("expected ErrMismatchNotFound, got %v", err)
+	}
+	var unfrozen models.Balance
+	db.Where("client_id = ?", "c2").First(&unfrozen)
+	if unfrozen.FrozenAt != nil {
+		t.Fatalf("balance still frozen")
+	}
+}
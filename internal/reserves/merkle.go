@@ -0,0 +1,169 @@
+package reserves
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Sums — суммы по активам: имя актива → сумма.
+type Sums map[string]decimal.Decimal
+
+// encode кодирует суммы однозначно: активы по алфавиту, суммы без
+// незначащих нулей.
+func (s Sums) encode() []byte {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(s[name].String())
+		sb.WriteByte(';')
+	}
+	return []byte(sb.String())
+}
+
+func (s Sums) add(o Sums) Sums {
+	res := make(Sums, len(s)+len(o))
+	for name, v := range s {
+		res[name] = v
+	}
+	for name, v := range o {
+		res[name] = res[name].Add(v)
+	}
+	return res
+}
+
+// Equal сравнивает суммы по значению; нулевые суммы равны отсутствующим.
+func (s Sums) Equal(o Sums) bool {
+	for name, v := range s {
+		if !v.Equal(o[name]) {
+			return false
+		}
+	}
+	for name, v := range o {
+		if !v.Equal(s[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Sums) negative() bool {
+	for _, v := range s {
+		if v.IsNegative() {
+			return true
+		}
+	}
+	return false
+}
+
+// node — узел дерева Меркла с суммами: хеш поддерева и суммы его листьев.
+type node struct {
+	hash [32]byte
+	sums Sums
+}
+
+// LeafHash возвращает хеш листа клиента. Идентификатор клиента входит в лист
+// только в виде хеша с солью, поэтому по опубликованным узлам нельзя
+// узнать, чьи это балансы.
+func LeafHash(clientID, salt string, balances Sums) string {
+	h := leafHash(clientID, salt, balances)
+	return hex.EncodeToString(h[:])
+}
+
+func leafHash(clientID, salt string, balances Sums) [32]byte {
+	id := sha256.Sum256([]byte(clientID + ":" + salt))
+	buf := append([]byte{0x00}, id[:]...)
+	return sha256.Sum256(append(buf, balances.encode()...))
+}
+
+// parent объединяет два узла. В хеш входят суммы обоих детей, поэтому
+// нельзя перенести обязательства из одного поддерева в другое, не изменив
+// корень.
+func parent(l, r node) node {
+	buf := []byte{0x01}
+	buf = append(buf, l.hash[:]...)
+	buf = append(buf, l.sums.encode()...)
+	buf = append(buf, r.hash[:]...)
+	buf = append(buf, r.sums.encode()...)
+	return node{hash: sha256.Sum256(buf), sums: l.sums.add(r.sums)}
+}
+
+// levels строит дерево снизу вверх. Узел без пары переносится на уровень
+// выше без изменений: дублирование удвоило бы его суммы.
+func levels(leaves []node) [][]node {
+	if len(leaves) == 0 {
+		return [][]node{{{sums: Sums{}}}}
+	}
+	res := [][]node{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([]node, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, parent(level[i], level[i+1]))
+		}
+		res = append(res, next)
+		level = next
+	}
+	return res
+}
+
+// Step — соседний узел на пути от листа к корню.
+type Step struct {
+	Hash string `json:"hash"`
+	Sums Sums   `json:"sums" swaggertype:"object"`
+	// Left — сосед стоит слева от узла пути.
+	Left bool `json:"left"`
+}
+
+// path возвращает соседей листа index снизу вверх. Уровни, где узел пути
+// переносится без пары, пропускаются.
+func path(tree [][]node, index int) []Step {
+	var steps []Step
+	for _, level := range tree[:len(tree)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			n := level[sibling]
+			steps = append(steps, Step{Hash: hex.EncodeToString(n.hash[:]), Sums: n.sums, Left: sibling < index})
+		}
+		index /= 2
+	}
+	return steps
+}
+
+// fold поднимается от листа по пути и возвращает корень.
+func fold(leaf node, steps []Step) (node, error) {
+	cur := leaf
+	for i, st := range steps {
+		raw, err := hex.DecodeString(st.Hash)
+		if err != nil || len(raw) != 32 {
+			return node{}, fmt.Errorf("step %d: invalid hash", i)
+		}
+		if st.Sums.negative() {
+			return node{}, fmt.Errorf("step %d: negative sums", i)
+		}
+		sibling := node{sums: st.Sums}
+		copy(sibling.hash[:], raw)
+		if st.Left {
+			cur = parent(sibling, cur)
+		} else {
+			cur = parent(cur, sibling)
+		}
+	}
+	return cur, nil
+}
+
+var errRootMismatch = errors.New("root mismatch")
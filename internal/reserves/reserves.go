@@ -0,0 +1,313 @@
+// Package reserves строит отчёт о платёжеспособности: обязательства перед
+// клиентами в виде дерева Меркла с суммами и средства платформы в сетях.
+package reserves
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"ptop/internal/chains"
+	"ptop/internal/models"
+	"ptop/internal/utils"
+)
+
+var (
+	ErrNoSnapshot  = errors.New("no reserve snapshot")
+	ErrNotIncluded = errors.New("client is not included in snapshot")
+)
+
+// HoldingsFunc возвращает чтение балансов сети chain.
+type HoldingsFunc func(chain string) (chains.Holdings, error)
+
+// NetworkReserve — средства актива в одной сети.
+type NetworkReserve struct {
+	Network   string          `json:"network"`
+	Addresses int             `json:"addresses"`
+	Amount    decimal.Decimal `json:"amount"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// AssetReserve сравнивает обязательства по активу со средствами в сетях.
+type AssetReserve struct {
+	Asset       string          `json:"asset"`
+	Liabilities decimal.Decimal `json:"liabilities"`
+	Reserves    decimal.Decimal `json:"reserves"`
+	// Verified — средства удалось посчитать во всех сетях актива. Фиатные
+	// активы и сети без чтения балансов не проверяются.
+	Verified bool `json:"verified"`
+	// Solvent — проверенные средства покрывают обязательства.
+	Solvent  bool             `json:"solvent"`
+	Networks []NetworkReserve `json:"networks,omitempty"`
+}
+
+// Report — отчёт до сохранения: снимок, его листья и сверка по активам.
+type Report struct {
+	Snapshot models.ReserveSnapshot
+	Leaves   []models.ReserveLeaf
+	Assets   []AssetReserve
+}
+
+// Generate собирает отчёт: суммирует Amount + AmountEscrow балансов по
+// клиентам и активам, строит дерево обязательств и считает средства на
+// адресах депозита и горячих кошельках hotWallets каждой сети.
+func Generate(ctx context.Context, db *gorm.DB, holdings HoldingsFunc, hotWallets map[string]string) (*Report, error) {
+	id, err := utils.GenerateNanoID()
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ClientID string
+		Asset    string
+		Amount   decimal.Decimal
+	}
+	if err := db.Table("balances").
+		Select("balances.client_id, assets.name AS asset, balances.amount + balances.amount_escrow AS amount").
+		Joins("JOIN assets ON assets.id = balances.asset_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	clients := make(map[string]Sums)
+	for _, r := range rows {
+		if r.Amount.IsNegative() {
+			return nil, fmt.Errorf("negative %s balance of client %s", r.Asset, r.ClientID)
+		}
+		if r.Amount.IsZero() {
+			continue
+		}
+		if clients[r.ClientID] == nil {
+			clients[r.ClientID] = Sums{}
+		}
+		clients[r.ClientID][r.Asset] = clients[r.ClientID][r.Asset].Add(r.Amount)
+	}
+
+	report := &Report{}
+	for clientID, balances := range clients {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		saltHex := hex.EncodeToString(salt)
+		data, _ := json.Marshal(balances)
+		report.Leaves = append(report.Leaves, models.ReserveLeaf{
+			SnapshotID: id,
+			ClientID:   clientID,
+			Salt:       saltHex,
+			Hash:       LeafHash(clientID, saltHex, balances),
+			Balances:   data,
+		})
+	}
+	// Листья упорядочены по хешу: порядок случаен и не выдаёт клиентов
+	sort.Slice(report.Leaves, func(i, j int) bool { return report.Leaves[i].Hash < report.Leaves[j].Hash })
+	nodes := make([]node, len(report.Leaves))
+	for i := range report.Leaves {
+		report.Leaves[i].Index = i
+		nodes[i] = node{sums: clients[report.Leaves[i].ClientID]}
+		raw, _ := hex.DecodeString(report.Leaves[i].Hash)
+		copy(nodes[i].hash[:], raw)
+	}
+	tree := levels(nodes)
+	root := tree[len(tree)-1][0]
+
+	report.Assets, err = collectReserves(ctx, db, root.sums, holdings, hotWallets)
+	if err != nil {
+		return nil, err
+	}
+	liabilities, _ := json.Marshal(root.sums)
+	assets, _ := json.Marshal(report.Assets)
+	report.Snapshot = models.ReserveSnapshot{
+		ID:          id,
+		Root:        hex.EncodeToString(root.hash[:]),
+		Liabilities: liabilities,
+		Reserves:    assets,
+		LeafCount:   len(report.Leaves),
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	return report, nil
+}
+
+// collectReserves считает средства каждого актива с обязательствами или
+// сетями на адресах его кошельков и горячем кошельке сети.
+func collectReserves(ctx context.Context, db *gorm.DB, liabilities Sums, holdings HoldingsFunc, hotWallets map[string]string) ([]AssetReserve, error) {
+	var assets []models.Asset
+	if err := db.Preload("Networks", func(tx *gorm.DB) *gorm.DB { return tx.Order("chain") }).
+		Order("name").Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	var res []AssetReserve
+	for _, asset := range assets {
+		ar := AssetReserve{Asset: asset.Name, Liabilities: liabilities[asset.Name]}
+		if ar.Liabilities.IsZero() && len(asset.Networks) == 0 {
+			continue
+		}
+		ar.Verified = asset.Type == models.AssetTypeCrypto && len(asset.Networks) > 0
+		for _, network := range asset.Networks {
+			nr := NetworkReserve{Network: network.Chain}
+			var addresses []string
+			if err := db.Model(&models.Wallet{}).
+				Where("asset_id = ? AND network = ?", asset.ID, network.Chain).
+				Distinct().Pluck("value", &addresses).Error; err != nil {
+				return nil, err
+			}
+			if hot := hotWallets[network.Chain]; hot != "" {
+				addresses = append(addresses, hot)
+			}
+			nr.Addresses = len(addresses)
+			h, err := holdings(network.Chain)
+			if err == nil {
+				nr.Amount, err = h.Balance(ctx, network, addresses)
+			}
+			if err != nil {
+				nr.Error = err.Error()
+				ar.Verified = false
+			}
+			ar.Reserves = ar.Reserves.Add(nr.Amount)
+			ar.Networks = append(ar.Networks, nr)
+		}
+		ar.Solvent = ar.Verified && ar.Reserves.GreaterThanOrEqual(ar.Liabilities)
+		res = append(res, ar)
+	}
+	return res, nil
+}
+
+// ParseSigningKey разбирает ключ подписи отчётов: seed Ed25519 в hex.
+func ParseSigningKey(s string) (ed25519.PrivateKey, error) {
+	seed, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New("signing key must be a hex ed25519 seed")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// Payload возвращает подписываемое содержимое снимка: строки id, createdAt
+// (RFC 3339, UTC), root, leafCount, liabilities и reserves через "\n".
+func Payload(s models.ReserveSnapshot) []byte {
+	return []byte(strings.Join([]string{
+		s.ID,
+		s.CreatedAt.UTC().Format(time.RFC3339),
+		s.Root,
+		strconv.Itoa(s.LeafCount),
+		string(s.Liabilities),
+		string(s.Reserves),
+	}, "\n"))
+}
+
+// Sign подписывает снимок отчёта ключом key.
+func (r *Report) Sign(key ed25519.PrivateKey) {
+	r.Snapshot.PublicKey = hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	r.Snapshot.Signature = hex.EncodeToString(ed25519.Sign(key, Payload(r.Snapshot)))
+}
+
+// VerifySignature проверяет подпись снимка его публичным ключом.
+func VerifySignature(s models.ReserveSnapshot) error {
+	pub, err := hex.DecodeString(s.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return errors.New("invalid public key")
+	}
+	sig, err := hex.DecodeString(s.Signature)
+	if err != nil || !ed25519.Verify(pub, Payload(s), sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Save сохраняет снимок вместе с листьями.
+func (r *Report) Save(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&r.Snapshot).Error; err != nil {
+			return err
+		}
+		if len(r.Leaves) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&r.Leaves, 500).Error
+	})
+}
+
+// Proof — доказательство включения балансов клиента в последний снимок.
+type Proof struct {
+	Snapshot models.ReserveSnapshot `json:"snapshot"`
+	Index    int                    `json:"index"`
+	Salt     string                 `json:"salt"`
+	Balances Sums                   `json:"balances" swaggertype:"object"`
+	Path     []Step                 `json:"path"`
+}
+
+// ProofFor строит доказательство для клиента по последнему снимку.
+func ProofFor(db *gorm.DB, clientID string) (*Proof, error) {
+	var snap models.ReserveSnapshot
+	if err := db.Order("created_at DESC").First(&snap).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoSnapshot
+		}
+		return nil, err
+	}
+	var own models.ReserveLeaf
+	if err := db.Where("snapshot_id = ? AND client_id = ?", snap.ID, clientID).First(&own).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotIncluded
+		}
+		return nil, err
+	}
+	var leaves []models.ReserveLeaf
+	if err := db.Select("leaf_index", "hash", "balances").
+		Where("snapshot_id = ?", snap.ID).Order("leaf_index").Find(&leaves).Error; err != nil {
+		return nil, err
+	}
+	nodes := make([]node, len(leaves))
+	for i, l := range leaves {
+		raw, err := hex.DecodeString(l.Hash)
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("leaf %d: invalid hash", l.Index)
+		}
+		copy(nodes[i].hash[:], raw)
+		if err := json.Unmarshal(l.Balances, &nodes[i].sums); err != nil {
+			return nil, fmt.Errorf("leaf %d: %w", l.Index, err)
+		}
+	}
+	tree := levels(nodes)
+	if root := tree[len(tree)-1][0]; hex.EncodeToString(root.hash[:]) != snap.Root {
+		return nil, errRootMismatch
+	}
+	proof := &Proof{Snapshot: snap, Index: own.Index, Salt: own.Salt, Path: path(tree, own.Index)}
+	if err := json.Unmarshal(own.Balances, &proof.Balances); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// VerifyProof проверяет, что балансы клиента clientID входят в снимок:
+// путь от листа даёт корень снимка, а суммы корня равны опубликованным
+// обязательствам.
+func VerifyProof(p Proof, clientID string) error {
+	leaf := node{hash: leafHash(clientID, p.Salt, p.Balances), sums: p.Balances}
+	if leaf.sums.negative() {
+		return errors.New("negative balances")
+	}
+	root, err := fold(leaf, p.Path)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(root.hash[:]) != p.Snapshot.Root {
+		return errRootMismatch
+	}
+	var liabilities Sums
+	if err := json.Unmarshal(p.Snapshot.Liabilities, &liabilities); err != nil {
+		return err
+	}
+	if !root.sums.Equal(liabilities) {
+		return errors.New("liabilities mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package reserves
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ptop/config"
+	"ptop/internal/chains"
+	"ptop/internal/models"
+)
+
+type fakeHoldings map[string]decimal.Decimal
+
+func (h fakeHoldings) Balance(ctx context.Context, network models.AssetNetwork, addresses []string) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for _, a := range addresses {
+		total = total.Add(h[a])
+	}
+	return total, nil
+}
+
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.Balance{}, &models.ReserveSnapshot{}, &models.ReserveLeaf{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestSnapshotProofs(t *testing.T) {
+	db := setupDB(t)
+	btc := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, Chain: "btc"}
+	usd := models.Asset{Name: "USD", Type: models.AssetTypeFiat}
+	db.Create(&btc)
+	db.Create(&usd)
+	db.Create(&models.AssetNetwork{AssetID: btc.ID, Chain: "btc", Decimals: 8})
+	db.Create(&models.Wallet{ClientID: "c0", AssetID: btc.ID, Network: "btc", Value: "bc1qdeposit"})
+
+	// пять клиентов дают дерево с непарным листом на нескольких уровнях
+	for i := 0; i < 5; i++ {
+		client := fmt.Sprintf("c%d", i)
+		db.Create(&models.Balance{ClientID: client, AssetID: btc.ID, Amount: decimal.RequireFromString("0.5"), AmountEscrow: decimal.NewFromInt(int64(i))})
+		db.Create(&models.Balance{ClientID: client, AssetID: usd.ID, Amount: decimal.NewFromInt(100), AmountEscrow: decimal.Zero})
+	}
+	// нулевой баланс не попадает в дерево
+	db.Create(&models.Balance{ClientID: "empty", AssetID: btc.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero})
+
+	holdings := fakeHoldings{"bc1qdeposit": decimal.NewFromInt(3), "bc1qhot": decimal.NewFromInt(10)}
+	report, err := Generate(context.Background(), db, func(string) (chains.Holdings, error) { return holdings, nil }, map[string]string{"btc": "bc1qhot"})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if report.Snapshot.LeafCount != 5 {
+		t.Fatalf("expected 5 leaves, got %d", report.Snapshot.LeafCount)
+	}
+	var liabilities Sums
+	json.Unmarshal(report.Snapshot.Liabilities, &liabilities)
+	if !liabilities.Equal(Sums{"BTC": decimal.RequireFromString("12.5"), "USD": decimal.NewFromInt(500)}) {
+		t.Fatalf("liabilities %v", liabilities)
+	}
+	if len(report.Assets) != 2 {
+		t.Fatalf("assets %+v", report.Assets)
+	}
+	if a := report.Assets[0]; a.Asset != "BTC" || !a.Reserves.Equal(decimal.NewFromInt(13)) || !a.Verified || !a.Solvent || a.Networks[0].Addresses != 2 {
+		t.Fatalf("btc reserve %+v", a)
+	}
+	if a := report.Assets[1]; a.Asset != "USD" || a.Verified || a.Solvent {
+		t.Fatalf("fiat must be unverified: %+v", a)
+	}
+
+	key, err := ParseSigningKey("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	report.Sign(key)
+	if err := report.Save(db); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		client := fmt.Sprintf("c%d", i)
+		proof, err := ProofFor(db, client)
+		if err != nil {
+			t.Fatalf("proof %s: %v", client, err)
+		}
+		if err := VerifyProof(*proof, client); err != nil {
+			t.Fatalf("verify %s: %v", client, err)
+		}
+		if err := VerifySignature(proof.Snapshot); err != nil {
+			t.Fatalf("signature %s: %v", client, err)
+		}
+		if i == 0 {
+			// чужой лист и заниженный баланс не сходятся с корнем
+			if err := VerifyProof(*proof, "c1"); err == nil {
+				t.Fatalf("proof accepted for another client")
+			}
+			proof.Balances["BTC"] = proof.Balances["BTC"].Sub(decimal.NewFromInt(1))
+			if err := VerifyProof(*proof, client); err == nil {
+				t.Fatalf("tampered balance accepted")
+			}
+			proof.Snapshot.Root = "00"
+			if err := VerifySignature(proof.Snapshot); err == nil {
+				t.Fatalf("tampered snapshot signature accepted")
+			}
+		}
+	}
+	if _, err := ProofFor(db, "empty"); !errors.Is(err, ErrNotIncluded) {
+		t.Fatalf("expected ErrNotIncluded, got %v", err)
+	}
+}
+
+func TestGenerateInsolventAndUnavailable(t *testing.T) {
+	db := setupDB(t)
+	eth := models.Asset{Name: "ETH", Type: models.AssetTypeCrypto, Chain: "eth"}
+	db.Create(&eth)
+	db.Create(&models.AssetNetwork{AssetID: eth.ID, Chain: "eth", Decimals: 18})
+	db.Create(&models.AssetNetwork{AssetID: eth.ID, Chain: "base", Decimals: 18})
+	db.Create(&models.Balance{ClientID: "c1", AssetID: eth.ID, Amount: decimal.NewFromInt(5), AmountEscrow: decimal.NewFromInt(1)})
+
+	holdings := func(chain string) (chains.Holdings, error) {
+		if chain == "base" {
+			// сеть без настроенного узла
+			return chains.NewHoldings(context.Background(), chain, &config.Config{})
+		}
+		return fakeHoldings{"0xhot": decimal.NewFromInt(4)}, nil
+	}
+	report, err := Generate(context.Background(), db, holdings, map[string]string{"eth": "0xhot"})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	a := report.Assets[0]
+	if a.Verified || a.Solvent || !a.Reserves.Equal(decimal.NewFromInt(4)) || a.Networks[0].Error == "" {
+		t.Fatalf("unexpected reserve %+v", a)
+	}
+
+	db.Create(&models.Balance{ClientID: "c2", AssetID: eth.ID, Amount: decimal.NewFromInt(-1), AmountEscrow: decimal.Zero})
+	if _, err := Generate(context.Background(), db, holdings, nil); err == nil {
+		t.Fatalf("negative balance accepted")
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"ptop/config"
+)
+
+// AuthLimitOptions — пороги защиты от перебора.
+type AuthLimitOptions struct {
+	// MaxAttempts — неудачных попыток в окне Window до блокировки ключа.
+	MaxAttempts int
+	Window      time.Duration
+	// LockBase — первая блокировка; каждая следующая в пределах StrikeTTL
+	// вдвое дольше, но не дольше LockMax.
+	LockBase  time.Duration
+	LockMax   time.Duration
+	StrikeTTL time.Duration
+}
+
+// AuthLock — действующая блокировка ключа.
+type AuthLock struct {
+	Key        string        `json:"key"`
+	RetryAfter time.Duration `json:"retryAfter"`
+	Strikes    int64         `json:"strikes"`
+}
+
+// AuthLimiter считает неудачные попытки аутентификации в Redis и
+// блокирует ключи (имя пользователя, IP, клиент) с растущей длительностью.
+type AuthLimiter struct {
+	client *redis.Client
+	opts   AuthLimitOptions
+}
+
+const (
+	authFailPrefix   = "auth:fail:"
+	authLockPrefix   = "auth:lock:"
+	authStrikePrefix = "auth:strikes:"
+)
+
+func NewAuthLimiter(client *redis.Client, opts AuthLimitOptions) *AuthLimiter {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Window <= 0 {
+		opts.Window = 15 * time.Minute
+	}
+	if opts.LockBase <= 0 {
+		opts.LockBase = time.Minute
+	}
+	if opts.LockMax < opts.LockBase {
+		opts.LockMax = 24 * time.Hour
+	}
+	if opts.StrikeTTL <= 0 {
+		opts.StrikeTTL = 24 * time.Hour
+	}
+	return &AuthLimiter{client: client, opts: opts}
+}
+
+// Locked возвращает наибольшее оставшееся время блокировки среди ключей;
+// ноль — ни один ключ не заблокирован.
+func (l *AuthLimiter) Locked(ctx context.Context, keys ...string) (time.Duration, error) {
+	var retry time.Duration
+	for _, key := range keys {
+		ttl, err := l.client.PTTL(ctx, authLockPrefix+key).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ttl > retry {
+			retry = ttl
+		}
+	}
+	return retry, nil
+}
+
+// Fail учитывает неудачную попытку по каждому ключу. Ключ, набравший max
+// попыток (MaxAttempts, если max <= 0), блокируется; возвращается
+// наибольшая из новых блокировок.
+func (l *AuthLimiter) Fail(ctx context.Context, max int, keys ...string) (time.Duration, error) {
+	if max <= 0 {
+		max = l.opts.MaxAttempts
+	}
+	var retry time.Duration
+	for _, key := range keys {
+		n, err := l.client.Incr(ctx, authFailPrefix+key).Result()
+		if err != nil {
+			return 0, err
+		}
+		if n == 1 {
+			if err := l.client.PExpire(ctx, authFailPrefix+key, l.opts.Window).Err(); err != nil {
+				return 0, err
+			}
+		}
+		if n < int64(max) {
+			continue
+		}
+		strikes, err := l.client.Incr(ctx, authStrikePrefix+key).Result()
+		if err != nil {
+			return 0, err
+		}
+		lock := l.opts.LockBase
+		for i := int64(1); i < strikes && lock < l.opts.LockMax; i++ {
+			lock *= 2
+		}
+		if lock > l.opts.LockMax {
+			lock = l.opts.LockMax
+		}
+		pipe := l.client.TxPipeline()
+		pipe.PExpire(ctx, authStrikePrefix+key, l.opts.StrikeTTL)
+		pipe.Set(ctx, authLockPrefix+key, strikes, lock)
+		pipe.Del(ctx, authFailPrefix+key)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, err
+		}
+		if lock > retry {
+			retry = lock
+		}
+	}
+	return retry, nil
+}
+
+// Throttle учитывает неудачную попытку по ключам мягкого ограничения. После
+// MaxAttempts неудач за Window каждая следующая закрывает ключ на LockBase
+// без удвоения: попытки замедляются до одной за LockBase, но владелец
+// учётной записи не оказывается заперт чужими неудачами надолго.
+func (l *AuthLimiter) Throttle(ctx context.Context, keys ...string) (time.Duration, error) {
+	var retry time.Duration
+	for _, key := range keys {
+		n, err := l.client.Incr(ctx, authFailPrefix+key).Result()
+		if err != nil {
+			return 0, err
+		}
+		if n == 1 {
+			if err := l.client.PExpire(ctx, authFailPrefix+key, l.opts.Window).Err(); err != nil {
+				return 0, err
+			}
+		}
+		if n < int64(l.opts.MaxAttempts) {
+			continue
+		}
+		if err := l.client.Set(ctx, authLockPrefix+key, 0, l.opts.LockBase).Err(); err != nil {
+			return 0, err
+		}
+		if l.opts.LockBase > retry {
+			retry = l.opts.LockBase
+		}
+	}
+	return retry, nil
+}
+
+// Reset сбрасывает счётчики неудачных попыток и историю блокировок ключей
+// после успешного входа.
+func (l *AuthLimiter) Reset(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	del := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		del = append(del, authFailPrefix+key, authStrikePrefix+key)
+	}
+	return l.client.Del(ctx, del...).Err()
+}
+
+// Locks возвращает действующие блокировки, самые длинные первыми.
+func (l *AuthLimiter) Locks(ctx context.Context) ([]AuthLock, error) {
+	var locks []AuthLock
+	iter := l.client.Scan(ctx, 0, authLockPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		redisKey := iter.Val()
+		ttl, err := l.client.PTTL(ctx, redisKey).Result()
+		if err != nil {
+			return nil, err
+		}
+		strikes, err := l.client.Get(ctx, redisKey).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if ttl <= 0 {
+			continue
+		}
+		locks = append(locks, AuthLock{Key: strings.TrimPrefix(redisKey, authLockPrefix), RetryAfter: ttl, Strikes: strikes})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].RetryAfter > locks[j].RetryAfter })
+	return locks, nil
+}
+
+// Unlock снимает блокировку ключа и сбрасывает его счётчики.
+func (l *AuthLimiter) Unlock(ctx context.Context, key string) error {
+	return l.client.Del(ctx, authLockPrefix+key, authFailPrefix+key, authStrikePrefix+key).Err()
+}
+
+// BuildAuthLimiter создаёт ограничитель по настройкам AUTH_*.
+func BuildAuthLimiter(client *redis.Client, cfg *config.Config) *AuthLimiter {
+	return NewAuthLimiter(client, AuthLimitOptions{
+		MaxAttempts: cfg.AuthMaxFailures,
+		Window:      cfg.AuthFailureWindow,
+		LockBase:    cfg.AuthLockBase,
+		LockMax:     cfg.AuthLockMax,
+	})
+}
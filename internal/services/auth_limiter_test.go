@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestAuthLimiter(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	l := NewAuthLimiter(rdb, AuthLimitOptions{MaxAttempts: 3, Window: time.Minute, LockBase: time.Minute, LockMax: 3 * time.Minute})
+	ctx := context.Background()
+
+	fail := func(key string) time.Duration {
+		t.Helper()
+		retry, err := l.Fail(ctx, 0, key)
+		if err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+		return retry
+	}
+	locked := func(key string) time.Duration {
+		t.Helper()
+		retry, err := l.Locked(ctx, key)
+		if err != nil {
+			t.Fatalf("locked: %v", err)
+		}
+		return retry
+	}
+
+	// Третья неудача подряд блокирует ключ, каждая следующая блокировка вдвое
+	// дольше, но не дольше LockMax
+	for strike, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		if fail("login:user:bob") != 0 || fail("login:user:bob") != 0 {
+			t.Fatalf("strike %d: locked too early", strike)
+		}
+		if got := fail("login:user:bob"); got != want {
+			t.Fatalf("strike %d: lock %v, want %v", strike, got, want)
+		}
+		if locked("login:user:bob") <= 0 || locked("login:user:alice") != 0 {
+			t.Fatalf("strike %d: unexpected lock state", strike)
+		}
+		s.FastForward(want)
+		if locked("login:user:bob") != 0 {
+			t.Fatalf("strike %d: lock not expired", strike)
+		}
+	}
+
+	// Неудачи за пределами окна не накапливаются
+	fail("login:user:carol")
+	fail("login:user:carol")
+	s.FastForward(2 * time.Minute)
+	if fail("login:user:carol") != 0 {
+		t.Fatalf("failures outside window must not lock")
+	}
+
+	// Успешный вход сбрасывает счётчик и историю блокировок
+	fail("login:user:dave")
+	fail("login:user:dave")
+	if err := l.Reset(ctx, "login:user:dave"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if fail("login:user:dave") != 0 {
+		t.Fatalf("reset must clear failures")
+	}
+
+	if retry, _ := l.Fail(ctx, 1, "token:ip:10.0.0.1"); retry != time.Minute {
+		t.Fatalf("custom threshold lock %v", retry)
+	}
+	locks, err := l.Locks(ctx)
+	if err != nil {
+		t.Fatalf("locks: %v", err)
+	}
+	if len(locks) != 1 || locks[0].Key != "token:ip:10.0.0.1" || locks[0].Strikes != 1 {
+		t.Fatalf("unexpected locks %+v", locks)
+	}
+	if err := l.Unlock(ctx, "token:ip:10.0.0.1"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if locked("token:ip:10.0.0.1") != 0 {
+		t.Fatalf("unlock must remove lock")
+	}
+
+	// Мягкое ограничение закрывает ключ на LockBase после каждой неудачи
+	// сверх порога и не удваивает блокировку
+	for i := 0; i < 2; i++ {
+		if retry, _ := l.Throttle(ctx, "login:user:erin"); retry != 0 {
+			t.Fatalf("throttled too early")
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if retry, _ := l.Throttle(ctx, "login:user:erin"); retry != time.Minute {
+			t.Fatalf("throttle %d: %v", i, retry)
+		}
+		if locked("login:user:erin") != time.Minute {
+			t.Fatalf("throttle %d: unexpected lock", i)
+		}
+	}
+}
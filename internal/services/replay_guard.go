@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const replayPrefix = "apikey:replay:"
+
+// ReplayGuard запоминает подписи запросов в Redis, чтобы один подписанный
+// запрос нельзя было отправить повторно.
+type ReplayGuard struct {
+	client *redis.Client
+}
+
+func NewReplayGuard(client *redis.Client) *ReplayGuard {
+	return &ReplayGuard{client: client}
+}
+
+// Claim отмечает id использованным на ttl. Возвращает false, если id уже
+// встречался.
+func (g *ReplayGuard) Claim(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	return g.client.SetNX(ctx, replayPrefix+id, 1, ttl).Result()
+}
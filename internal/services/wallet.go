@@ -1,168 +1,140 @@
 package services
 
 import (
-	"bytes"
-	"crypto/ecdsa"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"os"
 	"strings"
 
-	btcec "github.com/btcsuite/btcd/btcec"
-	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcutil/hdkeychain"
-	"github.com/ethereum/go-ethereum/crypto"
-	solana "github.com/gagliardetto/solana-go"
-	bip39 "github.com/tyler-smith/go-bip39"
-	ed25519hd "github.com/wealdtech/go-ed25519hd"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"ptop/internal/chains"
 	"ptop/internal/models"
 )
 
-func nextIndex(db *gorm.DB, assetID string) (uint32, error) {
+// ErrDepositsDisabled возвращается, если пополнения актива в сети отключены.
+var ErrDepositsDisabled = errors.New("deposits disabled")
+
+// ErrUnknownNetwork возвращается, если актив не поддерживает запрошенную сеть.
+var ErrUnknownNetwork = errors.New("unknown network")
+
+// lockCounter блокирует счётчик индексов деривации актива в группе сетей с
+// общей схемой адресов (SELECT ... FOR UPDATE) до конца транзакции tx.
+// Счётчик создаётся при первом обращении и начинается после наибольшего уже
+// выданного индекса.
+func lockCounter(tx *gorm.DB, assetID string, networks []string) (models.DerivationCounter, error) {
+	scope := chains.AddressScope(networks[0])
+	var counter models.DerivationCounter
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("asset_id = ? AND scope = ?", assetID, scope).First(&counter).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return counter, err
+	}
 	var max sql.NullInt64
-	if err := db.Model(&models.Wallet{}).Where("asset_id = ?", assetID).Select("MAX(derivation_index)").Scan(&max).Error; err != nil {
-		return 0, err
+	if err := tx.Model(&models.Wallet{}).Where("asset_id = ? AND network IN ?", assetID, networks).Select("MAX(derivation_index)").Scan(&max).Error; err != nil {
+		return counter, err
 	}
+	counter = models.DerivationCounter{AssetID: assetID, Scope: scope}
 	if max.Valid {
-		return uint32(max.Int64 + 1), nil
+		counter.NextIndex = uint32(max.Int64 + 1)
+	}
+	// Счётчик мог создать параллельный запрос — тогда берём его строку
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&counter).Error; err != nil {
+		return counter, err
 	}
-	return 0, nil
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("asset_id = ? AND scope = ?", assetID, scope).First(&counter).Error
+	return counter, err
+}
+
+// GetAddress выделяет индекс деривации и возвращает адрес депозита клиента
+// в сети network. Пустая сеть означает основную сеть актива (assets.chain).
+// Индексы деривации ведутся отдельно для каждой сети актива; EVM-сети делят
+// общие индексы, и клиент получает в них тот же адрес, что уже выдан ему в
+// другой EVM-сети.
+//
+// Вызывается в транзакции, которая создаёт кошелёк: счётчик индексов
+// заблокирован до её завершения, поэтому параллельный запрос того же
+// клиента в другой EVM-сети дождётся кошелька и получит тот же индекс, а
+// параллельные запросы разных клиентов — разные индексы. Выделенный индекс
+// не возвращается, даже если кошелёк затем не создан.
+func GetAddress(tx *gorm.DB, clientID, assetID, network string) (string, uint32, error) {
+	return address(tx, clientID, assetID, network, true)
+}
+
+// NewAddress выделяет новый, ещё не использованный индекс деривации и
+// возвращает адрес депозита клиента в сети network. Используется при ротации
+// адреса: в отличие от GetAddress, индекс из других EVM-сетей не переиспользуется.
+func NewAddress(tx *gorm.DB, clientID, assetID, network string) (string, uint32, error) {
+	return address(tx, clientID, assetID, network, false)
 }
 
-func GetAddress(db *gorm.DB, clientID, assetID string) (string, uint32, error) {
+func address(tx *gorm.DB, clientID, assetID, network string, reuse bool) (string, uint32, error) {
 	var asset models.Asset
-	if err := db.Where("id = ?", assetID).First(&asset).Error; err != nil {
+	if err := tx.Where("id = ?", assetID).First(&asset).Error; err != nil {
 		return "", 0, err
 	}
-
-	if strings.EqualFold(os.Getenv("DEBUG_FAKE_NETWORK"), "true") {
-		idx, err := nextIndex(db, assetID)
-		if err != nil {
-			return "", 0, err
-		}
-		return fmt.Sprintf("fake:%s:%s:%d", assetID, clientID, idx), idx, nil
+	if network == "" {
+		network = asset.Chain
 	}
 
-	switch {
-	case strings.HasPrefix(asset.Name, "BTC"):
-		idx, err := nextIndex(db, assetID)
-		if err != nil {
+	params := models.AssetNetwork{AssetID: asset.ID, Chain: network}
+	if err := tx.Where("asset_id = ? AND chain = ?", asset.ID, network).First(&params).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return "", 0, err
 		}
-		if asset.Xpub == "" {
-			return "", 0, errors.New("no xpub")
-		}
-		key, err := hdkeychain.NewKeyFromString(asset.Xpub)
-		if err != nil {
-			return "", 0, err
+		// Основная сеть актива работает и без записи в asset_networks
+		if network != asset.Chain {
+			return "", 0, ErrUnknownNetwork
 		}
-		child, err := key.Child(idx)
-		if err != nil {
-			return "", 0, err
-		}
-		addr, err := child.Address(&chaincfg.MainNetParams)
-		if err != nil {
-			return "", 0, err
-		}
-		return addr.EncodeAddress(), idx, nil
+	}
+	if params.ID != "" && !params.DepositEnabled {
+		return "", 0, ErrDepositsDisabled
+	}
 
-	case strings.HasPrefix(asset.Name, "ETH"):
-		idx, err := nextIndex(db, assetID)
-		if err != nil {
-			return "", 0, err
-		}
-		if asset.Xpub == "" {
-			return "", 0, errors.New("no xpub")
-		}
-		key, err := hdkeychain.NewKeyFromString(asset.Xpub)
-		if err != nil {
-			return "", 0, err
-		}
-		change, err := key.Child(0)
-		if err != nil {
-			return "", 0, err
-		}
-		child, err := change.Child(idx)
-		if err != nil {
-			return "", 0, err
-		}
-		pk, err := child.ECPubKey()
-		if err != nil {
+	group := chains.AddressGroup(network)
+	counter, err := lockCounter(tx, assetID, group)
+	if err != nil {
+		return "", 0, err
+	}
+	// Кошелёк в другой EVM-сети ищется под блокировкой счётчика
+	var existing []models.Wallet
+	if reuse {
+		if err := tx.Where("client_id = ? AND asset_id = ? AND network IN ? AND network <> ? AND is_enabled = ?", clientID, assetID, group, network, true).
+			Order("created_at").Limit(1).Find(&existing).Error; err != nil {
 			return "", 0, err
 		}
-		ecdsaPK := ecdsa.PublicKey{Curve: btcec.S256(), X: pk.X, Y: pk.Y}
-		addr := crypto.PubkeyToAddress(ecdsaPK)
-		return addr.Hex(), idx, nil
+	}
+	idx := counter.NextIndex
+	if len(existing) > 0 {
+		idx = existing[0].DerivationIndex
+	} else if err := tx.Model(&models.DerivationCounter{}).Where("asset_id = ? AND scope = ?", counter.AssetID, counter.Scope).
+		Update("next_index", idx+1).Error; err != nil {
+		return "", 0, err
+	}
 
-	case strings.HasPrefix(asset.Name, "USDC"):
-		idx, err := nextIndex(db, assetID)
-		if err != nil {
-			return "", 0, err
-		}
-		if asset.Xpub == "" {
-			return "", 0, errors.New("no seed")
-		}
-		seed := bip39.NewSeed(asset.Xpub, "")
-		path := fmt.Sprintf("m/44'/501'/0'/0'/%d", idx)
-		pub, _, err := ed25519hd.Keys(seed, path)
-		if err != nil {
-			return "", 0, err
-		}
-		pubKey := solana.PublicKeyFromBytes(pub)
-		mintAddr := os.Getenv("USDC_MINT_ADDRESS")
-		if mintAddr == "" {
-			return "", 0, errors.New("no usdc mint")
-		}
-		mint := solana.MustPublicKeyFromBase58(mintAddr)
-		ata, _, err := solana.FindAssociatedTokenAddress(pubKey, mint)
-		if err != nil {
-			return "", 0, err
-		}
-		return ata.String(), idx, nil
+	if strings.EqualFold(os.Getenv("DEBUG_FAKE_NETWORK"), "true") {
+		return fmt.Sprintf("fake:%s:%s:%d", assetID, clientID, idx), idx, nil
+	}
 
-	case strings.HasPrefix(asset.Name, "XMR"):
-		rpcURL := os.Getenv("MONERO_RPC_URL")
-		if rpcURL == "" {
-			rpcURL = "http://localhost:18083/json_rpc"
-		}
-		payload := map[string]any{
-			"jsonrpc": "2.0",
-			"id":      "0",
-			"method":  "create_address",
-			"params": map[string]any{
-				"account_index": 0,
-				"label":         clientID,
-			},
-		}
-		body, _ := json.Marshal(payload)
-		resp, err := http.Post(rpcURL, "application/json", bytes.NewReader(body))
-		if err != nil {
-			return "", 0, err
-		}
-		defer resp.Body.Close()
-		var res struct {
-			Result struct {
-				Address      string `json:"address"`
-				AddressIndex uint32 `json:"address_index"`
-			} `json:"result"`
-			Error *struct {
-				Message string `json:"message"`
-			} `json:"error"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-			return "", 0, err
-		}
-		if res.Error != nil {
-			return "", 0, errors.New(res.Error.Message)
-		}
-		return res.Result.Address, res.Result.AddressIndex, nil
+	chain, err := chains.Get(network)
+	if err != nil {
+		return "", 0, err
+	}
+	if params.Xpub != "" {
+		asset.Xpub = params.Xpub
+	}
+	return chain.DeriveAddress(asset, params, clientID, idx)
+}
 
-	default:
-		return "", 0, fmt.Errorf("unsupported asset")
+// LinkWallet сохраняет сведения, по которым наблюдатель сети сопоставляет
+// переводы с созданным кошельком: для Monero — номер аккаунта и индекс
+// подадреса. Адреса выдаются в аккаунте 0.
+func LinkWallet(db *gorm.DB, w models.Wallet) error {
+	if w.Network != chains.ChainXMR {
+		return nil
 	}
+	return db.Create(&models.XMRSubaddress{WalletID: w.ID, AddressIndex: w.DerivationIndex, Address: w.Value}).Error
 }
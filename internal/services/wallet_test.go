@@ -1,11 +1,21 @@
 package services
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 
 	"ptop/internal/models"
 )
@@ -16,14 +26,14 @@ func TestGetAddressFake(t *testing.T) {
 	if err != nil {
 		t.Fatalf("db open: %v", err)
 	}
-	if err := db.AutoMigrate(&models.Asset{}, &models.Wallet{}); err != nil {
+	if err := db.AutoMigrate(&models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.DerivationCounter{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	asset := models.Asset{Name: "BTC_fake", Type: models.AssetTypeCrypto, IsActive: true}
 	if err := db.Create(&asset).Error; err != nil {
 		t.Fatalf("asset: %v", err)
 	}
-	addr, idx, err := GetAddress(db, "clientA", asset.ID)
+	addr, idx, err := GetAddress(db, "clientA", asset.ID, "")
 	if err != nil {
 		t.Fatalf("get address: %v", err)
 	}
@@ -35,7 +45,7 @@ func TestGetAddressFake(t *testing.T) {
 	if err := db.Create(&w).Error; err != nil {
 		t.Fatalf("wallet: %v", err)
 	}
-	addr2, idx2, err := GetAddress(db, "clientA", asset.ID)
+	addr2, idx2, err := GetAddress(db, "clientA", asset.ID, "")
 	if err != nil {
 		t.Fatalf("second address: %v", err)
 	}
@@ -44,3 +54,242 @@ func TestGetAddressFake(t *testing.T) {
 		t.Fatalf("expected %s idx 1, got %s idx %d", exp2, addr2, idx2)
 	}
 }
+
+func TestGetAddressRequiresChain(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:test_no_chain?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.DerivationCounter{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	asset := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, IsActive: true}
+	if err := db.Create(&asset).Error; err != nil {
+		t.Fatalf("asset: %v", err)
+	}
+	if _, _, err := GetAddress(db, "clientA", asset.ID, ""); err == nil {
+		t.Fatalf("expected error for asset without chain")
+	}
+}
+
+func TestGetAddressNetworks(t *testing.T) {
+	t.Setenv("DEBUG_FAKE_NETWORK", "true")
+	db, err := gorm.Open(sqlite.Open("file:test_networks?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.DerivationCounter{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	asset := models.Asset{Name: "USDT", Type: models.AssetTypeCrypto, Chain: "eth", IsActive: true}
+	if err := db.Create(&asset).Error; err != nil {
+		t.Fatalf("asset: %v", err)
+	}
+	sol := models.AssetNetwork{AssetID: asset.ID, Chain: "sol", Decimals: 6}
+	if err := db.Create(&sol).Error; err != nil {
+		t.Fatalf("network: %v", err)
+	}
+	w := models.Wallet{ClientID: "clientA", AssetID: asset.ID, Network: "eth", Value: "a", DerivationIndex: 0, IsEnabled: true}
+	if err := db.Create(&w).Error; err != nil {
+		t.Fatalf("wallet: %v", err)
+	}
+	if _, idx, err := GetAddress(db, "clientA", asset.ID, ""); err != nil || idx != 1 {
+		t.Fatalf("eth index %d: %v", idx, err)
+	}
+	if _, idx, err := GetAddress(db, "clientA", asset.ID, "sol"); err != nil || idx != 0 {
+		t.Fatalf("sol index %d: %v", idx, err)
+	}
+	if _, _, err := GetAddress(db, "clientA", asset.ID, "xmr"); !errors.Is(err, ErrUnknownNetwork) {
+		t.Fatalf("expected unknown network, got %v", err)
+	}
+	db.Model(&sol).Update("deposit_enabled", false)
+	if _, _, err := GetAddress(db, "clientA", asset.ID, "sol"); !errors.Is(err, ErrDepositsDisabled) {
+		t.Fatalf("expected deposits disabled, got %v", err)
+	}
+}
+
+func TestGetAddressSharesEVMIndex(t *testing.T) {
+	t.Setenv("DEBUG_FAKE_NETWORK", "true")
+	db, err := gorm.Open(sqlite.Open("file:test_evm_index?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.DerivationCounter{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	asset := models.Asset{Name: "USDT", Type: models.AssetTypeCrypto, Chain: "eth", IsActive: true}
+	db.Create(&asset)
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: "arbitrum", Decimals: 6, DepositEnabled: true})
+	db.Create(&models.Wallet{ClientID: "clientA", AssetID: asset.ID, Network: "eth", Value: "a", DerivationIndex: 0, IsEnabled: true})
+	db.Create(&models.Wallet{ClientID: "clientB", AssetID: asset.ID, Network: "eth", Value: "b", DerivationIndex: 1, IsEnabled: true})
+
+	// клиент с адресом в Ethereum получает тот же индекс в Arbitrum
+	if _, idx, err := GetAddress(db, "clientB", asset.ID, "arbitrum"); err != nil || idx != 1 {
+		t.Fatalf("arbitrum index %d: %v", idx, err)
+	}
+	// новый клиент получает индекс, не занятый ни в одной EVM-сети
+	if _, idx, err := GetAddress(db, "clientC", asset.ID, "arbitrum"); err != nil || idx != 2 {
+		t.Fatalf("new client index %d: %v", idx, err)
+	}
+}
+
+// concurrentDB открывает базу для проверки параллельной выдачи адресов. С
+// TEST_POSTGRES_DSN тест идёт на Postgres и проверяет блокировку строки
+// счётчика (SELECT ... FOR UPDATE). Без неё используется файловая sqlite с
+// _txlock=immediate: транзакции выполняются по очереди, поэтому проверяется
+// только то, что индекс выделяется и кошелёк создаётся в одной транзакции.
+func concurrentDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	var dialector gorm.Dialector
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		dialector = postgres.Open(dsn)
+	} else {
+		dialector = sqlite.Open(filepath.Join(t.TempDir(), "wallets.db") + "?_txlock=immediate&_busy_timeout=10000")
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.DerivationCounter{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// createAsset создаёт актив с уникальным именем и удаляет его кошельки после
+// теста, чтобы тесты не мешали друг другу в общей базе Postgres.
+func createAsset(t *testing.T, db *gorm.DB, asset models.Asset) models.Asset {
+	t.Helper()
+	asset.Name += "_" + t.Name()
+	if err := db.Create(&asset).Error; err != nil {
+		t.Fatalf("asset: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("asset_id = ?", asset.ID).Delete(&models.Wallet{})
+		db.Where("asset_id = ?", asset.ID).Delete(&models.DerivationCounter{})
+		db.Where("asset_id = ?", asset.ID).Delete(&models.AssetNetwork{})
+		db.Delete(&asset)
+	})
+	return asset
+}
+
+// createWallet выделяет адрес и создаёт кошелёк в одной транзакции, как
+// обработчик POST /client/wallets.
+func createWallet(db *gorm.DB, clientID, assetID, network string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		addr, idx, err := GetAddress(tx, clientID, assetID, network)
+		if err != nil {
+			return err
+		}
+		if network == "" {
+			network = "btc"
+		}
+		return tx.Create(&models.Wallet{ClientID: clientID, AssetID: assetID, Network: network, Value: addr, DerivationIndex: idx, IsEnabled: true}).Error
+	})
+}
+
+func TestGetAddressConcurrent(t *testing.T) {
+	db := concurrentDB(t)
+	master, err := hdkeychain.NewMaster(bytes.Repeat([]byte{0x05}, 32), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("master: %v", err)
+	}
+	xpub, err := master.Neuter()
+	if err != nil {
+		t.Fatalf("neuter: %v", err)
+	}
+	asset := createAsset(t, db, models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, Chain: "btc", Xpub: xpub.String(), IsActive: true})
+
+	const n = 40
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- createWallet(db, fmt.Sprintf("client%d", i), asset.ID, "")
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("create wallet: %v", err)
+		}
+	}
+
+	var wallets []models.Wallet
+	db.Where("asset_id = ?", asset.ID).Find(&wallets)
+	if len(wallets) != n {
+		t.Fatalf("expected %d wallets, got %d", n, len(wallets))
+	}
+	addrs := map[string]bool{}
+	indexes := map[uint32]bool{}
+	for _, w := range wallets {
+		if addrs[w.Value] || indexes[w.DerivationIndex] {
+			t.Fatalf("address %s index %d reused", w.Value, w.DerivationIndex)
+		}
+		addrs[w.Value] = true
+		indexes[w.DerivationIndex] = true
+	}
+	var counter models.DerivationCounter
+	db.Where("asset_id = ? AND scope = ?", asset.ID, "btc").First(&counter)
+	if counter.NextIndex != n {
+		t.Fatalf("expected next index %d, got %d", n, counter.NextIndex)
+	}
+
+	// повторно выданный индекс отклоняется ограничением уникальности
+	dup := models.Wallet{ClientID: "clientX", AssetID: asset.ID, Network: "btc", Value: "other", DerivationIndex: 0, IsEnabled: true}
+	if err := db.Create(&dup).Error; err == nil {
+		t.Fatalf("expected unique index violation")
+	}
+}
+
+// Параллельные запросы клиента в разных EVM-сетях получают один индекс.
+func TestGetAddressConcurrentEVM(t *testing.T) {
+	t.Setenv("DEBUG_FAKE_NETWORK", "true")
+	db := concurrentDB(t)
+	asset := createAsset(t, db, models.Asset{Name: "USDT", Type: models.AssetTypeCrypto, Chain: "eth", IsActive: true})
+	for _, chain := range []string{"arbitrum", "base"} {
+		db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: chain, Decimals: 6, DepositEnabled: true})
+	}
+
+	const clients = 10
+	networks := []string{"eth", "arbitrum", "base"}
+	var wg sync.WaitGroup
+	errs := make(chan error, clients*len(networks))
+	for i := 0; i < clients; i++ {
+		for _, network := range networks {
+			wg.Add(1)
+			go func(clientID, network string) {
+				defer wg.Done()
+				errs <- createWallet(db, clientID, asset.ID, network)
+			}(fmt.Sprintf("client%d", i), network)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("create wallet: %v", err)
+		}
+	}
+
+	var wallets []models.Wallet
+	db.Where("asset_id = ?", asset.ID).Find(&wallets)
+	byClient := map[string]uint32{}
+	owner := map[uint32]string{}
+	for _, w := range wallets {
+		if idx, ok := byClient[w.ClientID]; ok && idx != w.DerivationIndex {
+			t.Fatalf("client %s got indexes %d and %d", w.ClientID, idx, w.DerivationIndex)
+		}
+		if c, ok := owner[w.DerivationIndex]; ok && c != w.ClientID {
+			t.Fatalf("index %d shared by %s and %s", w.DerivationIndex, c, w.ClientID)
+		}
+		byClient[w.ClientID] = w.DerivationIndex
+		owner[w.DerivationIndex] = w.ClientID
+	}
+	if len(wallets) != clients*len(networks) || len(byClient) != clients {
+		t.Fatalf("unexpected wallets %d for %d clients", len(wallets), len(byClient))
+	}
+}
@@ -8,25 +8,50 @@ import (
 	"log"
 	"math/big"
 	"strings"
+	"sync"
+	"time"
 
 	solana "github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
 	ws "github.com/gagliardetto/solana-go/rpc/ws"
 	"github.com/shopspring/decimal"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
 
+	"ptop/internal/deposits"
 	"ptop/internal/models"
 )
 
-// Watcher отслеживает переводы USDC в сети Solana и сохраняет депозиты.
+// chainName — значение assets.chain для активов Solana.
+const chainName = "sol"
+
+// tokenInfo содержит информацию о токене SPL или нативном SOL.
+type tokenInfo struct {
+	assetID  string
+	decimals int32
+}
+
+// Watcher отслеживает переводы нативного SOL на адреса клиентов и токенов
+// SPL на их ассоциированные токен-аккаунты (ATA) и сохраняет депозиты.
 type Watcher struct {
-	wsClient  *ws.Client
+	wsURL     string
 	rpcClient *rpc.Client
 	db        *gorm.DB
-	mint      solana.PublicKey
+	// tokens содержит токены SPL по адресу mint, native — актив нативного
+	// SOL (запись asset_networks без контракта).
+	tokens    map[string]*tokenInfo
+	native    *tokenInfo
 	debug     bool
 	debugCh   chan debugDeposit
+	debugOnce sync.Once
+	// refreshInterval — период проверки новых кошельков для подписки.
+	refreshInterval time.Duration
+	// cursors хранит последнюю обработанную подпись каждого аккаунта в
+	// рамках подключения.
+	cursors map[solana.PublicKey]solana.Signature
+
+	mu      sync.Mutex
+	slot    uint64
+	blockAt time.Time
 }
 
 type debugDeposit struct {
@@ -34,20 +59,10 @@ type debugDeposit struct {
 	amount   decimal.Decimal
 }
 
-// New создаёт нового наблюдателя.
-func New(db *gorm.DB, rpcURL, mintAddress string, debug bool) (*Watcher, error) {
-	w := &Watcher{db: db, debug: debug}
-	if mintAddress == "" {
-		if !debug {
-			return nil, fmt.Errorf("mint address required")
-		}
-	} else {
-		mint, err := solana.PublicKeyFromBase58(mintAddress)
-		if err != nil {
-			return nil, fmt.Errorf("mint address: %w", err)
-		}
-		w.mint = mint
-	}
+// New создаёт нового наблюдателя. Токены берутся из настроек сетей активов
+// при каждом запуске Run.
+func New(db *gorm.DB, rpcURL string, debug bool) (*Watcher, error) {
+	w := &Watcher{db: db, debug: debug, refreshInterval: 30 * time.Second}
 	if debug {
 		w.debugCh = make(chan debugDeposit)
 		return w, nil
@@ -55,12 +70,7 @@ func New(db *gorm.DB, rpcURL, mintAddress string, debug bool) (*Watcher, error)
 	if rpcURL == "" {
 		return nil, fmt.Errorf("solana rpc url required")
 	}
-	ctx := context.Background()
-	wsClient, err := ws.Connect(ctx, rpcURL)
-	if err != nil {
-		return nil, err
-	}
-	w.wsClient = wsClient
+	w.wsURL = rpcURL
 	httpURL := rpcURL
 	if strings.HasPrefix(httpURL, "wss://") {
 		httpURL = "https://" + strings.TrimPrefix(httpURL, "wss://")
@@ -71,42 +81,279 @@ func New(db *gorm.DB, rpcURL, mintAddress string, debug bool) (*Watcher, error)
 	return w, nil
 }
 
-// Start запускает подписку на логи программы SPL Token.
+// Start запускает наблюдатель в фоне без перезапусков при ошибках.
 func (w *Watcher) Start() error {
 	if w.debug {
-		go w.debugLoop()
+		w.startDebug()
 		return nil
 	}
-	sub, err := w.wsClient.LogsSubscribe(ws.LogsSubscribeFilterAll, rpc.CommitmentFinalized)
+	go func() {
+		if err := w.Run(context.Background()); err != nil {
+			log.Printf("sol watcher: %v", err)
+		}
+	}()
+	return nil
+}
+
+// accountEvent — уведомление об изменении токен-аккаунта.
+type accountEvent struct {
+	address solana.PublicKey
+	slot    uint64
+}
+
+// Run подключается к websocket-узлу, подписывается на изменения ATA
+// кошельков клиентов и обрабатывает новые переводы, пока подписка не
+// завершится ошибкой или не будет отменён ctx. После подключения пропущенные
+// переводы догружаются через getSignaturesForAddress; новые кошельки
+// подписываются раз в refreshInterval.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.debug {
+		w.startDebug()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tokens, native, err := loadTokens(w.db)
+	if err != nil {
+		return fmt.Errorf("load tokens: %w", err)
+	}
+	if len(tokens) == 0 && native == nil {
+		return fmt.Errorf("no solana assets configured")
+	}
+	w.tokens, w.native = tokens, native
+	w.cursors = nil
+	wsClient, err := ws.Connect(ctx, w.wsURL)
 	if err != nil {
+		return fmt.Errorf("ws connect: %w", err)
+	}
+	defer wsClient.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := make(chan accountEvent)
+	errCh := make(chan error, 1)
+	subs := make(map[solana.PublicKey]*ws.AccountSubscription)
+	defer func() {
+		for _, sub := range subs {
+			sub.Unsubscribe()
+		}
+	}()
+	// subscribe подписывается на ещё не отслеживаемые аккаунты и догружает
+	// их историю.
+	subscribe := func() error {
+		accounts, err := w.loadAccounts()
+		if err != nil {
+			return fmt.Errorf("load accounts: %w", err)
+		}
+		for _, acc := range accounts {
+			if _, ok := subs[acc]; ok {
+				continue
+			}
+			sub, err := wsClient.AccountSubscribe(acc, rpc.CommitmentFinalized)
+			if err != nil {
+				return fmt.Errorf("account subscribe %s: %w", acc, err)
+			}
+			subs[acc] = sub
+			go forward(ctx, acc, sub, events, errCh)
+			if err := w.syncAccount(ctx, acc); err != nil {
+				return fmt.Errorf("reconcile %s: %w", acc, err)
+			}
+		}
+		return nil
+	}
+	if err := subscribe(); err != nil {
 		return err
 	}
-	go w.handleLogs(sub)
-	return nil
+	ticker := time.NewTicker(w.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errCh:
+			return err
+		case <-ticker.C:
+			if err := subscribe(); err != nil {
+				return err
+			}
+		case ev := <-events:
+			w.mu.Lock()
+			if ev.slot > w.slot {
+				w.slot = ev.slot
+				w.blockAt = time.Now()
+			}
+			w.mu.Unlock()
+			if err := w.syncAccount(ctx, ev.address); err != nil {
+				return fmt.Errorf("sync %s: %w", ev.address, err)
+			}
+		}
+	}
 }
 
-func (w *Watcher) handleLogs(sub *ws.LogSubscription) {
-	ctx := context.Background()
+// forward передаёт уведомления подписки аккаунта в events, а ошибку — в
+// errCh.
+func forward(ctx context.Context, acc solana.PublicKey, sub *ws.AccountSubscription, events chan<- accountEvent, errCh chan<- error) {
 	for {
 		res, err := sub.Recv(ctx)
 		if err != nil {
-			log.Printf("sol logs recv: %v", err)
+			select {
+			case errCh <- fmt.Errorf("account %s recv: %w", acc, err):
+			default:
+			}
 			return
 		}
-		if res.Value.Err != nil {
+		select {
+		case events <- accountEvent{address: acc, slot: res.Context.Slot}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// loadAccounts возвращает ATA кошельков клиентов в сети Solana.
+func (w *Watcher) loadAccounts() ([]solana.PublicKey, error) {
+	var values []string
+	if err := w.db.Model(&models.Wallet{}).Where("network = ?", chainName).Distinct().Pluck("value", &values).Error; err != nil {
+		return nil, err
+	}
+	accounts := make([]solana.PublicKey, 0, len(values))
+	for _, v := range values {
+		pk, err := solana.PublicKeyFromBase58(v)
+		if err != nil {
 			continue
 		}
-		w.processSignature(res.Value.Signature)
+		accounts = append(accounts, pk)
 	}
+	return accounts, nil
 }
 
-func (w *Watcher) processSignature(sig solana.Signature) {
-	tx, err := w.rpcClient.GetParsedTransaction(context.Background(), sig, nil)
-	if err != nil || tx == nil || tx.Transaction == nil {
-		return
+// signaturePageSize — максимальный размер страницы getSignaturesForAddress.
+const signaturePageSize = 1000
+
+// syncAccount обрабатывает транзакции аккаунта от старых к новым после
+// последней обработанной подписи, а при первом вызове — после последнего
+// сохранённого депозита на аккаунт.
+func (w *Watcher) syncAccount(ctx context.Context, acc solana.PublicKey) error {
+	if w.cursors == nil {
+		w.cursors = make(map[solana.PublicKey]solana.Signature)
+	}
+	until, ok := w.cursors[acc]
+	if !ok {
+		var err error
+		if until, err = w.lastSignature(acc); err != nil {
+			return err
+		}
+	}
+	var sigs []*rpc.TransactionSignature
+	limit := signaturePageSize
+	opts := &rpc.GetSignaturesForAddressOpts{Limit: &limit, Until: until, Commitment: rpc.CommitmentFinalized}
+	for {
+		page, err := w.rpcClient.GetSignaturesForAddressWithOpts(ctx, acc, opts)
+		if err != nil {
+			return err
+		}
+		sigs = append(sigs, page...)
+		if len(page) < limit {
+			break
+		}
+		opts.Before = page[len(page)-1].Signature
 	}
-	for _, inst := range tx.Transaction.Message.Instructions {
-		if inst.Program != "spl-token" || inst.Parsed == nil {
+	for i := len(sigs) - 1; i >= 0; i-- {
+		if sigs[i].Err != nil {
+			continue
+		}
+		w.mu.Lock()
+		if sigs[i].Slot > w.slot {
+			w.slot = sigs[i].Slot
+			w.blockAt = time.Now()
+		}
+		w.mu.Unlock()
+		if err := w.processSignature(sigs[i].Signature); err != nil {
+			return err
+		}
+		w.cursors[acc] = sigs[i].Signature
+	}
+	if _, ok := w.cursors[acc]; !ok {
+		w.cursors[acc] = until
+	}
+	return nil
+}
+
+// lastSignature возвращает подпись последнего сохранённого депозита на
+// аккаунт или нулевую подпись, если депозитов не было.
+func (w *Watcher) lastSignature(acc solana.PublicKey) (solana.Signature, error) {
+	var tx models.TransactionIn
+	err := w.db.Joins("JOIN wallets ON wallets.id = transaction_ins.wallet_id").
+		Where("wallets.value = ? AND transaction_ins.network = ? AND transaction_ins.data ->> 'signature' IS NOT NULL", acc.String(), chainName).
+		Order("transaction_ins.block_height DESC, transaction_ins.created_at DESC").First(&tx).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return solana.Signature{}, nil
+	}
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	var data struct {
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(tx.Data, &data); err != nil {
+		return solana.Signature{}, err
+	}
+	return solana.SignatureFromBase58(data.Signature)
+}
+
+// Progress возвращает последний обработанный слот и время его получения.
+// Подписки доставляют события финализированных слотов, поэтому высота
+// сети совпадает с обработанной.
+func (w *Watcher) Progress() (height, tip uint64, blockAt time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.slot, w.slot, w.blockAt
+}
+
+// loadTokens загружает mint-адреса токенов SPL и актив нативного SOL из
+// настроек сетей активов.
+func loadTokens(db *gorm.DB) (map[string]*tokenInfo, *tokenInfo, error) {
+	var networks []models.AssetNetwork
+	if err := db.Where("chain = ?", chainName).Find(&networks).Error; err != nil {
+		return nil, nil, err
+	}
+	tokens := make(map[string]*tokenInfo, len(networks))
+	var native *tokenInfo
+	for _, n := range networks {
+		if n.Contract == "" {
+			native = &tokenInfo{assetID: n.AssetID, decimals: deposits.Decimals(&n, 9)}
+			continue
+		}
+		if _, err := solana.PublicKeyFromBase58(n.Contract); err != nil {
+			log.Printf("некорректный mint %q актива %s", n.Contract, n.AssetID)
+			continue
+		}
+		tokens[n.Contract] = &tokenInfo{assetID: n.AssetID, decimals: n.Decimals}
+	}
+	return tokens, native, nil
+}
+
+// transfer — входящий перевод из инструкции транзакции. Для нативного SOL
+// mint пустой.
+type transfer struct {
+	index       int
+	mint        string
+	destination string
+	amount      *big.Int
+}
+
+// transfers извлекает переводы нативного SOL и токенов SPL из инструкций
+// транзакции, включая вложенные. Для transfer без mint он определяется по
+// балансам токен-аккаунтов после транзакции.
+func transfers(tx *rpc.GetParsedTransactionResult) []transfer {
+	instructions := tx.Transaction.Message.Instructions
+	if tx.Meta != nil {
+		for _, inner := range tx.Meta.InnerInstructions {
+			instructions = append(instructions, inner.Instructions...)
+		}
+	}
+	var out []transfer
+	for i, inst := range instructions {
+		if inst.Parsed == nil || (inst.Program != "system" && inst.Program != "spl-token") {
 			continue
 		}
 		var parsed rpc.InstructionInfo
@@ -117,14 +364,26 @@ func (w *Watcher) processSignature(sig solana.Signature) {
 		if err := json.Unmarshal(pData, &parsed); err != nil {
 			continue
 		}
-		mintStr, _ := parsed.Info["mint"].(string)
-		if mintStr != w.mint.String() {
-			continue
-		}
 		dest, _ := parsed.Info["destination"].(string)
 		if dest == "" {
 			continue
 		}
+		t := transfer{index: i, destination: dest}
+		if inst.Program == "system" {
+			if parsed.InstructionType != "transfer" && parsed.InstructionType != "transferWithSeed" {
+				continue
+			}
+			lamports, ok := parsed.Info["lamports"].(float64)
+			if !ok {
+				continue
+			}
+			t.amount = new(big.Int).SetUint64(uint64(lamports))
+			out = append(out, t)
+			continue
+		}
+		if parsed.InstructionType != "transfer" && parsed.InstructionType != "transferChecked" {
+			continue
+		}
 		var amountStr string
 		if v, ok := parsed.Info["amount"].(string); ok {
 			amountStr = v
@@ -133,48 +392,101 @@ func (w *Watcher) processSignature(sig solana.Signature) {
 				amountStr = am
 			}
 		}
-		if amountStr == "" {
+		amount, ok := new(big.Int).SetString(amountStr, 10)
+		if !ok {
+			continue
+		}
+		t.amount = amount
+		t.mint, _ = parsed.Info["mint"].(string)
+		if t.mint == "" {
+			t.mint = accountMint(tx, dest)
+		}
+		if t.mint != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// accountMint возвращает mint токен-аккаунта account по балансам после
+// транзакции.
+func accountMint(tx *rpc.GetParsedTransactionResult, account string) string {
+	if tx.Meta == nil {
+		return ""
+	}
+	for _, b := range tx.Meta.PostTokenBalances {
+		keys := tx.Transaction.Message.AccountKeys
+		if int(b.AccountIndex) < len(keys) && keys[b.AccountIndex].PublicKey.String() == account {
+			return b.Mint.String()
+		}
+	}
+	return ""
+}
+
+// processSignature сохраняет депозиты из переводов нативного SOL и токенов
+// SPL транзакции.
+func (w *Watcher) processSignature(sig solana.Signature) error {
+	version := uint64(0)
+	tx, err := w.rpcClient.GetParsedTransaction(context.Background(), sig, &rpc.GetParsedTransactionOpts{
+		Commitment:                     rpc.CommitmentFinalized,
+		MaxSupportedTransactionVersion: &version,
+	})
+	if err != nil {
+		return fmt.Errorf("get transaction %s: %w", sig, err)
+	}
+	if tx == nil || tx.Transaction == nil || (tx.Meta != nil && tx.Meta.Err != nil) {
+		return nil
+	}
+	for _, t := range transfers(tx) {
+		info := w.native
+		if t.mint != "" {
+			info = w.tokens[t.mint]
+		}
+		if info == nil {
 			continue
 		}
 		var wallet models.Wallet
-		if err := w.db.Where("value = ?", dest).First(&wallet).Error; err != nil {
+		if err := w.db.Where("value = ? AND asset_id = ? AND network = ?", t.destination, info.assetID, chainName).First(&wallet).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				continue
 			}
-			log.Printf("db error: %v", err)
-			continue
+			return fmt.Errorf("find wallet: %w", err)
 		}
 		var existing models.TransactionIn
-		if err := w.db.Where("data ->> 'signature' = ?", sig.String()).First(&existing).Error; err == nil {
+		if err := w.db.Where("wallet_id = ? AND data ->> 'signature' = ? AND CAST(data ->> 'instruction' AS INTEGER) = ?", wallet.ID, sig.String(), t.index).First(&existing).Error; err == nil {
 			continue
 		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("existing check error: %v", err)
-			continue
-		}
-		amtBig, ok := new(big.Int).SetString(amountStr, 10)
-		if !ok {
-			continue
+			return fmt.Errorf("existing check: %w", err)
 		}
-		amount := decimal.NewFromBigInt(amtBig, -6)
-		data, _ := json.Marshal(map[string]any{"signature": sig.String(), "slot": tx.Slot})
-		dep := models.TransactionIn{
-			ClientID: wallet.ClientID,
-			WalletID: wallet.ID,
-			AssetID:  wallet.AssetID,
-			Amount:   amount,
-			Status:   models.TransactionInStatusConfirmed,
-			Data:     datatypes.JSON(data),
-		}
-		if err := w.db.Create(&dep).Error; err != nil {
-			log.Printf("failed to save deposit: %v", err)
-			continue
+		dep := deposits.Deposit{
+			Chain:       chainName,
+			Wallet:      wallet,
+			Amount:      decimal.NewFromBigInt(t.amount, -info.decimals),
+			BlockHeight: tx.Slot,
+			Data:        depositData(t.mint, tx.Slot),
 		}
-		if err := w.db.Model(&models.Balance{}).
-			Where("client_id = ? AND asset_id = ?", wallet.ClientID, wallet.AssetID).
-			Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-			log.Printf("failed to update balance: %v", err)
+		dep.Data["signature"] = sig.String()
+		dep.Data["instruction"] = t.index
+		// Транзакции запрашиваются с commitment finalized, поэтому депозит
+		// сразу окончательный.
+		if _, err := deposits.Record(w.db, dep, 0); err != nil {
+			return fmt.Errorf("save deposit: %w", err)
 		}
 	}
+	return nil
+}
+
+// depositData возвращает общие поля данных депозита: mint токена (для
+// нативного SOL — native) и слот.
+func depositData(mint string, slot uint64) map[string]any {
+	if mint == "" {
+		return map[string]any{"native": true, "slot": slot}
+	}
+	return map[string]any{"mint": mint, "slot": slot}
+}
+
+func (w *Watcher) startDebug() {
+	w.debugOnce.Do(func() { go w.debugLoop() })
 }
 
 func (w *Watcher) debugLoop() {
@@ -196,29 +508,27 @@ func (w *Watcher) createDebugDeposit(walletID string, amount decimal.Decimal) {
 		log.Printf("ошибка поиска кошелька: %v", err)
 		return
 	}
-	data, _ := json.Marshal(map[string]any{"debug": true})
-	dep := models.TransactionIn{
-		ClientID: wal.ClientID,
-		WalletID: wal.ID,
-		AssetID:  wal.AssetID,
-		Amount:   amount,
-		Status:   models.TransactionInStatusConfirmed,
-		Data:     datatypes.JSON(data),
-	}
-	if err := w.db.Create(&dep).Error; err != nil {
-		log.Printf("не удалось сохранить депозит: %v", err)
+	var network models.AssetNetwork
+	if err := w.db.Where("asset_id = ? AND chain = ?", wal.AssetID, chainName).First(&network).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("ошибка поиска сети актива: %v", err)
 		return
 	}
-	if err := w.db.Model(&models.Balance{}).
-		Where("client_id = ? AND asset_id = ?", wal.ClientID, wal.AssetID).
-		Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-		log.Printf("не удалось обновить баланс: %v", err)
+	w.mu.Lock()
+	slot := w.slot
+	w.mu.Unlock()
+	data := depositData(network.Contract, slot)
+	data["debug"] = true
+	dep := deposits.Deposit{Chain: chainName, Wallet: wal, Amount: amount, Data: data}
+	if _, err := deposits.Record(w.db, dep, 0); err != nil {
+		log.Printf("не удалось сохранить депозит: %v", err)
 	}
 }
 
 // TriggerSignature используется только для тестов, чтобы обработать сигнатуру.
 func (w *Watcher) TriggerSignature(sig solana.Signature) {
 	if !w.debug {
-		w.processSignature(sig)
+		if err := w.processSignature(sig); err != nil {
+			log.Printf("sol watcher: %v", err)
+		}
 	}
 }
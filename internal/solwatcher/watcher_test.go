@@ -1,9 +1,17 @@
 package solwatcher
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	solana "github.com/gagliardetto/solana-go"
 	"github.com/shopspring/decimal"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -16,7 +24,7 @@ func TestWatcherDebugDeposit(t *testing.T) {
 	if err != nil {
 		t.Fatalf("db open: %v", err)
 	}
-	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	client := models.Client{Username: "u"}
@@ -28,7 +36,7 @@ func TestWatcherDebugDeposit(t *testing.T) {
 	bal := models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero}
 	db.Create(&bal)
 
-	w, err := New(db, "", "", true)
+	w, err := New(db, "", true)
 	if err != nil {
 		t.Fatalf("watcher: %v", err)
 	}
@@ -52,13 +60,246 @@ func TestWatcherDebugDeposit(t *testing.T) {
 	}
 }
 
-func TestWatcherMintRequiredInProd(t *testing.T) {
+func TestWatcherRequiresTokensInProd(t *testing.T) {
 	db, err := gorm.Open(sqlite.Open("file:sol_prod?mode=memory&cache=shared"), &gorm.Config{})
 	if err != nil {
 		t.Fatalf("db open: %v", err)
 	}
-	if _, err := New(db, "", "", false); err == nil {
-		t.Fatalf("expected error")
+	if _, err := New(db, "", false); err == nil {
+		t.Fatalf("expected error without rpc url")
 	}
+	if err := db.AutoMigrate(&models.Asset{}, &models.AssetNetwork{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	w, err := New(db, "ws://127.0.0.1:1", false)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	if err := w.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "no solana assets") {
+		t.Fatalf("expected no tokens error, got %v", err)
+	}
+}
+
+const usdcMint = "EPjFWdd5AufqSSqeM2qXznJkBLFKe4MtSnTwWehAw1v"
+
+// fakeRPC имитирует JSON-RPC узел Solana: getSignaturesForAddress отдаёт
+// подписи новее параметра until, getTransaction — перевод USDC на ata.
+func fakeRPC(t *testing.T, ata solana.PublicKey, sigs []solana.Signature, untils *[]string) *httptest.Server {
+	transfer := func(amount string) string {
+		return `{"program":"spl-token","programId":"TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA","parsed":{"type":"transferChecked","info":{"mint":"` +
+			usdcMint + `","destination":"` + ata.String() + `","tokenAmount":{"amount":"` + amount + `","decimals":6}}}}`
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var result string
+		switch req.Method {
+		case "getSignaturesForAddress":
+			var opts struct {
+				Until string `json:"until"`
+			}
+			if len(req.Params) > 1 {
+				json.Unmarshal(req.Params[1], &opts)
+			}
+			*untils = append(*untils, opts.Until)
+			var items []string
+			for i := len(sigs) - 1; i >= 0 && sigs[i].String() != opts.Until; i-- {
+				items = append(items, fmt.Sprintf(`{"signature":"%s","slot":%d,"err":null}`, sigs[i], 100+i))
+			}
+			result = "[" + strings.Join(items, ",") + "]"
+		case "getTransaction":
+			var sig string
+			json.Unmarshal(req.Params[0], &sig)
+			switch sig {
+			case sigs[0].String():
+				result = `{"slot":100,"transaction":{"signatures":["` + sig + `"],"message":{"accountKeys":[],"instructions":[` + transfer("1500000") + `]}},"meta":{"err":null}}`
+			case sigs[1].String():
+				// перевод во вложенной инструкции другой программы
+				result = `{"slot":101,"transaction":{"signatures":["` + sig + `"],"message":{"accountKeys":[],"instructions":[]}},"meta":{"err":null,"innerInstructions":[{"index":0,"instructions":[` + transfer("2000000") + `]}]}}`
+			default:
+				result = "null"
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%s,"id":%s}`, result, req.ID)
+	}))
+}
+
+func TestSyncAccountReconciles(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:sol_sync?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	client := models.Client{Username: "u"}
+	db.Create(&client)
+	asset := models.Asset{Name: "USDC", Type: models.AssetTypeCrypto, Chain: chainName}
+	db.Create(&asset)
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: chainName, Contract: usdcMint, Decimals: 6, Confirmations: 1})
+	ata := solana.PublicKeyFromBytes(bytes.Repeat([]byte{0x11}, 32))
+	db.Create(&models.Wallet{ClientID: client.ID, AssetID: asset.ID, Network: chainName, Value: ata.String(), DerivationIndex: 0})
+	bal := models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero}
+	db.Create(&bal)
+
+	sigs := []solana.Signature{solana.SignatureFromBytes(bytes.Repeat([]byte{1}, 64)), solana.SignatureFromBytes(bytes.Repeat([]byte{2}, 64))}
+	var untils []string
+	srv := fakeRPC(t, ata, sigs, &untils)
+	defer srv.Close()
+
+	w, err := New(db, srv.URL, false)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	if w.tokens, w.native, err = loadTokens(db); err != nil {
+		t.Fatalf("tokens: %v", err)
+	}
+	accounts, err := w.loadAccounts()
+	if err != nil || len(accounts) != 1 || accounts[0] != ata {
+		t.Fatalf("accounts %v: %v", accounts, err)
+	}
+	ctx := context.Background()
+	if err := w.syncAccount(ctx, ata); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	db.First(&bal, "id = ?", bal.ID)
+	if !bal.Amount.Equal(decimal.RequireFromString("3.5")) {
+		t.Fatalf("balance %s", bal.Amount)
+	}
+	if height, _, _ := w.Progress(); height != 101 {
+		t.Fatalf("slot %d", height)
+	}
+
+	// после переподключения история догружается с последнего депозита
+	w2, _ := New(db, srv.URL, false)
+	w2.tokens = w.tokens
+	if err := w2.syncAccount(ctx, ata); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(untils) != 2 || untils[0] != "" || untils[1] != sigs[1].String() {
+		t.Fatalf("unexpected until params %v", untils)
+	}
+	var count int64
+	db.Model(&models.TransactionIn{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("expected 2 deposits, got %d", count)
+	}
+}
 
+// setupNative создаёт активы SOL и USDC с кошельками клиента: адресом
+// владельца и его ATA.
+func setupNative(t *testing.T) (*gorm.DB, models.Wallet, models.Wallet) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	client := models.Client{Username: "u"}
+	db.Create(&client)
+	sol := models.Asset{Name: "SOL", Type: models.AssetTypeCrypto, Chain: chainName}
+	usdc := models.Asset{Name: "USDC", Type: models.AssetTypeCrypto, Chain: chainName}
+	db.Create(&sol)
+	db.Create(&usdc)
+	db.Create(&models.AssetNetwork{AssetID: sol.ID, Chain: chainName, Decimals: 9, Confirmations: 1})
+	db.Create(&models.AssetNetwork{AssetID: usdc.ID, Chain: chainName, Contract: usdcMint, Decimals: 6, Confirmations: 1})
+	owner := solana.PublicKeyFromBytes(bytes.Repeat([]byte{0x22}, 32))
+	ata, _, _ := solana.FindAssociatedTokenAddress(owner, solana.MustPublicKeyFromBase58(usdcMint))
+	native := models.Wallet{ClientID: client.ID, AssetID: sol.ID, Network: chainName, Value: owner.String()}
+	token := models.Wallet{ClientID: client.ID, AssetID: usdc.ID, Network: chainName, Value: ata.String()}
+	db.Create(&native)
+	db.Create(&token)
+	for _, a := range []models.Asset{sol, usdc} {
+		db.Create(&models.Balance{ClientID: client.ID, AssetID: a.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero})
+	}
+	return db, native, token
+}
+
+func depositOf(t *testing.T, db *gorm.DB, wallet models.Wallet) (models.TransactionIn, map[string]any) {
+	t.Helper()
+	var tx models.TransactionIn
+	if err := db.First(&tx, "wallet_id = ?", wallet.ID).Error; err != nil {
+		t.Fatalf("tx: %v", err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(tx.Data, &data); err != nil {
+		t.Fatalf("data: %v", err)
+	}
+	return tx, data
+}
+
+func TestProcessSignatureNativeAndSPL(t *testing.T) {
+	db, native, token := setupNative(t)
+	sig := solana.SignatureFromBytes(bytes.Repeat([]byte{3}, 64))
+	// нативный перевод SOL и transfer токена без mint: mint берётся из
+	// postTokenBalances
+	txJSON := `{"slot":250,"transaction":{"signatures":["` + sig.String() + `"],"message":{"accountKeys":[
+		{"pubkey":"` + native.Value + `","signer":true,"writable":true},
+		{"pubkey":"` + token.Value + `","signer":false,"writable":true}],"instructions":[
+		{"program":"system","programId":"11111111111111111111111111111111","parsed":{"type":"transfer","info":{"source":"` + native.Value + `","destination":"` + native.Value + `","lamports":1500000000}}},
+		{"program":"spl-token","programId":"TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA","parsed":{"type":"transfer","info":{"source":"` + native.Value + `","destination":"` + token.Value + `","amount":"2500000"}}}]}},
+		"meta":{"err":null,"postTokenBalances":[{"accountIndex":1,"mint":"` + usdcMint + `","uiTokenAmount":{"amount":"2500000","decimals":6}}]}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","result":%s,"id":%s}`, txJSON, req.ID)
+	}))
+	defer srv.Close()
+
+	w, err := New(db, srv.URL, false)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	if w.tokens, w.native, err = loadTokens(db); err != nil || w.native == nil || len(w.tokens) != 1 {
+		t.Fatalf("tokens %v %v: %v", w.tokens, w.native, err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := w.processSignature(sig); err != nil {
+			t.Fatalf("process: %v", err)
+		}
+	}
+	var count int64
+	db.Model(&models.TransactionIn{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("expected 2 deposits, got %d", count)
+	}
+	tx, data := depositOf(t, db, native)
+	if !tx.Amount.Equal(decimal.RequireFromString("1.5")) || data["native"] != true || data["slot"] != float64(250) {
+		t.Fatalf("unexpected native deposit %s %v", tx.Amount, data)
+	}
+	tx, data = depositOf(t, db, token)
+	if !tx.Amount.Equal(decimal.RequireFromString("2.5")) || data["mint"] != usdcMint || data["slot"] != float64(250) {
+		t.Fatalf("unexpected token deposit %s %v", tx.Amount, data)
+	}
+}
+
+func TestWatcherDebugNativeAndSPL(t *testing.T) {
+	db, native, token := setupNative(t)
+	w, err := New(db, "", true)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	w.TriggerDeposit(native.ID, decimal.RequireFromString("1"))
+	w.TriggerDeposit(token.ID, decimal.RequireFromString("5"))
+	time.Sleep(50 * time.Millisecond)
+	if _, data := depositOf(t, db, native); data["native"] != true || data["debug"] != true {
+		t.Fatalf("unexpected native data %v", data)
+	}
+	if _, data := depositOf(t, db, token); data["mint"] != usdcMint || data["debug"] != true {
+		t.Fatalf("unexpected token data %v", data)
+	}
 }
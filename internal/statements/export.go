@@ -0,0 +1,202 @@
+package statements
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02 15:04:05"
+
+// WriteCSV пишет выписку в CSV: строка заголовка, остатки на начало периода
+// (opening_balance), движения и остатки на конец (closing_balance).
+func WriteCSV(w io.Writer, s *Statement) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{{"date", "type", "asset", "amount", "balance", "status", "reference", "details"}}
+	for _, asset := range s.Assets() {
+		rows = append(rows, []string{s.From.UTC().Format(dateLayout), "opening_balance", asset, "", s.Opening[asset].String(), "", "", ""})
+	}
+	for _, e := range s.Entries {
+		rows = append(rows, []string{e.Time.UTC().Format(dateLayout), string(e.Kind), e.Asset, e.Amount.String(),
+			e.Balance.String(), e.Status, e.Reference, e.Details})
+	}
+	for _, asset := range s.Assets() {
+		rows = append(rows, []string{s.To.UTC().Format(dateLayout), "closing_balance", asset, "", s.Closing[asset].String(), "", "", ""})
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Размеры страницы A4 альбомной ориентации и таблицы в пунктах.
+const (
+	pageWidth   = 842
+	pageHeight  = 595
+	pageMargin  = 36
+	lineHeight  = 13
+	fontSize    = 8
+	titleSize   = 14
+	maxCellRune = 48
+)
+
+// Колонки таблицы PDF: заголовок и отступ от левого поля.
+var pdfColumns = []struct {
+	title string
+	x     float64
+}{
+	{"Date (UTC)", 0},
+	{"Type", 90},
+	{"Asset", 160},
+	{"Amount", 240},
+	{"Balance", 330},
+	{"Status", 420},
+	{"Reference", 480},
+	{"Details", 580},
+}
+
+// WritePDF пишет выписку в PDF со стандартным шрифтом Helvetica. Шрифт не
+// содержит кириллицы, поэтому символы вне Latin-1 заменяются на "?".
+func WritePDF(w io.Writer, s *Statement) error {
+	doc := &pdfDoc{}
+	doc.newPage()
+	doc.text(pageMargin, doc.y, titleSize, "Account statement")
+	doc.y -= titleSize + 6
+	doc.text(pageMargin, doc.y, fontSize, "Client: "+s.ClientID)
+	doc.y -= lineHeight
+	period := fmt.Sprintf("Period: %s - %s UTC", s.From.UTC().Format(dateLayout), s.To.UTC().Format(dateLayout))
+	if s.Asset != "" {
+		period += ", asset " + s.Asset
+	}
+	doc.text(pageMargin, doc.y, fontSize, period)
+	doc.y -= lineHeight
+	for _, asset := range s.Assets() {
+		doc.text(pageMargin, doc.y, fontSize, fmt.Sprintf("Opening balance %s: %s", asset, s.Opening[asset]))
+		doc.y -= lineHeight
+	}
+	doc.y -= lineHeight / 2
+	doc.header()
+	for _, e := range s.Entries {
+		doc.row([]string{e.Time.UTC().Format(dateLayout), string(e.Kind), e.Asset, e.Amount.String(),
+			e.Balance.String(), e.Status, e.Reference, e.Details})
+	}
+	doc.y -= lineHeight / 2
+	for _, asset := range s.Assets() {
+		doc.line(fmt.Sprintf("Closing balance %s: %s", asset, s.Closing[asset]))
+	}
+	doc.line("Generated " + time.Now().UTC().Format(dateLayout) + " UTC")
+	return doc.write(w)
+}
+
+// pdfDoc — минимальный генератор PDF 1.4: страницы с текстом одним шрифтом.
+type pdfDoc struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func (d *pdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - pageMargin - titleSize
+}
+
+// ensure начинает новую страницу с заголовком таблицы, если строка не
+// помещается на текущую.
+func (d *pdfDoc) ensure(table bool) {
+	if d.y >= pageMargin {
+		return
+	}
+	d.newPage()
+	if table {
+		d.header()
+	}
+}
+
+func (d *pdfDoc) header() {
+	d.ensure(false)
+	for _, col := range pdfColumns {
+		d.text(pageMargin+col.x, d.y, fontSize, col.title)
+	}
+	d.y -= lineHeight
+}
+
+func (d *pdfDoc) row(cells []string) {
+	d.ensure(true)
+	for i, col := range pdfColumns {
+		d.text(pageMargin+col.x, d.y, fontSize, truncate(cells[i]))
+	}
+	d.y -= lineHeight
+}
+
+func (d *pdfDoc) line(s string) {
+	d.ensure(false)
+	d.text(pageMargin, d.y, fontSize, s)
+	d.y -= lineHeight
+}
+
+func (d *pdfDoc) text(x, y float64, size int, s string) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /F1 %d Tf %.2f %.2f Td (%s) Tj ET\n", size, x, y, pdfString(s))
+}
+
+func truncate(s string) string {
+	r := []rune(s)
+	if len(r) <= maxCellRune {
+		return s
+	}
+	return string(r[:maxCellRune-3]) + "..."
+}
+
+// pdfString экранирует строку PDF и переводит её в WinAnsi.
+func pdfString(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20:
+			sb.WriteByte(' ')
+		case r < 0x80:
+			sb.WriteRune(r)
+		case r < 0x100:
+			fmt.Fprintf(&sb, "\\%03o", r)
+		default:
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
+
+// write собирает объекты документа: каталог, дерево страниц, шрифт и по
+// странице с потоком содержимого на каждую страницу, затем таблицу xref.
+func (d *pdfDoc) write(w io.Writer) error {
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	buf.WriteString("%PDF-1.4\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	for i, p := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 5+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.String()))
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
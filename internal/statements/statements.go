@@ -0,0 +1,188 @@
+// Package statements собирает выписку клиента: депозиты, выводы, внутренние
+// переводы, эскроу и сделки в хронологическом порядке с остатками.
+package statements
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+)
+
+// Kind — тип строки выписки.
+type Kind string
+
+const (
+	KindDeposit     Kind = "deposit"
+	KindWithdrawal  Kind = "withdrawal"
+	KindTransferIn  Kind = "transfer_in"
+	KindTransferOut Kind = "transfer_out"
+	KindEscrow      Kind = "escrow"
+	// KindTrade — завершённая сделка. Средства по ней движутся внутренними
+	// переводами и эскроу, поэтому строка сделки остаток не меняет.
+	KindTrade Kind = "trade"
+)
+
+// Entry — строка выписки. Amount — изменение доступного баланса актива,
+// Balance — остаток после строки.
+type Entry struct {
+	Time      time.Time
+	Kind      Kind
+	Asset     string
+	Amount    decimal.Decimal
+	Balance   decimal.Decimal
+	Status    string
+	Reference string
+	Details   string
+}
+
+// Statement — выписка за период. Opening и Closing — доступные балансы по
+// активам на начало и конец периода.
+type Statement struct {
+	ClientID string
+	From     time.Time
+	To       time.Time
+	Asset    string
+	Opening  map[string]decimal.Decimal
+	Closing  map[string]decimal.Decimal
+	Entries  []Entry
+}
+
+// Assets возвращает активы выписки по алфавиту.
+func (s *Statement) Assets() []string {
+	names := make([]string, 0, len(s.Closing))
+	for name := range s.Closing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build собирает выписку клиента за [from, to). Учитываются те же движения,
+// что и при сверке балансов: подтверждённые депозиты и внутренние переводы,
+// выводы кроме неудачных и отменённых, средства в эскроу. Движения до from
+// входят в остаток на начало периода. assetID ограничивает выписку одним
+// активом, если не пуст.
+func Build(db *gorm.DB, clientID string, from, to time.Time, assetID string) (*Statement, error) {
+	var entries []Entry
+	scope := func(q *gorm.DB, table string) *gorm.DB {
+		q = q.Joins("JOIN assets ON assets.id = "+table+".asset_id").
+			Where(table+".created_at < ?", to)
+		if assetID != "" {
+			q = q.Where(table+".asset_id = ?", assetID)
+		}
+		return q
+	}
+
+	var ins []models.TransactionIn
+	if err := scope(db.Model(&models.TransactionIn{}).Select("transaction_ins.*, assets.name AS asset_name"), "transaction_ins").
+		Where("transaction_ins.client_id = ? AND transaction_ins.status = ?", clientID, models.TransactionInStatusConfirmed).
+		Find(&ins).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range ins {
+		entries = append(entries, Entry{Time: t.CreatedAt, Kind: KindDeposit, Asset: t.AssetName, Amount: t.Amount,
+			Status: string(t.Status), Reference: t.ID, Details: t.Network})
+	}
+
+	var outs []models.TransactionOut
+	if err := scope(db.Model(&models.TransactionOut{}).Select("transaction_outs.*, assets.name AS asset_name"), "transaction_outs").
+		Where("transaction_outs.client_id = ? AND transaction_outs.status NOT IN ?", clientID,
+			[]models.TransactionOutStatus{models.TransactionOutStatusFailed, models.TransactionOutStatusCancelled}).
+		Find(&outs).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range outs {
+		entries = append(entries, Entry{Time: t.CreatedAt, Kind: KindWithdrawal, Asset: t.AssetName, Amount: t.Amount.Neg(),
+			Status: string(t.Status), Reference: t.ID, Details: t.ToAddress})
+	}
+
+	var internals []models.TransactionInternal
+	if err := scope(db.Model(&models.TransactionInternal{}).Select("transaction_internals.*, assets.name AS asset_name"), "transaction_internals").
+		Where("transaction_internals.status = ? AND (transaction_internals.from_client_id = ? OR transaction_internals.to_client_id = ?)",
+			models.TransactionInternalStatusConfirmed, clientID, clientID).
+		Find(&internals).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range internals {
+		// Перевод самому себе даёт две строки, которые взаимно погашаются
+		if t.ToClientID == clientID {
+			entries = append(entries, Entry{Time: t.CreatedAt, Kind: KindTransferIn, Asset: t.AssetName, Amount: t.Amount,
+				Status: string(t.Status), Reference: t.ID, Details: t.OrderInfo})
+		}
+		if t.FromClientID == clientID {
+			entries = append(entries, Entry{Time: t.CreatedAt, Kind: KindTransferOut, Asset: t.AssetName, Amount: t.Amount.Neg(),
+				Status: string(t.Status), Reference: t.ID, Details: t.OrderInfo})
+		}
+	}
+
+	var escrows []struct {
+		models.Escrow
+		AssetName string
+	}
+	if err := scope(db.Model(&models.Escrow{}).Select("escrows.*, assets.name AS asset_name"), "escrows").
+		Where("escrows.client_id = ?", clientID).
+		Find(&escrows).Error; err != nil {
+		return nil, err
+	}
+	for _, e := range escrows {
+		ref := e.ID
+		if e.OrderID != nil {
+			ref = *e.OrderID
+		} else if e.OfferID != nil {
+			ref = *e.OfferID
+		}
+		entries = append(entries, Entry{Time: e.CreatedAt, Kind: KindEscrow, Asset: e.AssetName, Amount: e.Amount.Neg(),
+			Status: "locked", Reference: ref})
+	}
+
+	var orders []struct {
+		models.Order
+		FromName string
+		ToName   string
+	}
+	q := db.Model(&models.Order{}).
+		Select("orders.*, fa.name AS from_name, ta.name AS to_name").
+		Joins("JOIN assets fa ON fa.id = orders.from_asset_id").
+		Joins("JOIN assets ta ON ta.id = orders.to_asset_id").
+		Where("orders.status = ? AND orders.released_at < ? AND (orders.buyer_id = ? OR orders.seller_id = ?)",
+			models.OrderStatusReleased, to, clientID, clientID)
+	if assetID != "" {
+		q = q.Where("(orders.from_asset_id = ? OR orders.to_asset_id = ?)", assetID, assetID)
+	}
+	if err := q.Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		side := "sell"
+		if o.BuyerID == clientID {
+			side = "buy"
+		}
+		// Сделка попадает в выписку по активу, которым ограничен запрос
+		asset := o.FromName
+		if assetID != "" && o.FromAssetID != assetID {
+			asset = o.ToName
+		}
+		entries = append(entries, Entry{Time: *o.ReleasedAt, Kind: KindTrade, Asset: asset, Amount: decimal.Zero,
+			Status: string(o.Status), Reference: o.ID,
+			Details: fmt.Sprintf("%s %s %s @ %s %s", side, o.Amount, o.FromName, o.Price, o.ToName)})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	st := &Statement{ClientID: clientID, From: from, To: to,
+		Opening: make(map[string]decimal.Decimal), Closing: make(map[string]decimal.Decimal)}
+	for _, e := range entries {
+		e.Balance = st.Closing[e.Asset].Add(e.Amount)
+		st.Closing[e.Asset] = e.Balance
+		if e.Time.Before(from) {
+			st.Opening[e.Asset] = e.Balance
+			continue
+		}
+		st.Entries = append(st.Entries, e)
+	}
+	return st, nil
+}
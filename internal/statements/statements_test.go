@@ -0,0 +1,134 @@
+package statements
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+)
+
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.Wallet{}, &models.Escrow{},
+		&models.TransactionIn{}, &models.TransactionOut{}, &models.TransactionInternal{}, &models.Order{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func dec(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+
+func TestBuild(t *testing.T) {
+	db := setupDB(t)
+	btc := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, IsActive: true}
+	usdt := models.Asset{Name: "USDT", Type: models.AssetTypeCrypto, IsActive: true}
+	db.Create(&btc)
+	db.Create(&usdt)
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	at := func(days int) time.Time { return from.AddDate(0, 0, days) }
+
+	// До периода: депозит 5 BTC
+	db.Create(&models.TransactionIn{ClientID: "c1", AssetID: btc.ID, Amount: dec("5"), Status: models.TransactionInStatusConfirmed, CreatedAt: at(-3)})
+	// В периоде: вывод 1, эскроу 2, получено 0.5, депозит USDT 100
+	db.Create(&models.TransactionOut{ClientID: "c1", AssetID: btc.ID, Amount: dec("1"), Status: models.TransactionOutStatusConfirmed, CreatedAt: at(1)})
+	orderID := "order1"
+	db.Create(&models.Escrow{ClientID: "c1", AssetID: btc.ID, Amount: dec("2"), OrderID: &orderID, CreatedAt: at(2)})
+	db.Create(&models.TransactionInternal{FromClientID: "c2", ToClientID: "c1", AssetID: btc.ID, Amount: dec("0.5"), Status: models.TransactionInternalStatusConfirmed, CreatedAt: at(3)})
+	db.Create(&models.TransactionIn{ClientID: "c1", AssetID: usdt.ID, Amount: dec("100"), Status: models.TransactionInStatusConfirmed, CreatedAt: at(4)})
+	released := at(5)
+	db.Create(&models.Order{ID: orderID, BuyerID: "c2", SellerID: "c1", FromAssetID: btc.ID, ToAssetID: usdt.ID,
+		Amount: dec("2"), Price: dec("50000"), Status: models.OrderStatusReleased, ReleasedAt: &released})
+	// Не учитываются: неподтверждённый депозит, отменённый вывод, движение после периода
+	db.Create(&models.TransactionIn{ClientID: "c1", AssetID: btc.ID, Amount: dec("9"), Status: models.TransactionInStatusPending, CreatedAt: at(6)})
+	db.Create(&models.TransactionOut{ClientID: "c1", AssetID: btc.ID, Amount: dec("9"), Status: models.TransactionOutStatusCancelled, CreatedAt: at(6)})
+	db.Create(&models.TransactionIn{ClientID: "c1", AssetID: btc.ID, Amount: dec("9"), Status: models.TransactionInStatusConfirmed, CreatedAt: to.Add(time.Hour)})
+
+	st, err := Build(db, "c1", from, to, "")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if !st.Opening["BTC"].Equal(dec("5")) || !st.Opening["USDT"].IsZero() {
+		t.Fatalf("opening %v", st.Opening)
+	}
+	if !st.Closing["BTC"].Equal(dec("2.5")) || !st.Closing["USDT"].Equal(dec("100")) {
+		t.Fatalf("closing %v", st.Closing)
+	}
+	want := []struct {
+		kind    Kind
+		asset   string
+		balance string
+	}{
+		{KindWithdrawal, "BTC", "4"},
+		{KindEscrow, "BTC", "2"},
+		{KindTransferIn, "BTC", "2.5"},
+		{KindDeposit, "USDT", "100"},
+		{KindTrade, "BTC", "2.5"},
+	}
+	if len(st.Entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(st.Entries))
+	}
+	for i, w := range want {
+		e := st.Entries[i]
+		if e.Kind != w.kind || e.Asset != w.asset || !e.Balance.Equal(dec(w.balance)) {
+			t.Fatalf("entry %d: %+v", i, e)
+		}
+	}
+	if st.Entries[1].Reference != orderID || st.Entries[4].Details != "sell 2 BTC @ 50000 USDT" {
+		t.Fatalf("unexpected entries %+v", st.Entries)
+	}
+
+	// Выписка по USDT: депозит и сделка
+	st, err = Build(db, "c1", from, to, usdt.ID)
+	if err != nil {
+		t.Fatalf("build usdt: %v", err)
+	}
+	if len(st.Entries) != 2 || st.Entries[1].Asset != "USDT" || len(st.Assets()) != 1 {
+		t.Fatalf("unexpected usdt statement %+v", st)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, st); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 5 || rows[1][1] != "opening_balance" || rows[4][1] != "closing_balance" || rows[4][4] != "100" {
+		t.Fatalf("unexpected csv %v", rows)
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	st := &Statement{ClientID: "c1", From: time.Now().Add(-time.Hour), To: time.Now(),
+		Opening: map[string]decimal.Decimal{"BTC": decimal.Zero}, Closing: map[string]decimal.Decimal{"BTC": dec("1")}}
+	// Строк больше, чем помещается на одну страницу
+	for i := 0; i < 100; i++ {
+		st.Entries = append(st.Entries, Entry{Time: time.Now(), Kind: KindDeposit, Asset: "BTC", Amount: dec("0.01"),
+			Balance: dec("0.01").Mul(decimal.NewFromInt(int64(i + 1))), Details: "адрес (test)"})
+	}
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, st); err != nil {
+		t.Fatalf("pdf: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("not a pdf")
+	}
+	if !strings.Contains(out, "/Count 3") || !strings.Contains(out, `????? \(test\)`) {
+		t.Fatalf("unexpected pdf content")
+	}
+}
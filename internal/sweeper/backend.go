@@ -0,0 +1,189 @@
+package sweeper
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/shopspring/decimal"
+)
+
+// errNotSupported возвращается для запросов, которых нет в модели сети.
+var errNotSupported = errors.New("not supported")
+
+// utxoBackend — JSON-RPC узел Bitcoin или его форка.
+type utxoBackend struct {
+	client *rpcclient.Client
+}
+
+// NewUTXOBackend подключается к JSON-RPC узлу UTXO-сети.
+func NewUTXOBackend(host, user, pass string) (Backend, error) {
+	if host == "" {
+		return nil, errors.New("rpc host required")
+	}
+	client, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         host,
+		User:         user,
+		Pass:         pass,
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &utxoBackend{client: client}, nil
+}
+
+// minFeeRate — ставка, если узел не может оценить комиссию (regtest, мало
+// данных после запуска): минимальная ставка ретрансляции.
+var minFeeRate = decimal.NewFromInt(1)
+
+// FeeRate оценивает ставку для подтверждения за 6 блоков в sat/vB.
+func (b *utxoBackend) FeeRate(ctx context.Context) (decimal.Decimal, error) {
+	res, err := b.client.RawRequest("estimatesmartfee", []json.RawMessage{json.RawMessage("6")})
+	if err != nil {
+		return decimal.Zero, err
+	}
+	var est struct {
+		FeeRate *json.Number `json:"feerate"`
+	}
+	if err := json.Unmarshal(res, &est); err != nil {
+		return decimal.Zero, err
+	}
+	if est.FeeRate == nil {
+		return minFeeRate, nil
+	}
+	// Узел возвращает ставку в монетах за 1000 vB
+	rate, err := decimal.NewFromString(est.FeeRate.String())
+	if err != nil {
+		return decimal.Zero, err
+	}
+	rate = rate.Mul(decimal.New(1, 5))
+	if rate.LessThan(minFeeRate) {
+		return minFeeRate, nil
+	}
+	return rate, nil
+}
+
+func (b *utxoBackend) Balance(ctx context.Context, address string) (decimal.Decimal, error) {
+	return decimal.Zero, errNotSupported
+}
+
+func (b *utxoBackend) Nonce(ctx context.Context, address string) (uint64, error) {
+	return 0, errNotSupported
+}
+
+// Confirmations запрашивает getrawtransaction: подтверждённые транзакции
+// вне мемпула узел находит только с -txindex.
+func (b *utxoBackend) Confirmations(ctx context.Context, txID string) (int64, error) {
+	param, _ := json.Marshal(txID)
+	res, err := b.client.RawRequest("getrawtransaction", []json.RawMessage{param, json.RawMessage("true")})
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCNoTxInfo {
+		return 0, ErrTxNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	var tx struct {
+		Confirmations int64 `json:"confirmations"`
+	}
+	if err := json.Unmarshal(res, &tx); err != nil {
+		return 0, err
+	}
+	return tx.Confirmations, nil
+}
+
+func (b *utxoBackend) Broadcast(ctx context.Context, raw []byte) (string, error) {
+	param, _ := json.Marshal(hex.EncodeToString(raw))
+	res, err := b.client.RawRequest("sendrawtransaction", []json.RawMessage{param})
+	if err != nil {
+		return "", err
+	}
+	var txid string
+	if err := json.Unmarshal(res, &txid); err != nil {
+		return "", err
+	}
+	return txid, nil
+}
+
+// evmBackend — JSON-RPC узел EVM-сети.
+type evmBackend struct {
+	client *ethclient.Client
+}
+
+// NewEVMBackend подключается к RPC узлу EVM-сети.
+func NewEVMBackend(ctx context.Context, rpcURL string) (Backend, error) {
+	if rpcURL == "" {
+		return nil, errors.New("rpc url required")
+	}
+	client, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	return &evmBackend{client: client}, nil
+}
+
+func (b *evmBackend) FeeRate(ctx context.Context) (decimal.Decimal, error) {
+	price, err := b.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return decimal.NewFromBigInt(price, 0), nil
+}
+
+func (b *evmBackend) Balance(ctx context.Context, address string) (decimal.Decimal, error) {
+	wei, err := b.client.BalanceAt(ctx, common.HexToAddress(address), nil)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return decimal.NewFromBigInt(wei, -18), nil
+}
+
+func (b *evmBackend) Nonce(ctx context.Context, address string) (uint64, error) {
+	return b.client.PendingNonceAt(ctx, common.HexToAddress(address))
+}
+
+func (b *evmBackend) Confirmations(ctx context.Context, txID string) (int64, error) {
+	hash := common.HexToHash(txID)
+	receipt, err := b.client.TransactionReceipt(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		if _, _, err := b.client.TransactionByHash(ctx, hash); err != nil {
+			if errors.Is(err, ethereum.NotFound) {
+				return 0, ErrTxNotFound
+			}
+			return 0, err
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return 0, ErrTxReverted
+	}
+	head, err := b.client.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return int64(head) - receipt.BlockNumber.Int64() + 1, nil
+}
+
+func (b *evmBackend) Broadcast(ctx context.Context, raw []byte) (string, error) {
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return "", fmt.Errorf("decode signed tx: %w", err)
+	}
+	if err := b.client.SendTransaction(ctx, &tx); err != nil {
+		return "", err
+	}
+	return tx.Hash().Hex(), nil
+}
@@ -0,0 +1,45 @@
+package sweeper
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"ptop/config"
+)
+
+// Build создаёт свиперы сетей из SWEEP_CHAINS с узлами наблюдателей и
+// сервисом подписи из конфига. Без SWEEP_SIGNER_URL работает только dry-run.
+func Build(ctx context.Context, db *gorm.DB, cfg *config.Config) ([]*Sweeper, error) {
+	var signer Signer
+	if cfg.SweepSignerURL != "" {
+		signer = HTTPSigner{URL: cfg.SweepSignerURL, Token: cfg.SweepSignerToken}
+	}
+	opts := Options{DryRun: cfg.SweepDryRun, MaxInputs: cfg.SweepMaxInputs}
+	var res []*Sweeper
+	for _, name := range cfg.SweepChains {
+		var (
+			backend Backend
+			err     error
+		)
+		if node, ok := cfg.UTXONodes[name]; ok {
+			backend, err = NewUTXOBackend(node.Host, node.User, node.Pass)
+		} else if url, ok := cfg.EVMRPCURLs[name]; ok {
+			backend, err = NewEVMBackend(ctx, url)
+		} else {
+			return nil, fmt.Errorf("sweeping is not supported for %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s sweeper backend: %w", name, err)
+		}
+		o := opts
+		o.HotWallet = cfg.SweepHotWallets[name]
+		s, err := New(db, name, backend, signer, o)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
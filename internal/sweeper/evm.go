@@ -0,0 +1,173 @@
+package sweeper
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"ptop/internal/models"
+)
+
+// Лимиты газа перевода нативной монеты и токена ERC20.
+const (
+	nativeGasLimit = 21000
+	tokenGasLimit  = 65000
+)
+
+var (
+	weiPerEther = decimal.New(1, 18)
+	// topUpMargin — запас пополнения на рост цены газа до перевода токена.
+	topUpMargin = decimal.RequireFromString("1.2")
+)
+
+// evmAddress — накопленные депозиты актива на одном адресе.
+type evmAddress struct {
+	assetID  string
+	walletID string
+	address  string
+	index    uint32
+	total    decimal.Decimal
+	deposits []string
+}
+
+// planEVM переводит на горячий кошелёк нативную монету и токены с адресов,
+// где накопилось не меньше порога. Если на адресе с токенами не хватает
+// нативной монеты на газ, вместо перевода планируется пополнение с горячего
+// кошелька; токены переводятся на следующем проходе.
+func (s *Sweeper) planEVM(ctx context.Context) ([]Tx, error) {
+	limits, err := s.thresholds()
+	if err != nil || len(limits) == 0 {
+		return nil, err
+	}
+	deps, err := s.pendingDeposits()
+	if err != nil || len(deps) == 0 {
+		return nil, err
+	}
+	gasPrice, err := s.backend.FeeRate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*evmAddress)
+	var order []*evmAddress
+	for _, d := range deps {
+		if _, ok := limits[d.AssetID]; !ok {
+			continue
+		}
+		key := d.AssetID + "/" + d.Address
+		g, ok := groups[key]
+		if !ok {
+			g = &evmAddress{assetID: d.AssetID, walletID: d.WalletID, address: d.Address, index: d.DerivationIndex}
+			groups[key] = g
+			order = append(order, g)
+		}
+		g.total = g.total.Add(d.Amount)
+		g.deposits = append(g.deposits, d.ID)
+	}
+
+	// Токены переводятся раньше нативной монеты того же адреса: иначе свип
+	// монеты забрал бы газ на перевод токена
+	sort.SliceStable(order, func(i, j int) bool {
+		return limits[order[i].assetID].Contract != "" && limits[order[j].assetID].Contract == ""
+	})
+
+	var plan []Tx
+	// Адрес может отправить несколько транзакций за проход: nonce и
+	// потраченный на газ баланс учитываются по адресам
+	nonces := make(map[string]uint64)
+	spent := make(map[string]decimal.Decimal)
+	nextNonce := func(address string) (uint64, error) {
+		n, ok := nonces[address]
+		if !ok {
+			var err error
+			if n, err = s.backend.Nonce(ctx, address); err != nil {
+				return 0, err
+			}
+		}
+		nonces[address] = n + 1
+		return n, nil
+	}
+	for _, g := range order {
+		network := limits[g.assetID]
+		if g.total.LessThan(network.SweepThreshold) {
+			continue
+		}
+		balance, err := s.backend.Balance(ctx, g.address)
+		if err != nil {
+			return nil, err
+		}
+		balance = balance.Sub(spent[g.address])
+		input := Input{WalletID: g.walletID, Address: g.address, DerivationIndex: g.index, Amount: g.total}
+		if network.Contract == "" {
+			fee := gasFee(gasPrice, nativeGasLimit)
+			amount := balance.Sub(fee)
+			if !amount.IsPositive() {
+				continue
+			}
+			nonce, err := nextNonce(g.address)
+			if err != nil {
+				return nil, err
+			}
+			input.Amount = balance
+			plan = append(plan, Tx{
+				Chain: s.chain, AssetID: g.assetID, Kind: models.SweepKindSweep,
+				Inputs: []Input{input}, To: s.opts.HotWallet, Amount: amount, Fee: fee,
+				FeeRate: gasPrice, GasLimit: nativeGasLimit, Nonce: nonce, depositIDs: g.deposits,
+			})
+			continue
+		}
+
+		fee := gasFee(gasPrice, tokenGasLimit)
+		if balance.LessThan(fee) {
+			waiting, err := s.recentTopUp(g.address)
+			if err != nil {
+				return nil, err
+			}
+			if waiting {
+				continue
+			}
+			nonce, err := nextNonce(s.opts.HotWallet)
+			if err != nil {
+				return nil, err
+			}
+			// Пополнение относится к активу, перевод которого оплачивает
+			plan = append(plan, Tx{
+				Chain: s.chain, AssetID: g.assetID, Kind: models.SweepKindTopUp,
+				Inputs: []Input{{Address: s.opts.HotWallet}}, To: g.address,
+				Amount: fee.Mul(topUpMargin).Sub(balance), Fee: gasFee(gasPrice, nativeGasLimit),
+				FeeRate: gasPrice, GasLimit: nativeGasLimit, Nonce: nonce,
+			})
+			continue
+		}
+		nonce, err := nextNonce(g.address)
+		if err != nil {
+			return nil, err
+		}
+		spent[g.address] = spent[g.address].Add(fee)
+		plan = append(plan, Tx{
+			Chain: s.chain, AssetID: g.assetID, Kind: models.SweepKindSweep,
+			Inputs: []Input{input}, To: s.opts.HotWallet, Amount: g.total, Fee: fee,
+			FeeRate: gasPrice, Contract: network.Contract, GasLimit: tokenGasLimit, Nonce: nonce,
+			depositIDs: g.deposits,
+		})
+	}
+	return plan, nil
+}
+
+// recentTopUp сообщает, отправлялось ли пополнение адреса газом за
+// TopUpCooldown: повторно адрес не пополняется, пока ждём подтверждения.
+func (s *Sweeper) recentTopUp(address string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.Sweep{}).
+		Where("network = ? AND kind = ? AND to_address = ? AND status = ? AND created_at > ?",
+			s.chain, models.SweepKindTopUp, address, models.SweepStatusSent, time.Now().Add(-s.opts.TopUpCooldown)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// gasFee переводит стоимость газа из wei в нативную монету.
+func gasFee(gasPrice decimal.Decimal, gasLimit int64) decimal.Decimal {
+	return gasPrice.Mul(decimal.NewFromInt(gasLimit)).Div(weiPerEther)
+}
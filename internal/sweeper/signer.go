@@ -0,0 +1,55 @@
+package sweeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPSigner отправляет транзакцию свипа во внешний сервис подписи, который
+// хранит ключи и возвращает подписанную транзакцию: POST JSON Tx, ответ
+// {"raw": "<hex>"}.
+type HTTPSigner struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (s HTTPSigner) Sign(ctx context.Context, tx Tx) ([]byte, error) {
+	body, err := json.Marshal(tx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Raw   string `json:"raw"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("signer response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || res.Raw == "" {
+		return nil, fmt.Errorf("signer: %s %s", resp.Status, res.Error)
+	}
+	return hex.DecodeString(res.Raw)
+}
@@ -0,0 +1,323 @@
+// Package sweeper переводит средства с адресов депозита на горячий кошелёк.
+package sweeper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"ptop/internal/chains"
+	"ptop/internal/models"
+)
+
+// Input — источник средств транзакции свипа. Для UTXO-сетей это выход
+// депозита, для EVM-сетей — адрес депозита целиком.
+type Input struct {
+	WalletID        string          `json:"walletID,omitempty"`
+	Address         string          `json:"address"`
+	DerivationIndex uint32          `json:"index"`
+	TxID            string          `json:"txid,omitempty"`
+	Vout            int             `json:"vout,omitempty"`
+	Amount          decimal.Decimal `json:"amount"`
+}
+
+// Tx — неподписанная транзакция свипа. Суммы указаны в единицах актива
+// (BTC, ETH, токен), комиссия — в нативной монете сети.
+type Tx struct {
+	Chain   string           `json:"chain"`
+	AssetID string           `json:"assetID"`
+	Kind    models.SweepKind `json:"kind"`
+	Inputs  []Input          `json:"inputs"`
+	To      string           `json:"to"`
+	Amount  decimal.Decimal  `json:"amount"`
+	Fee     decimal.Decimal  `json:"fee"`
+	// FeeRate — sat/vB для UTXO-сетей, цена газа в wei для EVM-сетей.
+	FeeRate decimal.Decimal `json:"feeRate"`
+	// Contract — контракт токена EVM; пустой для нативной монеты.
+	Contract string `json:"contract,omitempty"`
+	GasLimit uint64 `json:"gasLimit,omitempty"`
+	Nonce    uint64 `json:"nonce,omitempty"`
+	// depositIDs — депозиты, которые переводит транзакция.
+	depositIDs []string
+}
+
+// Signer подписывает транзакции свипа. Ключи адресов депозита выводятся по
+// активу и индексам деривации входов, ключ горячего кошелька нужен для
+// пополнений газом. Реализация подключается снаружи: HSM, отдельный сервис
+// подписи или HTTPSigner.
+type Signer interface {
+	Sign(ctx context.Context, tx Tx) ([]byte, error)
+}
+
+// Backend — узел сети, через который свипер узнаёт комиссии и балансы и
+// отправляет подписанные транзакции.
+type Backend interface {
+	// FeeRate возвращает ставку комиссии: sat/vB или цену газа в wei.
+	FeeRate(ctx context.Context) (decimal.Decimal, error)
+	// Balance возвращает баланс адреса в нативной монете (только EVM).
+	Balance(ctx context.Context, address string) (decimal.Decimal, error)
+	// Nonce возвращает следующий nonce адреса (только EVM).
+	Nonce(ctx context.Context, address string) (uint64, error)
+	// Broadcast отправляет подписанную транзакцию и возвращает её хеш.
+	Broadcast(ctx context.Context, raw []byte) (string, error)
+	// Confirmations возвращает число подтверждений отправленной транзакции,
+	// 0 — транзакция в мемпуле. ErrTxNotFound — транзакции нет ни в мемпуле,
+	// ни в блоках; ErrTxReverted — EVM-транзакция включена в блок и откатилась.
+	Confirmations(ctx context.Context, txID string) (int64, error)
+}
+
+var (
+	ErrTxNotFound = errors.New("transaction not found")
+	ErrTxReverted = errors.New("transaction reverted")
+)
+
+// Options — настройки свипа одной сети.
+type Options struct {
+	// HotWallet — адрес горячего кошелька, на который переводятся средства.
+	HotWallet string
+	// DryRun — только составить план без подписи и отправки.
+	DryRun bool
+	// MaxInputs — наибольшее число входов в одной UTXO-транзакции.
+	MaxInputs int
+	// TopUpCooldown — сколько ждать подтверждения пополнения газом, прежде
+	// чем пополнить адрес снова.
+	TopUpCooldown time.Duration
+	// DropTimeout — сколько отправленная транзакция может отсутствовать в
+	// сети, прежде чем свип считается выпавшим.
+	DropTimeout time.Duration
+}
+
+// Sweeper консолидирует депозиты одной сети на горячем кошельке.
+type Sweeper struct {
+	db      *gorm.DB
+	chain   string
+	backend Backend
+	signer  Signer
+	opts    Options
+}
+
+// New создаёт свипер сети chain. В режиме dry-run signer может быть nil.
+func New(db *gorm.DB, chain string, backend Backend, signer Signer, opts Options) (*Sweeper, error) {
+	if opts.HotWallet == "" {
+		return nil, fmt.Errorf("%s hot wallet required", chain)
+	}
+	c, err := chains.Get(chain)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ValidateAddress(opts.HotWallet); err != nil {
+		return nil, fmt.Errorf("%s hot wallet: %w", chain, err)
+	}
+	if signer == nil && !opts.DryRun {
+		return nil, fmt.Errorf("%s signer required", chain)
+	}
+	if opts.MaxInputs <= 0 {
+		opts.MaxInputs = 100
+	}
+	if opts.TopUpCooldown <= 0 {
+		opts.TopUpCooldown = time.Hour
+	}
+	if opts.DropTimeout <= 0 {
+		opts.DropTimeout = time.Hour
+	}
+	return &Sweeper{db: db, chain: chain, backend: backend, signer: signer, opts: opts}, nil
+}
+
+// RunOnce сверяет отправленные ранее свипы с сетью, составляет план свипа
+// и, если не включён dry-run, подписывает и отправляет транзакции.
+// Возвращает план: в dry-run он ничего не меняет.
+func (s *Sweeper) RunOnce(ctx context.Context) ([]Tx, error) {
+	var (
+		plan []Tx
+		err  error
+	)
+	if !s.opts.DryRun {
+		if err := s.reconcile(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := utxoChains[s.chain]; ok {
+		plan, err = s.planUTXO(ctx)
+	} else if isEVM(s.chain) {
+		plan, err = s.planEVM(ctx)
+	} else {
+		return nil, fmt.Errorf("sweeping is not supported for %s", s.chain)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range plan {
+		if s.opts.DryRun {
+			log.Printf("dry-run свип %s: %s %s → %s, входов %d, комиссия %s", s.chain, tx.Kind, tx.Amount, tx.To, len(tx.Inputs), tx.Fee)
+			continue
+		}
+		if err := s.execute(ctx, tx); err != nil {
+			log.Printf("свип %s не выполнен: %v", s.chain, err)
+		}
+	}
+	return plan, nil
+}
+
+// execute подписывает и отправляет транзакцию и сохраняет её в sweeps.
+// После успешной отправки депозиты связываются со свипом, чтобы не попасть в
+// следующий план; переведёнными их отмечает reconcile после подтверждения.
+func (s *Sweeper) execute(ctx context.Context, tx Tx) error {
+	data, _ := json.Marshal(map[string]any{"inputs": tx.Inputs, "contract": tx.Contract, "fee_rate": tx.FeeRate})
+	sweep := models.Sweep{
+		Network:   s.chain,
+		AssetID:   tx.AssetID,
+		Kind:      tx.Kind,
+		ToAddress: tx.To,
+		Amount:    tx.Amount,
+		Fee:       tx.Fee,
+		Status:    models.SweepStatusSent,
+		Data:      datatypes.JSON(data),
+	}
+	raw, err := s.signer.Sign(ctx, tx)
+	if err == nil {
+		sweep.TxID, err = s.backend.Broadcast(ctx, raw)
+	}
+	if err != nil {
+		sweep.Status = models.SweepStatusFailed
+		sweep.Error = err.Error()
+	}
+	if dbErr := s.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Create(&sweep).Error; err != nil {
+			return err
+		}
+		if sweep.Status != models.SweepStatusSent || len(tx.depositIDs) == 0 {
+			return nil
+		}
+		return db.Model(&models.TransactionIn{}).Where("id IN ?", tx.depositIDs).Update("sweep_id", sweep.ID).Error
+	}); dbErr != nil {
+		return errors.Join(err, dbErr)
+	}
+	return err
+}
+
+// reconcile проверяет в сети отправленные свипы. Свип с подтверждениями не
+// меньше порога сети актива подтверждается вместе с депозитами. Откатившийся
+// или пропавший дольше DropTimeout свип помечается выпавшим, а его депозиты
+// возвращаются в план.
+func (s *Sweeper) reconcile(ctx context.Context) error {
+	var sweeps []models.Sweep
+	if err := s.db.Where("network = ? AND status = ?", s.chain, models.SweepStatusSent).
+		Order("created_at").Find(&sweeps).Error; err != nil {
+		return err
+	}
+	for _, sweep := range sweeps {
+		confs, err := s.backend.Confirmations(ctx, sweep.TxID)
+		status := models.SweepStatusSent
+		switch {
+		case errors.Is(err, ErrTxReverted):
+			status = models.SweepStatusDropped
+		case errors.Is(err, ErrTxNotFound):
+			if time.Since(sweep.CreatedAt) < s.opts.DropTimeout {
+				continue
+			}
+			status = models.SweepStatusDropped
+		case err != nil:
+			log.Printf("свип %s %s: не удалось проверить транзакцию %s: %v", s.chain, sweep.ID, sweep.TxID, err)
+			continue
+		default:
+			var network models.AssetNetwork
+			if err := s.db.Where("asset_id = ? AND chain = ?", sweep.AssetID, s.chain).Limit(1).Find(&network).Error; err != nil {
+				return err
+			}
+			if confs < int64(max(network.Confirmations, 1)) {
+				continue
+			}
+			status = models.SweepStatusConfirmed
+		}
+		if err := s.db.Transaction(func(db *gorm.DB) error {
+			now := time.Now()
+			updates := map[string]any{"status": status}
+			deposits := db.Model(&models.TransactionIn{}).Where("sweep_id = ?", sweep.ID)
+			if status == models.SweepStatusConfirmed {
+				updates["confirmed_at"] = now
+				if err := deposits.Update("swept_at", now).Error; err != nil {
+					return err
+				}
+			} else {
+				updates["error"] = "transaction dropped from network"
+				if err := deposits.Update("sweep_id", nil).Error; err != nil {
+					return err
+				}
+			}
+			return db.Model(&models.Sweep{}).Where("id = ? AND status = ?", sweep.ID, models.SweepStatusSent).Updates(updates).Error
+		}); err != nil {
+			return err
+		}
+		if status == models.SweepStatusDropped {
+			log.Printf("свип %s %s выпал из сети, транзакция %s", s.chain, sweep.ID, sweep.TxID)
+		}
+	}
+	return nil
+}
+
+// Start запускает свип по расписанию в отдельной горутине до отмены ctx.
+func (s *Sweeper) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.RunOnce(ctx); err != nil {
+				log.Printf("ошибка свипа %s: %v", s.chain, err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// deposit — подтверждённый, ещё не переведённый депозит.
+type deposit struct {
+	ID              string
+	WalletID        string
+	AssetID         string
+	Address         string
+	DerivationIndex uint32
+	Amount          decimal.Decimal
+	Data            datatypes.JSON
+}
+
+// pendingDeposits возвращает подтверждённые депозиты сети, которые ещё не
+// переведены на горячий кошелёк.
+func (s *Sweeper) pendingDeposits() ([]deposit, error) {
+	var deps []deposit
+	err := s.db.Table("transaction_ins").
+		Select("transaction_ins.id, transaction_ins.wallet_id, transaction_ins.asset_id, wallets.value AS address, wallets.derivation_index, transaction_ins.amount, transaction_ins.data").
+		Joins("JOIN wallets ON wallets.id = transaction_ins.wallet_id").
+		Where("transaction_ins.network = ? AND transaction_ins.status = ? AND transaction_ins.sweep_id IS NULL", s.chain, models.TransactionInStatusConfirmed).
+		Order("transaction_ins.created_at").
+		Scan(&deps).Error
+	return deps, err
+}
+
+// thresholds возвращает пороги свипа активов в сети; активы с нулевым
+// порогом не переводятся.
+func (s *Sweeper) thresholds() (map[string]models.AssetNetwork, error) {
+	var networks []models.AssetNetwork
+	if err := s.db.Where("chain = ? AND sweep_threshold > 0", s.chain).Find(&networks).Error; err != nil {
+		return nil, err
+	}
+	res := make(map[string]models.AssetNetwork, len(networks))
+	for _, n := range networks {
+		res[n.AssetID] = n
+	}
+	return res, nil
+}
+
+func isEVM(chain string) bool {
+	return chains.AddressScope(chain) == "evm"
+}
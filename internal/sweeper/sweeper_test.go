@@ -0,0 +1,242 @@
+package sweeper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+)
+
+type fakeBackend struct {
+	rate      decimal.Decimal
+	balances  map[string]decimal.Decimal
+	nonces    map[string]uint64
+	broadcast int
+	// confs — подтверждения отправленных транзакций; ошибка задаёт
+	// выпавшую транзакцию
+	confs   map[string]int64
+	txError error
+}
+
+func (b *fakeBackend) FeeRate(ctx context.Context) (decimal.Decimal, error) { return b.rate, nil }
+
+func (b *fakeBackend) Balance(ctx context.Context, address string) (decimal.Decimal, error) {
+	return b.balances[address], nil
+}
+
+func (b *fakeBackend) Nonce(ctx context.Context, address string) (uint64, error) {
+	return b.nonces[address], nil
+}
+
+func (b *fakeBackend) Confirmations(ctx context.Context, txID string) (int64, error) {
+	if b.txError != nil {
+		return 0, b.txError
+	}
+	return b.confs[txID], nil
+}
+
+func (b *fakeBackend) Broadcast(ctx context.Context, raw []byte) (string, error) {
+	b.broadcast++
+	return "tx-" + string(raw), nil
+}
+
+type fakeSigner struct{ signed []Tx }
+
+func (s *fakeSigner) Sign(ctx context.Context, tx Tx) ([]byte, error) {
+	s.signed = append(s.signed, tx)
+	if tx.Amount.IsNegative() {
+		return nil, errors.New("negative amount")
+	}
+	return []byte(tx.Kind), nil
+}
+
+func setupDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Sweep{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func addDeposit(t *testing.T, db *gorm.DB, w models.Wallet, amount, txid string, vout int) {
+	t.Helper()
+	data, _ := json.Marshal(map[string]any{"txid": txid, "vout": vout})
+	tx := models.TransactionIn{ClientID: w.ClientID, WalletID: w.ID, AssetID: w.AssetID, Amount: decimal.RequireFromString(amount),
+		Status: models.TransactionInStatusConfirmed, Network: w.Network, Data: datatypes.JSON(data)}
+	if err := db.Create(&tx).Error; err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+}
+
+func TestSweepUTXO(t *testing.T) {
+	db := setupDB(t, "sweep_utxo")
+	asset := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, Chain: "btc"}
+	db.Create(&asset)
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: "btc", Decimals: 8, SweepThreshold: decimal.RequireFromString("0.001")})
+	rich := models.Wallet{ClientID: "c1", AssetID: asset.ID, Network: "btc", Value: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", DerivationIndex: 0}
+	poor := models.Wallet{ClientID: "c2", AssetID: asset.ID, Network: "btc", Value: "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", DerivationIndex: 1}
+	db.Create(&rich)
+	db.Create(&poor)
+	addDeposit(t, db, rich, "0.0006", "aa", 0)
+	addDeposit(t, db, rich, "0.0005", "bb", 1)
+	// выход дешевле собственной траты при 10 sat/vB остаётся на адресе
+	addDeposit(t, db, rich, "0.000005", "cc", 0)
+	// адрес ниже порога не переводится
+	addDeposit(t, db, poor, "0.0002", "dd", 0)
+
+	backend := &fakeBackend{rate: decimal.NewFromInt(10)}
+	dry, err := New(db, "btc", backend, nil, Options{HotWallet: "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g", DryRun: true, MaxInputs: 1})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	plan, err := dry.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(plan) != 2 || len(plan[0].Inputs) != 1 || plan[0].Inputs[0].TxID != "aa" || plan[1].Inputs[0].TxID != "bb" {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	// 11 + 43 + 68 vB по 10 sat/vB
+	if !plan[0].Fee.Equal(decimal.RequireFromString("0.0000122")) || !plan[0].Amount.Equal(decimal.RequireFromString("0.0005878")) {
+		t.Fatalf("fee %s amount %s", plan[0].Fee, plan[0].Amount)
+	}
+	var count int64
+	db.Model(&models.Sweep{}).Count(&count)
+	if count != 0 || backend.broadcast != 0 {
+		t.Fatalf("dry run must not send: %d sweeps, %d broadcasts", count, backend.broadcast)
+	}
+
+	signer := &fakeSigner{}
+	live, _ := New(db, "btc", backend, signer, Options{HotWallet: "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g", MaxInputs: 10})
+	plan, err = live.RunOnce(context.Background())
+	if err != nil || len(plan) != 1 || len(plan[0].Inputs) != 2 {
+		t.Fatalf("live plan %+v: %v", plan, err)
+	}
+	var sweep models.Sweep
+	if err := db.First(&sweep).Error; err != nil || sweep.Status != models.SweepStatusSent || sweep.TxID == "" {
+		t.Fatalf("sweep %+v: %v", sweep, err)
+	}
+	db.Model(&models.TransactionIn{}).Where("sweep_id = ? AND swept_at IS NULL", sweep.ID).Count(&count)
+	if count != 2 {
+		t.Fatalf("expected 2 pending swept deposits, got %d", count)
+	}
+	// до подтверждения депозиты не планируются повторно и не считаются переведёнными
+	if plan, _ = live.RunOnce(context.Background()); len(plan) != 0 {
+		t.Fatalf("swept deposits planned again: %+v", plan)
+	}
+	db.First(&sweep, "id = ?", sweep.ID)
+	if sweep.Status != models.SweepStatusSent {
+		t.Fatalf("unconfirmed sweep %s", sweep.Status)
+	}
+
+	backend.confs = map[string]int64{sweep.TxID: 1}
+	if plan, _ = live.RunOnce(context.Background()); len(plan) != 0 {
+		t.Fatalf("confirmed deposits planned again: %+v", plan)
+	}
+	db.First(&sweep, "id = ?", sweep.ID)
+	db.Model(&models.TransactionIn{}).Where("sweep_id = ? AND swept_at IS NOT NULL", sweep.ID).Count(&count)
+	if sweep.Status != models.SweepStatusConfirmed || sweep.ConfirmedAt == nil || count != 2 {
+		t.Fatalf("sweep %s confirmed deposits %d", sweep.Status, count)
+	}
+}
+
+func TestSweepDropped(t *testing.T) {
+	db := setupDB(t, "sweep_dropped")
+	asset := models.Asset{Name: "BTC", Type: models.AssetTypeCrypto, Chain: "btc"}
+	db.Create(&asset)
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: "btc", Decimals: 8, Confirmations: 2, SweepThreshold: decimal.RequireFromString("0.001")})
+	w := models.Wallet{ClientID: "c1", AssetID: asset.ID, Network: "btc", Value: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"}
+	db.Create(&w)
+	addDeposit(t, db, w, "0.002", "aa", 0)
+
+	backend := &fakeBackend{rate: decimal.NewFromInt(10), confs: map[string]int64{}}
+	s, _ := New(db, "btc", backend, &fakeSigner{}, Options{HotWallet: "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g", DropTimeout: time.Minute})
+	if plan, err := s.RunOnce(context.Background()); err != nil || len(plan) != 1 {
+		t.Fatalf("plan %+v: %v", plan, err)
+	}
+	var first models.Sweep
+	db.First(&first)
+	// одного подтверждения меньше порога сети
+	backend.confs[first.TxID] = 1
+	if plan, _ := s.RunOnce(context.Background()); len(plan) != 0 {
+		t.Fatalf("pending sweep planned again: %+v", plan)
+	}
+	// пропавшая транзакция ждёт DropTimeout
+	backend.txError = ErrTxNotFound
+	if plan, _ := s.RunOnce(context.Background()); len(plan) != 0 {
+		t.Fatalf("missing sweep dropped before timeout: %+v", plan)
+	}
+	db.Model(&models.Sweep{}).Where("id = ?", first.ID).Update("created_at", time.Now().Add(-time.Hour))
+	plan, err := s.RunOnce(context.Background())
+	if err != nil || len(plan) != 1 {
+		t.Fatalf("dropped deposits not planned again %+v: %v", plan, err)
+	}
+	db.First(&first, "id = ?", first.ID)
+	var count int64
+	db.Model(&models.Sweep{}).Where("status = ?", models.SweepStatusSent).Count(&count)
+	if first.Status != models.SweepStatusDropped || count != 1 {
+		t.Fatalf("sweep %s, pending %d", first.Status, count)
+	}
+}
+
+func TestSweepERC20TopUp(t *testing.T) {
+	db := setupDB(t, "sweep_erc20")
+	asset := models.Asset{Name: "USDT", Type: models.AssetTypeCrypto, Chain: "eth"}
+	db.Create(&asset)
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: "eth", Contract: "0xdAC17F958D2ee523a2206206994597C13D831ec7", Decimals: 6, SweepThreshold: decimal.NewFromInt(10)})
+	addr := "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+	w := models.Wallet{ClientID: "c1", AssetID: asset.ID, Network: "eth", Value: addr}
+	db.Create(&w)
+	addDeposit(t, db, w, "50", "0xab", 0)
+
+	hot := "0x000000000000000000000000000000000000dEaD"
+	backend := &fakeBackend{
+		rate:     decimal.NewFromInt(20_000_000_000),
+		balances: map[string]decimal.Decimal{},
+		nonces:   map[string]uint64{hot: 7, addr: 3},
+	}
+	signer := &fakeSigner{}
+	s, err := New(db, "eth", backend, signer, Options{HotWallet: hot})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	// на адресе нет газа: сначала пополнение с горячего кошелька
+	plan, err := s.RunOnce(context.Background())
+	if err != nil || len(plan) != 1 || plan[0].Kind != models.SweepKindTopUp || plan[0].To != addr || plan[0].Nonce != 7 {
+		t.Fatalf("top-up plan %+v: %v", plan, err)
+	}
+	// 65000 газа по 20 gwei с запасом 20%
+	if !plan[0].Amount.Equal(decimal.RequireFromString("0.00156")) {
+		t.Fatalf("top-up amount %s", plan[0].Amount)
+	}
+	// пока пополнение не подтверждено, адрес повторно не пополняется
+	if plan, _ = s.RunOnce(context.Background()); len(plan) != 0 {
+		t.Fatalf("repeated top-up %+v", plan)
+	}
+
+	backend.balances[addr] = decimal.RequireFromString("0.00156")
+	plan, err = s.RunOnce(context.Background())
+	if err != nil || len(plan) != 1 || plan[0].Kind != models.SweepKindSweep || plan[0].Contract == "" || plan[0].Nonce != 3 {
+		t.Fatalf("token plan %+v: %v", plan, err)
+	}
+	if !plan[0].Amount.Equal(decimal.NewFromInt(50)) || plan[0].To != hot {
+		t.Fatalf("token sweep %+v", plan[0])
+	}
+	var count int64
+	db.Model(&models.TransactionIn{}).Where("sweep_id IS NOT NULL").Count(&count)
+	if count != 1 || len(signer.signed) != 2 {
+		t.Fatalf("swept %d, signed %d", count, len(signer.signed))
+	}
+}
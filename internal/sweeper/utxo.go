@@ -0,0 +1,139 @@
+package sweeper
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/shopspring/decimal"
+
+	"ptop/internal/btcwatcher"
+	"ptop/internal/chains"
+	"ptop/internal/models"
+)
+
+var utxoChains = map[string]struct{}{
+	chains.ChainBTC:  {},
+	chains.ChainLTC:  {},
+	chains.ChainDOGE: {},
+	chains.ChainBCH:  {},
+}
+
+// Размеры транзакции в виртуальных байтах: служебные поля и выход на
+// горячий кошелёк (с запасом под P2TR).
+const (
+	txOverheadVSize = 11
+	outputVSize     = 43
+	// dustSats — меньшие выходы узлы не ретранслируют.
+	dustSats = 546
+)
+
+var satsPerCoin = decimal.New(1, 8)
+
+// inputVSize оценивает размер входа, тратящего выход на адрес addr.
+func inputVSize(addr string, params *chaincfg.Params) int64 {
+	if hrp := params.Bech32HRPSegwit; hrp != "" {
+		switch lower := strings.ToLower(addr); {
+		case strings.HasPrefix(lower, hrp+"1p"):
+			return 58
+		case strings.HasPrefix(lower, hrp+"1q"):
+			return 68
+		}
+	}
+	return 148
+}
+
+// planUTXO собирает выходы депозитов с адресов, где накопилось не меньше
+// порога, и объединяет их в транзакции по MaxInputs входов. Выходы, трата
+// которых стоит дороже их суммы при текущей ставке, остаются до снижения
+// комиссий.
+func (s *Sweeper) planUTXO(ctx context.Context) ([]Tx, error) {
+	limits, err := s.thresholds()
+	if err != nil || len(limits) == 0 {
+		return nil, err
+	}
+	deps, err := s.pendingDeposits()
+	if err != nil || len(deps) == 0 {
+		return nil, err
+	}
+	rate, err := s.backend.FeeRate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params, err := btcwatcher.Params(s.chain, chains.Mode())
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]decimal.Decimal)
+	for _, d := range deps {
+		key := d.AssetID + "/" + d.Address
+		totals[key] = totals[key].Add(d.Amount)
+	}
+	inputs := make(map[string][]deposit)
+	var assets []string
+	for _, d := range deps {
+		limit, ok := limits[d.AssetID]
+		if !ok || totals[d.AssetID+"/"+d.Address].LessThan(limit.SweepThreshold) {
+			continue
+		}
+		cost := rate.Mul(decimal.NewFromInt(inputVSize(d.Address, params))).Div(satsPerCoin)
+		if d.Amount.LessThanOrEqual(cost) {
+			continue
+		}
+		if _, ok := inputs[d.AssetID]; !ok {
+			assets = append(assets, d.AssetID)
+		}
+		inputs[d.AssetID] = append(inputs[d.AssetID], d)
+	}
+
+	var plan []Tx
+	for _, assetID := range assets {
+		list := inputs[assetID]
+		for start := 0; start < len(list); start += s.opts.MaxInputs {
+			end := min(start+s.opts.MaxInputs, len(list))
+			tx, ok := s.utxoTx(assetID, list[start:end], rate, params)
+			if ok {
+				plan = append(plan, tx)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// utxoTx составляет транзакцию из выходов batch с комиссией по ставке rate.
+func (s *Sweeper) utxoTx(assetID string, batch []deposit, rate decimal.Decimal, params *chaincfg.Params) (Tx, bool) {
+	tx := Tx{Chain: s.chain, AssetID: assetID, Kind: models.SweepKindSweep, To: s.opts.HotWallet, FeeRate: rate}
+	vsize := int64(txOverheadVSize + outputVSize)
+	total := decimal.Zero
+	for _, d := range batch {
+		var data struct {
+			TxID string `json:"txid"`
+			Vout int    `json:"vout"`
+		}
+		if err := json.Unmarshal(d.Data, &data); err != nil || data.TxID == "" {
+			log.Printf("депозит %s без выхода для свипа", d.ID)
+			continue
+		}
+		tx.Inputs = append(tx.Inputs, Input{
+			WalletID:        d.WalletID,
+			Address:         d.Address,
+			DerivationIndex: d.DerivationIndex,
+			TxID:            data.TxID,
+			Vout:            data.Vout,
+			Amount:          d.Amount,
+		})
+		tx.depositIDs = append(tx.depositIDs, d.ID)
+		vsize += inputVSize(d.Address, params)
+		total = total.Add(d.Amount)
+	}
+	sats := rate.Mul(decimal.NewFromInt(vsize)).Ceil()
+	tx.Fee = sats.Div(satsPerCoin)
+	tx.Amount = total.Sub(tx.Fee)
+	if len(tx.Inputs) == 0 || tx.Amount.Mul(satsPerCoin).LessThan(decimal.NewFromInt(dustSats)) {
+		return Tx{}, false
+	}
+	return tx, true
+}
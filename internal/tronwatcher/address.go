@@ -0,0 +1,53 @@
+package tronwatcher
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+// addressPrefix — версия адресов основной сети TRON.
+const addressPrefix = 0x41
+
+// EncodeAddress кодирует 20 байт адреса в base58check-адрес TRON (T...).
+func EncodeAddress(addr []byte) string {
+	return base58.CheckEncode(addr, addressPrefix)
+}
+
+// DecodeAddress возвращает 20 байт адреса из base58check-адреса TRON.
+func DecodeAddress(address string) ([]byte, error) {
+	payload, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tron address %q: %w", address, err)
+	}
+	if version != addressPrefix || len(payload) != 20 {
+		return nil, fmt.Errorf("invalid tron address %q", address)
+	}
+	return payload, nil
+}
+
+// hexAddress приводит адрес из ответа узла (41..., 0x... или T...) к 20
+// байтам в нижнем hex без префикса.
+func hexAddress(s string) (string, error) {
+	if strings.HasPrefix(s, "T") {
+		b, err := DecodeAddress(s)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b), nil
+	}
+	s = strings.ToLower(strings.TrimPrefix(s, "0x"))
+	if len(s) == 42 && strings.HasPrefix(s, "41") {
+		s = s[2:]
+	}
+	if len(s) == 64 {
+		// topic: адрес дополнен нулями слева до 32 байт
+		s = s[24:]
+	}
+	if _, err := hex.DecodeString(s); err != nil || len(s) != 40 {
+		return "", fmt.Errorf("invalid hex address %q", s)
+	}
+	return s, nil
+}
@@ -0,0 +1,346 @@
+package tronwatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"ptop/internal/deposits"
+	"ptop/internal/models"
+)
+
+// chainName — значение assets.chain для активов TRON.
+const chainName = "tron"
+
+// transferTopic — keccak256("Transfer(address,address,uint256)") без 0x.
+const transferTopic = "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// tokenInfo содержит информацию о токене TRC20.
+type tokenInfo struct {
+	contract string
+	assetID  string
+	decimals int32
+}
+
+// Watcher опрашивает TronGrid-совместимый HTTP API и зачисляет переводы
+// TRC20 на кошельки клиентов.
+type Watcher struct {
+	apiURL       string
+	apiKey       string
+	http         *http.Client
+	db           *gorm.DB
+	pollInterval time.Duration
+	tokens       map[string]*tokenInfo
+	debug        bool
+	debugCh      chan debugDeposit
+	debugOnce    sync.Once
+
+	mu      sync.Mutex
+	height  uint64
+	tip     uint64
+	blockAt time.Time
+}
+
+type debugDeposit struct {
+	walletID string
+	amount   decimal.Decimal
+}
+
+// New создаёт новый наблюдатель.
+func New(db *gorm.DB, apiURL, apiKey string, interval time.Duration, debug bool) (*Watcher, error) {
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	w := &Watcher{
+		db:           db,
+		apiURL:       strings.TrimRight(apiURL, "/"),
+		apiKey:       apiKey,
+		http:         &http.Client{Timeout: 15 * time.Second},
+		pollInterval: interval,
+		debug:        debug,
+	}
+	if debug {
+		w.debugCh = make(chan debugDeposit)
+		return w, nil
+	}
+	if apiURL == "" {
+		return nil, fmt.Errorf("tron api url required")
+	}
+	return w, nil
+}
+
+// Start запускает наблюдатель в фоне без перезапусков при ошибках.
+func (w *Watcher) Start() error {
+	if w.debug {
+		w.startDebug()
+		return nil
+	}
+	go func() {
+		if err := w.Run(context.Background()); err != nil {
+			log.Printf("tron watcher: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Run опрашивает узел и обрабатывает новые блоки, пока запрос не завершится
+// ошибкой или не будет отменён ctx.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.debug {
+		w.startDebug()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tokens, err := loadTokens(w.db)
+	if err != nil {
+		return fmt.Errorf("load tokens: %w", err)
+	}
+	if len(tokens) == 0 {
+		return errors.New("no trc20 tokens configured")
+	}
+	w.tokens = tokens
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := w.poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Progress возвращает номер последнего обработанного блока, вершину сети и
+// время последнего блока.
+func (w *Watcher) Progress() (height, tip uint64, blockAt time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.height, w.tip, w.blockAt
+}
+
+// loadTokens загружает контракты TRC20 из настроек сетей активов. Ключ —
+// адрес контракта в hex без префикса 41.
+func loadTokens(db *gorm.DB) (map[string]*tokenInfo, error) {
+	var networks []models.AssetNetwork
+	if err := db.Where("chain = ? AND contract <> ''", chainName).Find(&networks).Error; err != nil {
+		return nil, err
+	}
+	tokens := make(map[string]*tokenInfo, len(networks))
+	for _, n := range networks {
+		addr, err := hexAddress(n.Contract)
+		if err != nil {
+			log.Printf("некорректный контракт %q актива %s", n.Contract, n.AssetID)
+			continue
+		}
+		tokens[addr] = &tokenInfo{contract: n.Contract, assetID: n.AssetID, decimals: n.Decimals}
+	}
+	return tokens, nil
+}
+
+func (w *Watcher) startDebug() {
+	w.debugOnce.Do(func() { go w.debugLoop() })
+}
+
+func (w *Watcher) debugLoop() {
+	for dep := range w.debugCh {
+		w.createDebugDeposit(dep.walletID, dep.amount)
+	}
+}
+
+// TriggerDeposit отправляет фейковый депозит для тестов.
+func (w *Watcher) TriggerDeposit(walletID string, amount decimal.Decimal) {
+	if w.debug {
+		w.debugCh <- debugDeposit{walletID: walletID, amount: amount}
+	}
+}
+
+func (w *Watcher) createDebugDeposit(walletID string, amount decimal.Decimal) {
+	var wallet models.Wallet
+	if err := w.db.First(&wallet, "id = ?", walletID).Error; err != nil {
+		log.Printf("ошибка поиска кошелька: %v", err)
+		return
+	}
+	dep := deposits.Deposit{Chain: chainName, Wallet: wallet, Amount: amount, Data: map[string]any{"debug": true}}
+	if _, err := deposits.Record(w.db, dep, 0); err != nil {
+		log.Printf("не удалось сохранить депозит: %v", err)
+	}
+}
+
+type nowBlock struct {
+	BlockHeader struct {
+		RawData struct {
+			Number    uint64 `json:"number"`
+			Timestamp int64  `json:"timestamp"`
+		} `json:"raw_data"`
+	} `json:"block_header"`
+}
+
+type txInfo struct {
+	ID             string  `json:"id"`
+	BlockNumber    uint64  `json:"blockNumber"`
+	BlockTimeStamp int64   `json:"blockTimeStamp"`
+	Log            []txLog `json:"log"`
+	Receipt        struct {
+		Result string `json:"result"`
+	} `json:"receipt"`
+}
+
+type txLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+// poll обрабатывает все блоки между последним обработанным и вершиной сети.
+// После перезапуска обработка продолжается с сохранённого в базе блока, при
+// самом первом запуске — с текущей вершины.
+func (w *Watcher) poll(ctx context.Context) error {
+	var now nowBlock
+	if err := w.call(ctx, "/wallet/getnowblock", struct{}{}, &now); err != nil {
+		return fmt.Errorf("getnowblock: %w", err)
+	}
+	tip := now.BlockHeader.RawData.Number
+	w.mu.Lock()
+	w.tip = tip
+	height := w.height
+	w.mu.Unlock()
+	if height == 0 {
+		saved, ok, err := deposits.Cursor(w.db, chainName)
+		if err != nil {
+			return fmt.Errorf("load cursor: %w", err)
+		}
+		switch {
+		case ok:
+			height = saved
+		case tip > 0:
+			height = tip - 1
+		}
+		w.mu.Lock()
+		w.height = height
+		w.mu.Unlock()
+	}
+	for h := height + 1; h <= tip; h++ {
+		var infos []txInfo
+		if err := w.call(ctx, "/wallet/gettransactioninfobyblocknum", map[string]uint64{"num": h}, &infos); err != nil {
+			return fmt.Errorf("gettransactioninfobyblocknum %d: %w", h, err)
+		}
+		blockAt := time.Now()
+		if h == tip && now.BlockHeader.RawData.Timestamp > 0 {
+			blockAt = time.UnixMilli(now.BlockHeader.RawData.Timestamp)
+		}
+		for _, info := range infos {
+			if info.BlockTimeStamp > 0 {
+				blockAt = time.UnixMilli(info.BlockTimeStamp)
+			}
+			w.processTx(info, h, tip)
+		}
+		if err := deposits.SaveCursor(w.db, chainName, h); err != nil {
+			return fmt.Errorf("save cursor: %w", err)
+		}
+		w.mu.Lock()
+		w.height = h
+		w.blockAt = blockAt
+		w.mu.Unlock()
+	}
+	if err := deposits.ConfirmPending(w.db, chainName, tip); err != nil {
+		log.Printf("не удалось подтвердить депозиты: %v", err)
+	}
+	return nil
+}
+
+// call выполняет POST-запрос к HTTP API узла.
+func (w *Watcher) call(ctx context.Context, path string, body, out any) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.apiURL+path, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.apiKey != "" {
+		req.Header.Set("TRON-PRO-API-KEY", w.apiKey)
+	}
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// processTx зачисляет переводы TRC20 из событий Transfer транзакции.
+func (w *Watcher) processTx(info txInfo, blockNumber, tip uint64) {
+	if info.Receipt.Result != "" && info.Receipt.Result != "SUCCESS" {
+		return
+	}
+	for i, l := range info.Log {
+		contract, err := hexAddress(l.Address)
+		if err != nil {
+			continue
+		}
+		token, ok := w.tokens[contract]
+		if !ok || len(l.Topics) < 3 || strings.ToLower(strings.TrimPrefix(l.Topics[0], "0x")) != transferTopic {
+			continue
+		}
+		w.processLog(info.ID, i, l, token, blockNumber, tip)
+	}
+}
+
+func (w *Watcher) processLog(txID string, index int, l txLog, token *tokenInfo, blockNumber, tip uint64) {
+	toHex, err := hexAddress(l.Topics[2])
+	if err != nil {
+		return
+	}
+	toBytes, _ := hex.DecodeString(toHex)
+	to := EncodeAddress(toBytes)
+	var wallet models.Wallet
+	if err := w.db.Where("value = ? AND asset_id = ? AND network = ?", to, token.assetID, chainName).First(&wallet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		log.Printf("ошибка базы данных: %v", err)
+		return
+	}
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(l.Data, "0x"), 16)
+	if !ok {
+		log.Printf("некорректная сумма перевода %s: %q", txID, l.Data)
+		return
+	}
+	dep := deposits.Deposit{
+		Chain:       chainName,
+		Wallet:      wallet,
+		Amount:      decimal.NewFromBigInt(value, -token.decimals),
+		BlockHeight: blockNumber,
+		TxHash:      txID,
+		LogIndex:    index,
+		Data: map[string]any{
+			"tx_hash":      txID,
+			"log_index":    index,
+			"block_number": blockNumber,
+			"token":        token.contract,
+		},
+	}
+	if _, err := deposits.Record(w.db, dep, tip); err != nil && !errors.Is(err, deposits.ErrDuplicate) {
+		log.Printf("не удалось сохранить депозит: %v", err)
+	}
+}
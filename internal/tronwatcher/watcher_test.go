@@ -0,0 +1,204 @@
+package tronwatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"ptop/internal/models"
+)
+
+const usdtContract = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+
+func setupDB(t *testing.T) (*gorm.DB, models.Wallet) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.WatcherCursor{}, &models.Balance{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	client := models.Client{Username: "u"}
+	db.Create(&client)
+	asset := models.Asset{Name: "USDT", Type: models.AssetTypeCrypto, Chain: chainName}
+	db.Create(&asset)
+	db.Create(&models.AssetNetwork{AssetID: asset.ID, Chain: chainName, Contract: usdtContract, Decimals: 6, Confirmations: 3})
+	wallet := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Network: chainName, Value: EncodeAddress(bytes.Repeat([]byte{0x11}, 20)), DerivationIndex: 1}
+	db.Create(&wallet)
+	db.Create(&models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero})
+	return db, wallet
+}
+
+// fakeNode имитирует HTTP API TronGrid: вершина сети задаётся tip, в блоке
+// 100 есть перевод USDT на кошелёк и перевод неизвестного токена.
+func fakeNode(t *testing.T, tip *atomic.Uint64) *httptest.Server {
+	to := strings.Repeat("0", 24) + strings.Repeat("11", 20)
+	from := strings.Repeat("0", 24) + strings.Repeat("22", 20)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("TRON-PRO-API-KEY") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/wallet/getnowblock":
+			fmt.Fprintf(w, `{"block_header":{"raw_data":{"number":%d,"timestamp":1700000000000}}}`, tip.Load())
+		case "/wallet/gettransactioninfobyblocknum":
+			var req struct {
+				Num uint64 `json:"num"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Num != 100 {
+				w.Write([]byte(`[]`))
+				return
+			}
+			fmt.Fprintf(w, `[{"id":"tx1","blockNumber":100,"blockTimeStamp":1699999990000,"receipt":{"result":"SUCCESS"},"log":[
+				{"address":"a614f803b6fd780986a42c78ec9c7f77e6ded13c","topics":["%[1]s","%[2]s","%[3]s"],"data":"%064x"},
+				{"address":"%[5]s","topics":["%[1]s","%[2]s","%[3]s"],"data":"%064[4]x"}]}]`,
+				transferTopic, from, to, 2500000, strings.Repeat("33", 20))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestWatcherCreditsTransfers(t *testing.T) {
+	db, wallet := setupDB(t)
+	var tip atomic.Uint64
+	tip.Store(100)
+	srv := fakeNode(t, &tip)
+	defer srv.Close()
+
+	w, err := New(db, srv.URL, "key", time.Second, false)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	if w.tokens, err = loadTokens(db); err != nil || len(w.tokens) != 1 {
+		t.Fatalf("tokens %v: %v", w.tokens, err)
+	}
+	ctx := context.Background()
+	if err := w.poll(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	var tx models.TransactionIn
+	if err := db.First(&tx).Error; err != nil {
+		t.Fatalf("tx: %v", err)
+	}
+	if tx.Status != models.TransactionInStatusProcessing || !tx.Amount.Equal(decimal.RequireFromString("2.5")) || tx.Network != chainName || tx.BlockHeight != 100 {
+		t.Fatalf("unexpected tx %+v", tx)
+	}
+
+	tip.Store(102)
+	if err := w.poll(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	var bal models.Balance
+	db.Where("client_id = ? AND asset_id = ?", wallet.ClientID, wallet.AssetID).First(&bal)
+	if !bal.Amount.Equal(decimal.RequireFromString("2.5")) {
+		t.Fatalf("balance %s", bal.Amount)
+	}
+	if height, top, _ := w.Progress(); height != 102 || top != 102 {
+		t.Fatalf("progress %d/%d", height, top)
+	}
+
+	// повторная обработка блока не создаёт второй депозит
+	w.height = 99
+	if err := w.poll(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	var count int64
+	db.Model(&models.TransactionIn{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 deposit, got %d", count)
+	}
+}
+
+// Блоки, добытые во время простоя, обрабатываются после перезапуска с
+// сохранённого блока.
+func TestWatcherResumesFromCursor(t *testing.T) {
+	db, wallet := setupDB(t)
+	var tip atomic.Uint64
+	tip.Store(99)
+	srv := fakeNode(t, &tip)
+	defer srv.Close()
+
+	start := func() *Watcher {
+		w, err := New(db, srv.URL, "key", time.Second, false)
+		if err != nil {
+			t.Fatalf("watcher: %v", err)
+		}
+		if w.tokens, err = loadTokens(db); err != nil {
+			t.Fatalf("tokens: %v", err)
+		}
+		return w
+	}
+	ctx := context.Background()
+	if err := start().poll(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	tip.Store(102)
+	w := start()
+	if err := w.poll(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	var bal models.Balance
+	db.Where("client_id = ? AND asset_id = ?", wallet.ClientID, wallet.AssetID).First(&bal)
+	if !bal.Amount.Equal(decimal.RequireFromString("2.5")) {
+		t.Fatalf("deposit during downtime not credited: balance %s", bal.Amount)
+	}
+	if height, _, _ := w.Progress(); height != 102 {
+		t.Fatalf("height %d", height)
+	}
+	var cursor models.WatcherCursor
+	db.First(&cursor, "network = ?", chainName)
+	if cursor.Height != 102 {
+		t.Fatalf("cursor %d", cursor.Height)
+	}
+}
+
+func TestWatcherRunRequiresTokens(t *testing.T) {
+	db, _ := setupDB(t)
+	db.Where("1 = 1").Delete(&models.AssetNetwork{})
+	if _, err := New(db, "", "", 0, false); err == nil {
+		t.Fatalf("expected error without api url")
+	}
+	w, err := New(db, "http://127.0.0.1:1", "", time.Second, false)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	if err := w.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "no trc20 tokens") {
+		t.Fatalf("expected no tokens error, got %v", err)
+	}
+}
+
+func TestWatcherDebugDeposit(t *testing.T) {
+	db, wallet := setupDB(t)
+	w, err := New(db, "", "", 0, true)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	w.TriggerDeposit(wallet.ID, decimal.RequireFromString("3"))
+	time.Sleep(50 * time.Millisecond)
+	var tx models.TransactionIn
+	if err := db.First(&tx).Error; err != nil {
+		t.Fatalf("tx: %v", err)
+	}
+	if !tx.Amount.Equal(decimal.RequireFromString("3")) || tx.Status != models.TransactionInStatusConfirmed {
+		t.Fatalf("unexpected tx %+v", tx)
+	}
+}
@@ -0,0 +1,27 @@
+package watchers
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"ptop/config"
+	"ptop/internal/chains"
+)
+
+// Build создаёт Supervisor с наблюдателями сетей, включёнными в конфиге.
+func Build(db *gorm.DB, cfg *config.Config) (*Supervisor, error) {
+	s := NewSupervisor(cfg.WatcherBackoffMin, cfg.WatcherBackoffMax)
+	for _, name := range cfg.WatchersEnabled {
+		chain, err := chains.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		w, err := chain.NewWatcher(db, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s watcher: %w", name, err)
+		}
+		s.Add(name, w)
+	}
+	return s, nil
+}
@@ -0,0 +1,193 @@
+package watchers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Watcher — наблюдатель одной сети, которым управляет Supervisor.
+type Watcher interface {
+	// Run блокируется до ошибки подписки/RPC или отмены ctx.
+	Run(ctx context.Context) error
+	// Progress возвращает последний обработанный блок, вершину сети и время
+	// последнего блока.
+	Progress() (height, tip uint64, blockAt time.Time)
+}
+
+// ChainStatus описывает состояние наблюдателя сети.
+type ChainStatus struct {
+	Chain       string     `json:"chain"`
+	Running     bool       `json:"running"`
+	Restarts    int        `json:"restarts"`
+	LastError   string     `json:"lastError,omitempty"`
+	Height      uint64     `json:"height"`
+	Tip         uint64     `json:"tip"`
+	Lag         uint64     `json:"lag"`
+	LastBlockAt *time.Time `json:"lastBlockAt,omitempty"`
+}
+
+type chain struct {
+	name     string
+	watcher  Watcher
+	running  bool
+	restarts int
+	lastErr  string
+}
+
+// Supervisor запускает наблюдатели сетей и перезапускает их с
+// экспоненциальной задержкой после ошибок.
+type Supervisor struct {
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu     sync.RWMutex
+	chains []*chain
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewSupervisor(minBackoff, maxBackoff time.Duration) *Supervisor {
+	if minBackoff <= 0 {
+		minBackoff = time.Second
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+	return &Supervisor{minBackoff: minBackoff, maxBackoff: maxBackoff}
+}
+
+// Add регистрирует наблюдатель сети. Вызывается до Start.
+func (s *Supervisor) Add(name string, w Watcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chains = append(s.chains, &chain{name: name, watcher: w})
+}
+
+// Watcher возвращает зарегистрированный наблюдатель сети.
+func (s *Supervisor) Watcher(name string) (Watcher, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, ch := range s.chains {
+		if ch.name == name {
+			return ch.watcher, true
+		}
+	}
+	return nil, false
+}
+
+// Chains возвращает имена сетей в порядке регистрации.
+func (s *Supervisor) Chains() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.chains))
+	for _, ch := range s.chains {
+		names = append(names, ch.name)
+	}
+	return names
+}
+
+// Start запускает все наблюдатели в отдельных горутинах.
+func (s *Supervisor) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancel = cancel
+	chains := append([]*chain(nil), s.chains...)
+	s.mu.Unlock()
+	for _, ch := range chains {
+		s.wg.Add(1)
+		go s.supervise(ctx, ch)
+	}
+}
+
+// Stop останавливает наблюдатели и дожидается их завершения.
+func (s *Supervisor) Stop() {
+	s.mu.RLock()
+	cancel := s.cancel
+	s.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+	s.wg.Wait()
+}
+
+// Status возвращает состояние всех наблюдателей в порядке регистрации.
+func (s *Supervisor) Status() []ChainStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]ChainStatus, 0, len(s.chains))
+	for _, ch := range s.chains {
+		height, tip, blockAt := ch.watcher.Progress()
+		st := ChainStatus{
+			Chain:     ch.name,
+			Running:   ch.running,
+			Restarts:  ch.restarts,
+			LastError: ch.lastErr,
+			Height:    height,
+			Tip:       tip,
+		}
+		if tip > height {
+			st.Lag = tip - height
+		}
+		if !blockAt.IsZero() {
+			t := blockAt
+			st.LastBlockAt = &t
+		}
+		res = append(res, st)
+	}
+	return res
+}
+
+func (s *Supervisor) supervise(ctx context.Context, ch *chain) {
+	defer s.wg.Done()
+	backoff := s.minBackoff
+	for {
+		started := time.Now()
+		s.setRunning(ch, true)
+		err := runSafe(ctx, ch.watcher)
+		s.setRunning(ch, false)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("watcher stopped")
+		}
+		// Долгая успешная работа сбрасывает задержку перезапуска
+		if time.Since(started) > s.maxBackoff {
+			backoff = s.minBackoff
+		}
+		s.mu.Lock()
+		ch.restarts++
+		ch.lastErr = err.Error()
+		s.mu.Unlock()
+		log.Printf("watcher %s: %v, перезапуск через %s", ch.name, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+func (s *Supervisor) setRunning(ch *chain, running bool) {
+	s.mu.Lock()
+	ch.running = running
+	s.mu.Unlock()
+}
+
+// runSafe запускает наблюдатель, превращая панику в ошибку.
+func runSafe(ctx context.Context, w Watcher) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return w.Run(ctx)
+}
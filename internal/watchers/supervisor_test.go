@@ -0,0 +1,80 @@
+package watchers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ptop/internal/chains"
+)
+
+type fakeWatcher struct {
+	mu    sync.Mutex
+	fails int
+	runs  int
+}
+
+func (f *fakeWatcher) Run(ctx context.Context) error {
+	f.mu.Lock()
+	f.runs++
+	fail := f.runs <= f.fails
+	f.mu.Unlock()
+	if fail {
+		return errors.New("subscription dropped")
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (f *fakeWatcher) Progress() (uint64, uint64, time.Time) {
+	return 90, 100, time.Unix(1700000000, 0)
+}
+
+func TestSupervisorRestartsWithBackoff(t *testing.T) {
+	w := &fakeWatcher{fails: 2}
+	s := NewSupervisor(time.Millisecond, 5*time.Millisecond)
+	s.Add(chains.ChainETH, w)
+	s.Start()
+	defer s.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		st := s.Status()[0]
+		if st.Running && st.Restarts == 2 {
+			if st.LastError != "subscription dropped" {
+				t.Fatalf("last error %q", st.LastError)
+			}
+			if st.Lag != 10 || st.LastBlockAt == nil {
+				t.Fatalf("unexpected progress %+v", st)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("watcher not restarted: %+v", st)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisorRecoversPanic(t *testing.T) {
+	s := NewSupervisor(time.Millisecond, time.Millisecond)
+	s.Add(chains.ChainBTC, panicWatcher{})
+	s.Start()
+	defer s.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for s.Status()[0].Restarts == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("panic not recovered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type panicWatcher struct{}
+
+func (panicWatcher) Run(ctx context.Context) error { panic("boom") }
+
+func (panicWatcher) Progress() (uint64, uint64, time.Time) { return 0, 0, time.Time{} }
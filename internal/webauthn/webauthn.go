@@ -0,0 +1,360 @@
+// Package webauthn реализует серверную часть WebAuthn (passkeys): параметры
+// регистрации и входа, проверку аттестации и подписи аутентификатора.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+var (
+	ErrClientData          = errors.New("webauthn: invalid client data")
+	ErrChallenge           = errors.New("webauthn: challenge mismatch")
+	ErrOrigin              = errors.New("webauthn: origin not allowed")
+	ErrRPID                = errors.New("webauthn: rp id hash mismatch")
+	ErrUserPresence        = errors.New("webauthn: user not present")
+	ErrUserVerification    = errors.New("webauthn: user not verified")
+	ErrAuthData            = errors.New("webauthn: invalid authenticator data")
+	ErrUnsupportedAttest   = errors.New("webauthn: unsupported attestation format")
+	ErrAttestation         = errors.New("webauthn: invalid attestation")
+	ErrSignature           = errors.New("webauthn: invalid signature")
+	ErrSignCount           = errors.New("webauthn: signature counter did not increase")
+	ErrUnsupportedAlg      = errors.New("webauthn: unsupported algorithm")
+	errCredentialDataShort = errors.New("webauthn: attested credential data too short")
+)
+
+// Флаги данных аутентификатора.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagBackupElig   = 0x08
+	flagAttested     = 0x40
+	flagExtensions   = 0x80
+)
+
+// Encoding — base64url без дополнения, в котором браузер передаёт бинарные
+// поля WebAuthn.
+var Encoding = base64.RawURLEncoding
+
+// RelyingParty — сервер, для которого регистрируются ключи: ID — домен
+// (RP ID), Origins — допустимые origin фронтенда.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// NewChallenge возвращает случайный вызов для одной церемонии.
+func NewChallenge() ([]byte, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+// User — владелец ключей: ID передаётся аутентификатору как user handle.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+type rpEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credParam struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CredentialDescriptor ссылается на зарегистрированный ключ.
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions — PublicKeyCredentialCreationOptions для
+// navigator.credentials.create; бинарные поля в base64url.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     rpEntity               `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credParam            `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions — PublicKeyCredentialRequestOptions для
+// navigator.credentials.get. Пустой AllowCredentials означает вход по
+// ключу, сохранённому на устройстве (discoverable credential).
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int                    `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// Timeout — время на церемонию в миллисекундах.
+const Timeout = 300000
+
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	res := make([]CredentialDescriptor, len(ids))
+	for i, id := range ids {
+		res[i] = CredentialDescriptor{Type: "public-key", ID: Encoding.EncodeToString(id)}
+	}
+	return res
+}
+
+// CreationOptions возвращает параметры регистрации ключа; exclude —
+// уже зарегистрированные ключи пользователя.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user User, exclude [][]byte) CreationOptions {
+	return CreationOptions{
+		Challenge: Encoding.EncodeToString(challenge),
+		RP:        rpEntity{ID: rp.ID, Name: rp.Name},
+		User:      userEntity{ID: Encoding.EncodeToString(user.ID), Name: user.Name, DisplayName: user.DisplayName},
+		PubKeyCredParams: []credParam{
+			{Type: "public-key", Alg: AlgES256}, {Type: "public-key", Alg: AlgEdDSA}, {Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:                Timeout,
+		ExcludeCredentials:     descriptors(exclude),
+		AuthenticatorSelection: authenticatorSelection{ResidentKey: "preferred", UserVerification: "preferred"},
+		Attestation:            "none",
+	}
+}
+
+// RequestOptions возвращает параметры проверки ключа. userVerification —
+// "required" для входа без пароля, "preferred" для второго фактора.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow [][]byte, userVerification string) RequestOptions {
+	return RequestOptions{
+		Challenge:        Encoding.EncodeToString(challenge),
+		RPID:             rp.ID,
+		Timeout:          Timeout,
+		AllowCredentials: descriptors(allow),
+		UserVerification: userVerification,
+	}
+}
+
+// Credential — зарегистрированный ключ. PublicKey хранится в COSE.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	Alg       int64
+	SignCount uint32
+	AAGUID    string
+	// BackupEligible — ключ может синхронизироваться между устройствами.
+	BackupEligible bool
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// checkClientData проверяет тип церемонии, вызов и origin.
+func (rp *RelyingParty) checkClientData(raw []byte, typ string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil || cd.Type != typ {
+		return ErrClientData
+	}
+	got, err := Encoding.DecodeString(cd.Challenge)
+	if err != nil || !bytes.Equal(got, challenge) {
+		return ErrChallenge
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) {
+		return ErrOrigin
+	}
+	return nil
+}
+
+type authData struct {
+	raw       []byte
+	flags     byte
+	signCount uint32
+	aaguid    []byte
+	credID    []byte
+	credKey   []byte
+}
+
+// parseAuthData разбирает данные аутентификатора и проверяет RP ID и флаги.
+func (rp *RelyingParty) parseAuthData(raw []byte, requireUV bool) (*authData, error) {
+	if len(raw) < 37 {
+		return nil, ErrAuthData
+	}
+	ad := &authData{raw: raw, flags: raw[32], signCount: binary.BigEndian.Uint32(raw[33:37])}
+	rpHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(raw[:32], rpHash[:]) {
+		return nil, ErrRPID
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return nil, ErrUserPresence
+	}
+	if requireUV && ad.flags&flagUserVerified == 0 {
+		return nil, ErrUserVerification
+	}
+	if ad.flags&flagAttested != 0 {
+		rest := raw[37:]
+		if len(rest) < 18 {
+			return nil, errCredentialDataShort
+		}
+		ad.aaguid = rest[:16]
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < n {
+			return nil, errCredentialDataShort
+		}
+		ad.credID = rest[:n]
+		_, tail, err := decodeCBOR(rest[n:])
+		if err != nil {
+			return nil, err
+		}
+		ad.credKey = rest[n : len(rest)-len(tail)]
+		if len(tail) > 0 && ad.flags&flagExtensions == 0 {
+			return nil, ErrAuthData
+		}
+	}
+	return ad, nil
+}
+
+// VerifyRegistration проверяет ответ navigator.credentials.create и
+// возвращает ключ для сохранения. Принимаются аттестации none и packed;
+// подпись packed проверяется, доверие к сертификату производителя нет.
+func (rp *RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte, requireUV bool) (*Credential, error) {
+	if err := rp.checkClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+	v, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(map[any]any)
+	if !ok {
+		return nil, ErrAttestation
+	}
+	format, _ := obj["fmt"].(string)
+	rawAuth, _ := obj["authData"].([]byte)
+	stmt, _ := obj["attStmt"].(map[any]any)
+	ad, err := rp.parseAuthData(rawAuth, requireUV)
+	if err != nil {
+		return nil, err
+	}
+	if ad.flags&flagAttested == 0 || len(ad.credID) == 0 {
+		return nil, ErrAuthData
+	}
+	key, err := parseCOSEKey(ad.credKey)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "none":
+		if len(stmt) != 0 {
+			return nil, ErrAttestation
+		}
+	case "packed":
+		if err := verifyPacked(stmt, key, ad.raw, clientDataJSON); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedAttest
+	}
+	return &Credential{
+		ID:             append([]byte(nil), ad.credID...),
+		PublicKey:      append([]byte(nil), ad.credKey...),
+		Alg:            key.alg,
+		SignCount:      ad.signCount,
+		AAGUID:         hex.EncodeToString(ad.aaguid),
+		BackupEligible: ad.flags&flagBackupElig != 0,
+	}, nil
+}
+
+// verifyPacked проверяет подпись аттестации packed: сертификатом из x5c
+// или, при самоаттестации, ключом самих учётных данных.
+func verifyPacked(stmt map[any]any, credKey *publicKey, authData, clientDataJSON []byte) error {
+	alg, _ := stmt["alg"].(int64)
+	sig, _ := stmt["sig"].([]byte)
+	cdHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), cdHash[:]...)
+	if x5c, ok := stmt["x5c"].([]any); ok && len(x5c) > 0 {
+		der, _ := x5c[0].([]byte)
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return ErrAttestation
+		}
+		var sigAlg x509.SignatureAlgorithm
+		switch alg {
+		case AlgES256:
+			sigAlg = x509.ECDSAWithSHA256
+		case AlgRS256:
+			sigAlg = x509.SHA256WithRSA
+		case AlgEdDSA:
+			sigAlg = x509.PureEd25519
+		default:
+			return ErrUnsupportedAlg
+		}
+		if cert.CheckSignature(sigAlg, signed, sig) != nil {
+			return ErrAttestation
+		}
+		return nil
+	}
+	if alg != credKey.alg || !credKey.verify(signed, sig) {
+		return ErrAttestation
+	}
+	return nil
+}
+
+// Assertion — ответ navigator.credentials.get.
+type Assertion struct {
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// VerifyAssertion проверяет подпись ключом cred и возвращает новое
+// значение счётчика подписей. Счётчик, который не вырос, означает
+// возможную копию ключа; нулевые счётчики (синхронизируемые passkeys)
+// не сравниваются.
+func (rp *RelyingParty) VerifyAssertion(cred Credential, challenge []byte, a Assertion, requireUV bool) (uint32, error) {
+	if err := rp.checkClientData(a.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	ad, err := rp.parseAuthData(a.AuthenticatorData, requireUV)
+	if err != nil {
+		return 0, err
+	}
+	key, err := parseCOSEKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	cdHash := sha256.Sum256(a.ClientDataJSON)
+	signed := append(append([]byte(nil), a.AuthenticatorData...), cdHash[:]...)
+	if !key.verify(signed, a.Signature) {
+		return 0, ErrSignature
+	}
+	if (ad.signCount != 0 || cred.SignCount != 0) && ad.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+	return ad.signCount, nil
+}
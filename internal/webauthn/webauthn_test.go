@@ -0,0 +1,93 @@
+package webauthn_test
+
+import (
+	"errors"
+	"testing"
+
+	"ptop/internal/webauthn"
+	"ptop/internal/webauthn/webauthntest"
+)
+
+func decode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := webauthn.Encoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return b
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := &webauthn.RelyingParty{ID: "example.com", Name: "ptop", Origins: []string{"https://example.com"}}
+	auth := webauthntest.New("example.com", "https://example.com")
+
+	challenge, _ := webauthn.NewChallenge()
+	opts := rp.CreationOptions(challenge, webauthn.User{ID: []byte("client1"), Name: "alice", DisplayName: "alice"}, nil)
+	created, err := auth.Create(opts)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	clientData := decode(t, created.Response.ClientDataJSON)
+	attestation := decode(t, created.Response.AttestationObject)
+
+	other, _ := webauthn.NewChallenge()
+	if _, err := rp.VerifyRegistration(other, clientData, attestation, false); !errors.Is(err, webauthn.ErrChallenge) {
+		t.Fatalf("expected challenge error, got %v", err)
+	}
+	foreign := &webauthn.RelyingParty{ID: "example.com", Origins: []string{"https://evil.example"}}
+	if _, err := foreign.VerifyRegistration(challenge, clientData, attestation, false); !errors.Is(err, webauthn.ErrOrigin) {
+		t.Fatalf("expected origin error, got %v", err)
+	}
+	wrongRP := &webauthn.RelyingParty{ID: "other.com", Origins: []string{"https://example.com"}}
+	if _, err := wrongRP.VerifyRegistration(challenge, clientData, attestation, false); !errors.Is(err, webauthn.ErrRPID) {
+		t.Fatalf("expected rp id error, got %v", err)
+	}
+	cred, err := rp.VerifyRegistration(challenge, clientData, attestation, true)
+	if err != nil {
+		t.Fatalf("verify registration: %v", err)
+	}
+	if cred.Alg != webauthn.AlgES256 || webauthn.Encoding.EncodeToString(cred.ID) != created.ID {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+
+	challenge, _ = webauthn.NewChallenge()
+	got, err := auth.Get(rp.RequestOptions(challenge, [][]byte{cred.ID}, "required"))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	a := webauthn.Assertion{
+		ClientDataJSON:    decode(t, got.Response.ClientDataJSON),
+		AuthenticatorData: decode(t, got.Response.AuthenticatorData),
+		Signature:         decode(t, got.Response.Signature),
+	}
+	count, err := rp.VerifyAssertion(*cred, challenge, a, true)
+	if err != nil || count != 1 {
+		t.Fatalf("verify assertion: count %d, err %v", count, err)
+	}
+	// Повтор того же ответа не проходит по счётчику
+	cred.SignCount = count
+	if _, err := rp.VerifyAssertion(*cred, challenge, a, true); !errors.Is(err, webauthn.ErrSignCount) {
+		t.Fatalf("expected sign count error, got %v", err)
+	}
+	// Подпись не подходит к изменённым данным
+	a.Signature[len(a.Signature)-1] ^= 0xff
+	cred.SignCount = 0
+	if _, err := rp.VerifyAssertion(*cred, challenge, a, true); !errors.Is(err, webauthn.ErrSignature) {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+
+	auth.UserVerified = false
+	challenge, _ = webauthn.NewChallenge()
+	got, _ = auth.Get(rp.RequestOptions(challenge, nil, "required"))
+	a = webauthn.Assertion{
+		ClientDataJSON:    decode(t, got.Response.ClientDataJSON),
+		AuthenticatorData: decode(t, got.Response.AuthenticatorData),
+		Signature:         decode(t, got.Response.Signature),
+	}
+	if _, err := rp.VerifyAssertion(*cred, challenge, a, true); !errors.Is(err, webauthn.ErrUserVerification) {
+		t.Fatalf("expected user verification error, got %v", err)
+	}
+	if _, err := rp.VerifyAssertion(*cred, challenge, a, false); err != nil {
+		t.Fatalf("verify without uv: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+// Package webauthntest — программный аутентификатор WebAuthn для тестов:
+// создаёт ключи ES256 и подписывает вызовы так же, как браузер с passkey.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+
+	"ptop/internal/webauthn"
+)
+
+// Authenticator хранит созданные ключи. UserVerified управляет флагом UV в
+// ответах; без Counter счётчик подписей не растёт, как у копии ключа.
+type Authenticator struct {
+	RPID         string
+	Origin       string
+	UserVerified bool
+	Counter      bool
+	keys         map[string]*credential
+}
+
+type credential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+func New(rpID, origin string) *Authenticator {
+	return &Authenticator{RPID: rpID, Origin: origin, UserVerified: true, Counter: true, keys: make(map[string]*credential)}
+}
+
+// AuthenticatorResponse — поле response ответа браузера, бинарные
+// значения в base64url.
+type AuthenticatorResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject,omitempty"`
+	AuthenticatorData string `json:"authenticatorData,omitempty"`
+	Signature         string `json:"signature,omitempty"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// PublicKeyCredential — ответ navigator.credentials.create/get.
+type PublicKeyCredential struct {
+	ID       string                `json:"id"`
+	RawID    string                `json:"rawId"`
+	Type     string                `json:"type"`
+	Response AuthenticatorResponse `json:"response"`
+}
+
+func (a *Authenticator) clientData(typ, challenge string) []byte {
+	b, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": a.Origin})
+	return b
+}
+
+func (a *Authenticator) authData(flags byte, count uint32, attested []byte) []byte {
+	h := sha256.Sum256([]byte(a.RPID))
+	out := append(h[:], flags)
+	out = binary.BigEndian.AppendUint32(out, count)
+	return append(out, attested...)
+}
+
+func (a *Authenticator) flags() byte {
+	f := byte(0x01)
+	if a.UserVerified {
+		f |= 0x04
+	}
+	return f
+}
+
+// Create создаёт ключ по параметрам регистрации и возвращает ответ с
+// аттестацией none.
+func (a *Authenticator) Create(opts webauthn.CreationOptions) (PublicKeyCredential, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return PublicKeyCredential{}, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return PublicKeyCredential{}, err
+	}
+	userHandle, err := webauthn.Encoding.DecodeString(opts.User.ID)
+	if err != nil {
+		return PublicKeyCredential{}, err
+	}
+	cred := &credential{id: id, key: key, userHandle: userHandle}
+	a.keys[string(id)] = cred
+
+	cose := encodeCBOR(map[any]any{
+		int64(1): int64(2), int64(3): int64(-7), int64(-1): int64(1),
+		int64(-2): key.X.FillBytes(make([]byte, 32)), int64(-3): key.Y.FillBytes(make([]byte, 32)),
+	})
+	attested := make([]byte, 16) // нулевой AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(id)))
+	attested = append(append(attested, id...), cose...)
+	obj := encodeCBOR(map[any]any{
+		"fmt":      "none",
+		"attStmt":  map[any]any{},
+		"authData": a.authData(a.flags()|0x40, 0, attested),
+	})
+	return PublicKeyCredential{
+		ID:    webauthn.Encoding.EncodeToString(id),
+		RawID: webauthn.Encoding.EncodeToString(id),
+		Type:  "public-key",
+		Response: AuthenticatorResponse{
+			ClientDataJSON:    webauthn.Encoding.EncodeToString(a.clientData("webauthn.create", opts.Challenge)),
+			AttestationObject: webauthn.Encoding.EncodeToString(obj),
+		},
+	}, nil
+}
+
+// Get подписывает вызов первым ключом из allowCredentials или, если список
+// пуст, любым ключом аутентификатора.
+func (a *Authenticator) Get(opts webauthn.RequestOptions) (PublicKeyCredential, error) {
+	var cred *credential
+	for _, d := range opts.AllowCredentials {
+		id, err := webauthn.Encoding.DecodeString(d.ID)
+		if err == nil && a.keys[string(id)] != nil {
+			cred = a.keys[string(id)]
+			break
+		}
+	}
+	if cred == nil && len(opts.AllowCredentials) == 0 {
+		for _, c := range a.keys {
+			cred = c
+			break
+		}
+	}
+	if cred == nil {
+		return PublicKeyCredential{}, webauthn.ErrSignature
+	}
+	if a.Counter {
+		cred.signCount++
+	}
+	cd := a.clientData("webauthn.get", opts.Challenge)
+	ad := a.authData(a.flags(), cred.signCount, nil)
+	h := sha256.Sum256(cd)
+	digest := sha256.Sum256(append(append([]byte(nil), ad...), h[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return PublicKeyCredential{}, err
+	}
+	return PublicKeyCredential{
+		ID:    webauthn.Encoding.EncodeToString(cred.id),
+		RawID: webauthn.Encoding.EncodeToString(cred.id),
+		Type:  "public-key",
+		Response: AuthenticatorResponse{
+			ClientDataJSON:    webauthn.Encoding.EncodeToString(cd),
+			AuthenticatorData: webauthn.Encoding.EncodeToString(ad),
+			Signature:         webauthn.Encoding.EncodeToString(sig),
+			UserHandle:        webauthn.Encoding.EncodeToString(cred.userHandle),
+		},
+	}, nil
+}
+
+// encodeCBOR кодирует значения, которые нужны аутентификатору: целые,
+// строки, байты и словари с упорядоченными ключами.
+func encodeCBOR(v any) []byte {
+	switch x := v.(type) {
+	case int64:
+		if x >= 0 {
+			return cborHead(0, uint64(x))
+		}
+		return cborHead(1, uint64(-1-x))
+	case []byte:
+		return append(cborHead(2, uint64(len(x))), x...)
+	case string:
+		return append(cborHead(3, uint64(len(x))), x...)
+	case map[any]any:
+		keys := make([][]byte, 0, len(x))
+		enc := make(map[string][]byte, len(x))
+		for k, val := range x {
+			kb := encodeCBOR(k)
+			keys = append(keys, kb)
+			enc[string(kb)] = encodeCBOR(val)
+		}
+		// Каноническая сортировка CTAP2: короче раньше, затем побайтно
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return string(keys[i]) < string(keys[j])
+		})
+		out := cborHead(5, uint64(len(x)))
+		for _, k := range keys {
+			out = append(append(out, k...), enc[string(k)]...)
+		}
+		return out
+	}
+	panic("webauthntest: unsupported cbor value")
+}
+
+func cborHead(major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return []byte{m | byte(n)}
+	case n <= 0xff:
+		return []byte{m | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{m | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{m | 26}, uint32(n))
+	}
+	return binary.BigEndian.AppendUint64([]byte{m | 27}, n)
+}
@@ -1,9 +1,12 @@
 package xmrwatcher
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/omani/go-monero-rpc-client/wallet"
@@ -11,9 +14,13 @@ import (
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 
+	"ptop/internal/deposits"
 	"ptop/internal/models"
 )
 
+// chainName — значение assets.chain для активов Monero.
+const chainName = "xmr"
+
 // Watcher отслеживает входящие транзакции Monero и сохраняет их в базе данных.
 type Watcher struct {
 	client       wallet.Client
@@ -21,6 +28,11 @@ type Watcher struct {
 	pollInterval time.Duration
 	debug        bool
 	debugCh      chan debugDeposit
+	debugOnce    sync.Once
+
+	mu      sync.Mutex
+	height  uint64
+	blockAt time.Time
 }
 
 type debugDeposit struct {
@@ -45,21 +57,55 @@ func New(db *gorm.DB, rpcURL string, interval time.Duration, debug bool) (*Watch
 	return w, nil
 }
 
-// Start запускает периодический опрос get_transfers.
+// Start запускает периодический опрос get_transfers в фоне без перезапусков
+// при ошибках.
 func (w *Watcher) Start() {
 	if w.debug {
-		go w.debugLoop()
+		w.startDebug()
 		return
 	}
-	go w.check()
-	ticker := time.NewTicker(w.pollInterval)
 	go func() {
-		for range ticker.C {
-			w.check()
+		if err := w.Run(context.Background()); err != nil {
+			log.Printf("xmr watcher: %v", err)
 		}
 	}()
 }
 
+// Run периодически опрашивает кошелёк, пока запрос не завершится ошибкой
+// или не будет отменён ctx.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.debug {
+		w.startDebug()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := w.check(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Progress возвращает высоту, до которой синхронизирован кошелёк, и время её
+// последнего изменения. Кошелёк не сообщает высоту сети, поэтому она
+// совпадает с обработанной.
+func (w *Watcher) Progress() (height, tip uint64, blockAt time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.height, w.height, w.blockAt
+}
+
+func (w *Watcher) startDebug() {
+	w.debugOnce.Do(func() { go w.debugLoop() })
+}
+
 func (w *Watcher) debugLoop() {
 	for dep := range w.debugCh {
 		w.createDebugDeposit(dep.walletID, dep.amount)
@@ -79,115 +125,175 @@ func (w *Watcher) createDebugDeposit(walletID string, amount decimal.Decimal) {
 		log.Printf("ошибка поиска кошелька: %v", err)
 		return
 	}
-	data, _ := json.Marshal(map[string]any{"debug": true})
-	dep := models.TransactionIn{
-		ClientID: wal.ClientID,
-		WalletID: wal.ID,
-		AssetID:  wal.AssetID,
-		Amount:   amount,
-		Status:   models.TransactionInStatusConfirmed,
-		Data:     datatypes.JSON(data),
-	}
-	if err := w.db.Create(&dep).Error; err != nil {
+	dep := deposits.Deposit{Chain: chainName, Wallet: wal, Amount: amount, Data: map[string]any{"debug": true}}
+	if _, err := deposits.Record(w.db, dep, 0); err != nil {
 		log.Printf("не удалось сохранить депозит: %v", err)
-		return
-	}
-	if err := w.db.Model(&models.Balance{}).
-		Where("client_id = ? AND asset_id = ?", wal.ClientID, wal.AssetID).
-		Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-		log.Printf("не удалось обновить баланс: %v", err)
 	}
 }
 
-func (w *Watcher) check() {
-	// Получаем все кошельки XMR.
-	var wallets []models.Wallet
-	if err := w.db.Joins("JOIN assets ON wallets.asset_id = assets.id").Where("assets.name LIKE ?", "XMR%").Find(&wallets).Error; err != nil {
-		log.Printf("ошибка базы данных: %v", err)
-		return
+// check загружает входящие переводы подадресов клиентов, включая
+// неподтверждённые переводы из пула, и зачисляет набравшие порог
+// подтверждений.
+func (w *Watcher) check() error {
+	h, err := w.client.GetHeight()
+	if err != nil {
+		return fmt.Errorf("get_height: %w", err)
 	}
-	if len(wallets) == 0 {
-		return
+	// get_height возвращает число блоков, вершина сети на один блок ниже.
+	var tip uint64
+	if h.Height > 0 {
+		tip = h.Height - 1
 	}
-	subMap := make(map[uint64]models.Wallet, len(wallets))
-	indices := make([]uint64, 0, len(wallets))
-	for _, wal := range wallets {
-		subMap[uint64(wal.DerivationIndex)] = wal
-		indices = append(indices, uint64(wal.DerivationIndex))
+	w.mu.Lock()
+	if tip != w.height {
+		w.height = tip
+		w.blockAt = time.Now()
 	}
+	w.mu.Unlock()
 
-	res, err := w.client.GetTransfers(&wallet.RequestGetTransfers{
-		In:             true,
-		Pending:        true,
-		AccountIndex:   0,
-		SubaddrIndices: indices,
-	})
+	accounts, err := w.loadSubaddresses()
 	if err != nil {
-		log.Printf("get_transfers error: %v", err)
-		return
+		log.Printf("ошибка базы данных: %v", err)
+		return nil
+	}
+	for account, subs := range accounts {
+		indices := make([]uint64, 0, len(subs))
+		for idx := range subs {
+			indices = append(indices, uint64(idx))
+		}
+		res, err := w.client.GetTransfers(&wallet.RequestGetTransfers{
+			In:             true,
+			Pool:           true,
+			Pending:        true,
+			AccountIndex:   uint64(account),
+			SubaddrIndices: indices,
+		})
+		if err != nil {
+			return fmt.Errorf("get_transfers: %w", err)
+		}
+		for _, list := range [][]*wallet.Transfer{res.Pool, res.Pending, res.In} {
+			for _, tr := range list {
+				w.handleTransfer(tr, subs, tip)
+			}
+		}
 	}
+	if err := deposits.ConfirmPending(w.db, chainName, tip); err != nil {
+		log.Printf("не удалось подтвердить депозиты: %v", err)
+	}
+	return nil
+}
 
-	for _, tr := range res.Pending {
-		w.handleTransfer(tr, subMap, models.TransactionInStatusPending)
+// loadSubaddresses возвращает кошельки Monero по номеру аккаунта и индексу
+// подадреса из xmr_subaddresses.
+func (w *Watcher) loadSubaddresses() (map[uint32]map[uint32]models.Wallet, error) {
+	var subs []models.XMRSubaddress
+	if err := w.db.Preload("Wallet").
+		Joins("JOIN wallets ON wallets.id = xmr_subaddresses.wallet_id").
+		Where("wallets.network = ?", chainName).
+		Find(&subs).Error; err != nil {
+		return nil, err
 	}
-	for _, tr := range res.In {
-		w.handleTransfer(tr, subMap, models.TransactionInStatusConfirmed)
+	accounts := make(map[uint32]map[uint32]models.Wallet)
+	for _, s := range subs {
+		if accounts[s.AccountIndex] == nil {
+			accounts[s.AccountIndex] = make(map[uint32]models.Wallet)
+		}
+		accounts[s.AccountIndex][s.AddressIndex] = s.Wallet
 	}
+	return accounts, nil
 }
 
-func (w *Watcher) handleTransfer(tr *wallet.Transfer, subMap map[uint64]models.Wallet, status models.TransactionInStatus) {
-	wal, ok := subMap[tr.SubaddrIndex.Minor]
+// handleTransfer сохраняет входящий перевод на подадрес. Перевод из пула
+// (Height = 0) сохраняется как processing; когда он попадает в блок,
+// записывается высота блока, а зачисление выполняет ConfirmPending по порогу
+// подтверждений сети. Категория pending содержит исходящие переводы
+// кошелька, они не являются депозитами и пропускаются.
+func (w *Watcher) handleTransfer(tr *wallet.Transfer, subs map[uint32]models.Wallet, tip uint64) {
+	if tr.Type != "in" && tr.Type != "pool" {
+		return
+	}
+	wal, ok := subs[uint32(tr.SubaddrIndex.Minor)]
 	if !ok {
 		return
 	}
-	amount := decimal.NewFromInt(int64(tr.Amount)).Div(decimal.NewFromInt(1e12))
+	var confirmations uint64
+	if tr.Height > 0 {
+		confirmations = uint64(deposits.Confirmations(tr.Height, tip))
+	}
 	var existing models.TransactionIn
-	err := w.db.Where("data ->> 'txid' = ? AND data ->> 'subaddr_index' = ?", tr.TxID, fmt.Sprintf("%d", tr.SubaddrIndex.Minor)).First(&existing).Error
+	err := w.db.Where("network = ? AND data ->> 'txid' = ? AND CAST(data ->> 'subaddr_index' AS INTEGER) = ?", chainName, tr.TxID, tr.SubaddrIndex.Minor).First(&existing).Error
 	if err == nil {
-		if existing.Status != status && status == models.TransactionInStatusConfirmed {
-			data, _ := json.Marshal(map[string]any{
-				"txid":          tr.TxID,
-				"subaddr_index": tr.SubaddrIndex.Minor,
-				"confirmations": tr.Confirmations,
-			})
-			if err := w.db.Model(&existing).Updates(map[string]any{"status": status, "data": datatypes.JSON(data)}).Error; err != nil {
-				log.Printf("не удалось обновить депозит: %v", err)
-				return
-			}
-			if err := w.db.Model(&models.Balance{}).
-				Where("client_id = ? AND asset_id = ?", wal.ClientID, wal.AssetID).
-				Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-				log.Printf("не удалось обновить баланс: %v", err)
-			}
-		}
+		w.updateTransfer(existing, tr, confirmations)
 		return
 	}
-	if err != nil && err != gorm.ErrRecordNotFound {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
 		log.Printf("ошибка проверки существующей транзакции: %v", err)
 		return
 	}
-	data, _ := json.Marshal(map[string]any{
-		"txid":          tr.TxID,
-		"subaddr_index": tr.SubaddrIndex.Minor,
-		"confirmations": tr.Confirmations,
-	})
-	dep := models.TransactionIn{
-		ClientID: wal.ClientID,
-		WalletID: wal.ID,
-		AssetID:  wal.AssetID,
-		Amount:   amount,
-		Status:   status,
-		Data:     datatypes.JSON(data),
-	}
-	if err := w.db.Create(&dep).Error; err != nil {
-		log.Printf("не удалось сохранить депозит: %v", err)
+	network, err := deposits.Network(w.db, wal.AssetID, chainName)
+	if err != nil {
+		log.Printf("ошибка базы данных: %v", err)
 		return
 	}
-	if status == models.TransactionInStatusConfirmed {
-		if err := w.db.Model(&models.Balance{}).
-			Where("client_id = ? AND asset_id = ?", wal.ClientID, wal.AssetID).
-			Update("amount", gorm.Expr("amount + ?", amount)).Error; err != nil {
-			log.Printf("не удалось обновить баланс: %v", err)
+	dep := deposits.Deposit{
+		Chain:       chainName,
+		Wallet:      wal,
+		Amount:      decimal.New(int64(tr.Amount), -deposits.Decimals(network, 12)),
+		BlockHeight: tr.Height,
+		Data: map[string]any{
+			"txid":          tr.TxID,
+			"account_index": tr.SubaddrIndex.Major,
+			"subaddr_index": tr.SubaddrIndex.Minor,
+			"confirmations": confirmations,
+		},
+	}
+	switch {
+	case tr.DoubleSpendSeen:
+		dep.Reject = "double_spend"
+	case tr.UnlockTime > 0:
+		// Выходы с нестандартной блокировкой нельзя потратить после
+		// обычного порога подтверждений.
+		dep.Reject = "unlock_time"
+		dep.Data["unlock_time"] = tr.UnlockTime
+	}
+	if _, err := deposits.Record(w.db, dep, tip); err != nil {
+		log.Printf("не удалось сохранить депозит: %v", err)
+	}
+}
+
+// updateTransfer обновляет сохранённый депозит: высоту блока после
+// попадания перевода в блок, число подтверждений и отклоняет депозит из
+// пула при обнаружении двойной траты.
+func (w *Watcher) updateTransfer(existing models.TransactionIn, tr *wallet.Transfer, confirmations uint64) {
+	data := map[string]any{}
+	if len(existing.Data) > 0 {
+		if err := json.Unmarshal(existing.Data, &data); err != nil {
+			log.Printf("некорректные данные депозита %s: %v", existing.ID, err)
+			return
 		}
 	}
+	updates := map[string]any{}
+	if existing.BlockHeight == 0 && tr.Height > 0 {
+		updates["block_height"] = tr.Height
+		// Записи, созданные до учёта подтверждений, хранили пул как pending
+		if existing.Status == models.TransactionInStatusPending {
+			updates["status"] = models.TransactionInStatusProcessing
+		}
+	}
+	if tr.DoubleSpendSeen && existing.Status == models.TransactionInStatusProcessing {
+		updates["status"] = models.TransactionInStatusFailed
+		data["reason"] = "double_spend"
+	}
+	prev, ok := data["confirmations"].(float64)
+	if !ok || uint64(prev) != confirmations || updates["status"] == models.TransactionInStatusFailed {
+		data["confirmations"] = confirmations
+		raw, _ := json.Marshal(data)
+		updates["data"] = datatypes.JSON(raw)
+	}
+	if len(updates) == 0 {
+		return
+	}
+	if err := w.db.Model(&models.TransactionIn{}).Where("id = ? AND status = ?", existing.ID, existing.Status).Updates(updates).Error; err != nil {
+		log.Printf("не удалось обновить депозит: %v", err)
+	}
 }
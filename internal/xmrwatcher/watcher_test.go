@@ -1,6 +1,11 @@
 package xmrwatcher
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,7 +21,7 @@ func TestWatcherDebugDeposit(t *testing.T) {
 	if err != nil {
 		t.Fatalf("db open: %v", err)
 	}
-	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	client := models.Client{Username: "u"}
@@ -49,3 +54,112 @@ func TestWatcherDebugDeposit(t *testing.T) {
 		t.Fatalf("balance amount %s", bal.Amount)
 	}
 }
+
+// fakeWallet имитирует monero-wallet-rpc: высота задаётся height, набор
+// переводов — transfers.
+func fakeWallet(t *testing.T, height *atomic.Uint64, transfers *atomic.Value) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var result string
+		switch req.Method {
+		case "get_height":
+			result = fmt.Sprintf(`{"height":%d}`, height.Load())
+		case "get_transfers":
+			result = transfers.Load().(string)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, result)
+	}))
+}
+
+func TestWatcherPoolToConfirmed(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:xmr_pool?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("db open: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Client{}, &models.Asset{}, &models.AssetNetwork{}, &models.Wallet{}, &models.XMRSubaddress{}, &models.TransactionIn{}, &models.Balance{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	client := models.Client{Username: "u"}
+	db.Create(&client)
+	asset := models.Asset{Name: "XMR", Type: models.AssetTypeCrypto, Chain: chainName}
+	db.Create(&asset)
+	// индекс деривации кошелька не участвует в сопоставлении
+	wallet := models.Wallet{ClientID: client.ID, AssetID: asset.ID, Network: chainName, Value: "8sub", DerivationIndex: 99}
+	db.Create(&wallet)
+	db.Create(&models.XMRSubaddress{WalletID: wallet.ID, AddressIndex: 3, Address: wallet.Value})
+	bal := models.Balance{ClientID: client.ID, AssetID: asset.ID, Amount: decimal.Zero, AmountEscrow: decimal.Zero}
+	db.Create(&bal)
+
+	var height atomic.Uint64
+	var transfers atomic.Value
+	height.Store(1001)
+	pending := `{"txid":"out1","type":"pending","amount":5,"height":0,"subaddr_index":{"major":0,"minor":3}}`
+	locked := `{"txid":"lock1","type":"pool","amount":7,"height":0,"unlock_time":2000,"subaddr_index":{"major":0,"minor":3}}`
+	transfers.Store(`{"pool":[{"txid":"tx1","type":"pool","amount":1500000000000,"height":0,"subaddr_index":{"major":0,"minor":3}},` + locked + `],"pending":[` + pending + `]}`)
+	srv := fakeWallet(t, &height, &transfers)
+	defer srv.Close()
+
+	w, err := New(db, srv.URL+"/json_rpc", time.Second, false)
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	deposit := func() (models.TransactionIn, map[string]any) {
+		t.Helper()
+		var tx models.TransactionIn
+		if err := db.Where("data ->> 'txid' = ?", "tx1").First(&tx).Error; err != nil {
+			t.Fatalf("tx: %v", err)
+		}
+		var data map[string]any
+		json.Unmarshal(tx.Data, &data)
+		return tx, data
+	}
+
+	if err := w.check(); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	tx, data := deposit()
+	if tx.Status != models.TransactionInStatusProcessing || tx.WalletID != wallet.ID || data["subaddr_index"] != float64(3) || data["confirmations"] != float64(0) {
+		t.Fatalf("unexpected pool deposit %+v %v", tx, data)
+	}
+	var count int64
+	db.Model(&models.TransactionIn{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("expected pool and locked deposits, got %d", count)
+	}
+	var lockedTx models.TransactionIn
+	db.Where("data ->> 'txid' = ?", "lock1").First(&lockedTx)
+	if lockedTx.Status != models.TransactionInStatusFailed {
+		t.Fatalf("locked transfer status %s", lockedTx.Status)
+	}
+
+	// перевод попал в блок 1000, но порог по умолчанию — 10 подтверждений
+	transfers.Store(`{"in":[{"txid":"tx1","type":"in","amount":1500000000000,"height":1000,"subaddr_index":{"major":0,"minor":3}}]}`)
+	height.Store(1006)
+	if err := w.check(); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	tx, data = deposit()
+	if tx.Status != models.TransactionInStatusProcessing || tx.BlockHeight != 1000 || data["confirmations"] != float64(6) {
+		t.Fatalf("unexpected mined deposit %+v %v", tx, data)
+	}
+
+	height.Store(1010)
+	if err := w.check(); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	tx, _ = deposit()
+	if tx.Status != models.TransactionInStatusConfirmed {
+		t.Fatalf("status %s", tx.Status)
+	}
+	db.First(&bal, "id = ?", bal.ID)
+	if !bal.Amount.Equal(decimal.RequireFromString("1.5")) {
+		t.Fatalf("balance %s", bal.Amount)
+	}
+}